@@ -0,0 +1,474 @@
+package alerts
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Updater/logging"
+
+	"github.com/lib/pq"
+)
+
+var logger = logging.For("component", "alerts")
+
+// deliveryTimeout bounds how long one evaluation waits for notifiers.
+const deliveryTimeout = 30 * time.Second
+
+// candidateLimit caps how many diff rows are read per rule and evaluation.
+const candidateLimit = 200
+
+// pairState tracks one (rule, pair) combination across evaluations.
+type pairState struct {
+	firstSeen time.Time // zero while the opportunity is absent
+	lastSeen  time.Time
+	lastFired time.Time
+}
+
+// Engine evaluates alert rules against the diff tables and sends notifications.
+type Engine struct {
+	db *sql.DB
+
+	mu        sync.Mutex
+	rules     []Rule
+	notifiers map[string]Notifier
+	state     map[string]*pairState
+
+	// One evaluation per market at a time; a run that is still busy makes the next one skip
+	marketLocks map[string]*sync.Mutex
+}
+
+// NewEngine creates an engine without rules. Call Reload to load them from the database.
+func NewEngine(db *sql.DB) *Engine {
+	return &Engine{
+		db:        db,
+		notifiers: make(map[string]Notifier),
+		state:     make(map[string]*pairState),
+		marketLocks: map[string]*sync.Mutex{
+			MarketSpot:    {},
+			MarketFutures: {},
+		},
+	}
+}
+
+// Reload replaces the engine's rules and notifiers with the enabled ones stored
+// in the database. Tracking state of rules that no longer exist is dropped.
+func (e *Engine) Reload(ctx context.Context) error {
+	channels, err := ListChannels(ctx, e.db)
+	if err != nil {
+		return err
+	}
+	notifiers := make(map[string]Notifier, len(channels))
+	for _, ch := range channels {
+		if !ch.Enabled {
+			continue
+		}
+		n, err := NewNotifier(ch)
+		if err != nil {
+			logger.Warn("skipping channel", "channel", ch.Name, "error", err)
+			continue
+		}
+		notifiers[ch.Name] = n
+	}
+
+	all, err := ListRules(ctx, e.db)
+	if err != nil {
+		return err
+	}
+	var rules []Rule
+	for _, r := range all {
+		if r.Enabled {
+			rules = append(rules, r)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = rules
+	e.notifiers = notifiers
+	for key := range e.state {
+		if !hasRulePrefix(rules, key) {
+			delete(e.state, key)
+		}
+	}
+	return nil
+}
+
+func hasRulePrefix(rules []Rule, key string) bool {
+	for i := range rules {
+		if strings.HasPrefix(key, statePrefix(&rules[i])) {
+			return true
+		}
+	}
+	return false
+}
+
+// Counts returns the number of active rules and channels.
+func (e *Engine) Counts() (rules, channels int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.rules), len(e.notifiers)
+}
+
+// Evaluate checks every rule of the market against the current diffs. It is
+// meant to run after the corresponding diff job has finished.
+func (e *Engine) Evaluate(ctx context.Context, market string) {
+	lock, ok := e.marketLocks[market]
+	if !ok {
+		return
+	}
+	if !lock.TryLock() {
+		logger.Warn("previous evaluation still running, skipping", "market", market)
+		return
+	}
+	defer lock.Unlock()
+
+	e.mu.Lock()
+	var rules []Rule
+	for _, r := range e.rules {
+		if r.Market == market {
+			rules = append(rules, r)
+		}
+	}
+	e.mu.Unlock()
+
+	now := time.Now()
+	for i := range rules {
+		rule := &rules[i]
+
+		candidates, err := e.candidates(ctx, rule)
+		if err != nil {
+			logger.Error("error evaluating rule", "rule", rule.Name, "error", err)
+			continue
+		}
+
+		due := e.track(rule, candidates, now)
+		if len(due) == 0 {
+			continue
+		}
+
+		alert := Alert{
+			Rule:          rule.Name,
+			Market:        rule.Market,
+			FiredAt:       now.UTC(),
+			Opportunities: due,
+		}
+		if e.deliver(ctx, rule, alert) {
+			e.markFired(rule, due, now)
+		}
+	}
+}
+
+// statePrefix uses the rule id so renaming a rule keeps its state, and the
+// market so that changing it starts over.
+func statePrefix(rule *Rule) string {
+	return strconv.Itoa(rule.ID) + "|" + rule.Market + "|"
+}
+
+func stateKey(rule *Rule, pairKey string) string {
+	return statePrefix(rule) + pairKey
+}
+
+// track updates lifetimes for the current candidates and returns those that
+// passed the minimum lifetime and are out of cooldown.
+func (e *Engine) track(rule *Rule, candidates []Opportunity, now time.Time) []Opportunity {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var due []Opportunity
+	for _, o := range candidates {
+		key := stateKey(rule, o.PairKey)
+		st, ok := e.state[key]
+		if !ok {
+			st = &pairState{}
+			e.state[key] = st
+		}
+		if st.firstSeen.IsZero() {
+			st.firstSeen = now
+		}
+		st.lastSeen = now
+
+		o.Lifetime = Duration(now.Sub(st.firstSeen))
+		if o.Lifetime < rule.MinLifetime {
+			continue
+		}
+		if !st.lastFired.IsZero() && now.Sub(st.lastFired) < rule.cooldown() {
+			continue
+		}
+		if len(due) < rule.maxResults() {
+			due = append(due, o)
+		}
+	}
+
+	// Opportunities that disappeared restart their lifetime; their cooldown is kept until it expires
+	prefix := statePrefix(rule)
+	for key, st := range e.state {
+		if !strings.HasPrefix(key, prefix) || st.lastSeen.Equal(now) {
+			continue
+		}
+		st.firstSeen = time.Time{}
+		if st.lastFired.IsZero() || now.Sub(st.lastFired) >= rule.cooldown() {
+			delete(e.state, key)
+		}
+	}
+
+	return due
+}
+
+func (e *Engine) markFired(rule *Rule, fired []Opportunity, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, o := range fired {
+		if st, ok := e.state[stateKey(rule, o.PairKey)]; ok {
+			st.lastFired = now
+		}
+	}
+}
+
+// ChannelResult is the outcome of delivering an alert to one channel.
+type ChannelResult struct {
+	Channel string `json:"channel"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// deliver sends the alert to every channel of the rule and reports whether at
+// least one delivery succeeded. Failed alerts are retried on the next evaluation.
+func (e *Engine) deliver(ctx context.Context, rule *Rule, alert Alert) bool {
+	for _, res := range e.send(ctx, rule, alert) {
+		if res.Status == DeliverySent {
+			return true
+		}
+	}
+	return false
+}
+
+// send delivers the alert to the rule's channels and records every attempt in
+// the delivery history.
+func (e *Engine) send(ctx context.Context, rule *Rule, alert Alert) []ChannelResult {
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+
+	e.mu.Lock()
+	notifiers := make(map[string]Notifier, len(rule.Channels))
+	for _, name := range rule.Channels {
+		if n, ok := e.notifiers[name]; ok {
+			notifiers[name] = n
+		}
+	}
+	e.mu.Unlock()
+
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		logger.Error("error encoding alert", "rule", rule.Name, "error", err)
+		payload = []byte("{}")
+	}
+
+	var results []ChannelResult
+	for _, name := range rule.Channels {
+		res := ChannelResult{Channel: name, Status: DeliverySent}
+		n, ok := notifiers[name]
+		if !ok && !alert.Test {
+			// Disabled channels are skipped silently during normal evaluation
+			continue
+		}
+		if !ok {
+			res.Status, res.Error = DeliveryFailed, "channel is disabled or invalid"
+		} else if err := n.Notify(ctx, alert); err != nil {
+			logger.Warn("error delivering alert", "rule", rule.Name, "channel", name, "error", err)
+			res.Status, res.Error = DeliveryFailed, err.Error()
+		}
+		results = append(results, res)
+
+		err := RecordDelivery(context.Background(), e.db, Delivery{
+			RuleID:   rule.ID,
+			RuleName: rule.Name,
+			Channel:  name,
+			Status:   res.Status,
+			Error:    res.Error,
+			Payload:  payload,
+			Test:     alert.Test,
+		})
+		if err != nil {
+			logger.Error("error recording delivery", "rule", rule.Name, "channel", name, "error", err)
+		}
+	}
+	return results
+}
+
+// TestFire sends a test alert for a rule, even a disabled one, using the
+// current matching opportunities. Lifetime and cooldown are ignored and the
+// rule's tracking state is not touched. Disabled channels are reported as failed.
+func (e *Engine) TestFire(ctx context.Context, ruleID int) ([]ChannelResult, error) {
+	rule, err := GetRule(ctx, e.db, ruleID)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := e.candidates(ctx, &rule)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) > rule.maxResults() {
+		candidates = candidates[:rule.maxResults()]
+	}
+	if candidates == nil {
+		candidates = []Opportunity{}
+	}
+
+	alert := Alert{
+		Rule:          rule.Name,
+		Market:        rule.Market,
+		Test:          true,
+		FiredAt:       time.Now().UTC(),
+		Opportunities: candidates,
+	}
+	return e.send(ctx, &rule, alert), nil
+}
+
+// candidates reads the diff rows matching the rule's thresholds and filters.
+func (e *Engine) candidates(ctx context.Context, rule *Rule) ([]Opportunity, error) {
+	if rule.Market == MarketFutures {
+		return e.futuresCandidates(ctx, rule)
+	}
+	return e.spotCandidates(ctx, rule)
+}
+
+// filterClause appends the symbol/asset/exchange filters shared by both diff tables.
+func filterClause(rule *Rule, args []interface{}) (string, []interface{}) {
+	var where strings.Builder
+	if len(rule.Exchanges) > 0 {
+		args = append(args, pq.Array(rule.Exchanges))
+		n := strconv.Itoa(len(args))
+		where.WriteString(" AND firstPairExchange = ANY($" + n + ") AND secondPairExchange = ANY($" + n + ")")
+	}
+	if len(rule.Symbols) > 0 {
+		args = append(args, pq.Array(rule.Symbols))
+		where.WriteString(" AND symbol = ANY($" + strconv.Itoa(len(args)) + ")")
+	}
+	if len(rule.BaseAssets) > 0 {
+		args = append(args, pq.Array(rule.BaseAssets))
+		where.WriteString(" AND baseAsset = ANY($" + strconv.Itoa(len(args)) + ")")
+	}
+	return where.String(), args
+}
+
+func (e *Engine) spotCandidates(ctx context.Context, rule *Rule) ([]Opportunity, error) {
+	args := []interface{}{rule.MinSpreadPercent, rule.MinVolume}
+	filters, args := filterClause(rule, args)
+
+	query := `
+	SELECT pairKey, symbol, baseAsset, quoteAsset, firstPairExchange, secondPairExchange,
+	       firstPairPrice, secondPairPrice, firstPairVolume * firstPairPrice, secondPairVolume * secondPairPrice,
+	       differencePercentage, firstExchangeNetworks, secondExchangeNetworks
+	FROM diffs
+	WHERE differencePercentage >= $1
+	  AND differencePercentage < 100000
+	  AND firstPairVolume * firstPairPrice >= $2
+	  AND secondPairVolume * secondPairPrice >= $2
+	  AND updatedAt >= NOW() AT TIME ZONE 'UTC' - INTERVAL '2 minutes'` + filters + `
+	ORDER BY differencePercentage DESC
+	LIMIT ` + strconv.Itoa(candidateLimit)
+
+	rows, err := e.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying diffs: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Opportunity
+	for rows.Next() {
+		var o Opportunity
+		var firstNets, secondNets []byte
+		if err := rows.Scan(&o.PairKey, &o.Symbol, &o.BaseAsset, &o.QuoteAsset, &o.FirstExchange, &o.SecondExchange,
+			&o.FirstPrice, &o.SecondPrice, &o.FirstVolume, &o.SecondVolume, &o.SpreadPercent, &firstNets, &secondNets); err != nil {
+			return nil, fmt.Errorf("error scanning diffs row: %w", err)
+		}
+
+		o.CommonNetworks = commonNetworks(firstNets, secondNets)
+		if rule.RequireCommonNetwork && len(o.CommonNetworks) == 0 {
+			continue
+		}
+		result = append(result, o)
+	}
+	return result, rows.Err()
+}
+
+func (e *Engine) futuresCandidates(ctx context.Context, rule *Rule) ([]Opportunity, error) {
+	args := []interface{}{rule.MinSpreadPercent, rule.MinFundingDiffPercent, rule.MinVolume}
+	filters, args := filterClause(rule, args)
+
+	// A zero threshold means the rule does not filter on that value
+	query := `
+	SELECT pairKey, symbol, baseAsset, quoteAsset, firstPairExchange, secondPairExchange,
+	       firstPairMarkPrice, secondPairMarkPrice, firstPairVolume * firstPairMarkPrice, secondPairVolume * secondPairMarkPrice,
+	       differenceMarkPercentage, differenceFundingRatePercent
+	FROM diffsfutures
+	WHERE ($1::numeric = 0 OR differenceMarkPercentage >= $1::numeric)
+	  AND ($2::numeric = 0 OR differenceFundingRatePercent >= $2::numeric)
+	  AND firstPairVolume * firstPairMarkPrice >= $3
+	  AND secondPairVolume * secondPairMarkPrice >= $3
+	  AND updatedAt >= NOW() AT TIME ZONE 'UTC' - INTERVAL '2 minutes'` + filters + `
+	ORDER BY differenceFundingRatePercent DESC, differenceMarkPercentage DESC
+	LIMIT ` + strconv.Itoa(candidateLimit)
+
+	rows, err := e.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying diffsfutures: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Opportunity
+	for rows.Next() {
+		var o Opportunity
+		if err := rows.Scan(&o.PairKey, &o.Symbol, &o.BaseAsset, &o.QuoteAsset, &o.FirstExchange, &o.SecondExchange,
+			&o.FirstPrice, &o.SecondPrice, &o.FirstVolume, &o.SecondVolume, &o.SpreadPercent, &o.FundingDiffPercent); err != nil {
+			return nil, fmt.Errorf("error scanning diffsfutures row: %w", err)
+		}
+		result = append(result, o)
+	}
+	return result, rows.Err()
+}
+
+// exchangeNetworks mirrors the JSON written into diffs.firstExchangeNetworks/secondExchangeNetworks.
+type exchangeNetworks struct {
+	BaseAsset []struct {
+		Network        string `json:"network"`
+		DepositEnable  bool   `json:"depositEnable"`
+		WithdrawEnable bool   `json:"withdrawEnable"`
+	} `json:"baseAsset"`
+}
+
+// commonNetworks returns the networks the base asset can be withdrawn through
+// on the first (cheaper) exchange and deposited through on the second.
+func commonNetworks(first, second []byte) []string {
+	var a, b exchangeNetworks
+	if json.Unmarshal(first, &a) != nil || json.Unmarshal(second, &b) != nil {
+		return nil
+	}
+
+	deposits := make(map[string]bool)
+	for _, n := range b.BaseAsset {
+		if n.DepositEnable {
+			deposits[strings.ToUpper(n.Network)] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	var common []string
+	for _, n := range a.BaseAsset {
+		name := strings.ToUpper(n.Network)
+		if n.WithdrawEnable && deposits[name] && !seen[name] {
+			seen[name] = true
+			common = append(common, name)
+		}
+	}
+	sort.Strings(common)
+	return common
+}
@@ -0,0 +1,214 @@
+package alerts
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func opportunities(pairKeys ...string) []Opportunity {
+	var result []Opportunity
+	for _, key := range pairKeys {
+		result = append(result, Opportunity{PairKey: key})
+	}
+	return result
+}
+
+// dueKeys runs one evaluation of rule over the candidates at now and marks the
+// due opportunities as fired, like a successful delivery does.
+func dueKeys(e *Engine, rule *Rule, now time.Time, pairKeys ...string) []string {
+	due := e.track(rule, opportunities(pairKeys...), now)
+	e.markFired(rule, due, now)
+	var keys []string
+	for _, o := range due {
+		keys = append(keys, o.PairKey)
+	}
+	return keys
+}
+
+func TestTrackMinLifetime(t *testing.T) {
+	e := NewEngine(nil)
+	rule := &Rule{ID: 1, Market: MarketSpot, MinLifetime: Duration(time.Minute)}
+	start := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		after   time.Duration
+		present []string
+		want    []string
+	}{
+		{0, []string{"A", "B"}, nil},
+		{30 * time.Second, []string{"A", "B"}, nil},
+		// B disappears and starts over when it comes back
+		{45 * time.Second, []string{"A"}, nil},
+		{50 * time.Second, []string{"A", "B"}, nil},
+		{time.Minute, []string{"A", "B"}, []string{"A"}},
+		{110 * time.Second, []string{"B"}, []string{"B"}},
+	}
+	for _, step := range steps {
+		if got := dueKeys(e, rule, start.Add(step.after), step.present...); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("after %s: due %v, want %v", step.after, got, step.want)
+		}
+	}
+}
+
+func TestTrackReportsLifetime(t *testing.T) {
+	e := NewEngine(nil)
+	rule := &Rule{ID: 1, Market: MarketSpot, MinLifetime: Duration(time.Minute)}
+	start := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	e.track(rule, opportunities("A"), start)
+	due := e.track(rule, opportunities("A"), start.Add(90*time.Second))
+	if len(due) != 1 || due[0].Lifetime != Duration(90*time.Second) {
+		t.Errorf("due = %+v, want A alive for 90s", due)
+	}
+}
+
+func TestTrackCooldown(t *testing.T) {
+	e := NewEngine(nil)
+	rule := &Rule{ID: 1, Market: MarketSpot, Cooldown: Duration(5 * time.Minute)}
+	start := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		after   time.Duration
+		present []string
+		want    []string
+	}{
+		{0, []string{"A"}, []string{"A"}},
+		// The same opportunity is not reported again within the cooldown
+		{time.Minute, []string{"A", "B"}, []string{"B"}},
+		// Disappearing does not reset the cooldown
+		{2 * time.Minute, nil, nil},
+		{3 * time.Minute, []string{"A"}, nil},
+		{5 * time.Minute, []string{"A", "B"}, []string{"A"}},
+		{6 * time.Minute, []string{"A", "B"}, []string{"B"}},
+	}
+	for _, step := range steps {
+		if got := dueKeys(e, rule, start.Add(step.after), step.present...); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("after %s: due %v, want %v", step.after, got, step.want)
+		}
+	}
+}
+
+func TestTrackFailedDeliveryIsRetried(t *testing.T) {
+	e := NewEngine(nil)
+	rule := &Rule{ID: 1, Market: MarketSpot}
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	// Without markFired the opportunity stays due on the next evaluation
+	e.track(rule, opportunities("A"), now)
+	if due := e.track(rule, opportunities("A"), now.Add(10*time.Second)); len(due) != 1 {
+		t.Errorf("due = %+v, want A again after a failed delivery", due)
+	}
+}
+
+func TestTrackMaxResults(t *testing.T) {
+	e := NewEngine(nil)
+	rule := &Rule{ID: 1, Market: MarketSpot, MaxResults: 2}
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	if got := dueKeys(e, rule, now, "A", "B", "C"); !reflect.DeepEqual(got, []string{"A", "B"}) {
+		t.Errorf("due %v, want the first 2 candidates", got)
+	}
+	// C was left out, not fired, so it is reported next
+	if got := dueKeys(e, rule, now.Add(time.Second), "A", "B", "C"); !reflect.DeepEqual(got, []string{"C"}) {
+		t.Errorf("due %v, want C", got)
+	}
+}
+
+func TestTrackSeparatesRules(t *testing.T) {
+	e := NewEngine(nil)
+	spot := &Rule{ID: 1, Market: MarketSpot}
+	other := &Rule{ID: 2, Market: MarketSpot}
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	dueKeys(e, spot, now, "A")
+	if got := dueKeys(e, other, now, "A"); !reflect.DeepEqual(got, []string{"A"}) {
+		t.Errorf("due %v, want A for a second rule despite the first one's cooldown", got)
+	}
+	// Moving a rule to another market starts it over
+	spot.Market = MarketFutures
+	if got := dueKeys(e, spot, now.Add(time.Second), "A"); !reflect.DeepEqual(got, []string{"A"}) {
+		t.Errorf("due %v, want A after the market changed", got)
+	}
+}
+
+func TestFilterClause(t *testing.T) {
+	rule := &Rule{
+		Exchanges:  []string{"Binance", "Bybit"},
+		Symbols:    []string{"BTCUSDT"},
+		BaseAssets: []string{"BTC", "ETH"},
+	}
+	where, args := filterClause(rule, []interface{}{1.5, 1000.0})
+
+	// Both legs must be on a listed exchange
+	wantWhere := " AND firstPairExchange = ANY($3) AND secondPairExchange = ANY($3)" +
+		" AND symbol = ANY($4) AND baseAsset = ANY($5)"
+	if where != wantWhere {
+		t.Errorf("where = %q, want %q", where, wantWhere)
+	}
+	wantArgs := []interface{}{1.5, 1000.0, pq.Array(rule.Exchanges), pq.Array(rule.Symbols), pq.Array(rule.BaseAssets)}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %v, want %v", args, wantArgs)
+	}
+
+	if where, args := filterClause(&Rule{}, []interface{}{1.5}); where != "" || len(args) != 1 {
+		t.Errorf("empty rule: where %q, args %v, want no filters", where, args)
+	}
+}
+
+func TestCommonNetworks(t *testing.T) {
+	first := []byte(`{"baseAsset": [
+		{"network": "BTC", "depositEnable": true, "withdrawEnable": true},
+		{"network": "bsc", "depositEnable": false, "withdrawEnable": true},
+		{"network": "LIGHTNING", "depositEnable": true, "withdrawEnable": false}
+	]}`)
+	second := []byte(`{"baseAsset": [
+		{"network": "LIGHTNING", "depositEnable": true, "withdrawEnable": true},
+		{"network": "BSC", "depositEnable": true, "withdrawEnable": false},
+		{"network": "BTC", "depositEnable": true, "withdrawEnable": true}
+	]}`)
+
+	// Withdrawable on the first exchange and depositable on the second, case-insensitive
+	if got, want := commonNetworks(first, second), []string{"BSC", "BTC"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("common networks = %v, want %v", got, want)
+	}
+	if got := commonNetworks(second, first); !reflect.DeepEqual(got, []string{"BTC", "LIGHTNING"}) {
+		t.Errorf("reversed common networks = %v, want [BTC LIGHTNING]", got)
+	}
+	if got := commonNetworks([]byte(`{}`), []byte(`not json`)); got != nil {
+		t.Errorf("invalid networks = %v, want none", got)
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	valid := func() Rule {
+		return Rule{Name: "r", Market: MarketSpot, MinSpreadPercent: 1, Channels: []string{"tg"}}
+	}
+	tests := []struct {
+		name  string
+		edit  func(r *Rule)
+		valid bool
+	}{
+		{"spot", func(r *Rule) {}, true},
+		{"futures funding only", func(r *Rule) { r.Market, r.MinSpreadPercent, r.MinFundingDiffPercent = MarketFutures, 0, 0.01 }, true},
+		{"no name", func(r *Rule) { r.Name = " " }, false},
+		{"unknown market", func(r *Rule) { r.Market = "options" }, false},
+		{"spot without spread", func(r *Rule) { r.MinSpreadPercent = 0 }, false},
+		{"spot with funding", func(r *Rule) { r.MinFundingDiffPercent = 0.01 }, false},
+		{"futures with networks", func(r *Rule) { r.Market, r.RequireCommonNetwork = MarketFutures, true }, false},
+		{"negative volume", func(r *Rule) { r.MinVolume = -1 }, false},
+		{"negative cooldown", func(r *Rule) { r.Cooldown = Duration(-time.Minute) }, false},
+		{"no channels", func(r *Rule) { r.Channels = nil }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid()
+			tt.edit(&r)
+			if err := r.Validate(); (err == nil) != tt.valid {
+				t.Errorf("Validate() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Channel types.
+const (
+	ChannelWebhook  = "webhook"
+	ChannelTelegram = "telegram"
+	ChannelSMTP     = "smtp"
+)
+
+// Channel configures where alerts are delivered. Only the fields of the selected type are used.
+type Channel struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Enabled bool   `json:"enabled"`
+
+	// webhook
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// telegram
+	BotToken string `json:"botToken,omitempty"`
+	ChatID   string `json:"chatId,omitempty"`
+	APIURL   string `json:"apiUrl,omitempty"` // defaults to https://api.telegram.org
+
+	// smtp
+	Host     string   `json:"host,omitempty"`
+	Port     int      `json:"port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+}
+
+// Validate checks that the fields required by the channel type are set.
+func (c *Channel) Validate() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return errors.New("channel name is required")
+	}
+	switch c.Type {
+	case ChannelWebhook:
+		if c.URL == "" {
+			return fmt.Errorf("channel %q: url is required", c.Name)
+		}
+	case ChannelTelegram:
+		if c.BotToken == "" || c.ChatID == "" {
+			return fmt.Errorf("channel %q: botToken and chatId are required", c.Name)
+		}
+	case ChannelSMTP:
+		if c.Host == "" || c.Port == 0 || c.From == "" || len(c.To) == 0 {
+			return fmt.Errorf("channel %q: host, port, from and to are required", c.Name)
+		}
+	default:
+		return fmt.Errorf("channel %q: type must be %q, %q or %q", c.Name, ChannelWebhook, ChannelTelegram, ChannelSMTP)
+	}
+	return nil
+}
+
+// Opportunity is a single diff row that matched a rule.
+type Opportunity struct {
+	PairKey            string   `json:"pairKey"`
+	Symbol             string   `json:"symbol"`
+	BaseAsset          string   `json:"baseAsset"`
+	QuoteAsset         string   `json:"quoteAsset"`
+	FirstExchange      string   `json:"firstExchange"`
+	SecondExchange     string   `json:"secondExchange"`
+	FirstPrice         float64  `json:"firstPrice"`
+	SecondPrice        float64  `json:"secondPrice"`
+	FirstVolume        float64  `json:"firstVolume"`  // quote currency
+	SecondVolume       float64  `json:"secondVolume"` // quote currency
+	SpreadPercent      float64  `json:"spreadPercent"`
+	FundingDiffPercent float64  `json:"fundingDiffPercent,omitempty"`
+	CommonNetworks     []string `json:"commonNetworks,omitempty"`
+	Lifetime           Duration `json:"lifetime"`
+}
+
+// Alert groups the opportunities found for one rule in one evaluation.
+type Alert struct {
+	Rule          string        `json:"rule"`
+	Market        string        `json:"market"`
+	Test          bool          `json:"test,omitempty"` // sent from the test fire endpoint
+	FiredAt       time.Time     `json:"firedAt"`
+	Opportunities []Opportunity `json:"opportunities"`
+}
+
+// Notifier delivers alerts to one channel.
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// NewNotifier builds the notifier for a validated channel.
+func NewNotifier(ch Channel) (Notifier, error) {
+	if err := ch.Validate(); err != nil {
+		return nil, err
+	}
+	switch ch.Type {
+	case ChannelWebhook:
+		return newWebhookNotifier(ch), nil
+	case ChannelTelegram:
+		return newTelegramNotifier(ch), nil
+	case ChannelSMTP:
+		return newSMTPNotifier(ch), nil
+	}
+	return nil, fmt.Errorf("channel %q: unsupported type %q", ch.Name, ch.Type)
+}
+
+// FormatText renders an alert as a plain text message for chat and email channels.
+func FormatText(alert Alert) string {
+	var b strings.Builder
+	if alert.Test {
+		b.WriteString("[TEST] ")
+	}
+	fmt.Fprintf(&b, "Alert %q (%s): %d opportunities\n", alert.Rule, alert.Market, len(alert.Opportunities))
+	for _, o := range alert.Opportunities {
+		fmt.Fprintf(&b, "\n%s %s -> %s: %.2f%%", o.Symbol, o.FirstExchange, o.SecondExchange, o.SpreadPercent)
+		if o.FundingDiffPercent != 0 {
+			fmt.Fprintf(&b, ", funding diff %.4f%%", o.FundingDiffPercent)
+		}
+		fmt.Fprintf(&b, "\n  price %g / %g, volume %.0f / %.0f %s", o.FirstPrice, o.SecondPrice, o.FirstVolume, o.SecondVolume, o.QuoteAsset)
+		if len(o.CommonNetworks) > 0 {
+			fmt.Fprintf(&b, "\n  networks: %s", strings.Join(o.CommonNetworks, ", "))
+		}
+		if o.Lifetime > 0 {
+			fmt.Fprintf(&b, "\n  alive for %s", time.Duration(o.Lifetime).Round(time.Second))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// secretMask replaces secrets in API responses. Sending it back on update keeps the stored value.
+const secretMask = "********"
+
+// Redacted returns a copy of the channel with credentials masked.
+func (c Channel) Redacted() Channel {
+	if c.URL != "" {
+		c.URL = maskURL(c.URL)
+	}
+	if c.BotToken != "" {
+		c.BotToken = secretMask
+	}
+	if c.Password != "" {
+		c.Password = secretMask
+	}
+	if len(c.Headers) > 0 {
+		headers := make(map[string]string, len(c.Headers))
+		for k := range c.Headers {
+			headers[k] = secretMask
+		}
+		c.Headers = headers
+	}
+	return c
+}
+
+// maskURL keeps the scheme and host of a webhook URL and masks the rest: Slack
+// and Discord webhook URLs carry their secret in the path.
+func maskURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return secretMask
+	}
+	return u.Scheme + "://" + u.Host + "/" + secretMask
+}
+
+// keepMaskedSecrets restores secrets that an update sent back masked.
+func (c *Channel) keepMaskedSecrets(stored Channel) {
+	if c.URL != "" && stored.URL != "" && c.URL == maskURL(stored.URL) {
+		c.URL = stored.URL
+	}
+	if c.BotToken == secretMask {
+		c.BotToken = stored.BotToken
+	}
+	if c.Password == secretMask {
+		c.Password = stored.Password
+	}
+	for k, v := range c.Headers {
+		if v == secretMask {
+			c.Headers[k] = stored.Headers[k]
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package alerts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Markets a rule can watch.
+const (
+	MarketSpot    = "spot"
+	MarketFutures = "futures"
+)
+
+// Duration is a time.Duration that reads and writes JSON as a string like "5m".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"5m\": %w", err)
+	}
+	if s == "" {
+		*d = 0
+		return nil
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Rule describes which opportunities in diffs/diffsfutures should be reported.
+// Filters that are left empty or zero are not applied.
+type Rule struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Market  string `json:"market"` // "spot" (diffs) or "futures" (diffsfutures)
+	Enabled bool   `json:"enabled"`
+
+	Symbols    []string `json:"symbols"`    // e.g. "BTCUSDT"
+	BaseAssets []string `json:"baseAssets"` // e.g. "BTC"
+	Exchanges  []string `json:"exchanges"`  // both legs must be on one of these exchanges
+
+	MinSpreadPercent      float64  `json:"minSpreadPercent"`      // price (spot) or mark price (futures) difference
+	MinFundingDiffPercent float64  `json:"minFundingDiffPercent"` // futures only
+	MinVolume             float64  `json:"minVolume"`             // 24h volume in quote currency on both legs
+	MinLifetime           Duration `json:"minLifetime"`           // how long the opportunity must persist before alerting
+	RequireCommonNetwork  bool     `json:"requireCommonNetwork"`  // spot only: base asset withdrawable on the first exchange and depositable on the second via the same network
+
+	Cooldown   Duration `json:"cooldown"`   // minimum time between alerts for the same pair, defaults to 15m
+	MaxResults int      `json:"maxResults"` // opportunities per notification, defaults to 10
+	Channels   []string `json:"channels"`   // names of channels to deliver to
+}
+
+const (
+	defaultCooldown   = 15 * time.Minute
+	defaultMaxResults = 10
+)
+
+// Validate checks that the rule is complete and consistent with its market.
+func (r *Rule) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("rule name is required")
+	}
+	switch r.Market {
+	case MarketSpot:
+		if r.MinFundingDiffPercent != 0 {
+			return fmt.Errorf("rule %q: minFundingDiffPercent only applies to futures", r.Name)
+		}
+		if r.MinSpreadPercent <= 0 {
+			return fmt.Errorf("rule %q: minSpreadPercent must be positive", r.Name)
+		}
+	case MarketFutures:
+		if r.RequireCommonNetwork {
+			return fmt.Errorf("rule %q: requireCommonNetwork only applies to spot", r.Name)
+		}
+		if r.MinSpreadPercent <= 0 && r.MinFundingDiffPercent <= 0 {
+			return fmt.Errorf("rule %q: minSpreadPercent or minFundingDiffPercent must be positive", r.Name)
+		}
+	default:
+		return fmt.Errorf("rule %q: market must be %q or %q", r.Name, MarketSpot, MarketFutures)
+	}
+	if r.MinSpreadPercent < 0 || r.MinFundingDiffPercent < 0 || r.MinVolume < 0 {
+		return fmt.Errorf("rule %q: thresholds must not be negative", r.Name)
+	}
+	if r.MinLifetime < 0 || r.Cooldown < 0 {
+		return fmt.Errorf("rule %q: durations must not be negative", r.Name)
+	}
+	if r.MaxResults < 0 {
+		return fmt.Errorf("rule %q: maxResults must not be negative", r.Name)
+	}
+	if len(r.Channels) == 0 {
+		return fmt.Errorf("rule %q: at least one channel is required", r.Name)
+	}
+	return nil
+}
+
+func (r *Rule) cooldown() time.Duration {
+	if r.Cooldown == 0 {
+		return defaultCooldown
+	}
+	return time.Duration(r.Cooldown)
+}
+
+func (r *Rule) maxResults() int {
+	if r.MaxResults == 0 {
+		return defaultMaxResults
+	}
+	return r.MaxResults
+}
+
+// FileConfig is the layout of the alerts file referenced by ALERTS_FILE. The file seeds
+// the alert tables on startup; rules and channels are then managed through the API.
+type FileConfig struct {
+	Channels []Channel `json:"channels"`
+	Rules    []Rule    `json:"rules"`
+}
+
+// LoadFile reads and validates an alerts file. ${VAR} references are expanded
+// from the environment so secrets such as bot tokens can stay out of the file.
+func LoadFile(path string) (*FileConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading alerts file %s: %w", path, err)
+	}
+
+	var cfg FileConfig
+	if err := json.Unmarshal([]byte(os.ExpandEnv(string(content))), &cfg); err != nil {
+		return nil, fmt.Errorf("error parsing alerts file %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid alerts file %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Validate checks every channel and rule, and that rules only reference known channels.
+func (c *FileConfig) Validate() error {
+	channels := make(map[string]bool)
+	for i := range c.Channels {
+		if err := c.Channels[i].Validate(); err != nil {
+			return err
+		}
+		if channels[c.Channels[i].Name] {
+			return fmt.Errorf("duplicate channel %q", c.Channels[i].Name)
+		}
+		channels[c.Channels[i].Name] = true
+	}
+
+	rules := make(map[string]bool)
+	for i := range c.Rules {
+		r := &c.Rules[i]
+		if err := r.Validate(); err != nil {
+			return err
+		}
+		if rules[r.Name] {
+			return fmt.Errorf("duplicate rule %q", r.Name)
+		}
+		rules[r.Name] = true
+		for _, ch := range r.Channels {
+			if !channels[ch] {
+				return fmt.Errorf("rule %q: unknown channel %q", r.Name, ch)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package alerts
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// smtpTimeout bounds a delivery when the context has no deadline.
+const smtpTimeout = 30 * time.Second
+
+// smtpNotifier emails alerts as plain text. STARTTLS is used when the server offers it.
+type smtpNotifier struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+func newSMTPNotifier(ch Channel) *smtpNotifier {
+	return &smtpNotifier{
+		addr:     net.JoinHostPort(ch.Host, strconv.Itoa(ch.Port)),
+		host:     ch.Host,
+		username: ch.Username,
+		password: ch.Password,
+		from:     ch.From,
+		to:       ch.To,
+	}
+}
+
+func (n *smtpNotifier) Notify(ctx context.Context, alert Alert) error {
+	// Rule names come from API clients, a line break would start a header of their own
+	rule := strings.NewReplacer("\r", " ", "\n", " ").Replace(alert.Rule)
+	subject := fmt.Sprintf("[arbtool] %s: %d opportunities", rule, len(alert.Opportunities))
+	msg := strings.Join([]string{
+		"From: " + n.from,
+		"To: " + strings.Join(n.to, ", "),
+		"Subject: " + mime.QEncoding.Encode("UTF-8", subject),
+		"Date: " + alert.FiredAt.Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		strings.ReplaceAll(FormatText(alert), "\n", "\r\n"),
+	}, "\r\n")
+
+	if err := n.send(ctx, []byte(msg)); err != nil {
+		return fmt.Errorf("smtp error sending to %s: %w", n.addr, err)
+	}
+	return nil
+}
+
+// send delivers msg like smtp.SendMail does. net/smtp has no context support,
+// so the connection gets the deadline of ctx and is cut off when ctx is cancelled.
+func (n *smtpNotifier) send(ctx context.Context, msg []byte) (err error) {
+	dialer := net.Dialer{Timeout: smtpTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer func() {
+		stop()
+		// The I/O error of a cut off connection says less than the context
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+	}()
+
+	c, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: n.host}); err != nil {
+			return err
+		}
+	}
+	if n.username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("server doesn't support AUTH")
+		}
+		if err := c.Auth(smtp.PlainAuth("", n.username, n.password, n.host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.from); err != nil {
+		return err
+	}
+	for _, addr := range n.to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,247 @@
+package alerts
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type receivedMail struct {
+	auth string // decoded AUTH PLAIN response
+	from string
+	to   []string
+	data string
+}
+
+// smtpServer is a minimal SMTP server that accepts every message, offers
+// AUTH PLAIN and no STARTTLS, and records what it received.
+type smtpServer struct {
+	listener net.Listener
+
+	mu    sync.Mutex
+	mails []receivedMail
+	idle  []net.Conn // connections that were never answered
+}
+
+func startSMTPServer(t *testing.T, greet bool) *smtpServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{listener: listener}
+	t.Cleanup(func() {
+		listener.Close()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, conn := range s.idle {
+			conn.Close()
+		}
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			if !greet {
+				// Hold the connection open without answering
+				s.mu.Lock()
+				s.idle = append(s.idle, conn)
+				s.mu.Unlock()
+				continue
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP test")
+
+	var mail receivedMail
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250-localhost")
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			_, encoded, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(encoded)
+			mail.auth = string(decoded)
+			tp.PrintfLine("235 2.7.0 Authentication successful")
+		case "MAIL":
+			mail.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			mail.to = append(mail.to, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mail.data = string(data)
+			s.mu.Lock()
+			s.mails = append(s.mails, mail)
+			s.mu.Unlock()
+			mail = receivedMail{}
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) channel() Channel {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return Channel{
+		Name: "mail", Type: ChannelSMTP, Host: "127.0.0.1", Port: addr.Port,
+		From: "alerts@example.com", To: []string{"a@example.com", "b@example.com"},
+	}
+}
+
+func (s *smtpServer) received() []receivedMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]receivedMail(nil), s.mails...)
+}
+
+func TestSMTPNotify(t *testing.T) {
+	srv := startSMTPServer(t, true)
+	ch := srv.channel()
+	ch.Username, ch.Password = "alerts", "hunter2"
+
+	alert := testAlert()
+	if err := newNotifierForTest(t, ch).Notify(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+
+	mails := srv.received()
+	if len(mails) != 1 {
+		t.Fatalf("got %d mails, want 1", len(mails))
+	}
+	mail := mails[0]
+	if mail.auth != "\x00alerts\x00hunter2" {
+		t.Errorf("auth = %q, want PLAIN credentials", mail.auth)
+	}
+	if mail.from != "alerts@example.com" || strings.Join(mail.to, ",") != "a@example.com,b@example.com" {
+		t.Errorf("envelope from %q to %v", mail.from, mail.to)
+	}
+	for _, want := range []string{
+		"Subject: [arbtool] btc spread: 1 opportunities\n",
+		"To: a@example.com, b@example.com\n",
+		"Content-Type: text/plain; charset=UTF-8\n",
+		FormatText(alert),
+	} {
+		if !strings.Contains(mail.data, want) {
+			t.Errorf("message does not contain %q:\n%s", want, mail.data)
+		}
+	}
+}
+
+func TestSMTPNotifyWithoutAuth(t *testing.T) {
+	srv := startSMTPServer(t, true)
+	if err := newNotifierForTest(t, srv.channel()).Notify(context.Background(), testAlert()); err != nil {
+		t.Fatal(err)
+	}
+	if mails := srv.received(); len(mails) != 1 || mails[0].auth != "" {
+		t.Errorf("mails = %+v, want one sent without AUTH", mails)
+	}
+}
+
+func TestSMTPNotifyCancelled(t *testing.T) {
+	srv := startSMTPServer(t, false)
+	ch := srv.channel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := newNotifierForTest(t, ch).Notify(ctx, testAlert())
+	if err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) ||
+		!strings.Contains(err.Error(), strconv.Itoa(ch.Port)) {
+		t.Errorf("error = %v, want the deadline and the server address", err)
+	}
+}
+
+func TestSMTPNotifySubjectInjection(t *testing.T) {
+	srv := startSMTPServer(t, true)
+	alert := testAlert()
+	alert.Rule = "btc\r\nBcc: victim@example.com\nX-Spam: yes"
+
+	if err := newNotifierForTest(t, srv.channel()).Notify(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+	mails := srv.received()
+	if len(mails) != 1 {
+		t.Fatalf("got %d mails, want 1", len(mails))
+	}
+	header, _, _ := strings.Cut(mails[0].data, "\n\n")
+	for _, injected := range []string{"\nBcc:", "\nX-Spam:"} {
+		if strings.Contains(header, injected) {
+			t.Errorf("header contains %q:\n%s", injected, header)
+		}
+	}
+	if !strings.Contains(header, "Subject: [arbtool] btc  Bcc: victim@example.com X-Spam: yes: 1 opportunities\n") {
+		t.Errorf("subject not kept on one line:\n%s", header)
+	}
+}
+
+func TestSMTPNotifyEncodesSubject(t *testing.T) {
+	srv := startSMTPServer(t, true)
+	alert := testAlert()
+	alert.Rule = "спред btc"
+
+	if err := newNotifierForTest(t, srv.channel()).Notify(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+	mails := srv.received()
+	if len(mails) != 1 {
+		t.Fatalf("got %d mails, want 1", len(mails))
+	}
+	want := "Subject: " + mime.QEncoding.Encode("UTF-8", "[arbtool] спред btc: 1 opportunities") + "\n"
+	if !strings.Contains(mails[0].data, want) || !strings.Contains(want, "=?UTF-8?q?") {
+		t.Errorf("message does not contain %q:\n%s", want, mails[0].data)
+	}
+}
+
+func TestSMTPNotifyClosesConnection(t *testing.T) {
+	srv := startSMTPServer(t, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	err := newNotifierForTest(t, srv.channel()).Notify(ctx, testAlert())
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+
+	// Nothing is left talking to the server once Notify returned
+	srv.mu.Lock()
+	conn := srv.idle[0]
+	srv.mu.Unlock()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("server read %v, want EOF from the closed connection", err)
+	}
+}
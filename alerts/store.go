@@ -0,0 +1,394 @@
+package alerts
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNotFound is returned when a rule or channel does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned for duplicate names and for channels still used by rules.
+	ErrConflict = errors.New("conflict")
+	// ErrInvalid wraps validation errors of rules and channels.
+	ErrInvalid = errors.New("invalid")
+)
+
+// Delivery statuses.
+const (
+	DeliverySent   = "sent"
+	DeliveryFailed = "failed"
+)
+
+// Delivery is one notification attempt recorded in alertdeliveries.
+type Delivery struct {
+	ID        int64           `json:"id"`
+	RuleID    int             `json:"ruleId"`
+	RuleName  string          `json:"ruleName"`
+	Channel   string          `json:"channel"`
+	Status    string          `json:"status"`
+	Error     string          `json:"error,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+	Test      bool            `json:"test"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// wrapWriteError maps unique violations to ErrConflict.
+func wrapWriteError(what string, err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return fmt.Errorf("%s already exists: %w", what, ErrConflict)
+	}
+	return fmt.Errorf("error saving %s: %w", what, err)
+}
+
+const channelColumns = `id, name, type, settings, enabled`
+
+func scanChannel(row interface{ Scan(...interface{}) error }) (Channel, error) {
+	var ch Channel
+	var id int
+	var name, typ string
+	var enabled bool
+	var settings []byte
+	if err := row.Scan(&id, &name, &typ, &settings, &enabled); err != nil {
+		return Channel{}, err
+	}
+	if err := json.Unmarshal(settings, &ch); err != nil {
+		return Channel{}, fmt.Errorf("error decoding settings of channel %q: %w", name, err)
+	}
+	ch.ID, ch.Name, ch.Type, ch.Enabled = id, name, typ, enabled
+	return ch, nil
+}
+
+// ListChannels returns all channels ordered by id.
+func ListChannels(ctx context.Context, db *sql.DB) ([]Channel, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+channelColumns+` FROM alertchannels ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing alert channels: %w", err)
+	}
+	defer rows.Close()
+
+	var channels []Channel
+	for rows.Next() {
+		ch, err := scanChannel(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning alert channel: %w", err)
+		}
+		channels = append(channels, ch)
+	}
+	return channels, rows.Err()
+}
+
+// GetChannel returns one channel by id.
+func GetChannel(ctx context.Context, db *sql.DB, id int) (Channel, error) {
+	ch, err := scanChannel(db.QueryRowContext(ctx, `SELECT `+channelColumns+` FROM alertchannels WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Channel{}, ErrNotFound
+	}
+	if err != nil {
+		return Channel{}, fmt.Errorf("error loading alert channel %d: %w", id, err)
+	}
+	return ch, nil
+}
+
+// CreateChannel validates and stores a new channel, setting its id.
+func CreateChannel(ctx context.Context, db *sql.DB, ch *Channel) error {
+	if err := ch.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	settings, err := json.Marshal(ch)
+	if err != nil {
+		return fmt.Errorf("error encoding channel settings: %w", err)
+	}
+	err = db.QueryRowContext(ctx,
+		`INSERT INTO alertchannels (name, type, settings, enabled) VALUES ($1, $2, $3, $4) RETURNING id`,
+		ch.Name, ch.Type, settings, ch.Enabled,
+	).Scan(&ch.ID)
+	if err != nil {
+		return wrapWriteError("channel "+ch.Name, err)
+	}
+	return nil
+}
+
+// UpdateChannel replaces a channel. Masked secrets keep their stored values, and
+// renaming updates the rules that reference the channel.
+func UpdateChannel(ctx context.Context, db *sql.DB, ch *Channel) error {
+	stored, err := GetChannel(ctx, db, ch.ID)
+	if err != nil {
+		return err
+	}
+	ch.keepMaskedSecrets(stored)
+	if err := ch.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	settings, err := json.Marshal(ch)
+	if err != nil {
+		return fmt.Errorf("error encoding channel settings: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error updating alert channel: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE alertchannels SET name = $1, type = $2, settings = $3, enabled = $4, updatedAt = $5 WHERE id = $6`,
+		ch.Name, ch.Type, settings, ch.Enabled, time.Now().UTC(), ch.ID)
+	if err != nil {
+		return wrapWriteError("channel "+ch.Name, err)
+	}
+	if stored.Name != ch.Name {
+		_, err = tx.ExecContext(ctx,
+			`UPDATE alertrules SET channels = array_replace(channels, $1, $2), updatedAt = $3 WHERE $1 = ANY(channels)`,
+			stored.Name, ch.Name, time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("error renaming channel in rules: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SetChannelEnabled enables or disables a channel.
+func SetChannelEnabled(ctx context.Context, db *sql.DB, id int, enabled bool) error {
+	return setEnabled(ctx, db, "alertchannels", id, enabled)
+}
+
+// DeleteChannel removes a channel that no rule uses anymore.
+func DeleteChannel(ctx context.Context, db *sql.DB, id int) error {
+	ch, err := GetChannel(ctx, db, id)
+	if err != nil {
+		return err
+	}
+	var users int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM alertrules WHERE $1 = ANY(channels)`, ch.Name).Scan(&users); err != nil {
+		return fmt.Errorf("error checking channel usage: %w", err)
+	}
+	if users > 0 {
+		return fmt.Errorf("channel %q is used by %d rules: %w", ch.Name, users, ErrConflict)
+	}
+	return deleteRow(ctx, db, "alertchannels", id)
+}
+
+const ruleColumns = `id, name, market, enabled, symbols, baseAssets, exchanges, minSpreadPercent, minFundingDiffPercent,
+	minVolume, minLifetimeSeconds, requireCommonNetwork, cooldownSeconds, maxResults, channels`
+
+func scanRule(row interface{ Scan(...interface{}) error }) (Rule, error) {
+	var r Rule
+	var lifetime, cooldown int64
+	err := row.Scan(&r.ID, &r.Name, &r.Market, &r.Enabled, pq.Array(&r.Symbols), pq.Array(&r.BaseAssets), pq.Array(&r.Exchanges),
+		&r.MinSpreadPercent, &r.MinFundingDiffPercent, &r.MinVolume, &lifetime, &r.RequireCommonNetwork, &cooldown,
+		&r.MaxResults, pq.Array(&r.Channels))
+	r.MinLifetime = Duration(time.Duration(lifetime) * time.Second)
+	r.Cooldown = Duration(time.Duration(cooldown) * time.Second)
+	return r, err
+}
+
+func ruleArgs(r *Rule) []interface{} {
+	return []interface{}{r.Name, r.Market, r.Enabled, pq.Array(nonNil(r.Symbols)), pq.Array(nonNil(r.BaseAssets)), pq.Array(nonNil(r.Exchanges)),
+		r.MinSpreadPercent, r.MinFundingDiffPercent, r.MinVolume, int64(time.Duration(r.MinLifetime) / time.Second),
+		r.RequireCommonNetwork, int64(time.Duration(r.Cooldown) / time.Second), r.MaxResults, pq.Array(nonNil(r.Channels))}
+}
+
+// nonNil turns a nil slice into an empty one so NOT NULL array columns accept it.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// ListRules returns all rules ordered by id.
+func ListRules(ctx context.Context, db *sql.DB) ([]Rule, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+ruleColumns+` FROM alertrules ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []Rule
+	for rows.Next() {
+		r, err := scanRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning alert rule: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// GetRule returns one rule by id.
+func GetRule(ctx context.Context, db *sql.DB, id int) (Rule, error) {
+	r, err := scanRule(db.QueryRowContext(ctx, `SELECT `+ruleColumns+` FROM alertrules WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Rule{}, ErrNotFound
+	}
+	if err != nil {
+		return Rule{}, fmt.Errorf("error loading alert rule %d: %w", id, err)
+	}
+	return r, nil
+}
+
+// validateRule checks the rule itself and that every referenced channel exists.
+func validateRule(ctx context.Context, db *sql.DB, r *Rule) error {
+	if err := r.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	var known int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(DISTINCT name) FROM alertchannels WHERE name = ANY($1)`, pq.Array(r.Channels)).Scan(&known)
+	if err != nil {
+		return fmt.Errorf("error checking rule channels: %w", err)
+	}
+	if known != len(uniqueStrings(r.Channels)) {
+		return fmt.Errorf("%w: rule %q references unknown channels", ErrInvalid, r.Name)
+	}
+	return nil
+}
+
+func uniqueStrings(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// CreateRule validates and stores a new rule, setting its id.
+func CreateRule(ctx context.Context, db *sql.DB, r *Rule) error {
+	if err := validateRule(ctx, db, r); err != nil {
+		return err
+	}
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO alertrules (name, market, enabled, symbols, baseAssets, exchanges, minSpreadPercent, minFundingDiffPercent,
+			minVolume, minLifetimeSeconds, requireCommonNetwork, cooldownSeconds, maxResults, channels)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id`, ruleArgs(r)...,
+	).Scan(&r.ID)
+	if err != nil {
+		return wrapWriteError("rule "+r.Name, err)
+	}
+	return nil
+}
+
+// UpdateRule replaces an existing rule.
+func UpdateRule(ctx context.Context, db *sql.DB, r *Rule) error {
+	if err := validateRule(ctx, db, r); err != nil {
+		return err
+	}
+	args := append(ruleArgs(r), time.Now().UTC(), r.ID)
+	res, err := db.ExecContext(ctx, `
+		UPDATE alertrules SET name = $1, market = $2, enabled = $3, symbols = $4, baseAssets = $5, exchanges = $6,
+			minSpreadPercent = $7, minFundingDiffPercent = $8, minVolume = $9, minLifetimeSeconds = $10,
+			requireCommonNetwork = $11, cooldownSeconds = $12, maxResults = $13, channels = $14, updatedAt = $15
+		WHERE id = $16`, args...)
+	if err != nil {
+		return wrapWriteError("rule "+r.Name, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetRuleEnabled enables or disables a rule.
+func SetRuleEnabled(ctx context.Context, db *sql.DB, id int, enabled bool) error {
+	return setEnabled(ctx, db, "alertrules", id, enabled)
+}
+
+// DeleteRule removes a rule. Its delivery history is kept.
+func DeleteRule(ctx context.Context, db *sql.DB, id int) error {
+	return deleteRow(ctx, db, "alertrules", id)
+}
+
+func setEnabled(ctx context.Context, db *sql.DB, table string, id int, enabled bool) error {
+	res, err := db.ExecContext(ctx, `UPDATE `+table+` SET enabled = $1, updatedAt = $2 WHERE id = $3`, enabled, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("error updating %s: %w", table, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func deleteRow(ctx context.Context, db *sql.DB, table string, id int) error {
+	res, err := db.ExecContext(ctx, `DELETE FROM `+table+` WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting from %s: %w", table, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordDelivery stores one notification attempt.
+func RecordDelivery(ctx context.Context, db *sql.DB, d Delivery) error {
+	var ruleID interface{}
+	if d.RuleID != 0 {
+		ruleID = d.RuleID
+	}
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO alertdeliveries (ruleId, ruleName, channel, status, error, payload, isTest, createdAt)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		ruleID, d.RuleName, d.Channel, d.Status, d.Error, []byte(d.Payload), d.Test, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("error recording alert delivery: %w", err)
+	}
+	return nil
+}
+
+// ListDeliveries returns the newest deliveries, optionally only for one rule.
+func ListDeliveries(ctx context.Context, db *sql.DB, ruleID int, limit int) ([]Delivery, error) {
+	query := `SELECT id, COALESCE(ruleId, 0), ruleName, channel, status, error, payload, isTest, createdAt FROM alertdeliveries`
+	args := []interface{}{limit}
+	if ruleID != 0 {
+		query += ` WHERE ruleId = $2`
+		args = append(args, ruleID)
+	}
+	query += ` ORDER BY id DESC LIMIT $1`
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing alert deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		var d Delivery
+		var payload []byte
+		if err := rows.Scan(&d.ID, &d.RuleID, &d.RuleName, &d.Channel, &d.Status, &d.Error, &payload, &d.Test, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning alert delivery: %w", err)
+		}
+		d.Payload = payload
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// SeedFromFile inserts the channels and rules of an alerts file that do not exist yet.
+// Existing entries are left alone so changes made through the API survive restarts.
+func SeedFromFile(ctx context.Context, db *sql.DB, cfg *FileConfig) error {
+	for i := range cfg.Channels {
+		ch := cfg.Channels[i]
+		ch.Enabled = true
+		if err := CreateChannel(ctx, db, &ch); err != nil && !errors.Is(err, ErrConflict) {
+			return err
+		}
+	}
+	for i := range cfg.Rules {
+		r := cfg.Rules[i]
+		r.Enabled = true
+		if err := CreateRule(ctx, db, &r); err != nil && !errors.Is(err, ErrConflict) {
+			return err
+		}
+	}
+	return nil
+}
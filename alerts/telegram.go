@@ -0,0 +1,85 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultTelegramAPIURL = "https://api.telegram.org"
+
+// Telegram rejects messages longer than 4096 characters, this leaves room for the
+// truncation marker.
+const telegramMaxMessageLength = 4000
+
+// telegramNotifier sends alerts through the Telegram Bot API sendMessage method.
+type telegramNotifier struct {
+	apiURL string
+	token  string
+	chatID string
+	client *http.Client
+}
+
+func newTelegramNotifier(ch Channel) *telegramNotifier {
+	apiURL := ch.APIURL
+	if apiURL == "" {
+		apiURL = defaultTelegramAPIURL
+	}
+	return &telegramNotifier{
+		apiURL: strings.TrimRight(apiURL, "/"),
+		token:  ch.BotToken,
+		chatID: ch.ChatID,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (n *telegramNotifier) Notify(ctx context.Context, alert Alert) error {
+	// Cut on a character boundary, Telegram rejects invalid UTF-8
+	text := FormatText(alert)
+	if runes := []rune(text); len(runes) > telegramMaxMessageLength {
+		text = string(runes[:telegramMaxMessageLength]) + "\n..."
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"chat_id":                  n.chatID,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return fmt.Errorf("telegram error encoding message: %w", err)
+	}
+
+	// The token is part of the path, so it is never included in returned errors
+	url := fmt.Sprintf("%s/bot%s/sendMessage", n.apiURL, n.token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("telegram error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("telegram error sending message: %w", redactToken(err, n.token))
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil || resp.StatusCode != http.StatusOK || !result.OK {
+		return fmt.Errorf("telegram sendMessage failed with status code %d: %s", resp.StatusCode, result.Description)
+	}
+	return nil
+}
+
+// redactToken strips the bot token from transport errors, which include the request URL.
+func redactToken(err error, token string) error {
+	return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), token, "<token>"))
+}
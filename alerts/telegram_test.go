@@ -0,0 +1,104 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+type telegramMessage struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview"`
+}
+
+// telegramServer stands in for the Bot API and records the sent messages.
+func telegramServer(t *testing.T, token string, messages *[]telegramMessage) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot"+token+"/sendMessage" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"ok":false,"error_code":401,"description":"Unauthorized"}`))
+			return
+		}
+		var msg telegramMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("decoding body: %v", err)
+		}
+		if msg.ChatID != "42" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`))
+			return
+		}
+		*messages = append(*messages, msg)
+		w.Write([]byte(`{"ok":true,"result":{"message_id":1}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestTelegramNotify(t *testing.T) {
+	var messages []telegramMessage
+	srv := telegramServer(t, "123:abc", &messages)
+
+	n := newNotifierForTest(t, Channel{Name: "tg", Type: ChannelTelegram, BotToken: "123:abc", ChatID: "42", APIURL: srv.URL + "/"})
+	alert := testAlert()
+	if err := n.Notify(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	if messages[0].Text != FormatText(alert) || !messages[0].DisableWebPagePreview {
+		t.Errorf("message = %+v, want the formatted alert without preview", messages[0])
+	}
+}
+
+func TestTelegramNotifyFailure(t *testing.T) {
+	var messages []telegramMessage
+	srv := telegramServer(t, "123:abc", &messages)
+
+	n := newNotifierForTest(t, Channel{Name: "tg", Type: ChannelTelegram, BotToken: "123:abc", ChatID: "7", APIURL: srv.URL})
+	err := n.Notify(context.Background(), testAlert())
+	if err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Errorf("error = %v, want the API description", err)
+	}
+
+	// Transport errors carry the request URL, the token must not leak through them
+	srv.Close()
+	err = n.Notify(context.Background(), testAlert())
+	if err == nil || strings.Contains(err.Error(), "123:abc") {
+		t.Errorf("error = %v, want a failure without the token", err)
+	}
+}
+
+func TestTelegramNotifyTruncates(t *testing.T) {
+	var messages []telegramMessage
+	srv := telegramServer(t, "123:abc", &messages)
+
+	// Multi-byte symbols make a byte offset cut fall inside a character
+	alert := testAlert()
+	o := alert.Opportunities[0]
+	alert.Opportunities = nil
+	for i := 0; i < 200; i++ {
+		o.Symbol = strings.Repeat("€", 7)
+		alert.Opportunities = append(alert.Opportunities, o)
+	}
+
+	n := newNotifierForTest(t, Channel{Name: "tg", Type: ChannelTelegram, BotToken: "123:abc", ChatID: "42", APIURL: srv.URL})
+	if err := n.Notify(context.Background(), alert); err != nil {
+		t.Fatal(err)
+	}
+	text := messages[0].Text
+	// encoding/json turns a split character into U+FFFD
+	if strings.ContainsRune(text, utf8.RuneError) {
+		t.Error("truncation split a character")
+	}
+	if n := utf8.RuneCountInString(text); n != telegramMaxMessageLength+len("\n...") {
+		t.Errorf("truncated text has %d characters, want %d", n, telegramMaxMessageLength+len("\n..."))
+	}
+}
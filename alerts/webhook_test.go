@@ -0,0 +1,112 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testAlert() Alert {
+	return Alert{
+		Rule:    "btc spread",
+		Market:  MarketSpot,
+		FiredAt: time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC),
+		Opportunities: []Opportunity{{
+			PairKey: "BTCUSDT_BTCUSDT_Binance-Bybit", Symbol: "BTCUSDT", BaseAsset: "BTC", QuoteAsset: "USDT",
+			FirstExchange: "Binance", SecondExchange: "Bybit", FirstPrice: 100, SecondPrice: 101.5,
+			FirstVolume: 1e6, SecondVolume: 2e6, SpreadPercent: 1.5, CommonNetworks: []string{"BTC"},
+			Lifetime: Duration(2 * time.Minute),
+		}},
+	}
+}
+
+func newNotifierForTest(t *testing.T, ch Channel) Notifier {
+	t.Helper()
+	n, err := NewNotifier(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestWebhookNotify(t *testing.T) {
+	var got Alert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/hooks/alerts" {
+			t.Errorf("request %s %s, want POST /hooks/alerts", r.Method, r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("Authorization = %q, want the configured header", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding body: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	n := newNotifierForTest(t, Channel{
+		Name: "hook", Type: ChannelWebhook, URL: srv.URL + "/hooks/alerts",
+		Headers: map[string]string{"Authorization": "Bearer secret"},
+	})
+	want := testAlert()
+	if err := n.Notify(context.Background(), want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("posted alert:\n got  %+v\n want %+v", got, want)
+	}
+}
+
+func TestWebhookNotifyFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such hook", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	n := newNotifierForTest(t, Channel{Name: "hook", Type: ChannelWebhook, URL: srv.URL})
+	err := n.Notify(context.Background(), testAlert())
+	if err == nil || !strings.Contains(err.Error(), "404") || !strings.Contains(err.Error(), "no such hook") {
+		t.Errorf("error = %v, want the status code and body", err)
+	}
+}
+
+func TestWebhookErrorsHideURL(t *testing.T) {
+	const secretPath = "/services/T000/B000/XXXXsecret"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	n := newNotifierForTest(t, Channel{Name: "hook", Type: ChannelWebhook, URL: srv.URL + secretPath + "?token=abc"})
+
+	// These errors end up in the delivery history
+	statusErr := n.Notify(context.Background(), testAlert())
+	srv.Close()
+	transportErr := n.Notify(context.Background(), testAlert())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cancelErr := n.Notify(ctx, testAlert())
+
+	for _, err := range []error{statusErr, transportErr, cancelErr} {
+		if err == nil {
+			t.Fatal("want an error")
+		}
+		if msg := err.Error(); strings.Contains(msg, "XXXXsecret") || strings.Contains(msg, "token=abc") {
+			t.Errorf("error %q contains the webhook URL", msg)
+		}
+	}
+	if !strings.Contains(statusErr.Error(), strings.TrimPrefix(srv.URL, "http://")) {
+		t.Errorf("error %q does not name the host", statusErr)
+	}
+	if !errors.Is(cancelErr, context.Canceled) {
+		t.Errorf("error %v does not wrap the cancellation", cancelErr)
+	}
+}
@@ -0,0 +1,278 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"Updater/alerts"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerAlertRoutes adds the alert rule, channel and delivery history endpoints.
+// Reads need the read role, every change needs the admin role.
+func registerAlertRoutes(read, admin *gin.RouterGroup, db *sql.DB, engine *alerts.Engine) {
+	h := &alertHandlers{db: db, engine: engine}
+
+	read.GET("/api/v1/alerts/channels", h.listChannels)
+	read.GET("/api/v1/alerts/channels/:id", h.getChannel)
+	admin.POST("/api/v1/alerts/channels", h.createChannel)
+	admin.PUT("/api/v1/alerts/channels/:id", h.updateChannel)
+	admin.DELETE("/api/v1/alerts/channels/:id", h.deleteChannel)
+	admin.POST("/api/v1/alerts/channels/:id/enable", h.setChannelEnabled(true))
+	admin.POST("/api/v1/alerts/channels/:id/disable", h.setChannelEnabled(false))
+
+	read.GET("/api/v1/alerts/rules", h.listRules)
+	read.GET("/api/v1/alerts/rules/:id", h.getRule)
+	admin.POST("/api/v1/alerts/rules", h.createRule)
+	admin.PUT("/api/v1/alerts/rules/:id", h.updateRule)
+	admin.DELETE("/api/v1/alerts/rules/:id", h.deleteRule)
+	admin.POST("/api/v1/alerts/rules/:id/enable", h.setRuleEnabled(true))
+	admin.POST("/api/v1/alerts/rules/:id/disable", h.setRuleEnabled(false))
+	admin.POST("/api/v1/alerts/rules/:id/test", h.testRule)
+
+	read.GET("/api/v1/alerts/deliveries", h.listDeliveries)
+}
+
+type alertHandlers struct {
+	db     *sql.DB
+	engine *alerts.Engine
+}
+
+// idParam parses the :id path parameter and writes a 400 response if it is invalid.
+func idParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id", "details": c.Param("id")})
+		return 0, false
+	}
+	return id, true
+}
+
+// storeError maps alert store errors to HTTP responses.
+func storeError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, alerts.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, alerts.ErrConflict):
+		status = http.StatusConflict
+	case errors.Is(err, alerts.ErrInvalid):
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, gin.H{"error": message, "details": err.Error()})
+}
+
+// reload applies a change to the running engine. The change itself is already
+// stored, so a failure is only logged.
+func (h *alertHandlers) reload(c *gin.Context) {
+	if err := h.engine.Reload(c.Request.Context()); err != nil {
+		slog.Error("error reloading alert rules", "error", err)
+	}
+}
+
+func (h *alertHandlers) listChannels(c *gin.Context) {
+	channels, err := alerts.ListChannels(c.Request.Context(), h.db)
+	if err != nil {
+		storeError(c, "Failed to fetch channels", err)
+		return
+	}
+	result := make([]alerts.Channel, 0, len(channels))
+	for _, ch := range channels {
+		result = append(result, ch.Redacted())
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *alertHandlers) getChannel(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	ch, err := alerts.GetChannel(c.Request.Context(), h.db, id)
+	if err != nil {
+		storeError(c, "Failed to fetch channel", err)
+		return
+	}
+	c.JSON(http.StatusOK, ch.Redacted())
+}
+
+func (h *alertHandlers) createChannel(c *gin.Context) {
+	ch := alerts.Channel{Enabled: true}
+	if err := c.ShouldBindJSON(&ch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel", "details": err.Error()})
+		return
+	}
+	if err := alerts.CreateChannel(c.Request.Context(), h.db, &ch); err != nil {
+		storeError(c, "Failed to create channel", err)
+		return
+	}
+	h.reload(c)
+	c.JSON(http.StatusCreated, ch.Redacted())
+}
+
+func (h *alertHandlers) updateChannel(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	ch := alerts.Channel{Enabled: true}
+	if err := c.ShouldBindJSON(&ch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel", "details": err.Error()})
+		return
+	}
+	ch.ID = id
+	if err := alerts.UpdateChannel(c.Request.Context(), h.db, &ch); err != nil {
+		storeError(c, "Failed to update channel", err)
+		return
+	}
+	h.reload(c)
+	c.JSON(http.StatusOK, ch.Redacted())
+}
+
+func (h *alertHandlers) deleteChannel(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	if err := alerts.DeleteChannel(c.Request.Context(), h.db, id); err != nil {
+		storeError(c, "Failed to delete channel", err)
+		return
+	}
+	h.reload(c)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *alertHandlers) setChannelEnabled(enabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := idParam(c)
+		if !ok {
+			return
+		}
+		if err := alerts.SetChannelEnabled(c.Request.Context(), h.db, id, enabled); err != nil {
+			storeError(c, "Failed to update channel", err)
+			return
+		}
+		h.reload(c)
+		c.JSON(http.StatusOK, gin.H{"id": id, "enabled": enabled})
+	}
+}
+
+func (h *alertHandlers) listRules(c *gin.Context) {
+	rules, err := alerts.ListRules(c.Request.Context(), h.db)
+	if err != nil {
+		storeError(c, "Failed to fetch rules", err)
+		return
+	}
+	if rules == nil {
+		rules = []alerts.Rule{}
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func (h *alertHandlers) getRule(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	rule, err := alerts.GetRule(c.Request.Context(), h.db, id)
+	if err != nil {
+		storeError(c, "Failed to fetch rule", err)
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *alertHandlers) createRule(c *gin.Context) {
+	rule := alerts.Rule{Enabled: true}
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule", "details": err.Error()})
+		return
+	}
+	if err := alerts.CreateRule(c.Request.Context(), h.db, &rule); err != nil {
+		storeError(c, "Failed to create rule", err)
+		return
+	}
+	h.reload(c)
+	c.JSON(http.StatusCreated, rule)
+}
+
+func (h *alertHandlers) updateRule(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	rule := alerts.Rule{Enabled: true}
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule", "details": err.Error()})
+		return
+	}
+	rule.ID = id
+	if err := alerts.UpdateRule(c.Request.Context(), h.db, &rule); err != nil {
+		storeError(c, "Failed to update rule", err)
+		return
+	}
+	h.reload(c)
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *alertHandlers) deleteRule(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	if err := alerts.DeleteRule(c.Request.Context(), h.db, id); err != nil {
+		storeError(c, "Failed to delete rule", err)
+		return
+	}
+	h.reload(c)
+	c.Status(http.StatusNoContent)
+}
+
+func (h *alertHandlers) setRuleEnabled(enabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := idParam(c)
+		if !ok {
+			return
+		}
+		if err := alerts.SetRuleEnabled(c.Request.Context(), h.db, id, enabled); err != nil {
+			storeError(c, "Failed to update rule", err)
+			return
+		}
+		h.reload(c)
+		c.JSON(http.StatusOK, gin.H{"id": id, "enabled": enabled})
+	}
+}
+
+// testRule sends a test notification with the rule's current matches to all of its channels.
+func (h *alertHandlers) testRule(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	// Deliveries keep running if the client disconnects so the history stays complete
+	results, err := h.engine.TestFire(context.WithoutCancel(c.Request.Context()), id)
+	if err != nil {
+		storeError(c, "Failed to test rule", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ruleId": id, "results": results})
+}
+
+func (h *alertHandlers) listDeliveries(c *gin.Context) {
+	ruleID, _ := strconv.Atoi(c.Query("ruleId"))
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	deliveries, err := alerts.ListDeliveries(c.Request.Context(), h.db, ruleID, limit)
+	if err != nil {
+		storeError(c, "Failed to fetch deliveries", err)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"Updater/auth"
+	"Updater/config"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// Context keys set by requireRole for downstream handlers.
+const (
+	ctxKeyAPIKeyID   = "apiKeyID"
+	ctxKeyAPIKeyRole = "apiKeyRole"
+)
+
+// corsMiddleware builds the CORS handler from the configured origins.
+// It returns nil when no origins are configured.
+func corsMiddleware(cfg *config.Config) gin.HandlerFunc {
+	if len(cfg.CORSAllowedOrigins) == 0 {
+		return nil
+	}
+
+	corsCfg := cors.Config{
+		AllowMethods:  []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowHeaders:  []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key"},
+		ExposeHeaders: []string{"Content-Length"},
+		MaxAge:        12 * time.Hour,
+	}
+	for _, origin := range cfg.CORSAllowedOrigins {
+		if origin == "*" {
+			// Browsers reject credentials with a wildcard origin
+			corsCfg.AllowAllOrigins = true
+			corsCfg.AllowOrigins = nil
+			return cors.New(corsCfg)
+		}
+		corsCfg.AllowOrigins = append(corsCfg.AllowOrigins, origin)
+	}
+	corsCfg.AllowCredentials = true
+	return cors.New(corsCfg)
+}
+
+// apiKeyFromRequest extracts the raw key from the X-API-Key header or a Bearer token.
+func apiKeyFromRequest(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	if authz := c.GetHeader("Authorization"); strings.HasPrefix(authz, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(authz, "Bearer "))
+	}
+	return ""
+}
+
+// requireRole rejects requests without a valid key for the given role.
+// When publicRead is set, read endpoints are open and keys are only checked if present.
+func requireRole(db *sql.DB, role string, publicRead bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := apiKeyFromRequest(c)
+		if raw == "" {
+			if publicRead && role == auth.RoleRead {
+				c.Next()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API key required"})
+			return
+		}
+
+		key, err := auth.Authenticate(db, raw)
+		if errors.Is(err, auth.ErrKeyNotFound) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+			return
+		}
+		if err != nil {
+			slog.Error("API key lookup failed", "error", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify API key"})
+			return
+		}
+
+		if !auth.Allows(key.Role, role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			return
+		}
+
+		c.Set(ctxKeyAPIKeyID, key.ID)
+		c.Set(ctxKeyAPIKeyRole, key.Role)
+		c.Next()
+	}
+}
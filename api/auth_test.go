@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"Updater/auth"
+	"Updater/config"
+	"Updater/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// keyStore is a database/sql driver that answers the queries of
+// auth.Authenticate from memory, so requests can be authenticated without Postgres.
+type keyStore struct {
+	mu       sync.Mutex
+	keys     map[string]*models.APIKey // by hash
+	lastUses int                       // lastUsedAt writes
+}
+
+// newKeyStore returns a database holding the given raw keys and their roles.
+func newKeyStore(t *testing.T, roles map[string]string) (*sql.DB, *keyStore) {
+	t.Helper()
+	store := &keyStore{keys: make(map[string]*models.APIKey)}
+	id := 0
+	for raw, role := range roles {
+		id++
+		store.keys[auth.HashKey(raw)] = &models.APIKey{ID: id, Name: role + " key", KeyPrefix: raw[:4], Role: role, CreatedAt: time.Now()}
+	}
+	db := sql.OpenDB(store)
+	t.Cleanup(func() { db.Close() })
+	return db, store
+}
+
+func (s *keyStore) Connect(context.Context) (driver.Conn, error) { return keyConn{s}, nil }
+func (s *keyStore) Driver() driver.Driver                        { return nil }
+
+type keyConn struct{ store *keyStore }
+
+func (c keyConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+func (c keyConn) Close() error              { return nil }
+func (c keyConn) Begin() (driver.Tx, error) { return nil, errors.New("transactions are not supported") }
+
+func (c keyConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if !strings.Contains(query, "FROM apikeys WHERE keyHash = $1") {
+		return nil, errors.New("unexpected query: " + query)
+	}
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	rows := &keyRows{}
+	if k, ok := c.store.keys[args[0].Value.(string)]; ok {
+		var lastUsedAt driver.Value
+		if k.LastUsedAt != nil {
+			lastUsedAt = *k.LastUsedAt
+		}
+		rows.values = [][]driver.Value{{int64(k.ID), k.Name, k.KeyPrefix, k.Role, lastUsedAt, k.CreatedAt}}
+	}
+	return rows, nil
+}
+
+func (c keyConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if !strings.HasPrefix(query, "UPDATE apikeys SET lastUsedAt") {
+		return nil, errors.New("unexpected statement: " + query)
+	}
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	usedAt, id := args[0].Value.(time.Time), args[1].Value.(int64)
+	for _, k := range c.store.keys {
+		if int64(k.ID) == id {
+			k.LastUsedAt = &usedAt
+		}
+	}
+	c.store.lastUses++
+	return driver.RowsAffected(1), nil
+}
+
+type keyRows struct {
+	values [][]driver.Value
+}
+
+func (r *keyRows) Columns() []string {
+	return []string{"id", "name", "keyprefix", "role", "lastusedat", "createdat"}
+}
+func (r *keyRows) Close() error { return nil }
+func (r *keyRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+const (
+	readKey  = "arb_readreadreadread"
+	adminKey = "arb_adminadminadmin"
+)
+
+func TestRequireRole(t *testing.T) {
+	db, _ := newKeyStore(t, map[string]string{readKey: auth.RoleRead, adminKey: auth.RoleAdmin})
+
+	tests := []struct {
+		name       string
+		publicRead bool
+		path       string
+		header     http.Header
+		want       int
+	}{
+		{"read without key", false, "/read", nil, http.StatusUnauthorized},
+		{"read with invalid key", false, "/read", http.Header{"X-Api-Key": {"arb_nope"}}, http.StatusUnauthorized},
+		{"read with read key", false, "/read", http.Header{"X-Api-Key": {readKey}}, http.StatusOK},
+		{"read with bearer token", false, "/read", http.Header{"Authorization": {"Bearer " + readKey}}, http.StatusOK},
+		{"read with admin key", false, "/read", http.Header{"X-Api-Key": {adminKey}}, http.StatusOK},
+		{"admin without key", false, "/admin", nil, http.StatusUnauthorized},
+		{"admin with read key", false, "/admin", http.Header{"X-Api-Key": {readKey}}, http.StatusForbidden},
+		{"admin with admin key", false, "/admin", http.Header{"X-Api-Key": {adminKey}}, http.StatusOK},
+
+		{"public read without key", true, "/read", nil, http.StatusOK},
+		// A key that is sent is still checked
+		{"public read with invalid key", true, "/read", http.Header{"X-Api-Key": {"arb_nope"}}, http.StatusUnauthorized},
+		{"public admin without key", true, "/admin", nil, http.StatusUnauthorized},
+		{"public admin with read key", true, "/admin", http.Header{"X-Api-Key": {readKey}}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+			router.GET("/read", requireRole(db, auth.RoleRead, tt.publicRead), ok)
+			router.GET("/admin", requireRole(db, auth.RoleAdmin, tt.publicRead), ok)
+
+			if rec := serve(router, http.MethodGet, tt.path, "192.0.2.1:1234", tt.header); rec.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestRequireRoleSetsKey(t *testing.T) {
+	db, _ := newKeyStore(t, map[string]string{adminKey: auth.RoleAdmin})
+	router := gin.New()
+	router.GET("/", requireRole(db, auth.RoleRead, false), func(c *gin.Context) {
+		c.String(http.StatusOK, "%v %v", c.GetInt(ctxKeyAPIKeyID), c.GetString(ctxKeyAPIKeyRole))
+	})
+
+	rec := serve(router, http.MethodGet, "/", "192.0.2.1:1234", http.Header{"X-Api-Key": {adminKey}})
+	if got := rec.Body.String(); got != "1 admin" {
+		t.Errorf("handler saw key %q, want \"1 admin\"", got)
+	}
+}
+
+func TestRequireRoleLookupFailure(t *testing.T) {
+	// A closed database makes the lookup fail
+	db, _ := newKeyStore(t, nil)
+	db.Close()
+	router := gin.New()
+	router.GET("/", requireRole(db, auth.RoleRead, false), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	if rec := serve(router, http.MethodGet, "/", "192.0.2.1:1234", http.Header{"X-Api-Key": {readKey}}); rec.Code != http.StatusInternalServerError {
+		t.Errorf("status %d, want 500", rec.Code)
+	}
+}
+
+func TestAuthenticateWritesLastUseOncePerMinute(t *testing.T) {
+	db, store := newKeyStore(t, map[string]string{readKey: auth.RoleRead})
+	router := newTestRouterWithDB(t, db, &config.Config{})
+
+	for i := 0; i < 5; i++ {
+		rec := serve(router, http.MethodGet, "/api/v1/exchanges/status", "192.0.2.1:1234", http.Header{"X-Api-Key": {readKey}})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i+1, rec.Code)
+		}
+	}
+	if store.lastUses != 1 {
+		t.Errorf("last use written %d times, want once", store.lastUses)
+	}
+
+	// An older last use is written again
+	store.mu.Lock()
+	for _, k := range store.keys {
+		old := time.Now().Add(-2 * time.Minute)
+		k.LastUsedAt = &old
+	}
+	store.mu.Unlock()
+	serve(router, http.MethodGet, "/api/v1/exchanges/status", "192.0.2.1:1234", http.Header{"X-Api-Key": {readKey}})
+	if store.lastUses != 2 {
+		t.Errorf("last use written %d times after a minute, want twice", store.lastUses)
+	}
+}
+
+func TestSetupRouterRoles(t *testing.T) {
+	db, _ := newKeyStore(t, map[string]string{readKey: auth.RoleRead})
+	router := newTestRouterWithDB(t, db, &config.Config{PublicRead: true})
+
+	// Public read never opens the destructive endpoints
+	if rec := serve(router, http.MethodPost, "/recreateTables", "192.0.2.1:1234", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("recreateTables without key: status %d, want 401", rec.Code)
+	}
+	if rec := serve(router, http.MethodPost, "/recreateTables", "192.0.2.1:1234", http.Header{"X-Api-Key": {readKey}}); rec.Code != http.StatusForbidden {
+		t.Errorf("recreateTables with a read key: status %d, want 403", rec.Code)
+	}
+	if rec := serve(router, http.MethodGet, "/api/v1/exchanges/status", "192.0.2.1:1234", nil); rec.Code != http.StatusOK {
+		t.Errorf("status without key: status %d, want 200", rec.Code)
+	}
+}
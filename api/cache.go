@@ -0,0 +1,209 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cache tags. Each cached endpoint belongs to one tag, and a tag is invalidated
+// when the table behind it is rewritten by a scheduled job.
+const (
+	CacheDiffs         = "diffs"
+	CacheDiffsFutures  = "diffsfutures"
+	CacheDiffsCalendar = "diffscalendar"
+	CacheDiffsInverse  = "diffsinverse"
+	CachePairs         = "pairs"
+	CachePairsFutures  = "pairsfutures"
+)
+
+// maxEntriesPerTag bounds memory use when clients send many distinct filters.
+const maxEntriesPerTag = 500
+
+type cacheEntry struct {
+	body        []byte
+	contentType string
+	etag        string
+	storedAt    time.Time
+}
+
+type tagCache struct {
+	generation uint64
+	entries    map[string]cacheEntry
+}
+
+// responseCache keeps rendered JSON responses per tag until the tag is invalidated or the TTL expires.
+type responseCache struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	tags map[string]*tagCache
+}
+
+var defaultCache = &responseCache{
+	ttl:  time.Minute,
+	tags: make(map[string]*tagCache),
+}
+
+// InvalidateCache drops cached responses for the given tags. Jobs call it after
+// they finish writing the corresponding table.
+func InvalidateCache(tags ...string) {
+	defaultCache.invalidate(tags...)
+}
+
+func (rc *responseCache) tag(name string) *tagCache {
+	t, ok := rc.tags[name]
+	if !ok {
+		t = &tagCache{entries: make(map[string]cacheEntry)}
+		rc.tags[name] = t
+	}
+	return t
+}
+
+func (rc *responseCache) invalidate(tags ...string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for _, name := range tags {
+		t := rc.tag(name)
+		t.generation++
+		t.entries = make(map[string]cacheEntry)
+	}
+}
+
+// get returns a fresh entry and the current generation of the tag.
+func (rc *responseCache) get(tag, key string) (cacheEntry, uint64, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	t := rc.tag(tag)
+	entry, ok := t.entries[key]
+	if ok && time.Since(entry.storedAt) > rc.ttl {
+		delete(t.entries, key)
+		ok = false
+	}
+	return entry, t.generation, ok
+}
+
+// set stores an entry unless the tag was invalidated since generation was read,
+// so a response computed from old data never outlives the job that replaced it.
+func (rc *responseCache) set(tag, key string, generation uint64, entry cacheEntry) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	t := rc.tag(tag)
+	if t.generation != generation {
+		return
+	}
+	if len(t.entries) >= maxEntriesPerTag {
+		t.entries = make(map[string]cacheEntry)
+	}
+	t.entries[key] = entry
+}
+
+// cacheKey normalizes the request path and query so equivalent filters share an entry:
+// parameters and list values are sorted, empty and "undefined" values are dropped.
+func cacheKey(c *gin.Context) string {
+	query := c.Request.URL.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(c.Request.URL.Path)
+	for _, name := range names {
+		var values []string
+		for _, v := range query[name] {
+			for _, part := range strings.Split(v, ",") {
+				part = strings.TrimSpace(part)
+				if part != "" && part != "undefined" {
+					values = append(values, part)
+				}
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		sort.Strings(values)
+		b.WriteString("|" + name + "=" + strings.Join(values, ","))
+	}
+	return b.String()
+}
+
+// bufferedWriter holds the handler output so it can be cached and tagged with an ETag before it is sent.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int)              { w.status = code }
+func (w *bufferedWriter) WriteHeaderNow()                   {}
+func (w *bufferedWriter) Write(b []byte) (int, error)       { return w.body.Write(b) }
+func (w *bufferedWriter) WriteString(s string) (int, error) { return w.body.WriteString(s) }
+func (w *bufferedWriter) Status() int                       { return w.status }
+func (w *bufferedWriter) Size() int                         { return w.body.Len() }
+func (w *bufferedWriter) Written() bool                     { return w.body.Len() > 0 }
+
+// writeEntry sends a cached entry, answering 304 when the client already has it.
+func writeEntry(c *gin.Context, entry cacheEntry) {
+	c.Header("ETag", entry.etag)
+	c.Header("Cache-Control", "no-cache")
+	if match := c.GetHeader("If-None-Match"); match != "" && etagMatches(match, entry.etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, entry.contentType, entry.body)
+}
+
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// cached serves GET responses from the response cache under the given tag.
+func cached(tag string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := cacheKey(c)
+		entry, generation, ok := defaultCache.get(tag, key)
+		if ok {
+			c.Header("X-Cache", "HIT")
+			writeEntry(c, entry)
+			c.Abort()
+			return
+		}
+
+		original := c.Writer
+		buffered := &bufferedWriter{ResponseWriter: original, status: http.StatusOK}
+		c.Writer = buffered
+		c.Next()
+		c.Writer = original
+
+		if buffered.status != http.StatusOK {
+			c.Data(buffered.status, original.Header().Get("Content-Type"), buffered.body.Bytes())
+			return
+		}
+
+		sum := sha256.Sum256(buffered.body.Bytes())
+		entry = cacheEntry{
+			body:        buffered.body.Bytes(),
+			contentType: original.Header().Get("Content-Type"),
+			etag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+			storedAt:    time.Now(),
+		}
+		defaultCache.set(tag, key, generation, entry)
+
+		c.Header("X-Cache", "MISS")
+		writeEntry(c, entry)
+	}
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cachedRouter serves a cached endpoint under tag that counts its handler calls.
+// The tag starts empty and entries live for a minute.
+func cachedRouter(t *testing.T, tag string, status int) (*gin.Engine, *int) {
+	t.Helper()
+	defaultCache.mu.Lock()
+	ttl := defaultCache.ttl
+	defaultCache.ttl = time.Minute
+	defaultCache.mu.Unlock()
+	t.Cleanup(func() {
+		defaultCache.mu.Lock()
+		defaultCache.ttl = ttl
+		defaultCache.mu.Unlock()
+	})
+	InvalidateCache(tag)
+
+	calls := 0
+	router := gin.New()
+	router.GET("/items", cached(tag), func(c *gin.Context) {
+		calls++
+		c.JSON(status, gin.H{"calls": calls})
+	})
+	return router, &calls
+}
+
+func TestCachedInvalidate(t *testing.T) {
+	tag := t.Name()
+	router, calls := cachedRouter(t, tag, http.StatusOK)
+
+	steps := []struct {
+		invalidate bool
+		wantCache  string
+		wantCalls  int
+	}{
+		{false, "MISS", 1},
+		{false, "HIT", 1},
+		{false, "HIT", 1},
+		// A job rewrote the table behind the tag
+		{true, "MISS", 2},
+		{false, "HIT", 2},
+	}
+	for i, step := range steps {
+		if step.invalidate {
+			InvalidateCache(tag)
+		}
+		rec := serve(router, http.MethodGet, "/items", "192.0.2.1:1234", nil)
+		if rec.Code != http.StatusOK || rec.Header().Get("X-Cache") != step.wantCache || *calls != step.wantCalls {
+			t.Errorf("request %d: status %d, X-Cache %q, %d handler calls, want 200, %q, %d",
+				i+1, rec.Code, rec.Header().Get("X-Cache"), *calls, step.wantCache, step.wantCalls)
+		}
+	}
+
+	// Other tags are not affected
+	InvalidateCache(tag + " other")
+	if rec := serve(router, http.MethodGet, "/items", "192.0.2.1:1234", nil); rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("X-Cache %q after invalidating another tag, want HIT", rec.Header().Get("X-Cache"))
+	}
+}
+
+func TestCacheSetAfterInvalidate(t *testing.T) {
+	rc := &responseCache{ttl: time.Minute, tags: make(map[string]*tagCache)}
+	_, generation, _ := rc.get("pairs", "/pairs")
+
+	// The job finished while the response was being computed from the old data
+	rc.invalidate("pairs")
+	rc.set("pairs", "/pairs", generation, cacheEntry{body: []byte("old"), storedAt: time.Now()})
+	if _, _, ok := rc.get("pairs", "/pairs"); ok {
+		t.Error("response from before the invalidation was cached")
+	}
+
+	_, generation, _ = rc.get("pairs", "/pairs")
+	rc.set("pairs", "/pairs", generation, cacheEntry{body: []byte("new"), storedAt: time.Now()})
+	if entry, _, ok := rc.get("pairs", "/pairs"); !ok || string(entry.body) != "new" {
+		t.Errorf("entry = %q, %v, want the new response", entry.body, ok)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	rc := &responseCache{ttl: time.Minute, tags: make(map[string]*tagCache)}
+	rc.set("pairs", "/pairs", 0, cacheEntry{storedAt: time.Now().Add(-2 * time.Minute)})
+	if _, _, ok := rc.get("pairs", "/pairs"); ok {
+		t.Error("expired entry was served")
+	}
+}
+
+func TestCachedNotModified(t *testing.T) {
+	router, calls := cachedRouter(t, t.Name(), http.StatusOK)
+
+	first := serve(router, http.MethodGet, "/items", "192.0.2.1:1234", nil)
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag on the response")
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		want        int
+	}{
+		{"same etag", etag, http.StatusNotModified},
+		{"weak etag", "W/" + etag, http.StatusNotModified},
+		{"etag in a list", `"other", ` + etag, http.StatusNotModified},
+		{"any", "*", http.StatusNotModified},
+		{"other etag", `"other"`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(router, http.MethodGet, "/items", "192.0.2.1:1234", http.Header{"If-None-Match": {tt.ifNoneMatch}})
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d", rec.Code, tt.want)
+			}
+			if rec.Header().Get("ETag") != etag {
+				t.Errorf("ETag %q, want %q", rec.Header().Get("ETag"), etag)
+			}
+			if tt.want == http.StatusNotModified && rec.Body.Len() != 0 {
+				t.Errorf("304 with body %q", rec.Body)
+			}
+		})
+	}
+	if *calls != 1 {
+		t.Errorf("handler called %d times, want once", *calls)
+	}
+}
+
+func TestCachedSkipsErrors(t *testing.T) {
+	router, calls := cachedRouter(t, t.Name(), http.StatusInternalServerError)
+	for i := 0; i < 2; i++ {
+		rec := serve(router, http.MethodGet, "/items", "192.0.2.1:1234", nil)
+		if rec.Code != http.StatusInternalServerError || rec.Header().Get("ETag") != "" {
+			t.Errorf("request %d: status %d with ETag %q, want 500 without ETag", i+1, rec.Code, rec.Header().Get("ETag"))
+		}
+	}
+	if *calls != 2 {
+		t.Errorf("handler called %d times, want every time", *calls)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"/diffs?exchange=A,B&symbol=BTC", "/diffs?symbol=BTC&exchange=B&exchange=A", true},
+		{"/diffs?exchange=A&symbol=", "/diffs?exchange=A", true},
+		{"/diffs?exchange=A&symbol=undefined", "/diffs?exchange=A", true},
+		{"/diffs?exchange=A, B", "/diffs?exchange=B,A", true},
+		{"/diffs?exchange=A", "/diffs?exchange=B", false},
+		{"/diffs?exchange=A", "/pairs?exchange=A", false},
+	}
+	for _, tt := range tests {
+		if same := keyOf(tt.a) == keyOf(tt.b); same != tt.same {
+			t.Errorf("cacheKey(%s) == cacheKey(%s) is %v, want %v (%q, %q)", tt.a, tt.b, same, tt.same, keyOf(tt.a), keyOf(tt.b))
+		}
+	}
+}
+
+func keyOf(target string) string {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, strings.ReplaceAll(target, " ", "%20"), nil)
+	return cacheKey(c)
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerDatedFuturesRoutes adds the calendar spreads and the term structure
+// of the dated futures, and the inverse against linear perpetual diffs.
+func registerDatedFuturesRoutes(read *gin.RouterGroup, db *sql.DB) {
+	read.GET("/diffsCalendar", cached(CacheDiffsCalendar), func(c *gin.Context) {
+		var filter sqlFilter
+		if exchanges := splitList(c.Query("exchanges")); len(exchanges) > 0 {
+			filter.in("nearExchange", exchanges)
+			filter.in("farExchange", exchanges)
+		}
+		if coins := c.QueryArray("coins"); len(coins) > 0 && coins[0] != "" {
+			filter.in("baseAsset", coins)
+		}
+		if c.Query("sameExchange") == "true" {
+			filter.where("nearExchange = farExchange")
+		}
+		filter.where("nearVolume <> 0")
+		filter.where("farVolume <> 0")
+
+		query := "SELECT * FROM diffscalendar" + filter.String() + " ORDER BY annualizedSpreadPercentage DESC"
+		// Як і в /diffsFutures: 500 рядків за замовчуванням, all без обмеження
+		if topRows := c.Query("topRows"); strings.ToLower(topRows) != "all" {
+			limit, err := strconv.Atoi(topRows)
+			if err != nil || limit <= 0 {
+				limit = 500
+			}
+			query += " LIMIT " + strconv.Itoa(limit)
+		}
+
+		queryMaps(c, db, query, filter.args...)
+	})
+
+	read.GET("/termStructure", cached(CachePairsFutures), func(c *gin.Context) {
+		var filter sqlFilter
+		if exchanges := splitList(c.Query("exchanges")); len(exchanges) > 0 {
+			filter.in("exchange", exchanges)
+		}
+		if coins := c.QueryArray("coins"); len(coins) > 0 && coins[0] != "" {
+			filter.in("baseAsset", coins)
+		}
+
+		queryMaps(c, db, "SELECT * FROM termstructure"+filter.String()+" ORDER BY baseAsset, expiry, exchange", filter.args...)
+	})
+
+	read.GET("/diffsInverse", cached(CacheDiffsInverse), func(c *gin.Context) {
+		var filter sqlFilter
+		if exchanges := splitList(c.Query("exchanges")); len(exchanges) > 0 {
+			filter.in("inverseExchange", exchanges)
+			filter.in("linearExchange", exchanges)
+		}
+		if coins := c.QueryArray("coins"); len(coins) > 0 && coins[0] != "" {
+			filter.in("baseAsset", coins)
+		}
+		filter.where("inverseVolume <> 0")
+		filter.where("linearVolume <> 0")
+
+		query := "SELECT * FROM diffsinverse" + filter.String() + " ORDER BY ABS(differenceMarkPercentage) DESC"
+		if topRows := c.Query("topRows"); strings.ToLower(topRows) != "all" {
+			limit, err := strconv.Atoi(topRows)
+			if err != nil || limit <= 0 {
+				limit = 500
+			}
+			query += " LIMIT " + strconv.Itoa(limit)
+		}
+
+		queryMaps(c, db, query, filter.args...)
+	})
+}
+
+// sqlFilter builds a WHERE clause with numbered placeholders.
+type sqlFilter struct {
+	conditions []string
+	args       []any
+}
+
+func (f *sqlFilter) where(condition string) {
+	f.conditions = append(f.conditions, condition)
+}
+
+func (f *sqlFilter) in(column string, values []string) {
+	placeholders := make([]string, len(values))
+	for i, v := range values {
+		f.args = append(f.args, v)
+		placeholders[i] = "$" + strconv.Itoa(len(f.args))
+	}
+	f.where(column + " IN (" + strings.Join(placeholders, ", ") + ")")
+}
+
+func (f *sqlFilter) String() string {
+	if len(f.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(f.conditions, " AND ")
+}
+
+func splitList(param string) []string {
+	if param == "" {
+		return nil
+	}
+	return strings.Split(param, ",")
+}
+
+// queryMaps responds with the rows of query as JSON objects keyed by column,
+// with numeric columns as numbers.
+func queryMaps(c *gin.Context, db *sql.DB, query string, args ...any) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch data", "details": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	results := []map[string]interface{}{}
+	cols, _ := rows.Columns()
+	for rows.Next() {
+		values := make([]interface{}, len(cols))
+		valuePtrs := make([]interface{}, len(cols))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch data", "details": err.Error()})
+			return
+		}
+
+		rowMap := make(map[string]interface{})
+		for i, col := range cols {
+			switch v := values[i].(type) {
+			case []byte:
+				strVal := string(v)
+				if numVal, err := strconv.ParseFloat(strVal, 64); err == nil {
+					rowMap[col] = numVal
+				} else {
+					rowMap[col] = strVal
+				}
+			default:
+				rowMap[col] = v
+			}
+		}
+		results = append(results, rowMap)
+	}
+	if err := rows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch data", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
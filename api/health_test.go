@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"Updater/config"
+	"Updater/health"
+)
+
+func TestHealth(t *testing.T) {
+	db, _ := newKeyStore(t, nil)
+	// Spot data is outdated as soon as it is written, futures never
+	tracker := health.NewTracker(func(job string) time.Duration {
+		if job == health.JobSpot {
+			return time.Nanosecond
+		}
+		return 0
+	})
+	router, err := SetupRouter(db, &config.Config{PublicRead: true}, nil, tracker)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type response struct {
+		Status string   `json:"status"`
+		DB     string   `json:"db"`
+		Stale  []string `json:"stale"`
+	}
+	get := func() response {
+		t.Helper()
+		rec := serve(router, http.MethodGet, "/api/health", "192.0.2.1:1234", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d, want 200", rec.Code)
+		}
+		var body response
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+
+	tracker.Register("Alpha", health.JobFutures)
+	if got, want := get(), (response{Status: "healthy", DB: "connected"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("health = %+v, want %+v", got, want)
+	}
+
+	tracker.Register("Beta", health.JobSpot)
+	tracker.Register("Alpha", health.JobSpot)
+	time.Sleep(time.Millisecond)
+	want := response{Status: "degraded", DB: "connected", Stale: []string{"Alpha spot", "Beta spot"}}
+	if got := get(); !reflect.DeepEqual(got, want) {
+		t.Errorf("health = %+v, want %+v", got, want)
+	}
+
+	// The status endpoint reports every job with its own classification
+	rec := serve(router, http.MethodGet, "/api/v1/exchanges/status", "192.0.2.1:1234", nil)
+	var statuses []health.JobStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &statuses); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, st := range statuses {
+		if st.Stale {
+			got = append(got, st.Exchange+" "+st.Job+" stale")
+		} else {
+			got = append(got, st.Exchange+" "+st.Job)
+		}
+	}
+	if want := []string{"Alpha futures", "Alpha spot stale", "Beta spot stale"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+}
+
+func TestHealthDatabaseDown(t *testing.T) {
+	db, _ := newKeyStore(t, nil)
+	db.Close()
+	router := newTestRouterWithDB(t, db, &config.Config{})
+
+	rec := serve(router, http.MethodGet, "/api/health", "192.0.2.1:1234", nil)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status %d, want 503", rec.Code)
+	}
+}
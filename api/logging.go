@@ -0,0 +1,40 @@
+package api
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestLogger logs every request once it is handled. Server errors are
+// logged as errors, client errors as warnings and the rest at debug level so
+// the default info level is not flooded by polling clients.
+func requestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelDebug
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		args := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"query", c.Request.URL.RawQuery,
+			"status", status,
+			"duration", time.Since(start),
+			"client", c.ClientIP(),
+		}
+		if len(c.Errors) > 0 {
+			args = append(args, "error", c.Errors.String())
+		}
+		slog.Log(c.Request.Context(), level, "api request", args...)
+	}
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"strconv"
+	"time"
+
+	"Updater/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestMetrics records the latency of every request by route template, so
+// /api/v1/alerts/rules/:id is one series no matter the id.
+func requestMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.APIRequestDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
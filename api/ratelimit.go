@@ -0,0 +1,124 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bucketIdleTTL is how long an unused client bucket is kept before it is swept.
+const bucketIdleTTL = 10 * time.Minute
+
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// rateLimiter is a token bucket limiter keyed by client (API key or IP).
+type rateLimiter struct {
+	mu        sync.Mutex
+	rate      float64 // tokens added per second
+	burst     float64 // bucket capacity
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow takes one token for client. When the bucket is empty it returns false
+// and how long the client has to wait for the next token.
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > bucketIdleTTL {
+		for k, b := range l.buckets {
+			if now.Sub(b.lastSeen) > bucketIdleTTL {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, lastSeen: now}
+		l.buckets[client] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.lastSeen).Seconds()*l.rate)
+	b.lastSeen = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// rateLimitIP limits requests per client IP. It runs before requireRole, so
+// requests with a missing or invalid key are limited before the key lookup and
+// keys cannot be guessed faster than the limit.
+// A non-positive rate disables limiting.
+func rateLimitIP(rate float64, burst int) gin.HandlerFunc {
+	return limitClients(rate, burst, func(c *gin.Context) (string, bool) {
+		return "ip:" + c.ClientIP(), true
+	})
+}
+
+// rateLimit limits requests per API key, so a key shared by several hosts
+// gets one budget. It must run after requireRole so the key id is known;
+// anonymous requests are only limited by rateLimitIP.
+// A non-positive rate disables limiting.
+func rateLimit(rate float64, burst int) gin.HandlerFunc {
+	return limitClients(rate, burst, func(c *gin.Context) (string, bool) {
+		id, ok := c.Get(ctxKeyAPIKeyID)
+		return fmt.Sprintf("key:%v", id), ok
+	})
+}
+
+// limitClients answers 429 with Retry-After when the bucket of the request's
+// client is empty. Requests for which client reports false are not limited.
+func limitClients(rate float64, burst int, client func(c *gin.Context) (string, bool)) gin.HandlerFunc {
+	if rate <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+	limiter := newRateLimiter(rate, burst)
+
+	return func(c *gin.Context) {
+		id, ok := client(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		allowed, wait := limiter.allow(id, time.Now())
+		if !allowed {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded", "retryAfter": retryAfter})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"Updater/auth"
+	"Updater/config"
+	"Updater/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestRouter sets up the API without a database, for requests that never
+// reach it.
+func newTestRouter(t *testing.T, cfg *config.Config) *gin.Engine {
+	t.Helper()
+	return newTestRouterWithDB(t, nil, cfg)
+}
+
+// newTestRouterWithDB sets up the API with a tracker and no alert engine.
+func newTestRouterWithDB(t *testing.T, db *sql.DB, cfg *config.Config) *gin.Engine {
+	t.Helper()
+	tracker := health.NewTracker(func(string) time.Duration { return 0 })
+	router, err := SetupRouter(db, cfg, nil, tracker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+// serve sends a request from remoteAddr with the given headers.
+func serve(router http.Handler, method, target, remoteAddr string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.RemoteAddr = remoteAddr
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	router := newTestRouter(t, &config.Config{PublicRead: true, RateLimit: 0.001, RateLimitBurst: 1})
+
+	for i, forwarded := range []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"} {
+		rec := serve(router, http.MethodGet, "/api/v1/exchanges/status", "192.0.2.10:40000",
+			http.Header{"X-Forwarded-For": {forwarded}, "X-Real-Ip": {forwarded}})
+		want := http.StatusOK
+		if i > 0 {
+			want = http.StatusTooManyRequests
+		}
+		if rec.Code != want {
+			t.Errorf("request %d with X-Forwarded-For %s: status %d, want %d", i+1, forwarded, rec.Code, want)
+		}
+	}
+}
+
+func TestRateLimitTrustedProxy(t *testing.T) {
+	router := newTestRouter(t, &config.Config{
+		PublicRead: true, RateLimit: 0.001, RateLimitBurst: 1,
+		TrustedProxies: []string{"192.0.2.0/24"},
+	})
+
+	// Behind a trusted proxy every forwarded client has its own bucket
+	for _, forwarded := range []string{"198.51.100.1", "198.51.100.2"} {
+		rec := serve(router, http.MethodGet, "/api/v1/exchanges/status", "192.0.2.10:40000",
+			http.Header{"X-Forwarded-For": {forwarded}})
+		if rec.Code != http.StatusOK {
+			t.Errorf("client %s: status %d, want 200", forwarded, rec.Code)
+		}
+	}
+	rec := serve(router, http.MethodGet, "/api/v1/exchanges/status", "192.0.2.10:40000",
+		http.Header{"X-Forwarded-For": {"198.51.100.1"}})
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("second request of 198.51.100.1: status %d, want 429", rec.Code)
+	}
+}
+
+func TestSetupRouterInvalidTrustedProxies(t *testing.T) {
+	tracker := health.NewTracker(func(string) time.Duration { return 0 })
+	if _, err := SetupRouter(nil, &config.Config{TrustedProxies: []string{"not an ip"}}, nil, tracker); err == nil {
+		t.Error("want an error for an invalid trusted proxy")
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter(0.5, 2)
+
+	steps := []struct {
+		client   string
+		after    time.Duration
+		wantOK   bool
+		wantWait time.Duration
+	}{
+		{"a", 0, true, 0},
+		{"a", 0, true, 0},
+		{"a", 0, false, 2 * time.Second},
+		// Each client has its own bucket
+		{"b", 0, true, 0},
+		{"a", time.Second, false, time.Second},
+		{"a", 2 * time.Second, true, 0},
+		// The bucket never holds more than the burst
+		{"a", time.Hour, true, 0},
+		{"a", time.Hour, true, 0},
+		{"a", time.Hour, false, 2 * time.Second},
+	}
+	for i, step := range steps {
+		ok, wait := limiter.allow(step.client, start.Add(step.after))
+		if ok != step.wantOK || wait != step.wantWait {
+			t.Errorf("step %d: allow(%s) = %v, %v, want %v, %v", i+1, step.client, ok, wait, step.wantOK, step.wantWait)
+		}
+	}
+}
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	router := gin.New()
+	router.GET("/", rateLimitIP(0.25, 1), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	if rec := serve(router, http.MethodGet, "/", "192.0.2.1:1234", nil); rec.Code != http.StatusOK {
+		t.Fatalf("first request: status %d, want 200", rec.Code)
+	}
+	rec := serve(router, http.MethodGet, "/", "192.0.2.1:1234", nil)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: status %d, want 429", rec.Code)
+	}
+	// One token every 4 seconds, rounded up to whole seconds
+	if got := rec.Header().Get("Retry-After"); got != "4" {
+		t.Errorf("Retry-After = %q, want 4", got)
+	}
+	var body struct {
+		RetryAfter int `json:"retryAfter"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.RetryAfter != 4 {
+		t.Errorf("body %s, want retryAfter 4", rec.Body)
+	}
+
+	// Another client is not limited
+	if rec := serve(router, http.MethodGet, "/", "192.0.2.2:1234", nil); rec.Code != http.StatusOK {
+		t.Errorf("other client: status %d, want 200", rec.Code)
+	}
+}
+
+func TestRateLimitPerKey(t *testing.T) {
+	db, _ := newKeyStore(t, map[string]string{readKey: auth.RoleRead})
+	router := newTestRouterWithDB(t, db, &config.Config{RateLimit: 0.001, RateLimitBurst: 1})
+	key := http.Header{"X-Api-Key": {readKey}}
+
+	// A key shared by several hosts has one budget
+	if rec := serve(router, http.MethodGet, "/api/v1/exchanges/status", "192.0.2.1:1234", key); rec.Code != http.StatusOK {
+		t.Fatalf("first host: status %d, want 200", rec.Code)
+	}
+	rec := serve(router, http.MethodGet, "/api/v1/exchanges/status", "192.0.2.2:1234", key)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("second host: status %d with Retry-After %q, want 429 with a Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	router := gin.New()
+	router.GET("/", rateLimitIP(0, 1), func(c *gin.Context) { c.Status(http.StatusOK) })
+	for i := 0; i < 10; i++ {
+		if rec := serve(router, http.MethodGet, "/", "192.0.2.1:1234", nil); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d, want 200", i+1, rec.Code)
+		}
+	}
+}
@@ -3,27 +3,47 @@ package api
 import (
 	"database/sql"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"time"
+
+	"Updater/alerts"
+	"Updater/auth"
+	"Updater/config"
+	"Updater/health"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // SetupRouter створює маршрути API
-func SetupRouter(db *sql.DB) *gin.Engine {
-	router := gin.Default()
+func SetupRouter(db *sql.DB, cfg *config.Config, alertEngine *alerts.Engine, tracker *health.Tracker) (*gin.Engine, error) {
+	router := gin.New()
+	// Client IPs come from forwarding headers only behind a configured proxy,
+	// otherwise every request could pick its own IP and skip the IP limiter
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	router.Use(gin.Recovery(), requestLogger(), requestMetrics())
 
 	// Додаємо CORS middleware
-	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-	}))
+	if corsHandler := corsMiddleware(cfg); corsHandler != nil {
+		router.Use(corsHandler)
+	}
+
+	// Read endpoints need at least a read key (unless public read is enabled),
+	// destructive endpoints always need an admin key. Requests are limited per IP
+	// before the key is looked up and per key after it.
+	ipLimiter := rateLimitIP(cfg.RateLimit, cfg.RateLimitBurst)
+	keyLimiter := rateLimit(cfg.RateLimit, cfg.RateLimitBurst)
+	read := router.Group("/", ipLimiter, requireRole(db, auth.RoleRead, cfg.PublicRead), keyLimiter)
+	admin := router.Group("/", ipLimiter, requireRole(db, auth.RoleAdmin, cfg.PublicRead), keyLimiter)
+
+	// Responses are cached until the job that rewrites the table invalidates them
+	defaultCache.ttl = cfg.CacheTTL
 
 	healthHandler := func(c *gin.Context) {
 		err := db.Ping()
@@ -31,6 +51,17 @@ func SetupRouter(db *sql.DB) *gin.Engine {
 			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unhealthy", "db": "disconnected"})
 			return
 		}
+
+		// Degraded still answers 200: the API works, but some exchange data is outdated
+		stale := tracker.Stale(time.Now())
+		if len(stale) > 0 {
+			staleJobs := make([]string, 0, len(stale))
+			for _, st := range stale {
+				staleJobs = append(staleJobs, st.Exchange+" "+st.Job)
+			}
+			c.JSON(http.StatusOK, gin.H{"status": "degraded", "db": "connected", "stale": staleJobs})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{"status": "healthy", "db": "connected"})
 	}
 
@@ -44,7 +75,14 @@ func SetupRouter(db *sql.DB) *gin.Engine {
 	router.GET("/api/health", healthHandler)
 	router.HEAD("/api/health", healthHandler)
 
-	router.GET("/diffs", func(c *gin.Context) {
+	// Prometheus scrapes with a read key (bearer token) unless public read is enabled
+	read.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	read.GET("/api/v1/exchanges/status", func(c *gin.Context) {
+		c.JSON(http.StatusOK, tracker.Snapshot(time.Now()))
+	})
+
+	read.GET("/diffs", cached(CacheDiffs), func(c *gin.Context) {
 		// Отримуємо параметри запиту
 		topRows := c.Query("topRows") // Якщо 0, то 500 за замовчуванням
 		exchangesParam := c.DefaultQuery("exchanges", "")
@@ -56,14 +94,6 @@ func SetupRouter(db *sql.DB) *gin.Engine {
 		maxLifeTime := c.Query("maxLifeTime")
 		minLifeTime := c.Query("minLifeTime")
 
-		// fmt.Println("params - topRows:", topRows)
-		// fmt.Println("params - exchanges:", exchanges)
-		// fmt.Println("params - maxDiffPerc:", maxDiffPerc)
-		// fmt.Println("params - minDiffPerc:", minDiffPerc)
-		// fmt.Println("params - symbols:", symbols)
-		// fmt.Println("params - maxLifeTime:", maxLifeTime)
-		// fmt.Println("params - minLifeTime:", minLifeTime)
-
 		// Формуємо динамічний SQL-запит
 		query := "SELECT * FROM diffs WHERE 1=1"
 
@@ -124,8 +154,7 @@ func SetupRouter(db *sql.DB) *gin.Engine {
 			}
 		}
 
-		// Виводимо фінальний SQL-запит у консоль
-		fmt.Println("Final SQL Query:", query)
+		slog.Debug("diffs query", "query", query)
 
 		// Виконуємо запит до бази
 		rows, err := db.Query(query)
@@ -168,7 +197,7 @@ func SetupRouter(db *sql.DB) *gin.Engine {
 		c.JSON(http.StatusOK, results)
 	})
 
-	router.GET("/diffsFutures", func(c *gin.Context) {
+	read.GET("/diffsFutures", cached(CacheDiffsFutures), func(c *gin.Context) {
 		// Отримуємо параметри запиту
 		topRows := c.Query("topRows") // Якщо 0, то 500 за замовчуванням
 		exchangesParam := c.DefaultQuery("exchanges", "")
@@ -221,8 +250,7 @@ func SetupRouter(db *sql.DB) *gin.Engine {
 			}
 		}
 
-		// Виводимо фінальний SQL-запит у консоль
-		fmt.Println("Final SQL Query:", query)
+		slog.Debug("diffs futures query", "query", query)
 
 		// Виконуємо запит до бази
 		rows, err := db.Query(query)
@@ -265,7 +293,7 @@ func SetupRouter(db *sql.DB) *gin.Engine {
 		c.JSON(http.StatusOK, results)
 	})
 
-	router.GET("/pairs", func(c *gin.Context) {
+	read.GET("/pairs", cached(CachePairs), func(c *gin.Context) {
 		// Виконуємо запит до бази для отримання унікальних символів
 		symbolsQuery := "SELECT DISTINCT symbol FROM Pairs"
 		symbolsRows, err := db.Query(symbolsQuery)
@@ -331,7 +359,7 @@ func SetupRouter(db *sql.DB) *gin.Engine {
 		})
 	})
 
-	router.GET("/pairsFutures", func(c *gin.Context) {
+	read.GET("/pairsFutures", cached(CachePairsFutures), func(c *gin.Context) {
 		// Виконуємо запит до бази для отримання унікальних символів
 		symbolsQuery := "SELECT DISTINCT symbol FROM pairsfutures"
 		symbolsRows, err := db.Query(symbolsQuery)
@@ -403,27 +431,29 @@ func SetupRouter(db *sql.DB) *gin.Engine {
 		})
 	})
 
-	router.POST("/recreateTables", func(c *gin.Context) {
-		fmt.Println("--- Post delete run")
-		err := executeSQLFromFile(db, "db/queries/recreateTables.sql")
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recreate tables", "details": err.Error()})
-			return
+	admin.POST("/recreateTables", func(c *gin.Context) {
+		// The term structure view is defined by the migration and depends on the new tables
+		for _, file := range []string{"db/queries/recreateTables.sql", "db/queries/migrateDatedFutures.sql"} {
+			if err := executeSQLFromFile(db, file); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recreate tables", "details": err.Error()})
+				return
+			}
 		}
+		InvalidateCache(CacheDiffs, CacheDiffsFutures, CacheDiffsCalendar, CacheDiffsInverse, CachePairs, CachePairsFutures)
 		c.JSON(http.StatusOK, gin.H{"message": "Tables recreated successfully"})
 	})
 
-	return router
+	registerDatedFuturesRoutes(read, db)
+	registerAlertRoutes(read, admin, db, alertEngine)
+
+	return router, nil
 }
 
 func executeSQLFromFile(db *sql.DB, filePath string) error {
-	fmt.Println("--- Post delete executeSQLFromFile run")
-
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to read SQL file: %w", err)
 	}
-	fmt.Printf("--- Content SQL file length: %d\n", len(content))
 
 	_, err = db.Exec(string(content))
 	if err != nil {
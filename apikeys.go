@@ -0,0 +1,87 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"Updater/auth"
+)
+
+const apiKeysUsage = `Usage:
+  arbToolDBUpdater apikeys create -name <name> [-role read|admin]
+  arbToolDBUpdater apikeys revoke <id>
+  arbToolDBUpdater apikeys list`
+
+// runAPIKeysCommand handles the "apikeys" management subcommand and returns the process exit code.
+func runAPIKeysCommand(dbConn *sql.DB, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, apiKeysUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("apikeys create", flag.ContinueOnError)
+		name := fs.String("name", "", "human readable name of the key owner")
+		role := fs.String("role", auth.RoleRead, "key role: read or admin")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+
+		raw, key, err := auth.CreateKey(dbConn, *name, *role)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating API key: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Created %s key #%d (%s)\n", key.Role, key.ID, key.Name)
+		fmt.Printf("Key: %s\n", raw)
+		fmt.Println("Store it now, it cannot be shown again.")
+		return 0
+
+	case "revoke":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, apiKeysUsage)
+			return 2
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid key id %q\n", args[1])
+			return 2
+		}
+		if err := auth.RevokeKey(dbConn, id); err != nil {
+			fmt.Fprintf(os.Stderr, "Error revoking API key #%d: %v\n", id, err)
+			return 1
+		}
+		fmt.Printf("Revoked key #%d\n", id)
+		return 0
+
+	case "list":
+		keys, err := auth.ListKeys(dbConn)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing API keys: %v\n", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tPREFIX\tROLE\tLAST USED\tREVOKED")
+		for _, k := range keys {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", k.ID, k.Name, k.KeyPrefix, k.Role, formatOptionalTime(k.LastUsedAt), formatOptionalTime(k.RevokedAt))
+		}
+		w.Flush()
+		return 0
+	}
+
+	fmt.Fprintln(os.Stderr, apiKeysUsage)
+	return 2
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
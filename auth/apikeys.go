@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"Updater/models"
+)
+
+// Roles supported by the API. Admin keys can do everything read keys can.
+const (
+	RoleRead  = "read"
+	RoleAdmin = "admin"
+)
+
+const (
+	keyPrefix    = "arb_"
+	keyRandBytes = 24
+	prefixLength = 12
+
+	// lastUsedResolution bounds how often a key's last use is written, so
+	// authenticated reads do not each cost a write.
+	lastUsedResolution = time.Minute
+)
+
+// ErrKeyNotFound is returned when a key does not exist or is already revoked.
+var ErrKeyNotFound = errors.New("api key not found")
+
+// ValidRole reports whether role is one of the supported roles.
+func ValidRole(role string) bool {
+	return role == RoleRead || role == RoleAdmin
+}
+
+// Allows reports whether a key with the given role may access an endpoint that requires required.
+func Allows(role, required string) bool {
+	if role == RoleAdmin {
+		return true
+	}
+	return role == required
+}
+
+// GenerateKey returns a new random raw API key.
+func GenerateKey() (string, error) {
+	buf := make([]byte, keyRandBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error generating api key: %w", err)
+	}
+	return keyPrefix + hex.EncodeToString(buf), nil
+}
+
+// HashKey returns the hex encoded SHA-256 hash of a raw key. Keys are long random
+// strings, so a fast hash is enough and lets us look them up by hash directly.
+func HashKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateKey generates and stores a new key. The raw key is returned once and is not recoverable afterwards.
+func CreateKey(db *sql.DB, name, role string) (string, models.APIKey, error) {
+	if name == "" {
+		return "", models.APIKey{}, errors.New("api key name is required")
+	}
+	if !ValidRole(role) {
+		return "", models.APIKey{}, fmt.Errorf("invalid role %q (expected %q or %q)", role, RoleRead, RoleAdmin)
+	}
+
+	raw, err := GenerateKey()
+	if err != nil {
+		return "", models.APIKey{}, err
+	}
+
+	key := models.APIKey{
+		Name:      name,
+		KeyPrefix: raw[:prefixLength],
+		Role:      role,
+	}
+	err = db.QueryRow(
+		`INSERT INTO apikeys (name, keyPrefix, keyHash, role) VALUES ($1, $2, $3, $4) RETURNING id, createdAt`,
+		key.Name, key.KeyPrefix, HashKey(raw), key.Role,
+	).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return "", models.APIKey{}, fmt.Errorf("error storing api key: %w", err)
+	}
+
+	return raw, key, nil
+}
+
+// RevokeKey marks a key as revoked. Revoked keys are kept for auditing.
+func RevokeKey(db *sql.DB, id int) error {
+	res, err := db.Exec(`UPDATE apikeys SET revokedAt = $1 WHERE id = $2 AND revokedAt IS NULL`, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("error revoking api key: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error revoking api key: %w", err)
+	}
+	if n == 0 {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+// ListKeys returns all keys, including revoked ones, ordered by id.
+func ListKeys(db *sql.DB) ([]models.APIKey, error) {
+	rows, err := db.Query(`SELECT id, name, keyPrefix, role, lastUsedAt, revokedAt, createdAt FROM apikeys ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		var k models.APIKey
+		if err := rows.Scan(&k.ID, &k.Name, &k.KeyPrefix, &k.Role, &k.LastUsedAt, &k.RevokedAt, &k.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning api key: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// Authenticate resolves a raw key to its stored record and updates its last
+// used time when the stored one is older than lastUsedResolution.
+func Authenticate(db *sql.DB, raw string) (models.APIKey, error) {
+	var k models.APIKey
+	err := db.QueryRow(
+		`SELECT id, name, keyPrefix, role, lastUsedAt, createdAt FROM apikeys WHERE keyHash = $1 AND revokedAt IS NULL`,
+		HashKey(raw),
+	).Scan(&k.ID, &k.Name, &k.KeyPrefix, &k.Role, &k.LastUsedAt, &k.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, ErrKeyNotFound
+	}
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("error looking up api key: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= lastUsedResolution {
+		if _, err := db.Exec(`UPDATE apikeys SET lastUsedAt = $1 WHERE id = $2`, now, k.ID); err != nil {
+			return models.APIKey{}, fmt.Errorf("error updating api key last use: %w", err)
+		}
+		k.LastUsedAt = &now
+	}
+	return k, nil
+}
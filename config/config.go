@@ -1,16 +1,87 @@
 package config
 
 import (
-	"log"
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"Updater/exchanges/signing"
 
 	"github.com/joho/godotenv"
 )
 
+// signedExchanges are the exchanges whose network data comes from signed endpoints.
+var signedExchanges = []string{"Backpack", "Binance", "Bybit", "MEXC", "OKX"}
+
 // Config holds application configuration values.
 type Config struct {
 	DatabaseURL string
 	APIPort     string
+
+	// CORSAllowedOrigins lists origins allowed to call the API from a browser.
+	// Empty disables CORS headers entirely, "*" allows any origin without credentials.
+	CORSAllowedOrigins []string
+	// PublicRead exposes read-only endpoints without an API key.
+	PublicRead bool
+	// TrustedProxies are the addresses or CIDRs of reverse proxies whose X-Forwarded-For
+	// and X-Real-IP headers give the client IP. Empty trusts none and uses the peer address.
+	TrustedProxies []string
+
+	// RateLimit is the sustained number of requests per second allowed per client, 0 disables limiting.
+	RateLimit float64
+	// RateLimitBurst is how many requests a client can send at once before being limited.
+	RateLimitBurst int
+	// CacheTTL is the upper bound for how long an API response is cached between job runs.
+	CacheTTL time.Duration
+
+	// ExchangeCredentials are the API keys of the exchanges in signedExchanges, read from
+	// API_KEY_<EXCHANGE>, API_SECRET_<EXCHANGE> and API_PASSPHRASE_<EXCHANGE>.
+	ExchangeCredentials map[string]signing.Credentials
+
+	// AlertsFile is the path to a JSON file that seeds alert rules and channels on startup, empty skips seeding.
+	AlertsFile string
+
+	// StaleAfter is how old spot and futures data of an exchange may get before health reports degraded.
+	StaleAfter time.Duration
+	// NetworksStaleAfter is the same threshold for network data, which is refreshed less often.
+	NetworksStaleAfter time.Duration
+
+	// PairsStaleAfter marks a market stale when it was not updated for this long.
+	PairsStaleAfter time.Duration
+	// PairsDelistAfter marks a market delisted when its exchange kept updating without it for this long.
+	PairsDelistAfter time.Duration
+	// PairsDelistedRetention is how long delisted markets stay in the database, 0 keeps them.
+	PairsDelistedRetention time.Duration
+
+	// SchedulerFile is the YAML or TOML file with exchange and diff job schedules, empty uses the built-in ones.
+	SchedulerFile string
+	// SchedulerWatchInterval is how often SchedulerFile is checked for changes, 0 only reloads on SIGHUP.
+	SchedulerWatchInterval time.Duration
+
+	// ConnectorsDir holds YAML definitions of exchanges collected by the generic connector, empty collects none.
+	ConnectorsDir string
+
+	// DEXPoolsFile is the YAML or TOML file with the on-chain pools collected as spot markets, empty collects none.
+	DEXPoolsFile string
+	// DEXRPCURL is the EVM JSON-RPC endpoint the pools are read from, overriding the one in DEXPoolsFile.
+	DEXRPCURL string
+
+	// SimulatorScenario is a YAML or TOML simulator scenario; when set, exchange requests go to the built-in simulator instead of the real exchanges.
+	SimulatorScenario string
+	// SimulatorAddr is where the simulator listens.
+	SimulatorAddr string
+
+	// ShutdownTimeout bounds how long running jobs and API requests may take to finish on SIGINT/SIGTERM.
+	ShutdownTimeout time.Duration
+
+	// LogLevel is the minimum level logged: debug, info, warn or error.
+	LogLevel string
+	// LogFormat is text or json.
+	LogFormat string
+	// LogSampleInterval limits repetitive warnings, such as parse failures, to one per interval, 0 logs all of them.
+	LogSampleInterval time.Duration
 }
 
 // LoadConfig reads configuration variables or returns default values.
@@ -19,15 +90,116 @@ func LoadConfig() (*Config, error) {
 	_ = godotenv.Load()
 
 	cfg := &Config{
-		DatabaseURL: os.Getenv("DATABASE_URL"),
-		APIPort:     os.Getenv("API_PORT"),
+		DatabaseURL:        os.Getenv("DATABASE_URL"),
+		APIPort:            os.Getenv("API_PORT"),
+		CORSAllowedOrigins: splitList(os.Getenv("CORS_ALLOWED_ORIGINS")),
+		PublicRead:         strings.EqualFold(os.Getenv("API_PUBLIC_READ"), "true"),
+		TrustedProxies:     splitList(os.Getenv("API_TRUSTED_PROXIES")),
+		RateLimit:          envFloat("API_RATE_LIMIT", 5),
+		RateLimitBurst:     envInt("API_RATE_LIMIT_BURST", 20),
+		CacheTTL:           envDuration("API_CACHE_TTL", time.Minute),
+		AlertsFile:         os.Getenv("ALERTS_FILE"),
+		StaleAfter:         envDuration("HEALTH_STALE_AFTER", 2*time.Minute),
+		NetworksStaleAfter: envDuration("HEALTH_NETWORKS_STALE_AFTER", 10*time.Minute),
+
+		PairsStaleAfter:        envDuration("PAIRS_STALE_AFTER", 2*time.Minute),
+		PairsDelistAfter:       envDuration("PAIRS_DELIST_AFTER", 10*time.Minute),
+		PairsDelistedRetention: envDuration("PAIRS_DELISTED_RETENTION", 24*time.Hour),
+
+		SchedulerFile:          os.Getenv("SCHEDULER_CONFIG"),
+		SchedulerWatchInterval: envDuration("SCHEDULER_WATCH_INTERVAL", 5*time.Second),
+
+		ConnectorsDir: os.Getenv("CONNECTORS_DIR"),
+
+		DEXPoolsFile: os.Getenv("DEX_POOLS_FILE"),
+		DEXRPCURL:    os.Getenv("DEX_RPC_URL"),
+
+		SimulatorScenario: os.Getenv("SIMULATOR_SCENARIO"),
+		SimulatorAddr:     envString("SIMULATOR_ADDR", "127.0.0.1:8090"),
+
+		ShutdownTimeout: envDuration("SHUTDOWN_TIMEOUT", 20*time.Second),
+
+		LogLevel:          envString("LOG_LEVEL", "info"),
+		LogFormat:         envString("LOG_FORMAT", "text"),
+		LogSampleInterval: envDuration("LOG_SAMPLE_INTERVAL", time.Minute),
+	}
+
+	cfg.ExchangeCredentials = make(map[string]signing.Credentials)
+	for _, exchange := range signedExchanges {
+		suffix := strings.ToUpper(exchange)
+		cfg.ExchangeCredentials[exchange] = signing.Credentials{
+			Key:        os.Getenv("API_KEY_" + suffix),
+			Secret:     os.Getenv("API_SECRET_" + suffix),
+			Passphrase: os.Getenv("API_PASSPHRASE_" + suffix),
+		}
 	}
 
 	if cfg.APIPort == "" {
 		cfg.APIPort = ":8082"
 	}
 
-	log.Printf("Config loaded successfully ...")
+	slog.Info("config loaded")
 
 	return cfg, nil
 }
+
+// splitList splits a comma separated value, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// envString reads a string variable, falling back to def when it is unset.
+func envString(name, def string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return def
+}
+
+// envFloat reads a float variable, falling back to def when it is unset or invalid.
+func envFloat(name string, def float64) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		slog.Warn("invalid config value, using default", "name", name, "value", value, "default", def)
+		return def
+	}
+	return f
+}
+
+// envInt reads an integer variable, falling back to def when it is unset or invalid.
+func envInt(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("invalid config value, using default", "name", name, "value", value, "default", def)
+		return def
+	}
+	return i
+}
+
+// envDuration reads a duration variable such as "30s", falling back to def when it is unset or invalid.
+func envDuration(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("invalid config value, using default", "name", name, "value", value, "default", def)
+		return def
+	}
+	return d
+}
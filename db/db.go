@@ -2,7 +2,7 @@ package db
 
 import (
 	"database/sql"
-	"log"
+	"log/slog"
 
 	_ "github.com/lib/pq"
 )
@@ -19,7 +19,7 @@ func Connect(databaseURL string) (*sql.DB, error) {
 		return nil, err
 	}
 
-	log.Printf("Database connected successfully ...")
+	slog.Info("database connected")
 
 	return db, nil
 }
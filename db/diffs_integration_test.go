@@ -0,0 +1,825 @@
+//go:build integration
+
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+)
+
+// The diff jobs run against a real Postgres: TEST_DATABASE_URL when it is set,
+// otherwise an embedded server started for the test run. The tests drop and
+// recreate every table, so TEST_DATABASE_URL must point at a throwaway database.
+var testDB *sql.DB
+
+var testStatusConfig = PairStatusConfig{
+	StaleAfter:  5 * time.Minute,
+	DelistAfter: time.Hour,
+}
+
+func TestMain(m *testing.M) {
+	os.Exit(runWithDatabase(m))
+}
+
+func runWithDatabase(m *testing.M) int {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		url, stop, err := startEmbeddedPostgres()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "starting embedded postgres (set TEST_DATABASE_URL to use an existing server):", err)
+			return 1
+		}
+		defer stop()
+		databaseURL = url
+	}
+
+	conn, err := Connect(databaseURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "connecting to the test database:", err)
+		return 1
+	}
+	defer conn.Close()
+
+	testDB = conn
+	return m.Run()
+}
+
+// startEmbeddedPostgres runs a Postgres server on a free port. The binaries are
+// downloaded on first use and cached in ~/.embedded-postgres-go.
+func startEmbeddedPostgres() (string, func(), error) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return "", nil, err
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	dir, err := os.MkdirTemp("", "updater-postgres-")
+	if err != nil {
+		return "", nil, err
+	}
+
+	cfg := embeddedpostgres.DefaultConfig().
+		Version(embeddedpostgres.V16).
+		Port(uint32(port)).
+		RuntimePath(dir).
+		Logger(io.Discard)
+	pg := embeddedpostgres.NewDatabase(cfg)
+	if err := pg.Start(); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+
+	stop := func() {
+		pg.Stop()
+		os.RemoveAll(dir)
+	}
+	return cfg.GetConnectionURL() + "?sslmode=disable", stop, nil
+}
+
+// resetSchema recreates the tables the way a fresh deployment does.
+func resetSchema(t *testing.T) {
+	t.Helper()
+	for _, file := range []string{"queries/recreateTables.sql", "queries/migratePairsStatus.sql", "queries/migrateOpenInterest.sql", "queries/migrateDatedFutures.sql", "queries/migrateInverseFutures.sql"} {
+		execFile(t, file)
+	}
+}
+
+func execFile(t *testing.T, file string) {
+	t.Helper()
+	query, err := LoadSQLFromFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ExecuteSQL(testDB, query); err != nil {
+		t.Fatalf("%s: %v", file, err)
+	}
+}
+
+func mustExec(t *testing.T, query string, args ...any) {
+	t.Helper()
+	if _, err := testDB.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+// runDiffs runs one cycle of a diff job like main's runDiffJob does.
+func runDiffs(t *testing.T, pairsTable, sqlFile string) {
+	t.Helper()
+	// Every cycle must see a later NOW() than the previous one
+	time.Sleep(10 * time.Millisecond)
+	if err := UpdatePairStatuses(testDB, pairsTable, testStatusConfig); err != nil {
+		t.Fatal(err)
+	}
+	execFile(t, sqlFile)
+}
+
+type spotPair struct {
+	exchange    string
+	symbol      string
+	baseAsset   string
+	quoteAsset  string
+	price       float64
+	baseVolume  float64
+	lastUpdated time.Duration // how long ago the exchange delivered the pair
+}
+
+// upsertPairs writes pairs the way the connectors do, stamped with the database clock.
+func upsertPairs(t *testing.T, pairs ...spotPair) {
+	t.Helper()
+	for _, p := range pairs {
+		mustExec(t, `
+			INSERT INTO pairs (pairkey, symbol, exchange, market, price, baseasset, quoteasset, displayname,
+				pricechangepercent24h, basevolume24h, quotevolume24h, updatedat)
+			VALUES ($1, $2, $3, 'spot', $4, $5, $6, $7, 0, $8, 0, NOW() AT TIME ZONE 'UTC' - $9::float8 * INTERVAL '1 second')
+			ON CONFLICT (pairkey) DO UPDATE SET
+				price = EXCLUDED.price,
+				basevolume24h = EXCLUDED.basevolume24h,
+				updatedat = EXCLUDED.updatedat`,
+			p.symbol+"_"+p.exchange+"_spot", p.symbol, p.exchange, p.price, p.baseAsset, p.quoteAsset,
+			p.baseAsset+"/"+p.quoteAsset, p.baseVolume, p.lastUpdated.Seconds())
+	}
+}
+
+type futuresPair struct {
+	exchange      string
+	symbol        string
+	baseAsset     string
+	quoteAsset    string
+	markPrice     float64
+	indexPrice    float64
+	fundingRate   float64
+	expiry        int64
+	contractType  string // linear when empty
+	contractValue float64
+	lastUpdated   time.Duration
+}
+
+func upsertFuturesPairs(t *testing.T, pairs ...futuresPair) {
+	t.Helper()
+	for _, p := range pairs {
+		contractType := p.contractType
+		if contractType == "" {
+			contractType = "linear"
+		}
+		mustExec(t, `
+			INSERT INTO pairsfutures (pairkey, symbol, exchange, market, markprice, indexprice, baseasset, quoteasset,
+				displayname, fundingratepercent, nextfundingtimestamp, pricechangepercent24h, basevolume24h, quotevolume24h,
+				expiry, contracttype, contractvalue, updatedat)
+			VALUES ($1, $2, $3, 'futures', $4, $5, $6, $7, $8, $9, 0, 0, 1, 0, $11, $12, $13, NOW() AT TIME ZONE 'UTC' - $10::float8 * INTERVAL '1 second')
+			ON CONFLICT (pairkey) DO UPDATE SET
+				markprice = EXCLUDED.markprice,
+				indexprice = EXCLUDED.indexprice,
+				fundingratepercent = EXCLUDED.fundingratepercent,
+				updatedat = EXCLUDED.updatedat`,
+			p.symbol+"_"+p.exchange+"_futures", p.symbol, p.exchange, p.markPrice, p.indexPrice, p.baseAsset, p.quoteAsset,
+			p.baseAsset+"/"+p.quoteAsset, p.fundingRate, p.lastUpdated.Seconds(), p.expiry, contractType, p.contractValue)
+	}
+}
+
+type spotDiff struct {
+	Symbol               string
+	BaseAsset            string
+	QuoteAsset           string
+	FirstPairExchange    string
+	FirstPairPrice       float64
+	FirstPairVolume      float64
+	SecondPairExchange   string
+	SecondPairPrice      float64
+	SecondPairVolume     float64
+	Difference           float64
+	DifferencePercentage float64
+}
+
+func spotDiffs(t *testing.T) map[string]spotDiff {
+	t.Helper()
+	rows, err := testDB.Query(`
+		SELECT pairKey, symbol, baseAsset, quoteAsset, firstPairExchange, firstPairPrice, firstPairVolume,
+			secondPairExchange, secondPairPrice, secondPairVolume, difference, differencePercentage
+		FROM diffs`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	diffs := make(map[string]spotDiff)
+	for rows.Next() {
+		var key string
+		var d spotDiff
+		err := rows.Scan(&key, &d.Symbol, &d.BaseAsset, &d.QuoteAsset, &d.FirstPairExchange, &d.FirstPairPrice, &d.FirstPairVolume,
+			&d.SecondPairExchange, &d.SecondPairPrice, &d.SecondPairVolume, &d.Difference, &d.DifferencePercentage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diffs[key] = d
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return diffs
+}
+
+func compareDiffs[T any](t *testing.T, got, want map[string]T) {
+	t.Helper()
+	for key, w := range want {
+		g, ok := got[key]
+		if !ok {
+			t.Errorf("missing diff %s", key)
+			continue
+		}
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("diff %s:\n got  %+v\n want %+v", key, g, w)
+		}
+	}
+	for key := range got {
+		if _, ok := want[key]; !ok {
+			t.Errorf("unexpected diff %s", key)
+		}
+	}
+}
+
+func TestUpdateDiffs(t *testing.T) {
+	resetSchema(t)
+	upsertPairs(t,
+		spotPair{exchange: "Binance", symbol: "BTCUSDT", baseAsset: "BTC", quoteAsset: "USDT", price: 100, baseVolume: 10},
+		spotPair{exchange: "Bybit", symbol: "BTCUSDT", baseAsset: "BTC", quoteAsset: "USDT", price: 101.5, baseVolume: 20},
+		// No price yet
+		spotPair{exchange: "OKX", symbol: "BTCUSDT", baseAsset: "BTC", quoteAsset: "USDT", baseVolume: 5},
+		// Kraken stopped delivering data
+		spotPair{exchange: "Kraken", symbol: "BTCUSDT", baseAsset: "BTC", quoteAsset: "USDT", price: 90, lastUpdated: 10 * time.Minute},
+		// Spot pairs only match on the same symbol
+		spotPair{exchange: "Binance", symbol: "ETHUSDT", baseAsset: "ETH", quoteAsset: "USDT", price: 3500},
+		spotPair{exchange: "Bybit", symbol: "ETHUSDC", baseAsset: "ETH", quoteAsset: "USDC", price: 3600},
+	)
+
+	runDiffs(t, "pairs", "queries/updateDiffs.sql")
+
+	// The percentage is truncated, not rounded: -1.5 / 101.5 is -1.4778%
+	compareDiffs(t, spotDiffs(t), map[string]spotDiff{
+		"BTCUSDT_Binance-Bybit": {
+			Symbol: "BTCUSDT", BaseAsset: "BTC", QuoteAsset: "USDT",
+			FirstPairExchange: "Binance", FirstPairPrice: 100, FirstPairVolume: 10,
+			SecondPairExchange: "Bybit", SecondPairPrice: 101.5, SecondPairVolume: 20,
+			Difference: 1.5, DifferencePercentage: 1.5,
+		},
+		"BTCUSDT_Bybit-Binance": {
+			Symbol: "BTCUSDT", BaseAsset: "BTC", QuoteAsset: "USDT",
+			FirstPairExchange: "Bybit", FirstPairPrice: 101.5, FirstPairVolume: 20,
+			SecondPairExchange: "Binance", SecondPairPrice: 100, SecondPairVolume: 10,
+			Difference: -1.5, DifferencePercentage: -1.47,
+		},
+	})
+}
+
+func TestUpdateDiffsClampsPercentage(t *testing.T) {
+	resetSchema(t)
+	upsertPairs(t,
+		spotPair{exchange: "Binance", symbol: "PEPEUSDT", baseAsset: "PEPE", quoteAsset: "USDT", price: 0.00000001},
+		spotPair{exchange: "Bybit", symbol: "PEPEUSDT", baseAsset: "PEPE", quoteAsset: "USDT", price: 1000},
+	)
+
+	runDiffs(t, "pairs", "queries/updateDiffs.sql")
+
+	diffs := spotDiffs(t)
+	if got := diffs["PEPEUSDT_Binance-Bybit"].DifferencePercentage; got != 1e9 {
+		t.Errorf("Binance-Bybit percentage = %v, want it clamped to 1e9", got)
+	}
+	if got := diffs["PEPEUSDT_Bybit-Binance"].DifferencePercentage; got != -99.99 {
+		t.Errorf("Bybit-Binance percentage = %v, want -99.99", got)
+	}
+}
+
+func TestUpdateDiffsRemovesVanishedCombinations(t *testing.T) {
+	resetSchema(t)
+	pairs := []spotPair{
+		{exchange: "Binance", symbol: "BTCUSDT", baseAsset: "BTC", quoteAsset: "USDT", price: 100},
+		{exchange: "Bybit", symbol: "BTCUSDT", baseAsset: "BTC", quoteAsset: "USDT", price: 101},
+		{exchange: "Kraken", symbol: "BTCUSDT", baseAsset: "BTC", quoteAsset: "USDT", price: 102},
+		{exchange: "OKX", symbol: "BTCUSDT", baseAsset: "BTC", quoteAsset: "USDT", price: 103},
+	}
+	upsertPairs(t, pairs...)
+	runDiffs(t, "pairs", "queries/updateDiffs.sql")
+	if got := len(spotDiffs(t)); got != 12 {
+		t.Fatalf("got %d diffs for four exchanges, want 12", got)
+	}
+
+	// Kraken goes stale and OKX loses its price
+	pairs[2].lastUpdated = 10 * time.Minute
+	pairs[3].price = 0
+	upsertPairs(t, pairs...)
+	runDiffs(t, "pairs", "queries/updateDiffs.sql")
+
+	diffs := spotDiffs(t)
+	if len(diffs) != 2 {
+		t.Errorf("got %d diffs, want only Binance and Bybit: %v", len(diffs), diffs)
+	}
+	for _, key := range []string{"BTCUSDT_Binance-Bybit", "BTCUSDT_Bybit-Binance"} {
+		if _, ok := diffs[key]; !ok {
+			t.Errorf("missing diff %s", key)
+		}
+	}
+}
+
+type lifetime struct {
+	timeOfLife sql.NullTime
+	elapsed    float64 // seconds
+	consistent bool    // timeElapsed is the time since timeOfLife
+}
+
+func diffLifetime(t *testing.T, pairKey string) lifetime {
+	t.Helper()
+	var l lifetime
+	err := testDB.QueryRow(`
+		SELECT timeOfLife, EXTRACT(EPOCH FROM timeElapsed), timeElapsed = COALESCE(updatedAt - timeOfLife, INTERVAL '0 seconds')
+		FROM diffs WHERE pairKey = $1`, pairKey).Scan(&l.timeOfLife, &l.elapsed, &l.consistent)
+	if err != nil {
+		t.Fatalf("%s: %v", pairKey, err)
+	}
+	return l
+}
+
+func TestUpdateDiffsTracksLifetime(t *testing.T) {
+	resetSchema(t)
+	binance := spotPair{exchange: "Binance", symbol: "BTCUSDT", baseAsset: "BTC", quoteAsset: "USDT", price: 100}
+	bybit := spotPair{exchange: "Bybit", symbol: "BTCUSDT", baseAsset: "BTC", quoteAsset: "USDT", price: 101}
+	cycle := func() (lifetime, lifetime) {
+		t.Helper()
+		upsertPairs(t, binance, bybit)
+		runDiffs(t, "pairs", "queries/updateDiffs.sql")
+		return diffLifetime(t, "BTCUSDT_Binance-Bybit"), diffLifetime(t, "BTCUSDT_Bybit-Binance")
+	}
+
+	// A positive diff starts living, a negative one does not
+	first, reverse := cycle()
+	if !first.timeOfLife.Valid || first.elapsed != 0 {
+		t.Fatalf("new positive diff: %+v, want timeOfLife set and nothing elapsed", first)
+	}
+	if reverse.timeOfLife.Valid || reverse.elapsed != 0 {
+		t.Errorf("negative diff: %+v, want no timeOfLife", reverse)
+	}
+	born := first.timeOfLife.Time
+
+	// While it stays positive, timeOfLife is kept and timeElapsed is measured from it
+	previous := first.elapsed
+	for i := 0; i < 3; i++ {
+		bybit.price += 0.5
+		got, _ := cycle()
+		if !got.timeOfLife.Valid || !got.timeOfLife.Time.Equal(born) {
+			t.Errorf("cycle %d: timeOfLife = %v, want %v", i+2, got.timeOfLife, born)
+		}
+		if got.elapsed <= previous || !got.consistent {
+			t.Errorf("cycle %d: timeElapsed = %vs after %vs, want it to grow as the time since timeOfLife", i+2, got.elapsed, previous)
+		}
+		previous = got.elapsed
+	}
+
+	// The diff turns negative and the reverse one starts living
+	bybit.price = 99
+	first, reverse = cycle()
+	if first.timeOfLife.Valid || first.elapsed != 0 {
+		t.Errorf("diff turned negative: %+v, want the lifetime reset", first)
+	}
+	if !reverse.timeOfLife.Valid || reverse.elapsed != 0 {
+		t.Errorf("reverse diff turned positive: %+v, want timeOfLife set", reverse)
+	}
+
+	// Turning positive again starts a new lifetime
+	bybit.price = 101
+	first, _ = cycle()
+	if !first.timeOfLife.Valid || !first.timeOfLife.Time.After(born) || first.elapsed != 0 {
+		t.Errorf("diff positive again: %+v, want a new timeOfLife after %v", first, born)
+	}
+}
+
+// network mirrors the objects updateDiffs.sql builds from nets, without updatedAt.
+type network struct {
+	Coin           string
+	Exchange       string
+	Network        string
+	NetworkName    string
+	DepositEnable  bool
+	WithdrawEnable bool
+}
+
+type exchangeNetworks struct {
+	BaseAsset  []network
+	QuoteAsset []network
+}
+
+func diffNetworks(t *testing.T, pairKey string) (first, second exchangeNetworks) {
+	t.Helper()
+	var firstJSON, secondJSON []byte
+	err := testDB.QueryRow(`SELECT firstExchangeNetworks, secondExchangeNetworks FROM diffs WHERE pairKey = $1`, pairKey).
+		Scan(&firstJSON, &secondJSON)
+	if err != nil {
+		t.Fatalf("%s: %v", pairKey, err)
+	}
+	for _, n := range []struct {
+		data []byte
+		into *exchangeNetworks
+	}{{firstJSON, &first}, {secondJSON, &second}} {
+		// Both keys must be present, an empty list included
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal(n.data, &raw); err != nil {
+			t.Fatalf("%s: %v", pairKey, err)
+		}
+		if string(raw["baseAsset"]) == "" || string(raw["quoteAsset"]) == "" {
+			t.Errorf("%s: networks %s miss baseAsset or quoteAsset", pairKey, n.data)
+		}
+		if err := json.Unmarshal(n.data, n.into); err != nil {
+			t.Fatalf("%s: %v", pairKey, err)
+		}
+		// jsonb_agg does not promise an order
+		for _, list := range [][]network{n.into.BaseAsset, n.into.QuoteAsset} {
+			sort.Slice(list, func(i, j int) bool { return list[i].Network < list[j].Network })
+		}
+	}
+	return first, second
+}
+
+func TestUpdateDiffsNetworks(t *testing.T) {
+	resetSchema(t)
+	upsertPairs(t,
+		spotPair{exchange: "Binance", symbol: "BTCUSDT", baseAsset: "BTC", quoteAsset: "USDT", price: 100},
+		spotPair{exchange: "Bybit", symbol: "BTCUSDT", baseAsset: "BTC", quoteAsset: "USDT", price: 101},
+	)
+	setNetwork := func(n network) {
+		t.Helper()
+		mustExec(t, `
+			INSERT INTO nets (coinkey, coin, exchange, network, networkname, depositenable, withdrawenable)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (coinkey) DO UPDATE SET
+				depositenable = EXCLUDED.depositenable,
+				withdrawenable = EXCLUDED.withdrawenable`,
+			n.Coin+"_"+n.Exchange+"_"+n.Network, n.Coin, n.Exchange, n.Network, n.NetworkName, n.DepositEnable, n.WithdrawEnable)
+	}
+	binanceBTC := network{Coin: "BTC", Exchange: "Binance", Network: "BTC", NetworkName: "Bitcoin", DepositEnable: true, WithdrawEnable: true}
+	binanceERC20 := network{Coin: "USDT", Exchange: "Binance", Network: "ETH", NetworkName: "Ethereum (ERC20)", DepositEnable: true, WithdrawEnable: true}
+	binanceTRC20 := network{Coin: "USDT", Exchange: "Binance", Network: "TRX", NetworkName: "Tron (TRC20)", DepositEnable: true}
+	bybitBTC := network{Coin: "BTC", Exchange: "Bybit", Network: "BTC", NetworkName: "BTC", DepositEnable: true, WithdrawEnable: true}
+	// Networks of other exchanges and coins stay out of the diff
+	krakenUSDT := network{Coin: "USDT", Exchange: "Kraken", Network: "TRX", NetworkName: "Tron", DepositEnable: true, WithdrawEnable: true}
+	bybitETH := network{Coin: "ETH", Exchange: "Bybit", Network: "ETH", NetworkName: "Ethereum", DepositEnable: true, WithdrawEnable: true}
+	for _, n := range []network{binanceBTC, binanceERC20, binanceTRC20, bybitBTC, krakenUSDT, bybitETH} {
+		setNetwork(n)
+	}
+
+	runDiffs(t, "pairs", "queries/updateDiffs.sql")
+
+	first, second := diffNetworks(t, "BTCUSDT_Binance-Bybit")
+	want := exchangeNetworks{BaseAsset: []network{binanceBTC}, QuoteAsset: []network{binanceERC20, binanceTRC20}}
+	if !reflect.DeepEqual(first, want) {
+		t.Errorf("Binance networks:\n got  %+v\n want %+v", first, want)
+	}
+	// Bybit has no USDT networks, the list is empty rather than missing
+	want = exchangeNetworks{BaseAsset: []network{bybitBTC}, QuoteAsset: []network{}}
+	if !reflect.DeepEqual(second, want) {
+		t.Errorf("Bybit networks:\n got  %+v\n want %+v", second, want)
+	}
+
+	// The next cycle picks up network changes
+	bybitBTC.DepositEnable = false
+	setNetwork(bybitBTC)
+	runDiffs(t, "pairs", "queries/updateDiffs.sql")
+
+	first, _ = diffNetworks(t, "BTCUSDT_Bybit-Binance")
+	want = exchangeNetworks{BaseAsset: []network{bybitBTC}, QuoteAsset: []network{}}
+	if !reflect.DeepEqual(first, want) {
+		t.Errorf("Bybit networks after the change:\n got  %+v\n want %+v", first, want)
+	}
+}
+
+type futuresDiff struct {
+	Symbol                       string
+	BaseAsset                    string
+	QuoteAsset                   string
+	FirstPairExchange            string
+	FirstPairMarkPrice           float64
+	FirstPairIndexPrice          float64
+	FirstPairFundingRate         float64
+	SecondPairExchange           string
+	SecondPairMarkPrice          float64
+	SecondPairIndexPrice         float64
+	SecondPairFundingRate        float64
+	DifferenceMark               float64
+	DifferenceIndex              float64
+	DifferenceMarkPercentage     float64
+	DifferenceIndexPercentage    float64
+	DifferenceFundingRatePercent float64
+	IsFundingRateOpposite        bool
+}
+
+func futuresDiffs(t *testing.T) map[string]futuresDiff {
+	t.Helper()
+	rows, err := testDB.Query(`
+		SELECT pairKey, symbol, baseAsset, quoteAsset,
+			firstPairExchange, firstPairMarkPrice, firstPairIndexPrice, firstPairFundingRate,
+			secondPairExchange, secondPairMarkPrice, secondPairIndexPrice, secondPairFundingRate,
+			differenceMark, differenceIndex, differenceMarkPercentage, differenceIndexPercentage,
+			differenceFundingRatePercent, isFundingRateOpposite
+		FROM diffsfutures`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	diffs := make(map[string]futuresDiff)
+	for rows.Next() {
+		var key string
+		var d futuresDiff
+		err := rows.Scan(&key, &d.Symbol, &d.BaseAsset, &d.QuoteAsset,
+			&d.FirstPairExchange, &d.FirstPairMarkPrice, &d.FirstPairIndexPrice, &d.FirstPairFundingRate,
+			&d.SecondPairExchange, &d.SecondPairMarkPrice, &d.SecondPairIndexPrice, &d.SecondPairFundingRate,
+			&d.DifferenceMark, &d.DifferenceIndex, &d.DifferenceMarkPercentage, &d.DifferenceIndexPercentage,
+			&d.DifferenceFundingRatePercent, &d.IsFundingRateOpposite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diffs[key] = d
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return diffs
+}
+
+func TestUpdateDiffsFutures(t *testing.T) {
+	resetSchema(t)
+	binance := futuresPair{exchange: "Binance", symbol: "BTCUSDT", baseAsset: "BTC", quoteAsset: "USDT", markPrice: 100, indexPrice: 100.5, fundingRate: 0.01}
+	okx := futuresPair{exchange: "OKX", symbol: "BTCUSDC", baseAsset: "BTC", quoteAsset: "USDC", markPrice: 102, indexPrice: 101, fundingRate: -0.005}
+	upsertFuturesPairs(t,
+		binance,
+		okx,
+		// USD is not interchangeable with USDT or USDC
+		futuresPair{exchange: "Kraken", symbol: "BTCUSD", baseAsset: "BTC", quoteAsset: "USD", markPrice: 101, indexPrice: 101},
+		// Inverse and linear USD contracts are only compared in diffsinverse
+		futuresPair{exchange: "Binance", symbol: "BTCUSD_PERP", baseAsset: "BTC", quoteAsset: "USD", markPrice: 100, indexPrice: 100, contractType: "inverse", contractValue: 100},
+		// No index price yet
+		futuresPair{exchange: "Bybit", symbol: "BTCUSDT", baseAsset: "BTC", quoteAsset: "USDT", markPrice: 101},
+	)
+
+	runDiffsFutures := func() {
+		t.Helper()
+		runDiffs(t, "pairsfutures", "queries/updateDiffsFutures.sql")
+	}
+	runDiffsFutures()
+
+	// USDT and USDC markets of the same base asset are compared
+	compareDiffs(t, futuresDiffs(t), map[string]futuresDiff{
+		"BTCUSDT_BTCUSDC_Binance-OKX": {
+			Symbol: "BTCUSDT_BTCUSDC", BaseAsset: "BTC", QuoteAsset: "USDT",
+			FirstPairExchange: "Binance", FirstPairMarkPrice: 100, FirstPairIndexPrice: 100.5, FirstPairFundingRate: 0.01,
+			SecondPairExchange: "OKX", SecondPairMarkPrice: 102, SecondPairIndexPrice: 101, SecondPairFundingRate: -0.005,
+			DifferenceMark: 2, DifferenceIndex: 0.5, DifferenceMarkPercentage: 2, DifferenceIndexPercentage: 0.49,
+			DifferenceFundingRatePercent: -0.015, IsFundingRateOpposite: true,
+		},
+		"BTCUSDC_BTCUSDT_OKX-Binance": {
+			Symbol: "BTCUSDC_BTCUSDT", BaseAsset: "BTC", QuoteAsset: "USDC",
+			FirstPairExchange: "OKX", FirstPairMarkPrice: 102, FirstPairIndexPrice: 101, FirstPairFundingRate: -0.005,
+			SecondPairExchange: "Binance", SecondPairMarkPrice: 100, SecondPairIndexPrice: 100.5, SecondPairFundingRate: 0.01,
+			DifferenceMark: -2, DifferenceIndex: -0.5, DifferenceMarkPercentage: -1.96, DifferenceIndexPercentage: -0.49,
+			DifferenceFundingRatePercent: 0.015, IsFundingRateOpposite: true,
+		},
+	})
+
+	// Futures diffs carry no lifetime or networks
+	var withLifetime, withNetworks int
+	err := testDB.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE timeOfLife IS NOT NULL OR timeElapsed <> INTERVAL '0 seconds'),
+			COUNT(*) FILTER (WHERE firstExchangeNetworks <> '{}'::jsonb OR secondExchangeNetworks <> '{}'::jsonb)
+		FROM diffsfutures`).Scan(&withLifetime, &withNetworks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if withLifetime != 0 || withNetworks != 0 {
+		t.Errorf("%d futures diffs with a lifetime and %d with networks, want none", withLifetime, withNetworks)
+	}
+
+	// Funding rates on the same side are not opposite, and the prices follow the pairs
+	okx.markPrice = 0.00000001
+	okx.fundingRate = 0.02
+	upsertFuturesPairs(t, binance, okx)
+	runDiffsFutures()
+
+	diffs := futuresDiffs(t)
+	got := diffs["BTCUSDC_BTCUSDT_OKX-Binance"]
+	if got.DifferenceMarkPercentage != 1e9 {
+		t.Errorf("mark percentage = %v, want it clamped to 1e9", got.DifferenceMarkPercentage)
+	}
+	if got.IsFundingRateOpposite || got.DifferenceFundingRatePercent != -0.01 {
+		t.Errorf("funding: opposite %v, difference %v, want false and -0.01", got.IsFundingRateOpposite, got.DifferenceFundingRatePercent)
+	}
+
+	// OKX goes stale and its diffs are removed
+	okx.lastUpdated = 10 * time.Minute
+	upsertFuturesPairs(t, binance, okx)
+	runDiffsFutures()
+
+	if diffs := futuresDiffs(t); len(diffs) != 0 {
+		t.Errorf("got %d futures diffs after OKX went stale, want none: %v", len(diffs), diffs)
+	}
+}
+
+type calendarDiff struct {
+	NearExchange               string
+	NearMarkPrice              float64
+	FarExchange                string
+	FarMarkPrice               float64
+	Spread                     float64
+	SpreadPercentage           float64
+	DaysBetween                float64
+	AnnualizedSpreadPercentage float64
+}
+
+func calendarDiffs(t *testing.T) map[string]calendarDiff {
+	t.Helper()
+	rows, err := testDB.Query(`
+		SELECT pairKey, nearExchange, nearMarkPrice, farExchange, farMarkPrice,
+			spread, spreadPercentage, daysBetween, annualizedSpreadPercentage
+		FROM diffscalendar`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	diffs := make(map[string]calendarDiff)
+	for rows.Next() {
+		var key string
+		var d calendarDiff
+		err := rows.Scan(&key, &d.NearExchange, &d.NearMarkPrice, &d.FarExchange, &d.FarMarkPrice,
+			&d.Spread, &d.SpreadPercentage, &d.DaysBetween, &d.AnnualizedSpreadPercentage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diffs[key] = d
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return diffs
+}
+
+func TestUpdateDiffsCalendar(t *testing.T) {
+	resetSchema(t)
+	day := 24 * time.Hour
+	now := time.Now()
+	upsertFuturesPairs(t,
+		futuresPair{exchange: "Binance", symbol: "BTCUSDT", baseAsset: "BTC", quoteAsset: "USDT", markPrice: 100, indexPrice: 100, fundingRate: 0.01},
+		futuresPair{exchange: "Binance", symbol: "BTCUSDT_Q1", baseAsset: "BTC", quoteAsset: "USDT", markPrice: 101, indexPrice: 100, expiry: now.Add(30 * day).UnixMilli()},
+		futuresPair{exchange: "Bybit", symbol: "BTC-Q2", baseAsset: "BTC", quoteAsset: "USDC", markPrice: 103, indexPrice: 100, expiry: now.Add(120 * day).UnixMilli()},
+		// Expired and waiting for delisting
+		futuresPair{exchange: "Binance", symbol: "BTCUSDT_Q0", baseAsset: "BTC", quoteAsset: "USDT", markPrice: 100.5, indexPrice: 100, expiry: now.Add(-day).UnixMilli()},
+		// USD is not interchangeable with USDT or USDC
+		futuresPair{exchange: "Kraken", symbol: "BTCUSD_Q2", baseAsset: "BTC", quoteAsset: "USD", markPrice: 102, indexPrice: 100, expiry: now.Add(120 * day).UnixMilli()},
+	)
+	runDiffs(t, "pairsfutures", "queries/updateDiffsCalendar.sql")
+
+	// Perpetuals are near legs of every dated contract, the days run from now
+	compareDiffs(t, calendarDiffs(t), map[string]calendarDiff{
+		"BTCUSDT_BTCUSDT_Q1_Binance-Binance": {
+			NearExchange: "Binance", NearMarkPrice: 100, FarExchange: "Binance", FarMarkPrice: 101,
+			Spread: 1, SpreadPercentage: 1, DaysBetween: 30, AnnualizedSpreadPercentage: 12.16,
+		},
+		"BTCUSDT_BTC-Q2_Binance-Bybit": {
+			NearExchange: "Binance", NearMarkPrice: 100, FarExchange: "Bybit", FarMarkPrice: 103,
+			Spread: 3, SpreadPercentage: 3, DaysBetween: 120, AnnualizedSpreadPercentage: 9.12,
+		},
+		"BTCUSDT_Q1_BTC-Q2_Binance-Bybit": {
+			NearExchange: "Binance", NearMarkPrice: 101, FarExchange: "Bybit", FarMarkPrice: 103,
+			Spread: 2, SpreadPercentage: 1.98, DaysBetween: 90, AnnualizedSpreadPercentage: 8.03,
+		},
+	})
+
+	// Dated contracts stay out of the perpetual diffs
+	runDiffs(t, "pairsfutures", "queries/updateDiffsFutures.sql")
+	if diffs := futuresDiffs(t); len(diffs) != 0 {
+		t.Errorf("got %d futures diffs with dated contracts, want none: %v", len(diffs), diffs)
+	}
+
+	// The term structure has the unexpired contracts, against the perpetual of their exchange
+	rows, err := testDB.Query(`SELECT symbol, perpetualSymbol, basisPercentage, daysToExpiry FROM termstructure ORDER BY expiry, exchange`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	type point struct {
+		Symbol       string
+		Perpetual    sql.NullString
+		Basis        sql.NullFloat64
+		DaysToExpiry float64
+	}
+	var got []point
+	for rows.Next() {
+		var p point
+		if err := rows.Scan(&p.Symbol, &p.Perpetual, &p.Basis, &p.DaysToExpiry); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, p)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []point{
+		{Symbol: "BTCUSDT_Q1", Perpetual: sql.NullString{String: "BTCUSDT", Valid: true}, Basis: sql.NullFloat64{Float64: 1, Valid: true}, DaysToExpiry: 30},
+		// Bybit has no BTC perpetual
+		{Symbol: "BTC-Q2", DaysToExpiry: 120},
+		{Symbol: "BTCUSD_Q2", DaysToExpiry: 120},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("term structure:\n got  %+v\n want %+v", got, want)
+	}
+}
+
+type inverseDiff struct {
+	InverseMarkPrice             float64
+	InverseConvertedMarkPrice    float64
+	LinearMarkPrice              float64
+	ConversionRate               float64
+	DifferenceMark               float64
+	DifferenceMarkPercentage     float64
+	DifferenceFundingRatePercent float64
+	IsFundingRateOpposite        bool
+}
+
+func inverseDiffs(t *testing.T) map[string]inverseDiff {
+	t.Helper()
+	rows, err := testDB.Query(`
+		SELECT pairKey, inverseMarkPrice, inverseConvertedMarkPrice, linearMarkPrice, conversionRate,
+			differenceMark, differenceMarkPercentage, differenceFundingRatePercent, isFundingRateOpposite
+		FROM diffsinverse`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	diffs := make(map[string]inverseDiff)
+	for rows.Next() {
+		var key string
+		var d inverseDiff
+		err := rows.Scan(&key, &d.InverseMarkPrice, &d.InverseConvertedMarkPrice, &d.LinearMarkPrice, &d.ConversionRate,
+			&d.DifferenceMark, &d.DifferenceMarkPercentage, &d.DifferenceFundingRatePercent, &d.IsFundingRateOpposite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diffs[key] = d
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return diffs
+}
+
+func TestUpdateDiffsInverse(t *testing.T) {
+	resetSchema(t)
+	// The median spot USDT/USD price converts the USD marks into USDT
+	upsertPairs(t,
+		spotPair{exchange: "Kraken", symbol: "USDTUSD", baseAsset: "USDT", quoteAsset: "USD", price: 1.001},
+		spotPair{exchange: "Coinbase", symbol: "USDTUSD", baseAsset: "USDT", quoteAsset: "USD", price: 1.002},
+		spotPair{exchange: "Bitstamp", symbol: "USDTUSD", baseAsset: "USDT", quoteAsset: "USD", price: 1.003},
+	)
+	inverse := futuresPair{exchange: "Binance", symbol: "BTCUSD_PERP", baseAsset: "BTC", quoteAsset: "USD", markPrice: 100, fundingRate: 0.01, contractType: "inverse", contractValue: 100}
+	upsertFuturesPairs(t, inverse,
+		futuresPair{exchange: "Bybit", symbol: "BTCUSDT", baseAsset: "BTC", quoteAsset: "USDT", markPrice: 100.5, fundingRate: -0.005, contractValue: 1},
+		// No USDC/USD market, so USDC is taken at par
+		futuresPair{exchange: "OKX", symbol: "BTC-USDC-SWAP", baseAsset: "BTC", quoteAsset: "USDC", markPrice: 101, fundingRate: 0.02, contractValue: 0.0001},
+		futuresPair{exchange: "Kraken", symbol: "PF_XBTUSD", baseAsset: "BTC", quoteAsset: "USD", markPrice: 99, fundingRate: 0.01, contractValue: 1},
+		// Dated inverse contracts belong to the calendar spreads
+		futuresPair{exchange: "Binance", symbol: "BTCUSD_Q1", baseAsset: "BTC", quoteAsset: "USD", markPrice: 102, contractType: "inverse", contractValue: 100,
+			expiry: time.Now().Add(30 * 24 * time.Hour).UnixMilli()},
+	)
+	runDiffs(t, "pairsfutures", "queries/updateDiffsInverse.sql")
+
+	compareDiffs(t, inverseDiffs(t), map[string]inverseDiff{
+		"BTCUSD_PERP_BTCUSDT_Binance-Bybit": {
+			InverseMarkPrice: 100, InverseConvertedMarkPrice: 99.8003992, LinearMarkPrice: 100.5, ConversionRate: 0.99800399,
+			DifferenceMark: 0.6996008, DifferenceMarkPercentage: 0.7, DifferenceFundingRatePercent: -0.015, IsFundingRateOpposite: true,
+		},
+		"BTCUSD_PERP_BTC-USDC-SWAP_Binance-OKX": {
+			InverseMarkPrice: 100, InverseConvertedMarkPrice: 100, LinearMarkPrice: 101, ConversionRate: 1,
+			DifferenceMark: 1, DifferenceMarkPercentage: 1, DifferenceFundingRatePercent: 0.01,
+		},
+		"BTCUSD_PERP_PF_XBTUSD_Binance-Kraken": {
+			InverseMarkPrice: 100, InverseConvertedMarkPrice: 100, LinearMarkPrice: 99, ConversionRate: 1,
+			DifferenceMark: -1, DifferenceMarkPercentage: -1,
+		},
+	})
+
+	// The inverse market goes stale and its diffs are removed
+	inverse.lastUpdated = 10 * time.Minute
+	upsertFuturesPairs(t, inverse)
+	runDiffs(t, "pairsfutures", "queries/updateDiffsInverse.sql")
+
+	if diffs := inverseDiffs(t); len(diffs) != 0 {
+		t.Errorf("got %d inverse diffs after Binance went stale, want none: %v", len(diffs), diffs)
+	}
+}
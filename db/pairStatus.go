@@ -0,0 +1,84 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Market statuses stored in pairs.status and pairsfutures.status.
+const (
+	PairActive   = "active"   // updated recently
+	PairStale    = "stale"    // the exchange stopped delivering data, the market may come back
+	PairDelisted = "delisted" // the exchange still updates, but no longer returns this market
+)
+
+// PairStatusConfig holds the thresholds used to classify markets.
+type PairStatusConfig struct {
+	// StaleAfter is how old a market's data may get before it is marked stale.
+	StaleAfter time.Duration
+	// DelistAfter is how long a market may be missing from an exchange's successful
+	// updates before it is marked delisted.
+	DelistAfter time.Duration
+	// DelistedRetention is how long delisted markets are kept before they are deleted, 0 keeps them.
+	DelistedRetention time.Duration
+}
+
+// pairSegments are the columns that identify the markets one collection run
+// writes together. Inverse futures come from their own endpoints, which can fail
+// while the linear ones are still stored.
+var pairSegments = map[string]string{
+	"pairs":        "exchange",
+	"pairsfutures": "exchange, contractType",
+}
+
+// Status is derived from updatedAt. A market is compared with the newest row of its
+// segment to tell a delisting (the exchange moved on without it) from an outage
+// (the whole segment is old). Rows updated again become active.
+const updatePairStatusQuery = `
+WITH latest AS (
+    SELECT %[2]s, MAX(updatedAt) AS lastUpdate
+    FROM %[1]s
+    GROUP BY %[2]s
+),
+classified AS (
+    SELECT p.id,
+        CASE
+            WHEN p.updatedAt < l.lastUpdate - $2::float8 * INTERVAL '1 second' THEN 'delisted'
+            WHEN p.updatedAt < NOW() AT TIME ZONE 'UTC' - $1::float8 * INTERVAL '1 second' THEN 'stale'
+            ELSE 'active'
+        END AS status
+    FROM %[1]s p
+    JOIN latest l USING (%[2]s)
+)
+UPDATE %[1]s p
+SET status = c.status
+FROM classified c
+WHERE p.id = c.id AND p.status <> c.status`
+
+const evictDelistedQuery = `
+DELETE FROM %s
+WHERE status = 'delisted'
+  AND updatedAt < NOW() AT TIME ZONE 'UTC' - $1::float8 * INTERVAL '1 second'`
+
+// UpdatePairStatuses classifies the markets of table ("pairs" or "pairsfutures")
+// as active, stale or delisted and evicts delisted markets past the retention.
+func UpdatePairStatuses(db *sql.DB, table string, cfg PairStatusConfig) error {
+	segment, ok := pairSegments[table]
+	if !ok {
+		return fmt.Errorf("unknown pairs table %q", table)
+	}
+
+	_, err := db.Exec(fmt.Sprintf(updatePairStatusQuery, table, segment), cfg.StaleAfter.Seconds(), cfg.DelistAfter.Seconds())
+	if err != nil {
+		return fmt.Errorf("error updating %s status: %w", table, err)
+	}
+
+	if cfg.DelistedRetention > 0 {
+		_, err = db.Exec(fmt.Sprintf(evictDelistedQuery, table), cfg.DelistedRetention.Seconds())
+		if err != nil {
+			return fmt.Errorf("error evicting delisted %s: %w", table, err)
+		}
+	}
+	return nil
+}
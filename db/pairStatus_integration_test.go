@@ -0,0 +1,115 @@
+//go:build integration
+
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+// pairStatuses returns the status of every row of table by pair key.
+func pairStatuses(t *testing.T, table string) map[string]string {
+	t.Helper()
+	rows, err := testDB.Query(`SELECT pairKey, status FROM ` + table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	statuses := make(map[string]string)
+	for rows.Next() {
+		var key, status string
+		if err := rows.Scan(&key, &status); err != nil {
+			t.Fatal(err)
+		}
+		statuses[key] = status
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return statuses
+}
+
+func compareStatuses(t *testing.T, got, want map[string]string) {
+	t.Helper()
+	for key, w := range want {
+		if g, ok := got[key]; !ok {
+			t.Errorf("%s was deleted, want %s", key, w)
+		} else if g != w {
+			t.Errorf("%s is %s, want %s", key, g, w)
+		}
+	}
+	for key, g := range got {
+		if _, ok := want[key]; !ok {
+			t.Errorf("%s is %s, want it deleted", key, g)
+		}
+	}
+}
+
+func TestUpdatePairStatuses(t *testing.T) {
+	resetSchema(t)
+	upsertPairs(t,
+		spotPair{exchange: "Binance", symbol: "BTCUSDT", baseAsset: "BTC", quoteAsset: "USDT", price: 100},
+		spotPair{exchange: "Binance", symbol: "ETHUSDT", baseAsset: "ETH", quoteAsset: "USDT", price: 10, lastUpdated: 2 * time.Hour},
+		spotPair{exchange: "Kraken", symbol: "BTCUSDT", baseAsset: "BTC", quoteAsset: "USDT", price: 100, lastUpdated: 10 * time.Minute},
+		spotPair{exchange: "Kraken", symbol: "ETHUSDT", baseAsset: "ETH", quoteAsset: "USDT", price: 10, lastUpdated: 2 * time.Hour},
+	)
+
+	cfg := testStatusConfig
+	if err := UpdatePairStatuses(testDB, "pairs", cfg); err != nil {
+		t.Fatal(err)
+	}
+	compareStatuses(t, pairStatuses(t, "pairs"), map[string]string{
+		"BTCUSDT_Binance_spot": PairActive,
+		"ETHUSDT_Binance_spot": PairDelisted,
+		// Kraken stopped updating altogether, which is an outage
+		"BTCUSDT_Kraken_spot": PairStale,
+		"ETHUSDT_Kraken_spot": PairDelisted,
+	})
+
+	cfg.DelistedRetention = 30 * time.Minute
+	if err := UpdatePairStatuses(testDB, "pairs", cfg); err != nil {
+		t.Fatal(err)
+	}
+	compareStatuses(t, pairStatuses(t, "pairs"), map[string]string{
+		"BTCUSDT_Binance_spot": PairActive,
+		"BTCUSDT_Kraken_spot":  PairStale,
+	})
+}
+
+func TestUpdatePairStatusesPerContractType(t *testing.T) {
+	resetSchema(t)
+	upsertFuturesPairs(t,
+		futuresPair{exchange: "Binance", symbol: "BTCUSDT", baseAsset: "BTC", quoteAsset: "USDT", markPrice: 100, indexPrice: 100},
+		futuresPair{exchange: "Binance", symbol: "ETHUSDT", baseAsset: "ETH", quoteAsset: "USDT", markPrice: 10, indexPrice: 10, lastUpdated: 2 * time.Hour},
+		// The COIN-M endpoints have been failing while the linear ones were stored
+		futuresPair{exchange: "Binance", symbol: "BTCUSD_PERP", baseAsset: "BTC", quoteAsset: "USD", markPrice: 100, indexPrice: 100,
+			contractType: "inverse", contractValue: 100, lastUpdated: 2 * time.Hour},
+		// A delisting among inverse contracts is still noticed
+		futuresPair{exchange: "Bybit", symbol: "BTCUSD", baseAsset: "BTC", quoteAsset: "USD", markPrice: 100, indexPrice: 100,
+			contractType: "inverse", contractValue: 1},
+		futuresPair{exchange: "Bybit", symbol: "ETHUSD", baseAsset: "ETH", quoteAsset: "USD", markPrice: 10, indexPrice: 10,
+			contractType: "inverse", contractValue: 1, lastUpdated: 2 * time.Hour},
+	)
+
+	cfg := testStatusConfig
+	cfg.DelistedRetention = 30 * time.Minute
+	if err := UpdatePairStatuses(testDB, "pairsfutures", cfg); err != nil {
+		t.Fatal(err)
+	}
+	compareStatuses(t, pairStatuses(t, "pairsfutures"), map[string]string{
+		"BTCUSDT_Binance_futures":     PairActive,
+		"BTCUSD_PERP_Binance_futures": PairStale,
+		"BTCUSD_Bybit_futures":        PairActive,
+	})
+
+	// The inverse endpoints recover
+	upsertFuturesPairs(t, futuresPair{exchange: "Binance", symbol: "BTCUSD_PERP", baseAsset: "BTC", quoteAsset: "USD",
+		markPrice: 100, indexPrice: 100, contractType: "inverse", contractValue: 100})
+	if err := UpdatePairStatuses(testDB, "pairsfutures", cfg); err != nil {
+		t.Fatal(err)
+	}
+	if got := pairStatuses(t, "pairsfutures")["BTCUSD_PERP_Binance_futures"]; got != PairActive {
+		t.Errorf("BTCUSD_PERP_Binance_futures is %s after an update, want active", got)
+	}
+}
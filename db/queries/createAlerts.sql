@@ -0,0 +1,44 @@
+CREATE TABLE IF NOT EXISTS alertchannels (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) UNIQUE NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    settings JSONB NOT NULL DEFAULT '{}'::JSONB,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    updatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS alertrules (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) UNIQUE NOT NULL,
+    market VARCHAR(20) NOT NULL,
+    symbols TEXT[] NOT NULL DEFAULT '{}',
+    baseAssets TEXT[] NOT NULL DEFAULT '{}',
+    exchanges TEXT[] NOT NULL DEFAULT '{}',
+    minSpreadPercent DECIMAL(12,4) NOT NULL DEFAULT 0,
+    minFundingDiffPercent DECIMAL(10,6) NOT NULL DEFAULT 0,
+    minVolume DECIMAL(30,2) NOT NULL DEFAULT 0,
+    minLifetimeSeconds INTEGER NOT NULL DEFAULT 0,
+    requireCommonNetwork BOOLEAN NOT NULL DEFAULT FALSE,
+    cooldownSeconds INTEGER NOT NULL DEFAULT 0,
+    maxResults INTEGER NOT NULL DEFAULT 0,
+    channels TEXT[] NOT NULL DEFAULT '{}',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    updatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS alertdeliveries (
+    id BIGSERIAL PRIMARY KEY,
+    ruleId INTEGER NULL,
+    ruleName VARCHAR(100) NOT NULL,
+    channel VARCHAR(100) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    payload JSONB NOT NULL,
+    isTest BOOLEAN NOT NULL DEFAULT FALSE,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS alertdeliveries_ruleId_idx ON alertdeliveries (ruleId);
+CREATE INDEX IF NOT EXISTS alertdeliveries_createdAt_idx ON alertdeliveries (createdAt);
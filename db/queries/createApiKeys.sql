@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS apikeys (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    keyPrefix VARCHAR(16) NOT NULL,
+    keyHash CHAR(64) UNIQUE NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    lastUsedAt TIMESTAMP NULL,
+    revokedAt TIMESTAMP NULL,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS apikeys_keyHash_idx ON apikeys (keyHash);
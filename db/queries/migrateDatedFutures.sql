@@ -0,0 +1,72 @@
+-- Adds the expiry of dated futures and the calendar spread table to databases
+-- created before them; fresh databases get them from recreateTables.sql.
+-- The term structure view is only defined here and is recreated after
+-- recreateTables.sql drops it.
+ALTER TABLE IF EXISTS pairsfutures ADD COLUMN IF NOT EXISTS expiry BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS diffscalendar (
+    id SERIAL PRIMARY KEY,
+    pairKey VARCHAR(100) UNIQUE NOT NULL,
+    symbol VARCHAR(60) NOT NULL,
+    baseAsset VARCHAR(20) NOT NULL,
+    quoteAsset VARCHAR(20) NOT NULL,
+    nearExchange VARCHAR(20) NOT NULL,
+    nearSymbol VARCHAR(30) NOT NULL,
+    nearExpiry BIGINT NOT NULL,
+    nearMarkPrice DECIMAL(20,8) NOT NULL,
+    nearVolume DECIMAL(30,2) NOT NULL,
+    farExchange VARCHAR(20) NOT NULL,
+    farSymbol VARCHAR(30) NOT NULL,
+    farExpiry BIGINT NOT NULL,
+    farMarkPrice DECIMAL(20,8) NOT NULL,
+    farVolume DECIMAL(30,2) NOT NULL,
+    spread DECIMAL(20,8) NOT NULL,
+    spreadPercentage DECIMAL(12,2) NOT NULL,
+    daysBetween DECIMAL(10,2) NOT NULL,
+    annualizedSpreadPercentage DECIMAL(14,2) NOT NULL,
+    updatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS diffs_baseAsset_calendar_idx ON diffscalendar (baseAsset);
+CREATE INDEX IF NOT EXISTS diffs_annualized_calendar_idx ON diffscalendar (annualizedSpreadPercentage);
+
+-- termstructure prices every unexpired dated contract against the perpetual of
+-- the same asset on the same exchange, preferring the perpetual with the same
+-- quote and then the most traded one.
+-- The view needs pairsfutures, which a blank database gets from recreateTables.sql
+DO $$
+BEGIN
+    IF to_regclass('pairsfutures') IS NOT NULL THEN
+        CREATE OR REPLACE VIEW termstructure AS
+        SELECT DISTINCT ON (d.pairKey)
+            d.exchange,
+            d.baseAsset,
+            d.quoteAsset,
+            d.symbol,
+            d.expiry,
+            d.markPrice,
+            d.baseVolume24h,
+            p.symbol AS perpetualSymbol,
+            p.markPrice AS perpetualMarkPrice,
+            ROUND((d.markPrice - p.markPrice) / p.markPrice * 100, 4) AS basisPercentage,
+            ROUND((d.expiry - EXTRACT(EPOCH FROM NOW()) * 1000) / 86400000, 2) AS daysToExpiry,
+            ROUND((d.markPrice - p.markPrice) / p.markPrice * 100 * 365
+                / ((d.expiry - EXTRACT(EPOCH FROM NOW()) * 1000) / 86400000), 4) AS annualizedBasisPercentage
+        FROM pairsfutures d
+        LEFT JOIN pairsfutures p
+            ON p.exchange = d.exchange
+            AND p.baseAsset = d.baseAsset
+            AND (
+                p.quoteAsset = d.quoteAsset
+                OR (p.quoteAsset IN ('USDT', 'USDC') AND d.quoteAsset IN ('USDT', 'USDC'))
+            )
+            AND p.expiry = 0
+            AND p.markPrice <> 0
+            AND p.status = 'active'
+        WHERE d.expiry > EXTRACT(EPOCH FROM NOW()) * 1000
+            AND d.markPrice <> 0
+            AND d.status = 'active'
+        ORDER BY d.pairKey, (p.quoteAsset = d.quoteAsset) DESC, p.baseVolume24h DESC;
+    END IF;
+END $$;
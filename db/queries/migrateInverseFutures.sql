@@ -0,0 +1,35 @@
+-- Adds the contract type and value of futures and the inverse diff table to
+-- databases created before them.
+-- Fresh databases get them from recreateTables.sql.
+ALTER TABLE IF EXISTS pairsfutures ADD COLUMN IF NOT EXISTS contractType VARCHAR(10) NOT NULL DEFAULT 'linear';
+ALTER TABLE IF EXISTS pairsfutures ADD COLUMN IF NOT EXISTS contractValue DECIMAL(24,8) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS diffsinverse (
+    id SERIAL PRIMARY KEY,
+    pairKey VARCHAR(100) UNIQUE NOT NULL,
+    symbol VARCHAR(60) NOT NULL,
+    baseAsset VARCHAR(20) NOT NULL,
+    inverseExchange VARCHAR(20) NOT NULL,
+    inverseSymbol VARCHAR(30) NOT NULL,
+    inverseMarkPrice DECIMAL(20,8) NOT NULL,
+    inverseConvertedMarkPrice DECIMAL(20,8) NOT NULL,
+    inverseContractValue DECIMAL(24,8) NOT NULL,
+    inverseVolume DECIMAL(30,2) NOT NULL,
+    inverseFundingRate DECIMAL(10,6) NOT NULL,
+    linearExchange VARCHAR(20) NOT NULL,
+    linearSymbol VARCHAR(30) NOT NULL,
+    linearQuoteAsset VARCHAR(20) NOT NULL,
+    linearMarkPrice DECIMAL(20,8) NOT NULL,
+    linearVolume DECIMAL(30,2) NOT NULL,
+    linearFundingRate DECIMAL(10,6) NOT NULL,
+    conversionRate DECIMAL(20,8) NOT NULL,
+    differenceMark DECIMAL(20,8) NOT NULL,
+    differenceMarkPercentage DECIMAL(12,2) NOT NULL,
+    differenceFundingRatePercent DECIMAL(10,6) NOT NULL,
+    isFundingRateOpposite BOOLEAN NOT NULL DEFAULT FALSE,
+    updatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS diffs_baseAsset_inverse_idx ON diffsinverse (baseAsset);
+CREATE INDEX IF NOT EXISTS diffs_markPercentage_inverse_idx ON diffsinverse (differenceMarkPercentage);
@@ -0,0 +1,3 @@
+-- Adds the open interest column to databases created before it existed.
+-- Fresh databases get it from recreateTables.sql.
+ALTER TABLE IF EXISTS pairsfutures ADD COLUMN IF NOT EXISTS openInterest DECIMAL(24,8) NOT NULL DEFAULT 0;
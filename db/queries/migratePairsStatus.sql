@@ -0,0 +1,4 @@
+-- Adds the market status column to databases created before it existed.
+-- Fresh databases get it from recreateTables.sql.
+ALTER TABLE IF EXISTS pairs ADD COLUMN IF NOT EXISTS status VARCHAR(10) NOT NULL DEFAULT 'active';
+ALTER TABLE IF EXISTS pairsfutures ADD COLUMN IF NOT EXISTS status VARCHAR(10) NOT NULL DEFAULT 'active';
@@ -1,8 +1,11 @@
+DROP VIEW IF EXISTS termstructure;
 DROP TABLE IF EXISTS pairs CASCADE;
 DROP TABLE IF EXISTS diffs CASCADE;
 DROP TABLE IF EXISTS nets CASCADE;
 DROP TABLE IF EXISTS pairsfutures CASCADE;
 DROP TABLE IF EXISTS diffsfutures CASCADE;
+DROP TABLE IF EXISTS diffscalendar CASCADE;
+DROP TABLE IF EXISTS diffsinverse CASCADE;
 
 CREATE TABLE pairs (
     id SERIAL PRIMARY KEY,
@@ -17,6 +20,7 @@ CREATE TABLE pairs (
     priceChangePercent24h DECIMAL(10,2) NOT NULL,
     baseVolume24h DECIMAL(20,2) NOT NULL,
     quoteVolume24h DECIMAL(20,2) NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'active',
     updatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
@@ -24,6 +28,7 @@ CREATE TABLE pairs (
 CREATE INDEX symbol_idx ON pairs (symbol);
 CREATE INDEX pairKey_idx ON pairs (pairKey);
 CREATE INDEX exchange_market_idx ON pairs (exchange, market);
+CREATE INDEX pairs_exchange_updatedAt_idx ON pairs (exchange, updatedAt);
 
 CREATE TABLE diffs (
     id SERIAL PRIMARY KEY,
@@ -85,6 +90,11 @@ CREATE TABLE pairsfutures (
     priceChangePercent24h DECIMAL(10,2) NOT NULL,
     baseVolume24h DECIMAL(20,2) NOT NULL,
     quoteVolume24h DECIMAL(20,2) NOT NULL,
+    openInterest DECIMAL(24,8) NOT NULL DEFAULT 0,
+    expiry BIGINT NOT NULL DEFAULT 0,
+    contractType VARCHAR(10) NOT NULL DEFAULT 'linear',
+    contractValue DECIMAL(24,8) NOT NULL DEFAULT 0,
+    status VARCHAR(10) NOT NULL DEFAULT 'active',
     updatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
@@ -92,6 +102,7 @@ CREATE TABLE pairsfutures (
 CREATE INDEX symbolFutures_idx ON pairsfutures (symbol);
 CREATE INDEX pairKeyFutures_idx ON pairsfutures (pairKey);
 CREATE INDEX exchange_market_futures_idx ON pairsfutures (exchange, market);
+CREATE INDEX pairsfutures_exchange_updatedAt_idx ON pairsfutures (exchange, updatedAt);
 
 CREATE TABLE diffsfutures (
     id SERIAL PRIMARY KEY,
@@ -127,3 +138,62 @@ CREATE TABLE diffsfutures (
 
 CREATE INDEX diffs_symbol_futures_idx ON diffsfutures (symbol);
 CREATE INDEX diffs_pairKey_futures_idx ON diffsfutures (pairKey);
+
+CREATE TABLE diffscalendar (
+    id SERIAL PRIMARY KEY,
+    pairKey VARCHAR(100) UNIQUE NOT NULL,
+    symbol VARCHAR(60) NOT NULL,
+    baseAsset VARCHAR(20) NOT NULL,
+    quoteAsset VARCHAR(20) NOT NULL,
+    nearExchange VARCHAR(20) NOT NULL,
+    nearSymbol VARCHAR(30) NOT NULL,
+    nearExpiry BIGINT NOT NULL,
+    nearMarkPrice DECIMAL(20,8) NOT NULL,
+    nearVolume DECIMAL(30,2) NOT NULL,
+    farExchange VARCHAR(20) NOT NULL,
+    farSymbol VARCHAR(30) NOT NULL,
+    farExpiry BIGINT NOT NULL,
+    farMarkPrice DECIMAL(20,8) NOT NULL,
+    farVolume DECIMAL(30,2) NOT NULL,
+    spread DECIMAL(20,8) NOT NULL,
+    spreadPercentage DECIMAL(12,2) NOT NULL,
+    daysBetween DECIMAL(10,2) NOT NULL,
+    annualizedSpreadPercentage DECIMAL(14,2) NOT NULL,
+    updatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX diffs_baseAsset_calendar_idx ON diffscalendar (baseAsset);
+CREATE INDEX diffs_annualized_calendar_idx ON diffscalendar (annualizedSpreadPercentage);
+
+CREATE TABLE diffsinverse (
+    id SERIAL PRIMARY KEY,
+    pairKey VARCHAR(100) UNIQUE NOT NULL,
+    symbol VARCHAR(60) NOT NULL,
+    baseAsset VARCHAR(20) NOT NULL,
+    inverseExchange VARCHAR(20) NOT NULL,
+    inverseSymbol VARCHAR(30) NOT NULL,
+    inverseMarkPrice DECIMAL(20,8) NOT NULL,
+    inverseConvertedMarkPrice DECIMAL(20,8) NOT NULL,
+    inverseContractValue DECIMAL(24,8) NOT NULL,
+    inverseVolume DECIMAL(30,2) NOT NULL,
+    inverseFundingRate DECIMAL(10,6) NOT NULL,
+    linearExchange VARCHAR(20) NOT NULL,
+    linearSymbol VARCHAR(30) NOT NULL,
+    linearQuoteAsset VARCHAR(20) NOT NULL,
+    linearMarkPrice DECIMAL(20,8) NOT NULL,
+    linearVolume DECIMAL(30,2) NOT NULL,
+    linearFundingRate DECIMAL(10,6) NOT NULL,
+    conversionRate DECIMAL(20,8) NOT NULL,
+    differenceMark DECIMAL(20,8) NOT NULL,
+    differenceMarkPercentage DECIMAL(12,2) NOT NULL,
+    differenceFundingRatePercent DECIMAL(10,6) NOT NULL,
+    isFundingRateOpposite BOOLEAN NOT NULL DEFAULT FALSE,
+    updatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX diffs_baseAsset_inverse_idx ON diffsinverse (baseAsset);
+CREATE INDEX diffs_markPercentage_inverse_idx ON diffsinverse (differenceMarkPercentage);
+
+-- The termstructure view is created by migrateDatedFutures.sql, which runs after this file
@@ -2,6 +2,7 @@ WITH unique_pairs AS (
     SELECT DISTINCT symbol
     FROM pairs
     WHERE price <> 0
+      AND status = 'active'
 ),
 market_combinations AS (
     SELECT 
@@ -21,6 +22,8 @@ market_combinations AS (
         ON a.symbol = b.symbol 
         AND a.exchange <> b.exchange
     WHERE a.price <> 0 AND b.price <> 0
+        -- Stale and delisted markets keep their last price, they must not produce diffs
+        AND a.status = 'active' AND b.status = 'active'
 ),
 calculated_diffs AS (
     SELECT 
@@ -67,9 +70,11 @@ updating_data AS (
                 THEN COALESCE(prev.timeOfLife, NOW() AT TIME ZONE 'UTC')
             ELSE NULL
         END AS timeOfLife,
+        -- timeOfLife is when the diff turned positive, so the elapsed time is measured from it
+        -- and not added to the previous value
         CASE 
             WHEN d.differencePercentage > 0 AND prev.timeOfLife IS NOT NULL
-                THEN COALESCE(prev.timeElapsed, INTERVAL '0 seconds') + (NOW() AT TIME ZONE 'UTC' - prev.timeOfLife)
+                THEN NOW() AT TIME ZONE 'UTC' - prev.timeOfLife
             ELSE INTERVAL '0 seconds'
         END AS timeElapsed,
         -- JSON для firstExchangeNetworks
@@ -167,3 +172,7 @@ SET
     firstExchangeNetworks = EXCLUDED.firstExchangeNetworks,
     secondExchangeNetworks = EXCLUDED.secondExchangeNetworks,
     updatedAt = NOW() AT TIME ZONE 'UTC';
+
+-- Rows not refreshed above belong to combinations that no longer exist
+-- (a market was delisted, went stale or lost its price)
+DELETE FROM diffs WHERE updatedAt < NOW() AT TIME ZONE 'UTC';
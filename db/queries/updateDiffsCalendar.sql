@@ -0,0 +1,133 @@
+-- Calendar spreads: every active contract (near leg) against every later dated
+-- contract of the same asset (far leg), on the same or another exchange.
+-- A perpetual is a near leg that never expires.
+WITH current_time_ms AS (
+    SELECT EXTRACT(EPOCH FROM NOW()) * 1000 AS ms
+),
+market_combinations AS (
+    SELECT
+        a.symbol AS nearSymbol,
+        a.exchange AS nearExchange,
+        a.expiry AS nearExpiry,
+        a.markPrice AS nearMarkPrice,
+        a.baseVolume24h AS nearVolume,
+        a.baseAsset AS baseAsset,
+        a.quoteAsset AS quoteAsset,
+
+        b.symbol AS farSymbol,
+        b.exchange AS farExchange,
+        b.expiry AS farExpiry,
+        b.markPrice AS farMarkPrice,
+        b.baseVolume24h AS farVolume,
+
+        -- The spread of a perpetual closes over the whole life of the far leg
+        (b.expiry - GREATEST(a.expiry, t.ms)) / 86400000 AS daysBetween
+
+    FROM pairsfutures a
+    JOIN pairsfutures b
+        ON a.baseAsset = b.baseAsset
+        AND (
+            a.quoteAsset = b.quoteAsset
+            OR (a.quoteAsset IN ('USDT', 'USDC') AND b.quoteAsset IN ('USDT', 'USDC'))
+        )
+        AND a.expiry < b.expiry
+    CROSS JOIN current_time_ms t
+
+    WHERE a.markPrice <> 0
+        AND b.markPrice <> 0
+        -- Expired contracts wait for delisting with their last price
+        AND (a.expiry = 0 OR a.expiry > t.ms)
+        AND b.expiry > t.ms
+        -- Stale and delisted markets keep their last price, they must not produce diffs
+        AND a.status = 'active' AND b.status = 'active'
+),
+calculated_diffs AS (
+    SELECT
+        CONCAT(nearSymbol, '_', farSymbol) AS symbol,
+        CONCAT(nearSymbol, '_', farSymbol, '_', nearExchange, '-', farExchange) AS pairKey,
+        baseAsset,
+        quoteAsset,
+        nearExchange,
+        nearSymbol,
+        nearExpiry,
+        ROUND(nearMarkPrice, 8) AS nearMarkPrice,
+        ROUND(nearVolume, 2) AS nearVolume,
+        farExchange,
+        farSymbol,
+        farExpiry,
+        ROUND(farMarkPrice, 8) AS farMarkPrice,
+        ROUND(farVolume, 2) AS farVolume,
+        ROUND(farMarkPrice - nearMarkPrice, 8) AS spread,
+        CASE
+            WHEN TRUNC(((farMarkPrice - nearMarkPrice) / nearMarkPrice) * 100, 2) > 1000000000 THEN 1000000000
+            WHEN TRUNC(((farMarkPrice - nearMarkPrice) / nearMarkPrice) * 100, 2) < -1000000000 THEN -1000000000
+            ELSE TRUNC(((farMarkPrice - nearMarkPrice) / nearMarkPrice) * 100, 2)
+        END AS spreadPercentage,
+        ROUND(daysBetween, 2) AS daysBetween,
+        -- Legs that expire within minutes of each other annualize to huge rates, they are capped
+        CASE
+            WHEN TRUNC(((farMarkPrice - nearMarkPrice) / nearMarkPrice) * 100 * 365 / daysBetween, 2) > 1000000000 THEN 1000000000
+            WHEN TRUNC(((farMarkPrice - nearMarkPrice) / nearMarkPrice) * 100 * 365 / daysBetween, 2) < -1000000000 THEN -1000000000
+            ELSE TRUNC(((farMarkPrice - nearMarkPrice) / nearMarkPrice) * 100 * 365 / daysBetween, 2)
+        END AS annualizedSpreadPercentage
+    FROM market_combinations
+)
+INSERT INTO diffscalendar (
+    pairKey,
+    symbol,
+    baseAsset,
+    quoteAsset,
+    nearExchange,
+    nearSymbol,
+    nearExpiry,
+    nearMarkPrice,
+    nearVolume,
+    farExchange,
+    farSymbol,
+    farExpiry,
+    farMarkPrice,
+    farVolume,
+    spread,
+    spreadPercentage,
+    daysBetween,
+    annualizedSpreadPercentage,
+    updatedAt
+)
+SELECT
+    pairKey,
+    symbol,
+    baseAsset,
+    quoteAsset,
+    nearExchange,
+    nearSymbol,
+    nearExpiry,
+    nearMarkPrice,
+    nearVolume,
+    farExchange,
+    farSymbol,
+    farExpiry,
+    farMarkPrice,
+    farVolume,
+    spread,
+    spreadPercentage,
+    daysBetween,
+    annualizedSpreadPercentage,
+    NOW() AT TIME ZONE 'UTC'
+FROM calculated_diffs
+ON CONFLICT (pairKey) DO UPDATE
+SET
+    nearExpiry = EXCLUDED.nearExpiry,
+    nearMarkPrice = EXCLUDED.nearMarkPrice,
+    nearVolume = EXCLUDED.nearVolume,
+    farExpiry = EXCLUDED.farExpiry,
+    farMarkPrice = EXCLUDED.farMarkPrice,
+    farVolume = EXCLUDED.farVolume,
+    spread = EXCLUDED.spread,
+    spreadPercentage = EXCLUDED.spreadPercentage,
+    daysBetween = EXCLUDED.daysBetween,
+    annualizedSpreadPercentage = EXCLUDED.annualizedSpreadPercentage,
+    updatedAt = NOW() AT TIME ZONE 'UTC';
+
+-- Rows not refreshed above belong to combinations that no longer exist
+-- (a contract expired or was delisted, went stale or lost its price)
+DELETE FROM diffscalendar WHERE updatedAt < NOW() AT TIME ZONE 'UTC';
@@ -3,6 +3,7 @@ WITH unique_pairs AS (
     FROM pairsfutures
     WHERE markPrice <> 0 
       AND indexPrice <> 0
+      AND status = 'active'
 ),
 market_combinations AS (
     SELECT 
@@ -40,11 +41,17 @@ market_combinations AS (
             a.quoteAsset = b.quoteAsset 
             OR (a.quoteAsset IN ('USDT', 'USDC') AND b.quoteAsset IN ('USDT', 'USDC'))
         )
+        -- Inverse contracts are compared with linear ones in updateDiffsInverse.sql
+        AND a.contractType = b.contractType
         
     WHERE a.markPrice <> 0 
         AND a.indexPrice <> 0 
         AND b.markPrice <> 0 
         AND b.indexPrice <> 0
+        -- Stale and delisted markets keep their last price, they must not produce diffs
+        AND a.status = 'active' AND b.status = 'active'
+        -- Dated contracts are compared in updateDiffsCalendar.sql
+        AND a.expiry = 0 AND b.expiry = 0
 ),
 calculated_diffs AS (
     SELECT 
@@ -193,3 +200,7 @@ SET
     secondExchangeNetworks = EXCLUDED.secondExchangeNetworks,
     timeElapsed = EXCLUDED.timeElapsed,
     updatedAt = NOW() AT TIME ZONE 'UTC';
+
+-- Rows not refreshed above belong to combinations that no longer exist
+-- (a market was delisted, went stale or lost its price)
+DELETE FROM diffsfutures WHERE updatedAt < NOW() AT TIME ZONE 'UTC';
@@ -0,0 +1,155 @@
+-- Inverse perpetuals against the linear perpetuals of the same asset, on the
+-- same or another exchange. Inverse contracts are priced in USD, so their mark
+-- price is converted into the quote of the linear contract with the median spot
+-- USDT/USD or USDC/USD price (1 when no exchange lists it).
+-- Funding is a fraction of the position value for both kinds, only the asset
+-- it is paid in differs, so the rates are compared as they are.
+WITH usd_prices AS (
+    SELECT
+        baseAsset AS asset,
+        PERCENTILE_CONT(0.5) WITHIN GROUP (ORDER BY price)::NUMERIC AS usdPrice
+    FROM pairs
+    WHERE quoteAsset = 'USD'
+        AND baseAsset IN ('USDT', 'USDC')
+        AND price > 0
+        AND status = 'active'
+    GROUP BY baseAsset
+),
+market_combinations AS (
+    SELECT
+        a.symbol AS inverseSymbol,
+        a.exchange AS inverseExchange,
+        a.markPrice AS inverseMarkPrice,
+        a.contractValue AS inverseContractValue,
+        a.baseVolume24h AS inverseVolume,
+        a.fundingRatePercent AS inverseFundingRate,
+        a.baseAsset AS baseAsset,
+
+        b.symbol AS linearSymbol,
+        b.exchange AS linearExchange,
+        b.quoteAsset AS linearQuoteAsset,
+        b.markPrice AS linearMarkPrice,
+        b.baseVolume24h AS linearVolume,
+        b.fundingRatePercent AS linearFundingRate,
+
+        -- Quote units of the linear contract per USD
+        CASE WHEN b.quoteAsset = 'USD' THEN 1 ELSE 1 / COALESCE(r.usdPrice, 1) END AS conversionRate
+
+    FROM pairsfutures a
+    JOIN pairsfutures b
+        ON a.baseAsset = b.baseAsset
+        AND b.contractType = 'linear'
+        AND b.quoteAsset IN ('USDT', 'USDC', 'USD')
+    LEFT JOIN usd_prices r ON r.asset = b.quoteAsset
+
+    WHERE a.contractType = 'inverse'
+        AND a.quoteAsset = 'USD'
+        -- Dated contracts are compared in updateDiffsCalendar.sql
+        AND a.expiry = 0 AND b.expiry = 0
+        AND a.markPrice <> 0
+        AND b.markPrice <> 0
+        -- Stale and delisted markets keep their last price, they must not produce diffs
+        AND a.status = 'active' AND b.status = 'active'
+),
+calculated_diffs AS (
+    SELECT
+        CONCAT(inverseSymbol, '_', linearSymbol) AS symbol,
+        CONCAT(inverseSymbol, '_', linearSymbol, '_', inverseExchange, '-', linearExchange) AS pairKey,
+        baseAsset,
+        inverseExchange,
+        inverseSymbol,
+        ROUND(inverseMarkPrice, 8) AS inverseMarkPrice,
+        ROUND(inverseMarkPrice * conversionRate, 8) AS inverseConvertedMarkPrice,
+        inverseContractValue,
+        ROUND(inverseVolume, 2) AS inverseVolume,
+        ROUND(inverseFundingRate, 6) AS inverseFundingRate,
+        linearExchange,
+        linearSymbol,
+        linearQuoteAsset,
+        ROUND(linearMarkPrice, 8) AS linearMarkPrice,
+        ROUND(linearVolume, 2) AS linearVolume,
+        ROUND(linearFundingRate, 6) AS linearFundingRate,
+        ROUND(conversionRate, 8) AS conversionRate,
+        ROUND(linearMarkPrice - inverseMarkPrice * conversionRate, 8) AS differenceMark,
+        CASE
+            WHEN TRUNC(((linearMarkPrice - inverseMarkPrice * conversionRate) / (inverseMarkPrice * conversionRate)) * 100, 2) > 1000000000 THEN 1000000000
+            WHEN TRUNC(((linearMarkPrice - inverseMarkPrice * conversionRate) / (inverseMarkPrice * conversionRate)) * 100, 2) < -1000000000 THEN -1000000000
+            ELSE TRUNC(((linearMarkPrice - inverseMarkPrice * conversionRate) / (inverseMarkPrice * conversionRate)) * 100, 2)
+        END AS differenceMarkPercentage,
+        ROUND(linearFundingRate - inverseFundingRate, 6) AS differenceFundingRatePercent,
+        (CASE
+            WHEN (inverseFundingRate > 0 AND linearFundingRate < 0)
+              OR (inverseFundingRate < 0 AND linearFundingRate > 0)
+            THEN TRUE
+            ELSE FALSE
+        END) AS isFundingRateOpposite
+    FROM market_combinations
+)
+INSERT INTO diffsinverse (
+    pairKey,
+    symbol,
+    baseAsset,
+    inverseExchange,
+    inverseSymbol,
+    inverseMarkPrice,
+    inverseConvertedMarkPrice,
+    inverseContractValue,
+    inverseVolume,
+    inverseFundingRate,
+    linearExchange,
+    linearSymbol,
+    linearQuoteAsset,
+    linearMarkPrice,
+    linearVolume,
+    linearFundingRate,
+    conversionRate,
+    differenceMark,
+    differenceMarkPercentage,
+    differenceFundingRatePercent,
+    isFundingRateOpposite,
+    updatedAt
+)
+SELECT
+    pairKey,
+    symbol,
+    baseAsset,
+    inverseExchange,
+    inverseSymbol,
+    inverseMarkPrice,
+    inverseConvertedMarkPrice,
+    inverseContractValue,
+    inverseVolume,
+    inverseFundingRate,
+    linearExchange,
+    linearSymbol,
+    linearQuoteAsset,
+    linearMarkPrice,
+    linearVolume,
+    linearFundingRate,
+    conversionRate,
+    differenceMark,
+    differenceMarkPercentage,
+    differenceFundingRatePercent,
+    isFundingRateOpposite,
+    NOW() AT TIME ZONE 'UTC'
+FROM calculated_diffs
+ON CONFLICT (pairKey) DO UPDATE
+SET
+    inverseMarkPrice = EXCLUDED.inverseMarkPrice,
+    inverseConvertedMarkPrice = EXCLUDED.inverseConvertedMarkPrice,
+    inverseContractValue = EXCLUDED.inverseContractValue,
+    inverseVolume = EXCLUDED.inverseVolume,
+    inverseFundingRate = EXCLUDED.inverseFundingRate,
+    linearMarkPrice = EXCLUDED.linearMarkPrice,
+    linearVolume = EXCLUDED.linearVolume,
+    linearFundingRate = EXCLUDED.linearFundingRate,
+    conversionRate = EXCLUDED.conversionRate,
+    differenceMark = EXCLUDED.differenceMark,
+    differenceMarkPercentage = EXCLUDED.differenceMarkPercentage,
+    differenceFundingRatePercent = EXCLUDED.differenceFundingRatePercent,
+    isFundingRateOpposite = EXCLUDED.isFundingRateOpposite,
+    updatedAt = NOW() AT TIME ZONE 'UTC';
+
+-- Rows not refreshed above belong to combinations that no longer exist
+-- (a market was delisted, went stale or lost its price)
+DELETE FROM diffsinverse WHERE updatedAt < NOW() AT TIME ZONE 'UTC';
@@ -1,18 +1,27 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
 	"os"
 	"strings"
 	"time"
+
+	"Updater/metrics"
 )
 
 // ExecuteSQL виконує переданий SQL-запит з retry при deadlock
 func ExecuteSQL(db *sql.DB, query string) error {
+	return ExecuteSQLContext(context.Background(), db, query)
+}
+
+// ExecuteSQLContext is ExecuteSQL that stops executing and retrying once ctx is done.
+func ExecuteSQLContext(ctx context.Context, db *sql.DB, query string) error {
 	maxRetries := 5
 	for i := 0; i < maxRetries; i++ {
-		_, err := db.Exec(query)
+		_, err := db.ExecContext(ctx, query)
 		if err == nil {
 			return nil
 		}
@@ -20,6 +29,8 @@ func ExecuteSQL(db *sql.DB, query string) error {
 		// Check if it's a deadlock error - retry with exponential backoff
 		if strings.Contains(err.Error(), "deadlock") {
 			if i < maxRetries-1 {
+				metrics.DeadlockRetries.Inc()
+				slog.Warn("deadlock detected, retrying SQL", "attempt", i+1)
 				time.Sleep(time.Duration(200*(i+1)) * time.Millisecond)
 				continue
 			}
@@ -1,28 +1,39 @@
 package backpack
 
 import (
-	"crypto/ed25519"
+	"context"
 	"database/sql"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"Updater/exchanges/httpclient"
+	"Updater/exchanges/signing"
+	"Updater/logging"
+	"Updater/metrics"
 	"Updater/models"
 )
 
+var (
+	logger       = logging.Exchange("Backpack")
+	parseSampler = logging.NewSampler()
+	httpClient   = httpclient.For("Backpack")
+)
+
+// baseURL is a variable so tests can point the connector at recorded responses
+var baseURL = "https://api.backpack.exchange"
+
 const (
-	exchangeInfoURL = "https://api.backpack.exchange/api/v1/markets"
-	ticker24hrURL   = "https://api.backpack.exchange/api/v1/tickers"
-	assetDetailURL  = "https://api.backpack.exchange/api/v1/capital"
-	serverTimeURL   = "https://api.backpack.exchange/api/v1/time"
-	markPricesURL   = "https://api.backpack.exchange/api/v1/markPrices"
+	exchangeInfoPath = "/api/v1/markets"
+	ticker24hrPath   = "/api/v1/tickers"
+	assetDetailPath  = "/api/v1/capital"
+	serverTimePath   = "/api/v1/time"
+	markPricesPath   = "/api/v1/markPrices"
 )
 
 // Структура для відповіді про торгові пари
@@ -62,10 +73,15 @@ type MarkPrices struct {
 }
 
 // Функція для виконання HTTP-запиту та парсингу JSON
-func fetchJSON(url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
+func fetchJSON(ctx context.Context, url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
 	defer wg.Done()
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		errChan <- fmt.Errorf("Backpack error fetching %s: %w", url, err)
+		return
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		errChan <- fmt.Errorf("Backpack error fetching %s: %w", url, err)
 		return
@@ -95,7 +111,8 @@ func parseFloat(s string, d string) (float64, bool) {
 	}
 	val, err := strconv.ParseFloat(s, 64)
 	if err != nil {
-		log.Printf("Backpack Warning: failed to parse float from %s, description: %s", s, d)
+		parseSampler.Warn(logger, "parseFloat", "failed to parse float", "value", s, "field", d)
+		metrics.ParseWarnings.WithLabelValues("Backpack").Inc()
 		return 0, false
 	}
 	return val, true
@@ -124,9 +141,8 @@ func generateNumberedPlaceholders(rows int, fieldCount int) string {
 	return strings.Join(placeholders, ", ")
 }
 
-// UpdateAllSpotPairs - оновлення даних про торгові пари
-func UpdateAllSpotPairs(db *sql.DB) bool {
-	// log.Printf("Backpack update pairs started")
+// fetchSpotPairs - завантаження спотових ринків і побудова пар
+func fetchSpotPairs(ctx context.Context) ([]models.Pair, error) {
 	var wg sync.WaitGroup
 	errChan := make(chan error, 3)
 
@@ -136,8 +152,8 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 
 	// Запускаємо три паралельні запити
 	wg.Add(2)
-	go fetchJSON(exchangeInfoURL, &exchangeInfo, &wg, errChan)
-	go fetchJSON(ticker24hrURL, &ticker24hrs, &wg, errChan)
+	go fetchJSON(ctx, baseURL+exchangeInfoPath, &exchangeInfo, &wg, errChan)
+	go fetchJSON(ctx, baseURL+ticker24hrPath, &ticker24hrs, &wg, errChan)
 
 	// Чекаємо завершення всіх запитів
 	wg.Wait()
@@ -146,8 +162,7 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 	// Перевіряємо наявність помилок
 	for err := range errChan {
 		if err != nil {
-			log.Printf("Error: %v", err)
-			return false
+			return nil, err
 		}
 	}
 
@@ -187,16 +202,25 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 			PriceChangePercent24h: formatFloat(priceChange, 2),
 			BaseVolume24h:         formatFloat(baseVolume, 2),
 			QuoteVolume24h:        formatFloat(quoteVolume, 2),
-			UpdatedAt:             time.Now(),
+			UpdatedAt:             time.Now().UTC(),
 		}
 		pairs = append(pairs, pair)
 	}
 
+	return pairs, nil
+}
+
+// UpdateAllSpotPairs - оновлення даних про торгові пари
+func UpdateAllSpotPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchSpotPairs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
 	// Зберігаємо в базу даних
 	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("Backpack Failed to begin transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Backpack Failed to begin transaction: %w", err)
 	}
 
 	// Використовуємо 12 колонок для запису
@@ -213,8 +237,7 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
     `
 	stmt, err := tx.Prepare(query)
 	if err != nil {
-		log.Printf("Backpack Failed to prepare statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Backpack Failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
@@ -227,81 +250,104 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 	_, err = stmt.Exec(args...)
 	if err != nil {
 		tx.Rollback()
-		log.Printf("Backpack Failed to execute statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Backpack Failed to execute statement: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("Backpack Failed to commit transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Backpack Failed to commit transaction: %w", err)
 	}
 
-	// log.Printf("Backpack pairs updated")
-	return true
+	return len(pairs), nil
 }
 
-// UpdateAllNetworks - оновлення даних про доступні мережі
-func UpdateAllNetworks(db *sql.DB, apiKey, secretKey string) bool {
-	if apiKey == "" || secretKey == "" {
-		log.Println("Backpack error: API key or secret key is empty")
-		return false
+// serverClock corrects signed request timestamps for the local clock skew.
+var serverClock = signing.NewClock("Backpack", getServerTime)
+
+const (
+	// capitalInstruction is the instruction Backpack signs for assetDetailPath
+	capitalInstruction = "balanceQuery"
+	receiveWindow      = 5000
+)
+
+// fetchNetworks - завантаження доступних мереж для кожного активу
+func fetchNetworks(ctx context.Context, creds signing.Credentials) ([]models.Network, error) {
+	if !creds.Configured() {
+		return nil, fmt.Errorf("Backpack error: %w", signing.ErrNoCredentials)
 	}
 
-	// Синхронізація часу з сервером Backpack
-	serverTime, err := getServerTime()
+	serverTime, err := serverClock.Now(ctx)
 	if err != nil {
-		log.Printf("Backpack error fetching server time: %v", err)
-		return false
+		return nil, err
 	}
-
-	// Додаємо timestamp і window до запиту
 	timestamp := serverTime.UnixMilli()
-	receiveWindow := 5000 // Рекомендоване значення
 	queryString := fmt.Sprintf("timestamp=%d&window=%d", timestamp, receiveWindow)
 
-	// Генеруємо підпис (Backpack використовує ED25519)
-	signature := generateSignature(queryString, secretKey)
-	urlWithSignature := fmt.Sprintf("%s?%s", assetDetailURL, queryString)
+	// Backpack signs the instruction followed by the query parameters
+	signature, err := signing.ED25519(creds.Secret, "instruction="+capitalInstruction+"&"+queryString)
+	if err != nil {
+		return nil, fmt.Errorf("Backpack error signing request: %w", err)
+	}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequest("GET", urlWithSignature, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+assetDetailPath+"?"+queryString, nil)
 	if err != nil {
-		log.Printf("Backpack error creating request: %v", err)
-		return false
+		return nil, fmt.Errorf("Backpack error creating request: %w", err)
 	}
-	req.Header.Set("X-API-Key", apiKey)
+	req.Header.Set("X-API-Key", creds.Key)
 	req.Header.Set("X-Signature", signature)
-	req.Header.Set("X-Timestamp", fmt.Sprintf("%d", timestamp))
-	req.Header.Set("X-Window", fmt.Sprintf("%d", receiveWindow))
+	req.Header.Set("X-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Window", strconv.Itoa(receiveWindow))
 
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		log.Printf("Backpack error fetching asset details: %v", err)
-		return false
+		return nil, fmt.Errorf("Backpack error fetching asset details: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("Backpack non-OK status code %d from %s", resp.StatusCode, urlWithSignature)
-		return false
+		// A rejected timestamp is the usual cause, measure the offset again on the next run
+		serverClock.Invalidate()
+		return nil, fmt.Errorf("Backpack non-OK status code %d from %s", resp.StatusCode, baseURL+assetDetailPath)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Backpack error reading response: %v", err)
-		return false
+		return nil, fmt.Errorf("Backpack error reading response: %w", err)
 	}
 
 	var assets []AssetDetail
 	if err := json.Unmarshal(body, &assets); err != nil {
-		log.Printf("Backpack error unmarshalling JSON: %v", err)
-		return false
+		return nil, fmt.Errorf("Backpack error unmarshalling JSON: %w", err)
+	}
+
+	var networks []models.Network
+	for _, asset := range assets {
+		for _, network := range asset.Networks {
+			networks = append(networks, models.Network{
+				CoinKey:        fmt.Sprintf("%s_Backpack_%s", asset.Asset, network.Network),
+				Coin:           asset.Asset,
+				Exchange:       "Backpack",
+				Network:        network.Network,
+				NetworkName:    network.Name,
+				DepositEnable:  network.DepositEnabled,
+				WithdrawEnable: network.WithdrawalEnabled,
+				UpdatedAt:      time.Now().UTC(),
+			})
+		}
+	}
+
+	return networks, nil
+}
+
+// UpdateAllNetworks - оновлення даних про доступні мережі
+func UpdateAllNetworks(ctx context.Context, db *sql.DB, creds signing.Credentials) (int, error) {
+	networks, err := fetchNetworks(ctx, creds)
+	if err != nil {
+		return 0, err
 	}
 
 	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("Backpack Failed to begin transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Backpack Failed to begin transaction: %w", err)
 	}
 
 	// Формуємо SQL-запит з `ON CONFLICT`
@@ -319,67 +365,46 @@ func UpdateAllNetworks(db *sql.DB, apiKey, secretKey string) bool {
 	var args []interface{}
 	counter := 1
 
-	for _, asset := range assets {
-		for _, network := range asset.Networks {
-			coinKey := fmt.Sprintf("%s_Backpack_%s", asset.Asset, network.Network)
-			values = append(values, fmt.Sprintf("($%d, $%d, 'Backpack', $%d, $%d, $%d, $%d, $%d)", counter, counter+1, counter+2, counter+3, counter+4, counter+5, counter+6))
-			args = append(args, coinKey, asset.Asset, network.Network, network.Name, network.DepositEnabled, network.WithdrawalEnabled, time.Now().UTC())
-			counter += 7
-		}
+	for _, n := range networks {
+		values = append(values, fmt.Sprintf("($%d, $%d, 'Backpack', $%d, $%d, $%d, $%d, $%d)", counter, counter+1, counter+2, counter+3, counter+4, counter+5, counter+6))
+		args = append(args, n.CoinKey, n.Coin, n.Network, n.NetworkName, n.DepositEnable, n.WithdrawEnable, n.UpdatedAt)
+		counter += 7
 	}
 
 	if len(values) == 0 {
-		log.Println("Backpack: No network data to update")
+		logger.Debug("no network data to update")
 		tx.Rollback()
-		return true
+		return 0, nil
 	}
 
 	fullQuery := fmt.Sprintf(query, strings.Join(values, ", "))
 	_, err = tx.Exec(fullQuery, args...)
 	if err != nil {
 		tx.Rollback()
-		log.Printf("Backpack Failed to execute statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Backpack Failed to execute statement: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("Backpack Failed to commit transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Backpack Failed to commit transaction: %w", err)
 	}
 
-	return true
+	return len(values), nil
 }
 
-// generateSignature - генерація ED25519 підпису
-func generateSignature(message, secretKey string) string {
-	// Backpack використовує ED25519, а не HMAC-SHA256, як Binance
-	secretBytes, err := base64.StdEncoding.DecodeString(secretKey)
+// getServerTime - отримання часу сервера Backpack
+func getServerTime(ctx context.Context) (time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+serverTimePath, nil)
 	if err != nil {
-		log.Printf("Backpack error decoding secret key: %v", err)
-		return ""
-	}
-
-	// Перевіряємо, чи ключ відповідає ED25519 (64 байти для приватного ключа)
-	if len(secretBytes) != ed25519.PrivateKeySize {
-		log.Printf("Backpack error: invalid ED25519 secret key length")
-		return ""
+		return time.Time{}, fmt.Errorf("error fetching server time: %w", err)
 	}
-
-	privateKey := ed25519.PrivateKey(secretBytes)
-	signature := ed25519.Sign(privateKey, []byte(message))
-	return base64.StdEncoding.EncodeToString(signature)
-}
-
-// getServerTime - отримання часу сервера Backpack
-func getServerTime() (time.Time, error) {
-	resp, err := http.Get(serverTimeURL)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return time.Time{}, fmt.Errorf("error fetching server time: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return time.Time{}, fmt.Errorf("non-OK status code %d from %s", resp.StatusCode, serverTimeURL)
+		return time.Time{}, fmt.Errorf("non-OK status code %d from %s", resp.StatusCode, baseURL+serverTimePath)
 	}
 
 	var result struct {
@@ -392,8 +417,8 @@ func getServerTime() (time.Time, error) {
 	return time.UnixMilli(result.ServerTime), nil
 }
 
-func UpdateAllFuturesPairs(db *sql.DB) bool {
-	// log.Printf("Backpack update pairs started")
+// fetchFuturesPairs - завантаження безстрокових ф'ючерсів (PERP) і побудова пар
+func fetchFuturesPairs(ctx context.Context) ([]models.PairFutures, error) {
 	var wg sync.WaitGroup
 	errChan := make(chan error, 3)
 
@@ -404,9 +429,9 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
 
 	// Запускаємо три паралельні запити
 	wg.Add(3)
-	go fetchJSON(exchangeInfoURL, &exchangeInfo, &wg, errChan)
-	go fetchJSON(ticker24hrURL, &ticker24hrs, &wg, errChan)
-	go fetchJSON(markPricesURL, &markPrices, &wg, errChan)
+	go fetchJSON(ctx, baseURL+exchangeInfoPath, &exchangeInfo, &wg, errChan)
+	go fetchJSON(ctx, baseURL+ticker24hrPath, &ticker24hrs, &wg, errChan)
+	go fetchJSON(ctx, baseURL+markPricesPath, &markPrices, &wg, errChan)
 
 	// Чекаємо завершення всіх запитів
 	wg.Wait()
@@ -415,8 +440,7 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
 	// Перевіряємо наявність помилок
 	for err := range errChan {
 		if err != nil {
-			log.Printf("Error: %v", err)
-			return false
+			return nil, err
 		}
 	}
 
@@ -468,16 +492,24 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
 			PriceChangePercent24h: formatFloat(priceChange, 2),
 			BaseVolume24h:         formatFloat(baseVolume, 2),
 			QuoteVolume24h:        formatFloat(quoteVolume, 2),
-			UpdatedAt:             time.Now(),
+			UpdatedAt:             time.Now().UTC(),
 		}
 		pairs = append(pairs, pair)
 	}
 
+	return pairs, nil
+}
+
+func UpdateAllFuturesPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchFuturesPairs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
 	// Зберігаємо в базу даних
 	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("Backpack Failed to begin transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Backpack Failed to begin transaction: %w", err)
 	}
 
 	// Використовуємо 15 колонок для запису
@@ -497,8 +529,7 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
     `
 	stmt, err := tx.Prepare(query)
 	if err != nil {
-		log.Printf("Backpack Failed to prepare statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Backpack Failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
@@ -527,15 +558,12 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
 	_, err = stmt.Exec(args...)
 	if err != nil {
 		tx.Rollback()
-		log.Printf("Backpack Failed to execute statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Backpack Failed to execute statement: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("Backpack Failed to commit transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Backpack Failed to commit transaction: %w", err)
 	}
 
-	// log.Printf("Backpack pairs futures updated")
-	return true
+	return len(pairs), nil
 }
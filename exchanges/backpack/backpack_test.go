@@ -0,0 +1,143 @@
+package backpack
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+
+	"Updater/exchanges/exchangetest"
+	"Updater/exchanges/signing"
+	"Updater/models"
+)
+
+func TestFetchSpotPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		exchangeInfoPath: "markets.json",
+		ticker24hrPath:   "tickers.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	pairs, err := fetchSpotPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// PERP and PREDICTION markets are not spot; NEW_USDC has no last price yet
+	exchangetest.Compare(t, pairs, []models.Pair{
+		{
+			PairKey:               "SOLUSDC_Backpack_spot",
+			Symbol:                "SOLUSDC",
+			Exchange:              "Backpack",
+			Market:                "spot",
+			Price:                 145.12345679,
+			BaseAsset:             "SOL",
+			QuoteAsset:            "USDC",
+			DisplayName:           "SOL/USDC",
+			PriceChangePercent24h: 1.41,
+			BaseVolume24h:         51234.57,
+			QuoteVolume24h:        7430000.46,
+		},
+		{
+			PairKey:               "BTCUSDC_Backpack_spot",
+			Symbol:                "BTCUSDC",
+			Exchange:              "Backpack",
+			Market:                "spot",
+			Price:                 67012.5,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDC",
+			DisplayName:           "BTC/USDC",
+			PriceChangePercent24h: -0.72,
+			BaseVolume24h:         12.35,
+			QuoteVolume24h:        827000.46,
+		},
+	})
+}
+
+func TestFetchFuturesPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		exchangeInfoPath: "markets.json",
+		ticker24hrPath:   "tickers.json",
+		markPricesPath:   "mark_prices.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	pairs, err := fetchFuturesPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only PERP markets are futures; ETH_USDC_PERP has no mark price and
+	// BTC_USDC_PERP no 24h statistics
+	exchangetest.Compare(t, pairs, []models.PairFutures{
+		{
+			PairKey:               "SOLUSDC_Backpack_futures",
+			Symbol:                "SOLUSDC",
+			Exchange:              "Backpack",
+			Market:                "futures",
+			MarkPrice:             145.04987654,
+			IndexPrice:            145.01234567,
+			BaseAsset:             "SOL",
+			QuoteAsset:            "USDC",
+			DisplayName:           "SOL/USDC",
+			FundingRatePercent:    0.000013,
+			NextFundingTimestamp:  1718006400,
+			PriceChangePercent24h: 1.43,
+			BaseVolume24h:         250000.1,
+			QuoteVolume24h:        36250000.75,
+		},
+		{
+			PairKey:              "BTCUSDC_Backpack_futures",
+			Symbol:               "BTCUSDC",
+			Exchange:             "Backpack",
+			Market:               "futures",
+			MarkPrice:            67005.25,
+			IndexPrice:           67001.5,
+			BaseAsset:            "BTC",
+			QuoteAsset:           "USDC",
+			DisplayName:          "BTC/USDC",
+			FundingRatePercent:   -0.00005,
+			NextFundingTimestamp: 1718006400,
+		},
+	})
+}
+
+func TestFetchNetworks(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		serverTimePath:  "server_time.json",
+		assetDetailPath: "capital.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	serverClock.Invalidate()
+
+	// Backpack hands out the 32 byte seed as the API secret
+	seed := make([]byte, ed25519.SeedSize)
+	privateKey := ed25519.NewKeyFromSeed(seed)
+	creds := signing.Credentials{Key: "key", Secret: base64.StdEncoding.EncodeToString(seed)}
+
+	networks, err := fetchNetworks(context.Background(), creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exchangetest.Compare(t, networks, []models.Network{
+		{CoinKey: "USDC_Backpack_Solana", Coin: "USDC", Exchange: "Backpack", Network: "Solana", NetworkName: "Solana", DepositEnable: true, WithdrawEnable: true},
+		{CoinKey: "USDC_Backpack_Ethereum", Coin: "USDC", Exchange: "Backpack", Network: "Ethereum", NetworkName: "Ethereum", WithdrawEnable: true},
+		{CoinKey: "SOL_Backpack_Solana", Coin: "SOL", Exchange: "Backpack", Network: "Solana", NetworkName: "Solana", DepositEnable: true},
+	})
+
+	requests := srv.Requests()
+	signed := requests[len(requests)-1]
+	if got := signed.Header.Get("X-API-Key"); got != "key" {
+		t.Errorf("X-API-Key = %q, want %q", got, "key")
+	}
+	if got := signed.Header.Get("X-Timestamp"); got != "1718000000000" {
+		t.Errorf("X-Timestamp = %q, want the server time", got)
+	}
+	signature, err := base64.StdEncoding.DecodeString(signed.Header.Get("X-Signature"))
+	message := "instruction=balanceQuery&" + signed.URL.RawQuery
+	if err != nil || !ed25519.Verify(privateKey.Public().(ed25519.PublicKey), []byte(message), signature) {
+		t.Errorf("X-Signature does not sign %q", message)
+	}
+}
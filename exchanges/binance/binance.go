@@ -1,31 +1,54 @@
 package binance
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"Updater/exchanges/httpclient"
+	"Updater/exchanges/signing"
+	"Updater/logging"
+	"Updater/metrics"
 	"Updater/models"
 )
 
+var (
+	logger       = logging.Exchange("Binance")
+	parseSampler = logging.NewSampler()
+	httpClient   = httpclient.For("Binance")
+	// The futures APIs have their own weight limits
+	futuresClient     = httpclient.For("Binance Futures")
+	coinFuturesClient = httpclient.For("Binance COIN-M")
+)
+
+// Base URLs are variables so tests can point the connector at recorded responses
+var (
+	spotBaseURL        = "https://api.binance.com"
+	futuresBaseURL     = "https://fapi.binance.com"
+	coinFuturesBaseURL = "https://dapi.binance.com"
+)
+
 const (
-	exchangeInfoURL        = "https://api.binance.com/api/v3/exchangeInfo?permissions=SPOT&symbolStatus=TRADING"
-	tickerPriceURL         = "https://api.binance.com/api/v3/ticker/price"
-	ticker24hrURL          = "https://api.binance.com/api/v3/ticker/24hr"
-	assetDetailURL         = "https://api.binance.com/sapi/v1/capital/config/getall"
-	serverTimeURL          = "https://api.binance.com/api/v3/time"
-	exchangeInfoFuturesURL = "https://fapi.binance.com/fapi/v1/exchangeInfo"
-	ticker24hrFuturesURL   = "https://fapi.binance.com/fapi/v1/ticker/24hr"
-	futuresDataURL         = "https://fapi.binance.com/fapi/v1/premiumIndex"
+	exchangeInfoPath        = "/api/v3/exchangeInfo?permissions=SPOT&symbolStatus=TRADING"
+	tickerPricePath         = "/api/v3/ticker/price"
+	ticker24hrPath          = "/api/v3/ticker/24hr"
+	assetDetailPath         = "/sapi/v1/capital/config/getall"
+	serverTimePath          = "/api/v3/time"
+	exchangeInfoFuturesPath = "/fapi/v1/exchangeInfo"
+	ticker24hrFuturesPath   = "/fapi/v1/ticker/24hr"
+	futuresDataPath         = "/fapi/v1/premiumIndex"
+	// COIN-M futures
+	exchangeInfoCoinFuturesPath = "/dapi/v1/exchangeInfo"
+	ticker24hrCoinFuturesPath   = "/dapi/v1/ticker/24hr"
+	coinFuturesDataPath         = "/dapi/v1/premiumIndex"
 )
 
 type AssetDetail struct {
@@ -59,12 +82,28 @@ type Ticker24hrResponse struct {
 	QuoteVolume24h        string `json:"quoteVolume"`
 }
 
+// CoinFuturesExchangeInfoResponse lists the COIN-M contracts, which are margined
+// in the base asset and worth contractSize USD each.
+type CoinFuturesExchangeInfoResponse struct {
+	Symbols []struct {
+		Symbol         string `json:"symbol"`
+		BaseAsset      string `json:"baseAsset"`
+		QuoteAsset     string `json:"quoteAsset"`
+		MarginAsset    string `json:"marginAsset"`
+		ContractType   string `json:"contractType"`
+		ContractStatus string `json:"contractStatus"`
+		ContractSize   int    `json:"contractSize"`
+		DeliveryDate   int64  `json:"deliveryDate"`
+	} `json:"symbols"`
+}
+
 type FuturesExchangeInfoResponse struct {
 	Symbols []struct {
 		Symbol                string `json:"symbol"`
 		BaseAsset             string `json:"baseAsset"`
 		QuoteAsset            string `json:"quoteAsset"`
 		ContractType          string `json:"contractType"`
+		DeliveryDate          int64  `json:"deliveryDate"`
 		PricePrecision        int    `json:"pricePrecision"`
 		QuantityPrecision     int    `json:"quantityPrecision"`
 		MaintMarginPercent    string `json:"maintMarginPercent"`
@@ -72,10 +111,15 @@ type FuturesExchangeInfoResponse struct {
 	} `json:"symbols"`
 }
 
-func fetchJSON(url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
+func fetchJSON(ctx context.Context, client *httpclient.Client, url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
 	defer wg.Done()
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		errChan <- fmt.Errorf("Binance error fetching %s: %w", url, err)
+		return
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		errChan <- fmt.Errorf("Binance error fetching %s: %w", url, err)
 		return
@@ -102,7 +146,8 @@ func fetchJSON(url string, target interface{}, wg *sync.WaitGroup, errChan chan<
 func parseFloat(s string, d string) float64 {
 	val, err := strconv.ParseFloat(s, 64)
 	if err != nil {
-		log.Printf("Binance Warning: failed to parse float from %s, description: %s", s, d)
+		parseSampler.Warn(logger, "parseFloat", "failed to parse float", "value", s, "field", d)
+		metrics.ParseWarnings.WithLabelValues("Binance").Inc()
 		return 0
 	}
 	return val
@@ -130,7 +175,8 @@ func generateNumberedPlaceholders(rows int, fieldCount int) string {
 	return strings.Join(placeholders, ", ")
 }
 
-func UpdateAllSpotPairs(db *sql.DB) bool {
+// fetchSpotPairs downloads the spot markets and builds their pairs.
+func fetchSpotPairs(ctx context.Context) ([]models.Pair, error) {
 	var wg sync.WaitGroup
 	errChan := make(chan error, 3)
 
@@ -141,9 +187,9 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 
 	// Запускаємо три паралельні запити
 	wg.Add(3)
-	go fetchJSON(exchangeInfoURL, &exchangeInfo, &wg, errChan)
-	go fetchJSON(tickerPriceURL, &tickerPrices, &wg, errChan)
-	go fetchJSON(ticker24hrURL, &ticker24hrs, &wg, errChan)
+	go fetchJSON(ctx, httpClient, spotBaseURL+exchangeInfoPath, &exchangeInfo, &wg, errChan)
+	go fetchJSON(ctx, httpClient, spotBaseURL+tickerPricePath, &tickerPrices, &wg, errChan)
+	go fetchJSON(ctx, httpClient, spotBaseURL+ticker24hrPath, &ticker24hrs, &wg, errChan)
 
 	// Чекаємо завершення всіх запитів
 	wg.Wait()
@@ -152,8 +198,7 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 	// Перевіряємо наявність помилок
 	for err := range errChan {
 		if err != nil {
-			log.Printf("Error: %v", err)
-			return false
+			return nil, err
 		}
 	}
 
@@ -190,15 +235,23 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 			PriceChangePercent24h: formatFloat(parseFloat(ticker24hr.PriceChangePercent24h, "ticker24hr.PriceChangePercent24h"), 2),
 			BaseVolume24h:         formatFloat(parseFloat(ticker24hr.BaseVolume24h, "ticker24hr.BaseVolume24h"), 2),
 			QuoteVolume24h:        formatFloat(parseFloat(ticker24hr.QuoteVolume24h, "ticker24hr.QuoteVolume24h"), 2),
-			UpdatedAt:             time.Now(),
+			UpdatedAt:             time.Now().UTC(),
 		}
 		pairs = append(pairs, pair)
 	}
 
+	return pairs, nil
+}
+
+func UpdateAllSpotPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchSpotPairs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
 	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("Binance Failed to begin transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Binance Failed to begin transaction: %w", err)
 	}
 
 	// Using 12 columns per record
@@ -215,8 +268,7 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
     `
 	stmt, err := tx.Prepare(query)
 	if err != nil {
-		log.Printf("Binance Failed to prepare statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Binance Failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
@@ -229,79 +281,89 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 	_, err = stmt.Exec(args...)
 	if err != nil {
 		tx.Rollback()
-		log.Printf("Binance Failed to execute statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Binance Failed to execute statement: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("Binance Failed to commit transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Binance Failed to commit transaction: %w", err)
 	}
 
-	return true
+	return len(pairs), nil
 }
 
-func UpdateAllNetworks(db *sql.DB, apiKey, secretKey string) bool {
-	if apiKey == "" || secretKey == "" {
-		log.Println("Binance error: API key or secret key is empty")
-		return false
+// serverClock corrects signed request timestamps for the local clock skew.
+var serverClock = signing.NewClock("Binance", getServerTime)
+
+// fetchNetworks downloads the deposit and withdrawal status of every coin network.
+func fetchNetworks(ctx context.Context, creds signing.Credentials) ([]models.Network, error) {
+	if !creds.Configured() {
+		return nil, fmt.Errorf("Binance error: %w", signing.ErrNoCredentials)
 	}
 
-	// Синхронізація часу з сервером Binance
-	serverTime, err := getServerTime()
+	serverTime, err := serverClock.Now(ctx)
 	if err != nil {
-		log.Printf("Binance error fetching server time: %v", err)
-		return false
+		return nil, err
 	}
-	// log.Printf("Binance: Server time synchronized: %v", serverTime)
-
-	// Додаємо timestamp до запиту
-	timestamp := serverTime.UnixMilli()
-	queryString := fmt.Sprintf("timestamp=%d", timestamp)
-
-	// Генеруємо signature
-	signature := generateSignature(queryString, secretKey)
-	urlWithSignature := fmt.Sprintf("%s?%s&signature=%s", assetDetailURL, queryString, signature)
+	queryString := fmt.Sprintf("timestamp=%d", serverTime.UnixMilli())
+	signature := signing.HMACSHA256Hex(creds.Secret, queryString)
+	urlWithSignature := fmt.Sprintf("%s?%s&signature=%s", spotBaseURL+assetDetailPath, queryString, signature)
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequest("GET", urlWithSignature, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlWithSignature, nil)
 	if err != nil {
-		log.Printf("Binance error creating request: %v", err)
-		return false
+		return nil, fmt.Errorf("Binance error creating request: %w", err)
 	}
-	req.Header.Set("X-MBX-APIKEY", apiKey)
+	req.Header.Set("X-MBX-APIKEY", creds.Key)
 
-	// Log API key for debugging (only first few characters for security)
-	// log.Printf("Binance: Using API key: %s...", apiKey[:5])
-
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		log.Printf("Binance error fetching asset details: %v", err)
-		return false
+		return nil, fmt.Errorf("Binance error fetching asset details: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("Binance non-OK status code %d from %s", resp.StatusCode, urlWithSignature)
-		return false
+		// A rejected timestamp is the usual cause, measure the offset again on the next run
+		serverClock.Invalidate()
+		return nil, fmt.Errorf("Binance non-OK status code %d from %s", resp.StatusCode, spotBaseURL+assetDetailPath)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Binance error reading response: %v", err)
-		return false
+		return nil, fmt.Errorf("Binance error reading response: %w", err)
 	}
 
 	var assets []AssetDetail
 	if err := json.Unmarshal(body, &assets); err != nil {
-		log.Printf("Binance error unmarshalling JSON: %v", err)
-		return false
+		return nil, fmt.Errorf("Binance error unmarshalling JSON: %w", err)
+	}
+
+	var networks []models.Network
+	for _, asset := range assets {
+		for _, network := range asset.NetworkList {
+			networks = append(networks, models.Network{
+				CoinKey:        fmt.Sprintf("%s_Binance_%s", asset.Coin, network.Network),
+				Coin:           asset.Coin,
+				Exchange:       "Binance",
+				Network:        network.Network,
+				NetworkName:    network.Name,
+				DepositEnable:  network.DepositEnable,
+				WithdrawEnable: network.WithdrawEnable,
+				UpdatedAt:      time.Now().UTC(),
+			})
+		}
+	}
+
+	return networks, nil
+}
+
+func UpdateAllNetworks(ctx context.Context, db *sql.DB, creds signing.Credentials) (int, error) {
+	networks, err := fetchNetworks(ctx, creds)
+	if err != nil {
+		return 0, err
 	}
 
 	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("Binance Failed to begin transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Binance Failed to begin transaction: %w", err)
 	}
 
 	// Формуємо SQL-запит з `ON CONFLICT`
@@ -319,53 +381,45 @@ func UpdateAllNetworks(db *sql.DB, apiKey, secretKey string) bool {
 	var args []interface{}
 	counter := 1
 
-	for _, asset := range assets {
-		for _, network := range asset.NetworkList {
-			coinKey := fmt.Sprintf("%s_Binance_%s", asset.Coin, network.Network)
-			values = append(values, fmt.Sprintf("($%d, $%d, 'Binance', $%d, $%d, $%d, $%d, $%d)", counter, counter+1, counter+2, counter+3, counter+4, counter+5, counter+6))
-			args = append(args, coinKey, asset.Coin, network.Network, network.Name, network.DepositEnable, network.WithdrawEnable, time.Now().UTC())
-			counter += 7
-		}
+	for _, n := range networks {
+		values = append(values, fmt.Sprintf("($%d, $%d, 'Binance', $%d, $%d, $%d, $%d, $%d)", counter, counter+1, counter+2, counter+3, counter+4, counter+5, counter+6))
+		args = append(args, n.CoinKey, n.Coin, n.Network, n.NetworkName, n.DepositEnable, n.WithdrawEnable, n.UpdatedAt)
+		counter += 7
 	}
 
 	if len(values) == 0 {
-		log.Println("Binance: No network data to update")
+		logger.Debug("no network data to update")
 		tx.Rollback()
-		return true
+		return 0, nil
 	}
 
 	fullQuery := fmt.Sprintf(query, strings.Join(values, ", "))
 	_, err = tx.Exec(fullQuery, args...)
 	if err != nil {
 		tx.Rollback()
-		log.Printf("Binance Failed to execute statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Binance Failed to execute statement: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("Binance Failed to commit transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Binance Failed to commit transaction: %w", err)
 	}
 
-	// log.Println("Binance: Successfully updated network availability")
-	return true
-}
-
-func generateSignature(message, secret string) string {
-	mac := hmac.New(sha256.New, []byte(secret))
-	mac.Write([]byte(message))
-	return fmt.Sprintf("%x", mac.Sum(nil))
+	return len(values), nil
 }
 
-func getServerTime() (time.Time, error) {
-	resp, err := http.Get(serverTimeURL)
+func getServerTime(ctx context.Context) (time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, spotBaseURL+serverTimePath, nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error fetching server time: %w", err)
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return time.Time{}, fmt.Errorf("error fetching server time: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return time.Time{}, fmt.Errorf("non-OK status code %d from %s", resp.StatusCode, serverTimeURL)
+		return time.Time{}, fmt.Errorf("non-OK status code %d from %s", resp.StatusCode, spotBaseURL+serverTimePath)
 	}
 
 	var result struct {
@@ -378,7 +432,9 @@ func getServerTime() (time.Time, error) {
 	return time.UnixMilli(result.ServerTime), nil
 }
 
-func UpdateAllFuturesPairs(db *sql.DB) bool {
+// fetchFuturesPairs downloads the perpetual and quarterly futures and builds
+// their pairs.
+func fetchFuturesPairs(ctx context.Context) ([]models.PairFutures, error) {
 	var wg sync.WaitGroup
 	errChan := make(chan error, 3)
 
@@ -400,9 +456,9 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
 
 	// Fetch data from the Binance futures endpoints
 	wg.Add(3)
-	go fetchJSON(exchangeInfoFuturesURL, &futuresExchangeInfo, &wg, errChan)
-	go fetchJSON(futuresDataURL, &futuresData, &wg, errChan)
-	go fetchJSON(ticker24hrFuturesURL, &ticker24hrFutures, &wg, errChan)
+	go fetchJSON(ctx, futuresClient, futuresBaseURL+exchangeInfoFuturesPath, &futuresExchangeInfo, &wg, errChan)
+	go fetchJSON(ctx, futuresClient, futuresBaseURL+futuresDataPath, &futuresData, &wg, errChan)
+	go fetchJSON(ctx, futuresClient, futuresBaseURL+ticker24hrFuturesPath, &ticker24hrFutures, &wg, errChan)
 
 	wg.Wait()
 	close(errChan)
@@ -410,8 +466,7 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
 	// Check for errors
 	for err := range errChan {
 		if err != nil {
-			log.Printf("Binance Error: %v", err)
-			return false
+			return nil, err
 		}
 	}
 
@@ -420,16 +475,24 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
 		BaseAsset   string
 		QuoteAsset  string
 		DisplayName string
+		Expiry      int64
 	})
 	for _, sym := range futuresExchangeInfo.Symbols {
+		// Perpetuals report a delivery date far in the future, only quarterlies expire
+		var expiry int64
+		if !strings.Contains(sym.ContractType, "PERPETUAL") {
+			expiry = sym.DeliveryDate
+		}
 		symbolInfoMap[sym.Symbol] = struct {
 			BaseAsset   string
 			QuoteAsset  string
 			DisplayName string
+			Expiry      int64
 		}{
 			BaseAsset:   sym.BaseAsset,
 			QuoteAsset:  sym.QuoteAsset,
 			DisplayName: fmt.Sprintf("%s/%s", sym.BaseAsset, sym.QuoteAsset),
+			Expiry:      expiry,
 		}
 	}
 
@@ -455,20 +518,22 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
 	for _, data := range futuresData {
 		symbolInfo, exists := symbolInfoMap[data.Symbol]
 		if !exists {
-			// log.Printf("Binance Warning: Symbol %s not found in exchangeInfoFuturesURL", data.Symbol)
 			continue
 		}
 
 		ticker24hr, exists := ticker24hrMap[data.Symbol]
 		if !exists {
-			// log.Printf("Binance Warning: Symbol %s not found in ticker24hrFuturesURL", data.Symbol)
 			continue
 		}
 
 		// Parse and sanitize data
 		markPrice := parseFloat(data.MarkPrice, "futuresData.MarkPrice")
 		indexPrice := parseFloat(data.IndexPrice, "futuresData.IndexPrice")
-		lastFundingRate := parseFloat(data.FundingRate, "futuresData.FundingRate")
+		// Dated contracts have no funding
+		var lastFundingRate float64
+		if symbolInfo.Expiry == 0 {
+			lastFundingRate = parseFloat(data.FundingRate, "futuresData.FundingRate")
+		}
 		// fundingRatePercent := lastFundingRate * 100
 		fundingRatePercent := lastFundingRate
 		priceChangePercent24h := parseFloat(ticker24hr.PriceChangePercent24h, "ticker24hr.PriceChangePercent24h")
@@ -477,7 +542,7 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
 
 		// Skip invalid data
 		if markPrice <= 0 || indexPrice <= 0 {
-			log.Printf("Binance Warning: Skipping invalid data for symbol %s", data.Symbol)
+			parseSampler.Warn(logger, "invalidData", "skipping invalid futures data", "symbol", data.Symbol)
 			continue
 		}
 
@@ -497,26 +562,155 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
 			PriceChangePercent24h: priceChangePercent24h,
 			BaseVolume24h:         baseVolume24h,
 			QuoteVolume24h:        quoteVolume24h,
-			UpdatedAt:             time.Now(),
+			Expiry:                symbolInfo.Expiry,
+			ContractType:          models.ContractLinear,
+			ContractValue:         1,
+			UpdatedAt:             time.Now().UTC(),
+		}
+		pairs = append(pairs, pair)
+	}
+
+	return pairs, nil
+}
+
+// fetchInversePairs downloads the COIN-M perpetual and quarterly futures and
+// builds their pairs. Their volume is counted in contracts of contractSize USD.
+func fetchInversePairs(ctx context.Context) ([]models.PairFutures, error) {
+	var wg sync.WaitGroup
+	errChan := make(chan error, 3)
+
+	var exchangeInfo CoinFuturesExchangeInfoResponse
+	var premiumIndex []struct {
+		Symbol               string `json:"symbol"`
+		MarkPrice            string `json:"markPrice"`
+		IndexPrice           string `json:"indexPrice"`
+		FundingRate          string `json:"lastFundingRate"`
+		NextFundingTimestamp int64  `json:"nextFundingTime"`
+	}
+	var ticker24hr []struct {
+		Symbol                string `json:"symbol"`
+		PriceChangePercent24h string `json:"priceChangePercent"`
+		Volume                string `json:"volume"`
+		BaseVolume24h         string `json:"baseVolume"`
+	}
+
+	wg.Add(3)
+	go fetchJSON(ctx, coinFuturesClient, coinFuturesBaseURL+exchangeInfoCoinFuturesPath, &exchangeInfo, &wg, errChan)
+	go fetchJSON(ctx, coinFuturesClient, coinFuturesBaseURL+coinFuturesDataPath, &premiumIndex, &wg, errChan)
+	go fetchJSON(ctx, coinFuturesClient, coinFuturesBaseURL+ticker24hrCoinFuturesPath, &ticker24hr, &wg, errChan)
+
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	symbolIndex := make(map[string]int, len(exchangeInfo.Symbols))
+	for i, sym := range exchangeInfo.Symbols {
+		if sym.ContractStatus == "TRADING" {
+			symbolIndex[sym.Symbol] = i
+		}
+	}
+	tickerIndex := make(map[string]int, len(ticker24hr))
+	for i, t := range ticker24hr {
+		tickerIndex[t.Symbol] = i
+	}
+
+	var pairs []models.PairFutures
+	for _, data := range premiumIndex {
+		i, exists := symbolIndex[data.Symbol]
+		if !exists {
+			continue
+		}
+		sym := exchangeInfo.Symbols[i]
+		t, exists := tickerIndex[data.Symbol]
+		if !exists {
+			continue
+		}
+		ticker := ticker24hr[t]
+
+		markPrice := parseFloat(data.MarkPrice, "coinFuturesData.MarkPrice")
+		indexPrice := parseFloat(data.IndexPrice, "coinFuturesData.IndexPrice")
+		if markPrice <= 0 || indexPrice <= 0 {
+			parseSampler.Warn(logger, "invalidData", "skipping invalid futures data", "symbol", data.Symbol)
+			continue
+		}
+
+		// Dated contracts have no funding
+		var expiry int64
+		var fundingRate float64
+		if strings.Contains(sym.ContractType, "PERPETUAL") {
+			fundingRate = parseFloat(data.FundingRate, "coinFuturesData.FundingRate")
+		} else {
+			expiry = sym.DeliveryDate
+		}
+
+		pair := models.PairFutures{
+			PairKey:               fmt.Sprintf("%s_Binance_futures", data.Symbol),
+			Symbol:                data.Symbol,
+			Exchange:              "Binance",
+			Market:                "futures",
+			MarkPrice:             markPrice,
+			IndexPrice:            indexPrice,
+			BaseAsset:             sym.BaseAsset,
+			QuoteAsset:            sym.QuoteAsset,
+			DisplayName:           fmt.Sprintf("%s/%s", sym.BaseAsset, sym.QuoteAsset),
+			FundingRatePercent:    fundingRate,
+			NextFundingTimestamp:  int(data.NextFundingTimestamp),
+			PriceChangePercent24h: parseFloat(ticker.PriceChangePercent24h, "coinTicker24hr.PriceChangePercent24h"),
+			BaseVolume24h:         parseFloat(ticker.BaseVolume24h, "coinTicker24hr.BaseVolume24h"),
+			Expiry:                expiry,
+			ContractType:          models.ContractInverse,
+			ContractValue:         float64(sym.ContractSize),
+			UpdatedAt:             time.Now().UTC(),
 		}
+		// volume counts contracts, their notional is the USD volume
+		pair.QuoteVolume24h = pair.Notional(parseFloat(ticker.Volume, "coinTicker24hr.Volume"), markPrice)
 		pairs = append(pairs, pair)
 	}
 
+	return pairs, nil
+}
+
+// fetchAllFuturesPairs builds the USDⓈ-M pairs and adds the COIN-M ones. COIN-M
+// has its own endpoints, so when they fail the USDⓈ-M pairs are still stored.
+func fetchAllFuturesPairs(ctx context.Context) ([]models.PairFutures, error) {
+	pairs, err := fetchFuturesPairs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inverse, err := fetchInversePairs(ctx)
+	if err != nil {
+		logger.Warn("skipping COIN-M futures", "error", err)
+		metrics.PartialFailures.WithLabelValues("Binance", "inverse").Inc()
+		return pairs, nil
+	}
+	return append(pairs, inverse...), nil
+}
+
+// UpdateAllFuturesPairs stores the USDⓈ-M and COIN-M futures.
+func UpdateAllFuturesPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchAllFuturesPairs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
 	// Insert pairs into the database
 	if len(pairs) == 0 {
-		log.Printf("Binance No futures pairs to update")
-		return false
+		return 0, errors.New("Binance No futures pairs to update")
 	}
 
 	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("Binance Failed to begin transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Binance Failed to begin transaction: %w", err)
 	}
 
-	placeholderStr := generateNumberedPlaceholders(len(pairs), 15)
+	placeholderStr := generateNumberedPlaceholders(len(pairs), 18)
 	query := `
-    INSERT INTO pairsfutures (pairkey, symbol, exchange, market, markprice, indexprice, baseasset, quoteasset, displayname, fundingRatePercent, nextfundingtimestamp, pricechangepercent24h, basevolume24h, quotevolume24h, updatedat)
+    INSERT INTO pairsfutures (pairkey, symbol, exchange, market, markprice, indexprice, baseasset, quoteasset, displayname, fundingRatePercent, nextfundingtimestamp, pricechangepercent24h, basevolume24h, quotevolume24h, expiry, contracttype, contractvalue, updatedat)
     VALUES ` + placeholderStr + `
     ON CONFLICT (pairkey) DO UPDATE SET
         markprice = EXCLUDED.markprice,
@@ -526,16 +720,18 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
         pricechangepercent24h = EXCLUDED.pricechangepercent24h,
         basevolume24h = EXCLUDED.basevolume24h,
         quotevolume24h = EXCLUDED.quotevolume24h,
+        expiry = EXCLUDED.expiry,
+        contracttype = EXCLUDED.contracttype,
+        contractvalue = EXCLUDED.contractvalue,
         updatedat = EXCLUDED.updatedat
     `
 	stmt, err := tx.Prepare(query)
 	if err != nil {
-		log.Printf("Binance Failed to prepare statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Binance Failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
-	args := make([]interface{}, 0, len(pairs)*15)
+	args := make([]interface{}, 0, len(pairs)*18)
 	for _, pair := range pairs {
 		args = append(
 			args,
@@ -553,6 +749,9 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
 			pair.PriceChangePercent24h,
 			pair.BaseVolume24h,
 			pair.QuoteVolume24h,
+			pair.Expiry,
+			pair.ContractType,
+			pair.ContractValue,
 			pair.UpdatedAt,
 		)
 	}
@@ -560,14 +759,12 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
 	_, err = stmt.Exec(args...)
 	if err != nil {
 		tx.Rollback()
-		log.Printf("Binance Failed to execute statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Binance Failed to execute statement: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("Binance Failed to commit transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Binance Failed to commit transaction: %w", err)
 	}
 
-	return true
+	return len(pairs), nil
 }
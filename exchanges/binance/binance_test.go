@@ -0,0 +1,257 @@
+package binance
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"Updater/exchanges/exchangetest"
+	"Updater/exchanges/signing"
+	"Updater/models"
+)
+
+func TestFetchSpotPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		exchangeInfoPath: "exchange_info.json",
+		tickerPricePath:  "ticker_price.json",
+		ticker24hrPath:   "ticker_24hr.json",
+	})
+	srv.SetURL(t, &spotBaseURL)
+
+	pairs, err := fetchSpotPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// LUNAUSDT is not tradable on spot; ETHBTC has no 24h statistics
+	exchangetest.Compare(t, pairs, []models.Pair{
+		{
+			PairKey:               "BTCUSDT_Binance_spot",
+			Symbol:                "BTCUSDT",
+			Exchange:              "Binance",
+			Market:                "spot",
+			Price:                 67012.3456789,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDT",
+			DisplayName:           "BTC/USDT",
+			PriceChangePercent24h: -1.03,
+			BaseVolume24h:         12345.68,
+			QuoteVolume24h:        827345678.91,
+		},
+		{
+			PairKey:     "ETHBTC_Binance_spot",
+			Symbol:      "ETHBTC",
+			Exchange:    "Binance",
+			Market:      "spot",
+			Price:       0.051234,
+			BaseAsset:   "ETH",
+			QuoteAsset:  "BTC",
+			DisplayName: "ETH/BTC",
+		},
+	})
+}
+
+func TestFetchFuturesPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		exchangeInfoFuturesPath: "futures_exchange_info.json",
+		futuresDataPath:         "premium_index.json",
+		ticker24hrFuturesPath:   "futures_ticker_24hr.json",
+	})
+	srv.SetURL(t, &futuresBaseURL)
+
+	pairs, err := fetchFuturesPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// DOGEUSDT has no mark price, SOLUSDT no exchange info and XRPUSDT no 24h statistics.
+	// The quarterly BTCUSDT_240628 expires and has no funding
+	exchangetest.Compare(t, pairs, []models.PairFutures{
+		{
+			PairKey:               "BTCUSDT_Binance_futures",
+			Symbol:                "BTCUSDT",
+			Exchange:              "Binance",
+			Market:                "futures",
+			MarkPrice:             67050.1,
+			IndexPrice:            67040.55,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDT",
+			DisplayName:           "BTC/USDT",
+			FundingRatePercent:    0.0001,
+			NextFundingTimestamp:  1718006400000,
+			PriceChangePercent24h: -0.95,
+			BaseVolume24h:         201234.567,
+			QuoteVolume24h:        13480000000.12,
+			ContractType:          models.ContractLinear,
+			ContractValue:         1,
+		},
+		{
+			PairKey:               "ETHUSDT_Binance_futures",
+			Symbol:                "ETHUSDT",
+			Exchange:              "Binance",
+			Market:                "futures",
+			MarkPrice:             3510.25,
+			IndexPrice:            3509.8,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "USDT",
+			DisplayName:           "ETH/USDT",
+			FundingRatePercent:    -0.000025,
+			NextFundingTimestamp:  1718006400000,
+			PriceChangePercent24h: 0.512,
+			BaseVolume24h:         1500000.5,
+			QuoteVolume24h:        5265000000,
+			ContractType:          models.ContractLinear,
+			ContractValue:         1,
+		},
+		{
+			PairKey:               "BTCUSDT_240628_Binance_futures",
+			Symbol:                "BTCUSDT_240628",
+			Exchange:              "Binance",
+			Market:                "futures",
+			MarkPrice:             68120.4,
+			IndexPrice:            67040.55,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDT",
+			DisplayName:           "BTC/USDT",
+			PriceChangePercent24h: -0.87,
+			BaseVolume24h:         1520.25,
+			QuoteVolume24h:        103500000.5,
+			Expiry:                1719561600000,
+			ContractType:          models.ContractLinear,
+			ContractValue:         1,
+		},
+	})
+}
+
+func TestFetchInversePairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		exchangeInfoCoinFuturesPath: "coin_futures_exchange_info.json",
+		coinFuturesDataPath:         "coin_premium_index.json",
+		ticker24hrCoinFuturesPath:   "coin_futures_ticker_24hr.json",
+	})
+	srv.SetURL(t, &coinFuturesBaseURL)
+
+	pairs, err := fetchInversePairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// BTCUSD_240329 is settling. Volumes are counted in contracts of 100 USD for BTC and 10 USD for ETH
+	exchangetest.Compare(t, pairs, []models.PairFutures{
+		{
+			PairKey:               "BTCUSD_PERP_Binance_futures",
+			Symbol:                "BTCUSD_PERP",
+			Exchange:              "Binance",
+			Market:                "futures",
+			MarkPrice:             67055.4,
+			IndexPrice:            67048.9,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USD",
+			DisplayName:           "BTC/USD",
+			FundingRatePercent:    0.00008,
+			NextFundingTimestamp:  1718006400000,
+			PriceChangePercent24h: -0.81,
+			BaseVolume24h:         7640.5,
+			QuoteVolume24h:        512340000,
+			ContractType:          models.ContractInverse,
+			ContractValue:         100,
+		},
+		{
+			PairKey:               "ETHUSD_PERP_Binance_futures",
+			Symbol:                "ETHUSD_PERP",
+			Exchange:              "Binance",
+			Market:                "futures",
+			MarkPrice:             3511.1,
+			IndexPrice:            3510.5,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "USD",
+			DisplayName:           "ETH/USD",
+			FundingRatePercent:    -0.00001,
+			NextFundingTimestamp:  1718006400000,
+			PriceChangePercent24h: 0.42,
+			BaseVolume24h:         6977.25,
+			QuoteVolume24h:        24500000,
+			ContractType:          models.ContractInverse,
+			ContractValue:         10,
+		},
+		{
+			PairKey:               "BTCUSD_240628_Binance_futures",
+			Symbol:                "BTCUSD_240628",
+			Exchange:              "Binance",
+			Market:                "futures",
+			MarkPrice:             68210.2,
+			IndexPrice:            67048.9,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USD",
+			DisplayName:           "BTC/USD",
+			PriceChangePercent24h: -0.77,
+			BaseVolume24h:         119.12,
+			QuoteVolume24h:        8125000,
+			Expiry:                1719561600000,
+			ContractType:          models.ContractInverse,
+			ContractValue:         100,
+		},
+	})
+}
+
+func TestFetchAllFuturesPairsWithoutCoinM(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		exchangeInfoFuturesPath:     "futures_exchange_info.json",
+		futuresDataPath:             "premium_index.json",
+		ticker24hrFuturesPath:       "futures_ticker_24hr.json",
+		exchangeInfoCoinFuturesPath: "",
+		coinFuturesDataPath:         "",
+		ticker24hrCoinFuturesPath:   "",
+	})
+	srv.SetURL(t, &futuresBaseURL)
+	srv.SetURL(t, &coinFuturesBaseURL)
+
+	pairs, err := fetchAllFuturesPairs(context.Background())
+	if err != nil {
+		t.Fatalf("COIN-M outage failed the job: %v", err)
+	}
+	var symbols []string
+	for _, p := range pairs {
+		symbols = append(symbols, p.Symbol)
+	}
+	if want := []string{"BTCUSDT", "ETHUSDT", "BTCUSDT_240628"}; !slices.Equal(symbols, want) {
+		t.Errorf("symbols = %v, want the USDⓈ-M pairs %v", symbols, want)
+	}
+}
+
+func TestFetchNetworks(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		serverTimePath:  "server_time.json",
+		assetDetailPath: "capital_config_getall.json",
+	})
+	srv.SetURL(t, &spotBaseURL)
+	serverClock.Invalidate()
+
+	networks, err := fetchNetworks(context.Background(), signing.Credentials{Key: "key", Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exchangetest.Compare(t, networks, []models.Network{
+		{CoinKey: "USDT_Binance_ETH", Coin: "USDT", Exchange: "Binance", Network: "ETH", NetworkName: "Ethereum (ERC20)", DepositEnable: true, WithdrawEnable: true},
+		{CoinKey: "USDT_Binance_TRX", Coin: "USDT", Exchange: "Binance", Network: "TRX", NetworkName: "Tron (TRC20)", DepositEnable: true},
+		{CoinKey: "BTC_Binance_BTC", Coin: "BTC", Exchange: "Binance", Network: "BTC", NetworkName: "Bitcoin", WithdrawEnable: true},
+	})
+
+	requests := srv.Requests()
+	signed := requests[len(requests)-1]
+	if got := signed.Header.Get("X-MBX-APIKEY"); got != "key" {
+		t.Errorf("X-MBX-APIKEY = %q, want %q", got, "key")
+	}
+	query := signed.URL.Query()
+	want := signing.HMACSHA256Hex("secret", "timestamp="+query.Get("timestamp"))
+	if query.Get("timestamp") != "1718000000000" || query.Get("signature") != want {
+		t.Errorf("signed query = %s, want the server timestamp signed with the secret", signed.URL.RawQuery)
+	}
+}
+
+func TestFetchNetworksWithoutKeys(t *testing.T) {
+	if _, err := fetchNetworks(context.Background(), signing.Credentials{}); err == nil {
+		t.Error("expected an error without API keys")
+	}
+}
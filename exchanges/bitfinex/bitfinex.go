@@ -0,0 +1,417 @@
+package bitfinex
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Updater/exchanges/httpclient"
+	"Updater/logging"
+	"Updater/models"
+)
+
+var (
+	logger       = logging.Exchange("Bitfinex")
+	parseSampler = logging.NewSampler()
+	httpClient   = httpclient.For("Bitfinex")
+)
+
+// baseURL is a variable so tests can point the connector at recorded responses
+var baseURL = "https://api-pub.bitfinex.com"
+
+const (
+	tickersPath         = "/v2/tickers?symbols=ALL"
+	pairsPath           = "/v2/conf/pub:list:pair:exchange"
+	currencySymbolsPath = "/v2/conf/pub:map:currency:sym"
+	txMethodsPath       = "/v2/conf/pub:map:tx:method"
+	txStatusPath        = "/v2/conf/pub:info:tx:status"
+
+	MAX_DECIMAL_18_8 = 9999999999.99999999   // Максимальне значення для DECIMAL(18,8)
+	MAX_DECIMAL_10_2 = 99999999.99           // Максимальне значення для DECIMAL(10,2)
+	MAX_DECIMAL_20_2 = 999999999999999999.99 // Максимальне значення для DECIMAL(20,2)
+)
+
+// Positions in a trading pair ticker: [SYMBOL, BID, BID_SIZE, ASK, ASK_SIZE,
+// DAILY_CHANGE, DAILY_CHANGE_RELATIVE, LAST_PRICE, VOLUME, HIGH, LOW]
+const (
+	tickerChangeRelative = 6
+	tickerLastPrice      = 7
+	tickerVolume         = 8
+	tickerFields         = 11
+)
+
+// Positions in a transfer method status: [METHOD, DEPOSIT_STATUS, WITHDRAWAL_STATUS, ...],
+// where a status of 1 is active and 0 under maintenance
+const (
+	txStatusDeposit  = 1
+	txStatusWithdraw = 2
+)
+
+// Conf responses wrap their single value in an outer array.
+type (
+	PairsResponse           [][]string
+	CurrencySymbolsResponse [][][2]string
+	TxMethodsResponse       [][]TxMethod
+	TxStatusResponse        [][][]any
+)
+
+// TxMethod is a deposit and withdrawal network and the currencies it carries,
+// such as ["TETHERUSE", ["UST"]].
+type TxMethod struct {
+	Method     string
+	Currencies []string
+}
+
+func (m *TxMethod) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) < 2 {
+		return fmt.Errorf("transfer method %s has %d fields, want 2", data, len(raw))
+	}
+	if err := json.Unmarshal(raw[0], &m.Method); err != nil {
+		return err
+	}
+	return json.Unmarshal(raw[1], &m.Currencies)
+}
+
+func fetchJSON(ctx context.Context, url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
+	defer wg.Done()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		errChan <- fmt.Errorf("Bitfinex error fetching %s: %w", url, err)
+		return
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		errChan <- fmt.Errorf("Bitfinex error fetching %s: %w", url, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errChan <- fmt.Errorf("Bitfinex non-OK status code %d from %s", resp.StatusCode, url)
+		return
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		errChan <- fmt.Errorf("Bitfinex error reading response from %s: %w", url, err)
+		return
+	}
+
+	if err := json.Unmarshal(body, target); err != nil {
+		errChan <- fmt.Errorf("Bitfinex error unmarshalling JSON from %s: %w", url, err)
+	}
+}
+
+// number reads a numeric array field, which Bitfinex sends as null when it has no value.
+func number(v any) float64 {
+	f, _ := v.(float64)
+	return f
+}
+
+func generateNumberedPlaceholders(rows int, fieldCount int) string {
+	placeholders := make([]string, rows)
+	counter := 1
+	for i := 0; i < rows; i++ {
+		inner := make([]string, fieldCount)
+		for j := 0; j < fieldCount; j++ {
+			inner[j] = "$" + strconv.Itoa(counter)
+			counter++
+		}
+		placeholders[i] = "(" + strings.Join(inner, ", ") + ")"
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+func sanitizeDecimal(value float64, maxValue float64, precision int) float64 {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0
+	}
+
+	if value > maxValue {
+		value = maxValue
+	} else if value < -maxValue {
+		value = -maxValue
+	}
+
+	format := "%." + strconv.Itoa(precision) + "f"
+	strVal := fmt.Sprintf(format, value)
+	formattedVal, _ := strconv.ParseFloat(strVal, 64)
+	return formattedVal
+}
+
+// currencyMap maps Bitfinex currency codes to the usual ones, such as UST to USDT.
+func currencyMap(symbols CurrencySymbolsResponse) map[string]string {
+	canonical := make(map[string]string)
+	if len(symbols) > 0 {
+		for _, s := range symbols[0] {
+			canonical[s[0]] = s[1]
+		}
+	}
+	return canonical
+}
+
+// currency returns the usual code of a Bitfinex currency.
+func currency(canonical map[string]string, code string) string {
+	if c, ok := canonical[code]; ok {
+		return c
+	}
+	return code
+}
+
+// splitPair splits a pair into its currencies. Pairs of two three letter
+// currencies are written together (BTCUSD), longer ones with a colon (DOGE:USD).
+func splitPair(pair string) (base, quote string, ok bool) {
+	if base, quote, found := strings.Cut(pair, ":"); found {
+		return base, quote, base != "" && quote != ""
+	}
+	if len(pair) == 6 {
+		return pair[:3], pair[3:], true
+	}
+	return "", "", false
+}
+
+// fetchSpotPairs downloads the exchange pairs, the currency symbols and the
+// tickers and builds the pairs with the usual currency codes, ordered by symbol.
+func fetchSpotPairs(ctx context.Context) ([]models.Pair, error) {
+	var wg sync.WaitGroup
+	errChan := make(chan error, 3)
+
+	var pairList PairsResponse
+	var symbols CurrencySymbolsResponse
+	var tickers [][]any
+
+	wg.Add(3)
+	go fetchJSON(ctx, baseURL+pairsPath, &pairList, &wg, errChan)
+	go fetchJSON(ctx, baseURL+currencySymbolsPath, &symbols, &wg, errChan)
+	go fetchJSON(ctx, baseURL+tickersPath, &tickers, &wg, errChan)
+
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	listed := make(map[string]bool)
+	if len(pairList) > 0 {
+		for _, pair := range pairList[0] {
+			listed[pair] = true
+		}
+	}
+	canonical := currencyMap(symbols)
+
+	var pairs []models.Pair
+	for _, ticker := range tickers {
+		if len(ticker) < tickerFields {
+			continue
+		}
+		// Trading pairs start with t, funding currencies with f
+		symbol, _ := ticker[0].(string)
+		pair, isTrading := strings.CutPrefix(symbol, "t")
+		if !isTrading || !listed[pair] {
+			continue
+		}
+		base, quote, ok := splitPair(pair)
+		if !ok || strings.HasPrefix(base, "TEST") {
+			continue
+		}
+		base, quote = currency(canonical, base), currency(canonical, quote)
+
+		last := number(ticker[tickerLastPrice])
+		price := sanitizeDecimal(last, MAX_DECIMAL_18_8, 8)
+		if price <= 0 {
+			parseSampler.Debug(logger, "noPrice", "skipping ticker without a last price", "symbol", symbol)
+			continue
+		}
+		volume := number(ticker[tickerVolume])
+
+		pairs = append(pairs, models.Pair{
+			PairKey:               fmt.Sprintf("%s%s_Bitfinex_spot", base, quote),
+			Symbol:                base + quote,
+			Exchange:              "Bitfinex",
+			Market:                "spot",
+			Price:                 price,
+			BaseAsset:             base,
+			QuoteAsset:            quote,
+			DisplayName:           fmt.Sprintf("%s/%s", base, quote),
+			PriceChangePercent24h: sanitizeDecimal(number(ticker[tickerChangeRelative])*100, MAX_DECIMAL_10_2, 2),
+			BaseVolume24h:         sanitizeDecimal(volume, MAX_DECIMAL_20_2, 2),
+			QuoteVolume24h:        sanitizeDecimal(volume*last, MAX_DECIMAL_20_2, 2),
+			UpdatedAt:             time.Now().UTC(),
+		})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Symbol < pairs[j].Symbol })
+
+	return pairs, nil
+}
+
+func UpdateAllSpotPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchSpotPairs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(pairs) == 0 {
+		return 0, errors.New("Bitfinex No pairs to update")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("Bitfinex Failed to begin transaction: %w", err)
+	}
+
+	placeholderStr := generateNumberedPlaceholders(len(pairs), 12)
+	query := `
+    INSERT INTO pairs (pairkey, symbol, exchange, market, price, baseasset, quoteasset, displayname, pricechangepercent24h, basevolume24h, quotevolume24h, updatedat)
+    VALUES ` + placeholderStr + `
+    ON CONFLICT (pairkey) DO UPDATE SET
+        price = EXCLUDED.price,
+        pricechangepercent24h = EXCLUDED.pricechangepercent24h,
+        basevolume24h = EXCLUDED.basevolume24h,
+        quotevolume24h = EXCLUDED.quotevolume24h,
+        updatedat = EXCLUDED.updatedat
+    `
+
+	args := make([]interface{}, 0, len(pairs)*12)
+	for _, pair := range pairs {
+		args = append(args, pair.PairKey, pair.Symbol, pair.Exchange, pair.Market, pair.Price, pair.BaseAsset, pair.QuoteAsset,
+			pair.DisplayName, pair.PriceChangePercent24h, pair.BaseVolume24h, pair.QuoteVolume24h, pair.UpdatedAt)
+	}
+
+	_, err = tx.Exec(query, args...)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("Bitfinex Failed to execute statement: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("Bitfinex Failed to commit transaction: %w", err)
+	}
+
+	return len(pairs), nil
+}
+
+// fetchNetworks downloads the transfer methods, their status and the currency
+// symbols and builds one record per currency and method, ordered by coin key.
+// Methods without a published status are skipped.
+func fetchNetworks(ctx context.Context) ([]models.Network, error) {
+	var wg sync.WaitGroup
+	errChan := make(chan error, 3)
+
+	var methods TxMethodsResponse
+	var statuses TxStatusResponse
+	var symbols CurrencySymbolsResponse
+
+	wg.Add(3)
+	go fetchJSON(ctx, baseURL+txMethodsPath, &methods, &wg, errChan)
+	go fetchJSON(ctx, baseURL+txStatusPath, &statuses, &wg, errChan)
+	go fetchJSON(ctx, baseURL+currencySymbolsPath, &symbols, &wg, errChan)
+
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	type status struct{ deposit, withdraw bool }
+	statusByMethod := make(map[string]status)
+	if len(statuses) > 0 {
+		for _, s := range statuses[0] {
+			if len(s) <= txStatusWithdraw {
+				continue
+			}
+			method, _ := s[0].(string)
+			statusByMethod[method] = status{deposit: number(s[txStatusDeposit]) == 1, withdraw: number(s[txStatusWithdraw]) == 1}
+		}
+	}
+	canonical := currencyMap(symbols)
+
+	var networks []models.Network
+	if len(methods) > 0 {
+		for _, m := range methods[0] {
+			s, ok := statusByMethod[m.Method]
+			if !ok {
+				continue
+			}
+			for _, code := range m.Currencies {
+				coin := currency(canonical, code)
+				networks = append(networks, models.Network{
+					CoinKey:        fmt.Sprintf("%s_Bitfinex_%s", coin, m.Method),
+					Coin:           coin,
+					Exchange:       "Bitfinex",
+					Network:        m.Method,
+					NetworkName:    m.Method,
+					DepositEnable:  s.deposit,
+					WithdrawEnable: s.withdraw,
+					UpdatedAt:      time.Now().UTC(),
+				})
+			}
+		}
+	}
+	sort.Slice(networks, func(i, j int) bool { return networks[i].CoinKey < networks[j].CoinKey })
+
+	return networks, nil
+}
+
+func UpdateAllNetworks(ctx context.Context, db *sql.DB) (int, error) {
+	networks, err := fetchNetworks(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(networks) == 0 {
+		logger.Debug("no network data to update")
+		return 0, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("Bitfinex Failed to begin transaction: %w", err)
+	}
+
+	placeholderStr := generateNumberedPlaceholders(len(networks), 8)
+	query := `
+	INSERT INTO nets (coinKey, coin, exchange, network, networkName, depositEnable, withdrawEnable, updatedAt)
+	VALUES ` + placeholderStr + `
+	ON CONFLICT (coinKey) DO UPDATE SET
+		networkName = EXCLUDED.networkName,
+		depositEnable = EXCLUDED.depositEnable,
+		withdrawEnable = EXCLUDED.withdrawEnable,
+		updatedAt = EXCLUDED.updatedAt
+	`
+
+	args := make([]interface{}, 0, len(networks)*8)
+	for _, n := range networks {
+		args = append(args, n.CoinKey, n.Coin, n.Exchange, n.Network, n.NetworkName, n.DepositEnable, n.WithdrawEnable, n.UpdatedAt)
+	}
+
+	_, err = tx.Exec(query, args...)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("Bitfinex Failed to execute statement: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("Bitfinex Failed to commit transaction: %w", err)
+	}
+
+	return len(networks), nil
+}
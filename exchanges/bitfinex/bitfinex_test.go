@@ -0,0 +1,121 @@
+package bitfinex
+
+import (
+	"context"
+	"testing"
+
+	"Updater/exchanges/exchangetest"
+	"Updater/models"
+)
+
+func TestFetchSpotPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		pairsPath:           "pairs.json",
+		currencySymbolsPath: "currency_symbols.json",
+		tickersPath:         "tickers.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	pairs, err := fetchSpotPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// UST is USDT and DOGE:USD splits at the colon; the test pair, NEWUSD
+	// without a price, the unlisted OLDUSD and the fUSD funding ticker are skipped
+	exchangetest.Compare(t, pairs, []models.Pair{
+		{
+			PairKey:               "BTCUSD_Bitfinex_spot",
+			Symbol:                "BTCUSD",
+			Exchange:              "Bitfinex",
+			Market:                "spot",
+			Price:                 67012.4,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USD",
+			DisplayName:           "BTC/USD",
+			PriceChangePercent24h: 1.53,
+			BaseVolume24h:         1234.56,
+			QuoteVolume24h:        82730828.54,
+		},
+		{
+			PairKey:               "BTCUSDT_Bitfinex_spot",
+			Symbol:                "BTCUSDT",
+			Exchange:              "Bitfinex",
+			Market:                "spot",
+			Price:                 67021,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDT",
+			DisplayName:           "BTC/USDT",
+			PriceChangePercent24h: 1.51,
+			BaseVolume24h:         850.25,
+			QuoteVolume24h:        56984605.25,
+		},
+		{
+			PairKey:               "DOGEUSD_Bitfinex_spot",
+			Symbol:                "DOGEUSD",
+			Exchange:              "Bitfinex",
+			Market:                "spot",
+			Price:                 0.1201,
+			BaseAsset:             "DOGE",
+			QuoteAsset:            "USD",
+			DisplayName:           "DOGE/USD",
+			PriceChangePercent24h: 1.69,
+			BaseVolume24h:         2000000,
+			QuoteVolume24h:        240200,
+		},
+		{
+			PairKey:               "ETHBTC_Bitfinex_spot",
+			Symbol:                "ETHBTC",
+			Exchange:              "Bitfinex",
+			Market:                "spot",
+			Price:                 0.051234,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "BTC",
+			DisplayName:           "ETH/BTC",
+			PriceChangePercent24h: -2.41,
+			BaseVolume24h:         812.5,
+			QuoteVolume24h:        41.63,
+		},
+	})
+}
+
+func TestFetchNetworks(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		txMethodsPath:       "tx_methods.json",
+		txStatusPath:        "tx_status.json",
+		currencySymbolsPath: "currency_symbols.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	networks, err := fetchNetworks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Status 1 is active and 0 under maintenance; LEGACY has no status
+	exchangetest.Compare(t, networks, []models.Network{
+		{CoinKey: "BTC_Bitfinex_BITCOIN", Coin: "BTC", Exchange: "Bitfinex", Network: "BITCOIN", NetworkName: "BITCOIN", DepositEnable: true, WithdrawEnable: true},
+		{CoinKey: "ETH_Bitfinex_ETHEREUM", Coin: "ETH", Exchange: "Bitfinex", Network: "ETHEREUM", NetworkName: "ETHEREUM", DepositEnable: true},
+		{CoinKey: "USDC_Bitfinex_UDC", Coin: "USDC", Exchange: "Bitfinex", Network: "UDC", NetworkName: "UDC", DepositEnable: true, WithdrawEnable: true},
+		{CoinKey: "USDT_Bitfinex_TETHERUSE", Coin: "USDT", Exchange: "Bitfinex", Network: "TETHERUSE", NetworkName: "TETHERUSE", DepositEnable: true, WithdrawEnable: true},
+		{CoinKey: "USDT_Bitfinex_TETHERUSX", Coin: "USDT", Exchange: "Bitfinex", Network: "TETHERUSX", NetworkName: "TETHERUSX", WithdrawEnable: true},
+	})
+}
+
+func TestSplitPair(t *testing.T) {
+	for pair, want := range map[string][2]string{
+		"BTCUSD":   {"BTC", "USD"},
+		"DOGE:USD": {"DOGE", "USD"},
+		"BTC:CNHT": {"BTC", "CNHT"},
+	} {
+		base, quote, ok := splitPair(pair)
+		if !ok || base != want[0] || quote != want[1] {
+			t.Errorf("splitPair(%q) = %q, %q, %v, want %q, %q", pair, base, quote, ok, want[0], want[1])
+		}
+	}
+	for _, pair := range []string{"BTCUSDT", ":USD"} {
+		if _, _, ok := splitPair(pair); ok {
+			t.Errorf("splitPair(%q) succeeded", pair)
+		}
+	}
+}
@@ -1,26 +1,46 @@
 package bitget
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"Updater/exchanges/httpclient"
+	"Updater/logging"
+	"Updater/metrics"
 	"Updater/models"
 )
 
+var (
+	logger       = logging.Exchange("Bitget")
+	parseSampler = logging.NewSampler()
+	httpClient   = httpclient.For("Bitget")
+)
+
+// baseURL is a variable so tests can point the connector at recorded responses
+var baseURL = "https://api.bitget.com"
+
 const (
-	marketListURL  = "https://api.bitget.com/api/v2/spot/public/symbols"
-	tickerPriceURL = "https://api.bitget.com/api/v2/spot/market/tickers"
-	networkInfoURL = "https://api.bitget.com/api/v2/spot/public/coins"
+	marketListPath  = "/api/v2/spot/public/symbols"
+	tickerPricePath = "/api/v2/spot/market/tickers"
+	networkInfoPath = "/api/v2/spot/public/coins"
+
+	// The futures paths take the product type, one of futuresProductTypes
+	contractsPath      = "/api/v2/mix/market/contracts?productType="
+	futuresTickersPath = "/api/v2/mix/market/tickers?productType="
 )
 
+// futuresProductTypes are the USDT-M and USDC-M perpetual markets
+var futuresProductTypes = []string{"USDT-FUTURES", "USDC-FUTURES"}
+
 type MarketListResponse struct {
 	Data []struct {
 		Symbol      string `json:"symbol"`
@@ -41,10 +61,40 @@ type TickerPriceResponse struct {
 	} `json:"data"`
 }
 
-func fetchJSON(url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
+type ContractsResponse struct {
+	Data []struct {
+		Symbol       string `json:"symbol"`
+		BaseCoin     string `json:"baseCoin"`
+		QuoteCoin    string `json:"quoteCoin"`
+		SymbolType   string `json:"symbolType"`
+		SymbolStatus string `json:"symbolStatus"`
+		FundInterval string `json:"fundInterval"` // hours between funding settlements
+	} `json:"data"`
+}
+
+type FuturesTickerResponse struct {
+	Data []struct {
+		Symbol      string `json:"symbol"`
+		LastPrice   string `json:"lastPr"`
+		MarkPrice   string `json:"markPrice"`
+		IndexPrice  string `json:"indexPrice"`
+		FundingRate string `json:"fundingRate"`
+		Change24h   string `json:"change24h"`
+		BaseVolume  string `json:"baseVolume"`
+		QuoteVolume string `json:"quoteVolume"`
+		Timestamp   string `json:"ts"`
+	} `json:"data"`
+}
+
+func fetchJSON(ctx context.Context, url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
 	defer wg.Done()
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		errChan <- fmt.Errorf("bitget error fetching %s: %w", url, err)
+		return
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		errChan <- fmt.Errorf("bitget error fetching %s: %w", url, err)
 		return
@@ -70,7 +120,8 @@ func fetchJSON(url string, target interface{}, wg *sync.WaitGroup, errChan chan<
 func parseFloat(s string, d string) float64 {
 	val, err := strconv.ParseFloat(s, 64)
 	if err != nil {
-		log.Printf("Bitget Warning: failed to parse float from %s, field: %v", s, d)
+		parseSampler.Warn(logger, "parseFloat", "failed to parse float", "value", s, "field", d)
+		metrics.ParseWarnings.WithLabelValues("Bitget").Inc()
 		return 0
 	}
 	return val
@@ -97,7 +148,8 @@ func generateNumberedPlaceholders(rows int, fieldCount int) string {
 	return strings.Join(placeholders, ", ")
 }
 
-func UpdateAllSpotPairs(db *sql.DB) bool {
+// fetchSpotPairs downloads the online spot markets and builds their pairs.
+func fetchSpotPairs(ctx context.Context) ([]models.Pair, error) {
 	var wg sync.WaitGroup
 	errChan := make(chan error, 2)
 
@@ -105,16 +157,15 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 	var tickerData TickerPriceResponse
 
 	wg.Add(2)
-	go fetchJSON(marketListURL, &marketList, &wg, errChan)
-	go fetchJSON(tickerPriceURL, &tickerData, &wg, errChan)
+	go fetchJSON(ctx, baseURL+marketListPath, &marketList, &wg, errChan)
+	go fetchJSON(ctx, baseURL+tickerPricePath, &tickerData, &wg, errChan)
 
 	wg.Wait()
 	close(errChan)
 
 	for err := range errChan {
 		if err != nil {
-			log.Printf("Error: %v", err)
-			return false
+			return nil, err
 		}
 	}
 
@@ -164,15 +215,23 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 			PriceChangePercent24h: formatFloat(parseFloat(ticker.ChangePercent24h, "PriceChangePercent24h"), 2),
 			BaseVolume24h:         formatFloat(parseFloat(ticker.BaseVolume24h, "BaseVolume24h"), 2),
 			QuoteVolume24h:        formatFloat(parseFloat(ticker.QuoteVolume24h, "QuoteVolume24h"), 2),
-			UpdatedAt:             time.Now(),
+			UpdatedAt:             time.Now().UTC(),
 		}
 		pairs = append(pairs, pair)
 	}
 
+	return pairs, nil
+}
+
+func UpdateAllSpotPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchSpotPairs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
 	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("Bitget Failed to begin transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Bitget Failed to begin transaction: %w", err)
 	}
 
 	placeholderStr := generateNumberedPlaceholders(len(pairs), 13)
@@ -188,8 +247,7 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
     `
 	stmt, err := tx.Prepare(query)
 	if err != nil {
-		log.Printf("Bitget Failed to prepare statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Bitget Failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
@@ -202,19 +260,18 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 	_, err = stmt.Exec(args...)
 	if err != nil {
 		tx.Rollback()
-		log.Printf("Bitget Failed to execute statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Bitget Failed to execute statement: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("Bitget Failed to commit transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Bitget Failed to commit transaction: %w", err)
 	}
 
-	return true
+	return len(pairs), nil
 }
 
-func UpdateAllNetworks(db *sql.DB) bool {
+// fetchNetworks downloads the deposit and withdrawal status of every coin chain.
+func fetchNetworks(ctx context.Context) ([]models.Network, error) {
 	type Chain struct {
 		Chain             string `json:"chain"`
 		NeedTag           string `json:"needTag"`
@@ -243,33 +300,57 @@ func UpdateAllNetworks(db *sql.DB) bool {
 	var networkInfo NetworkInfoResponse
 
 	// Fetch network data from Bitget API
-	resp, err := http.Get(networkInfoURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+networkInfoPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Bitget error fetching network info: %w", err)
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		log.Printf("Bitget error fetching network info: %v", err)
-		return false
+		return nil, fmt.Errorf("Bitget error fetching network info: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("Bitget non-OK status code %d from %s", resp.StatusCode, networkInfoURL)
-		return false
+		return nil, fmt.Errorf("Bitget non-OK status code %d from %s", resp.StatusCode, baseURL+networkInfoPath)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Bitget error reading response: %v", err)
-		return false
+		return nil, fmt.Errorf("Bitget error reading response: %w", err)
 	}
 
 	if err := json.Unmarshal(body, &networkInfo); err != nil {
-		log.Printf("Bitget error unmarshalling JSON: %v", err)
-		return false
+		return nil, fmt.Errorf("Bitget error unmarshalling JSON: %w", err)
+	}
+
+	var networks []models.Network
+	for _, coin := range networkInfo.Data {
+		for _, chain := range coin.Chains {
+			networks = append(networks, models.Network{
+				CoinKey:        fmt.Sprintf("%s_Bitget_%s", coin.Coin, chain.Chain),
+				Coin:           coin.Coin,
+				Exchange:       "Bitget",
+				Network:        chain.Chain,
+				NetworkName:    chain.Chain,
+				DepositEnable:  chain.Rechargeable == "true",
+				WithdrawEnable: chain.Withdrawable == "true",
+				UpdatedAt:      time.Now().UTC(),
+			})
+		}
+	}
+
+	return networks, nil
+}
+
+func UpdateAllNetworks(ctx context.Context, db *sql.DB) (int, error) {
+	networks, err := fetchNetworks(ctx)
+	if err != nil {
+		return 0, err
 	}
 
 	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("Bitget Failed to begin transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Bitget Failed to begin transaction: %w", err)
 	}
 
 	// Prepare SQL query with ON CONFLICT
@@ -287,34 +368,173 @@ func UpdateAllNetworks(db *sql.DB) bool {
 	var args []interface{}
 	counter := 1
 
-	for _, coin := range networkInfo.Data {
-		for _, chain := range coin.Chains {
-			coinKey := fmt.Sprintf("%s_Bitget_%s", coin.Coin, chain.Chain)
-			values = append(values, fmt.Sprintf("($%d, $%d, 'Bitget', $%d, $%d, $%d, $%d, $%d)", counter, counter+1, counter+2, counter+3, counter+4, counter+5, counter+6))
-			args = append(args, coinKey, coin.Coin, chain.Chain, chain.Chain, chain.Rechargeable == "true", chain.Withdrawable == "true", time.Now().UTC())
-			counter += 7
-		}
+	for _, n := range networks {
+		values = append(values, fmt.Sprintf("($%d, $%d, 'Bitget', $%d, $%d, $%d, $%d, $%d)", counter, counter+1, counter+2, counter+3, counter+4, counter+5, counter+6))
+		args = append(args, n.CoinKey, n.Coin, n.Network, n.NetworkName, n.DepositEnable, n.WithdrawEnable, n.UpdatedAt)
+		counter += 7
 	}
 
 	if len(values) == 0 {
-		log.Println("Bitget: No network data to update")
+		logger.Debug("no network data to update")
 		tx.Rollback()
-		return true
+		return 0, nil
 	}
 
 	fullQuery := fmt.Sprintf(query, strings.Join(values, ", "))
 	_, err = tx.Exec(fullQuery, args...)
 	if err != nil {
 		tx.Rollback()
-		log.Printf("Bitget Failed to execute statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Bitget Failed to execute statement: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("Bitget Failed to commit transaction: %w", err)
+	}
+
+	return len(values), nil
+}
+
+// nextFundingTime returns the first settlement after ts, both in milliseconds.
+// The tickers have no settlement time and the funding-time endpoint takes one
+// symbol per request, so the time is derived from fundInterval instead. This
+// assumes settlements every intervalHours counted from 00:00 UTC, as Bitget
+// schedules its 1, 2, 4 and 8 hour intervals; an interval that does not divide
+// the day starts over at midnight.
+func nextFundingTime(ts int64, intervalHours int) int {
+	if intervalHours <= 0 {
+		intervalHours = 8
+	}
+	interval := time.Duration(intervalHours) * time.Hour
+	t := time.UnixMilli(ts).UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	next := midnight.Add((t.Sub(midnight)/interval + 1) * interval)
+	if tomorrow := midnight.AddDate(0, 0, 1); next.After(tomorrow) {
+		next = tomorrow
+	}
+	return int(next.UnixMilli())
+}
+
+// fetchFuturesPairs downloads the USDT-M and USDC-M perpetuals and builds their pairs.
+func fetchFuturesPairs(ctx context.Context) ([]models.PairFutures, error) {
+	var wg sync.WaitGroup
+	errChan := make(chan error, 2*len(futuresProductTypes))
+
+	contracts := make([]ContractsResponse, len(futuresProductTypes))
+	tickers := make([]FuturesTickerResponse, len(futuresProductTypes))
+
+	wg.Add(2 * len(futuresProductTypes))
+	for i, productType := range futuresProductTypes {
+		go fetchJSON(ctx, baseURL+contractsPath+productType, &contracts[i], &wg, errChan)
+		go fetchJSON(ctx, baseURL+futuresTickersPath+productType, &tickers[i], &wg, errChan)
+	}
+
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var pairs []models.PairFutures
+	for i := range futuresProductTypes {
+		tickerMap := make(map[string]int, len(tickers[i].Data))
+		for j, t := range tickers[i].Data {
+			tickerMap[t.Symbol] = j
+		}
+
+		for _, contract := range contracts[i].Data {
+			if contract.SymbolType != "perpetual" || contract.SymbolStatus != "normal" {
+				continue
+			}
+			j, exists := tickerMap[contract.Symbol]
+			if !exists {
+				continue
+			}
+			ticker := tickers[i].Data[j]
+			if ticker.FundingRate == "" {
+				continue
+			}
+
+			interval, _ := strconv.Atoi(contract.FundInterval)
+			pair := models.PairFutures{
+				PairKey:               fmt.Sprintf("%s_Bitget_futures", contract.Symbol),
+				Symbol:                contract.Symbol,
+				Exchange:              "Bitget",
+				Market:                "futures",
+				MarkPrice:             formatFloat(parseFloat(ticker.MarkPrice, "MarkPrice"), 8),
+				IndexPrice:            formatFloat(parseFloat(ticker.IndexPrice, "IndexPrice"), 8),
+				BaseAsset:             contract.BaseCoin,
+				QuoteAsset:            contract.QuoteCoin,
+				DisplayName:           fmt.Sprintf("%s/%s", contract.BaseCoin, contract.QuoteCoin),
+				FundingRatePercent:    parseFloat(ticker.FundingRate, "FundingRate"),
+				NextFundingTimestamp:  nextFundingTime(int64(parseFloat(ticker.Timestamp, "Timestamp")), interval),
+				PriceChangePercent24h: formatFloat(parseFloat(ticker.Change24h, "PriceChangePercent24h")*100, 2),
+				BaseVolume24h:         formatFloat(parseFloat(ticker.BaseVolume, "BaseVolume24h"), 2),
+				QuoteVolume24h:        formatFloat(parseFloat(ticker.QuoteVolume, "QuoteVolume24h"), 2),
+				UpdatedAt:             time.Now().UTC(),
+				CreatedAt:             time.Now(),
+			}
+			pairs = append(pairs, pair)
+		}
+	}
+
+	if len(pairs) == 0 {
+		return nil, errors.New("Bitget No futures pairs to update")
+	}
+
+	return pairs, nil
+}
+
+func UpdateAllFuturesPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchFuturesPairs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("Bitget Failed to begin transaction: %w", err)
+	}
+
+	placeholderStr := generateNumberedPlaceholders(len(pairs), 16)
+	query := `
+    INSERT INTO pairsfutures (pairkey, symbol, exchange, market, markprice, indexprice, baseasset, quoteasset, displayname, fundingRatePercent, nextfundingtimestamp, pricechangepercent24h, basevolume24h, quotevolume24h, updatedat, createdat)
+    VALUES ` + placeholderStr + `
+    ON CONFLICT (pairkey) DO UPDATE SET
+        markprice = EXCLUDED.markprice,
+        indexprice = EXCLUDED.indexprice,
+        fundingRatePercent = EXCLUDED.fundingRatePercent,
+        nextfundingtimestamp = EXCLUDED.nextfundingtimestamp,
+        pricechangepercent24h = EXCLUDED.pricechangepercent24h,
+        basevolume24h = EXCLUDED.basevolume24h,
+        quotevolume24h = EXCLUDED.quotevolume24h,
+        updatedat = EXCLUDED.updatedat
+    `
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return 0, fmt.Errorf("Bitget Failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	args := make([]interface{}, 0, len(pairs)*16)
+	for _, pair := range pairs {
+		args = append(args, pair.PairKey, pair.Symbol, pair.Exchange, pair.Market, pair.MarkPrice, pair.IndexPrice, pair.BaseAsset,
+			pair.QuoteAsset, pair.DisplayName, pair.FundingRatePercent, pair.NextFundingTimestamp, pair.PriceChangePercent24h,
+			pair.BaseVolume24h, pair.QuoteVolume24h, pair.UpdatedAt, pair.CreatedAt)
+	}
+
+	_, err = stmt.Exec(args...)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("Bitget Failed to execute statement: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("Bitget Failed to commit transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Bitget Failed to commit transaction: %w", err)
 	}
 
-	// log.Println("Bitget: Successfully updated network availability")
-	return true
+	return len(pairs), nil
 }
@@ -0,0 +1,170 @@
+package bitget
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"Updater/exchanges/exchangetest"
+	"Updater/models"
+)
+
+func TestFetchSpotPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		marketListPath:  "symbols.json",
+		tickerPricePath: "tickers.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	pairs, err := fetchSpotPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// OLDUSDT is halted and NEWUSDT has no ticker yet
+	exchangetest.Compare(t, pairs, []models.Pair{
+		{
+			PairKey:               "BTCUSDT_Bitget_spot",
+			Symbol:                "BTCUSDT",
+			Exchange:              "Bitget",
+			Market:                "spot",
+			Price:                 67010.12345679,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDT",
+			DisplayName:           "BTC/USDT",
+			PriceChangePercent24h: -0.01,
+			BaseVolume24h:         8123.46,
+			QuoteVolume24h:        544321000.13,
+		},
+		{
+			PairKey:               "ETHUSDT_Bitget_spot",
+			Symbol:                "ETHUSDT",
+			Exchange:              "Bitget",
+			Market:                "spot",
+			Price:                 3510.42,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "USDT",
+			DisplayName:           "ETH/USDT",
+			PriceChangePercent24h: 0.02,
+			BaseVolume24h:         95123.4,
+			QuoteVolume24h:        333912345.68,
+		},
+	})
+}
+
+func TestFetchNetworks(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		networkInfoPath: "coins.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	networks, err := fetchNetworks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exchangetest.Compare(t, networks, []models.Network{
+		{CoinKey: "USDT_Bitget_TRC20", Coin: "USDT", Exchange: "Bitget", Network: "TRC20", NetworkName: "TRC20", DepositEnable: true, WithdrawEnable: true},
+		{CoinKey: "USDT_Bitget_ERC20", Coin: "USDT", Exchange: "Bitget", Network: "ERC20", NetworkName: "ERC20", DepositEnable: true},
+		{CoinKey: "BTC_Bitget_BTC", Coin: "BTC", Exchange: "Bitget", Network: "BTC", NetworkName: "BTC", WithdrawEnable: true},
+	})
+}
+
+func TestFetchFuturesPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		contractsPath + "USDT-FUTURES":      "contracts_usdt.json",
+		futuresTickersPath + "USDT-FUTURES": "tickers_usdt_futures.json",
+		contractsPath + "USDC-FUTURES":      "contracts_usdc.json",
+		futuresTickersPath + "USDC-FUTURES": "tickers_usdc_futures.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	pairs, err := fetchFuturesPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// BTCUSDT250627 is a delivery contract and OLDUSDT is under maintenance.
+	// The next funding follows fundInterval from the ticker time, 06:13 UTC.
+	exchangetest.Compare(t, pairs, []models.PairFutures{
+		{
+			PairKey:               "BTCUSDT_Bitget_futures",
+			Symbol:                "BTCUSDT",
+			Exchange:              "Bitget",
+			Market:                "futures",
+			MarkPrice:             67052.12345679,
+			IndexPrice:            67040.5,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDT",
+			DisplayName:           "BTC/USDT",
+			FundingRatePercent:    0.0001,
+			NextFundingTimestamp:  1718006400000,
+			PriceChangePercent24h: 1.23,
+			BaseVolume24h:         45678.9,
+			QuoteVolume24h:        3062345678.12,
+		},
+		{
+			PairKey:               "ETHUSDT_Bitget_futures",
+			Symbol:                "ETHUSDT",
+			Exchange:              "Bitget",
+			Market:                "futures",
+			MarkPrice:             3510.2,
+			IndexPrice:            3509.95,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "USDT",
+			DisplayName:           "ETH/USDT",
+			FundingRatePercent:    -0.00002,
+			NextFundingTimestamp:  1718002800000,
+			PriceChangePercent24h: -0.51,
+			BaseVolume24h:         812345.6,
+			QuoteVolume24h:        2851234567.8,
+		},
+		{
+			PairKey:               "BTCPERP_Bitget_futures",
+			Symbol:                "BTCPERP",
+			Exchange:              "Bitget",
+			Market:                "futures",
+			MarkPrice:             67050,
+			IndexPrice:            67041.3,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDC",
+			DisplayName:           "BTC/USDC",
+			FundingRatePercent:    0.00005,
+			NextFundingTimestamp:  1718006400000,
+			PriceChangePercent24h: 1.18,
+			BaseVolume24h:         1234.5,
+			QuoteVolume24h:        82776928.5,
+		},
+	})
+}
+
+func TestNextFundingTime(t *testing.T) {
+	at := func(day, hour, minute int) int64 {
+		return time.Date(2024, 6, day, hour, minute, 0, 0, time.UTC).UnixMilli()
+	}
+	tests := []struct {
+		name     string
+		ts       int64
+		interval int
+		want     int64
+	}{
+		{"between settlements", at(10, 6, 13), 8, at(10, 8, 0)},
+		{"at a settlement", at(10, 8, 0), 8, at(10, 16, 0)},
+		{"just before a settlement", at(10, 7, 59), 8, at(10, 8, 0)},
+		{"last settlement of the day", at(10, 23, 59), 8, at(11, 0, 0)},
+		{"4 hours", at(10, 6, 13), 4, at(10, 8, 0)},
+		{"1 hour", at(10, 6, 13), 1, at(10, 7, 0)},
+		{"missing interval", at(10, 17, 30), 0, at(11, 0, 0)},
+		// 5 hours does not divide the day, the schedule starts over at midnight
+		{"5 hours", at(10, 12, 30), 5, at(10, 15, 0)},
+		{"5 hours late", at(10, 21, 0), 5, at(11, 0, 0)},
+		{"end of month", at(30, 20, 0), 8, time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC).UnixMilli()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextFundingTime(tt.ts, tt.interval); int64(got) != tt.want {
+				t.Errorf("nextFundingTime = %v, want %v", time.UnixMilli(int64(got)).UTC(), time.UnixMilli(tt.want).UTC())
+			}
+		})
+	}
+}
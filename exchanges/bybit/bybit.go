@@ -1,33 +1,57 @@
 package bybit
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"Updater/exchanges/httpclient"
+	"Updater/exchanges/signing"
+	"Updater/logging"
+	"Updater/metrics"
 	"Updater/models"
 )
 
+var (
+	logger       = logging.Exchange("Bybit")
+	parseSampler = logging.NewSampler()
+	httpClient   = httpclient.For("Bybit")
+)
+
+// baseURL is a variable so tests can point the connector at recorded responses
+var baseURL = "https://api.bybit.com"
+
 const (
-	symbolsURL        = "https://api.bybit.com/v5/market/instruments-info?category=spot"
-	symbolsFuturesURL = "https://api.bybit.com/v5/market/instruments-info?category=linear"
-	tickerURL         = "https://api.bybit.com/v5/market/tickers?category=spot"
-	tickerFuturesURL  = "https://api.bybit.com/v5/market/tickers?category=linear"
+	symbolsPath        = "/v5/market/instruments-info?category=spot"
+	symbolsFuturesPath = "/v5/market/instruments-info?category=linear"
+	tickerPath         = "/v5/market/tickers?category=spot"
+	tickerFuturesPath  = "/v5/market/tickers?category=linear"
+	symbolsInversePath = "/v5/market/instruments-info?category=inverse"
+	tickerInversePath  = "/v5/market/tickers?category=inverse"
+	coinInfoPath       = "/v5/asset/coin/query-info"
+	serverTimePath     = "/v5/market/time"
 )
 
+// recvWindow is how many milliseconds after its timestamp Bybit accepts a signed request
+const recvWindow = "5000"
+
 type SymbolsResponse struct {
 	Result struct {
 		List []struct {
 			Symbol     string `json:"symbol"`
 			BaseAsset  string `json:"baseCoin"`
 			QuoteAsset string `json:"quoteCoin"`
+			// Only in the derivatives categories
+			ContractType string `json:"contractType"`
+			DeliveryTime string `json:"deliveryTime"`
 		} `json:"list"`
 	} `json:"result"`
 }
@@ -46,19 +70,44 @@ type TickerResponse struct {
 type TickerResponseFutures struct {
 	Result struct {
 		List []struct {
-			Symbol         string `json:"symbol"`
-			LastPrice      string `json:"lastPrice"`
-			PriceChange24h string `json:"price24hPcnt"`
-			BaseVolume24h  string `json:"volume24h"`
-			QuoteVolume24h string `json:"turnover24h"`
-			FundingRate    string `json:"fundingRate"`
+			Symbol          string `json:"symbol"`
+			LastPrice       string `json:"lastPrice"`
+			PriceChange24h  string `json:"price24hPcnt"`
+			BaseVolume24h   string `json:"volume24h"`
+			QuoteVolume24h  string `json:"turnover24h"`
+			FundingRate     string `json:"fundingRate"`
+			MarkPrice       string `json:"markPrice"`
+			IndexPrice      string `json:"indexPrice"`
+			NextFundingTime string `json:"nextFundingTime"`
 		} `json:"list"`
 	} `json:"result"`
 }
 
-func fetchJSON(url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
+// CoinInfoResponse is the deposit and withdrawal status of every coin chain.
+type CoinInfoResponse struct {
+	RetCode int    `json:"retCode"`
+	RetMsg  string `json:"retMsg"`
+	Result  struct {
+		Rows []struct {
+			Coin   string `json:"coin"`
+			Chains []struct {
+				Chain         string `json:"chain"`
+				ChainType     string `json:"chainType"`
+				ChainDeposit  string `json:"chainDeposit"`
+				ChainWithdraw string `json:"chainWithdraw"`
+			} `json:"chains"`
+		} `json:"rows"`
+	} `json:"result"`
+}
+
+func fetchJSON(ctx context.Context, url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
 	defer wg.Done()
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		errChan <- fmt.Errorf("error fetching %s: %w", url, err)
+		return
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		errChan <- fmt.Errorf("error fetching %s: %w", url, err)
 		return
@@ -84,7 +133,8 @@ func fetchJSON(url string, target interface{}, wg *sync.WaitGroup, errChan chan<
 func parseFloat(s string, d string) float64 {
 	val, err := strconv.ParseFloat(s, 64)
 	if err != nil {
-		log.Printf("Bybit Warning: failed to parse float from %s, description: %s", s, d)
+		parseSampler.Warn(logger, "parseFloat", "failed to parse float", "value", s, "field", d)
+		metrics.ParseWarnings.WithLabelValues("Bybit").Inc()
 		return 0
 	}
 	return val
@@ -104,7 +154,8 @@ func generateNumberedPlaceholders(rows int, fieldCount int) string {
 	return strings.Join(placeholders, ", ")
 }
 
-func UpdateAllSpotPairs(db *sql.DB) bool {
+// fetchSpotPairs downloads the spot instruments and builds their pairs.
+func fetchSpotPairs(ctx context.Context) ([]models.Pair, error) {
 	var wg sync.WaitGroup
 	errChan := make(chan error, 2)
 
@@ -112,16 +163,15 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 	var tickers TickerResponse
 
 	wg.Add(2)
-	go fetchJSON(symbolsURL, &symbols, &wg, errChan)
-	go fetchJSON(tickerURL, &tickers, &wg, errChan)
+	go fetchJSON(ctx, baseURL+symbolsPath, &symbols, &wg, errChan)
+	go fetchJSON(ctx, baseURL+tickerPath, &tickers, &wg, errChan)
 
 	wg.Wait()
 	close(errChan)
 
 	for err := range errChan {
 		if err != nil {
-			log.Printf("Error: %v", err)
-			return false
+			return nil, err
 		}
 	}
 
@@ -167,21 +217,28 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 			PriceChangePercent24h: parseFloat(ticker.PriceChange24h, "UpdateAllSpotPairs: parsing PriceChange24h") * 100,
 			BaseVolume24h:         parseFloat(ticker.BaseVolume24h, "UpdateAllSpotPairs: parsing BaseVolume24h"),
 			QuoteVolume24h:        parseFloat(ticker.QuoteVolume24h, "UpdateAllSpotPairs: parsing QuoteVolume24h"),
-			UpdatedAt:             time.Now(),
+			UpdatedAt:             time.Now().UTC(),
 			CreatedAt:             time.Now(),
 		}
 		pairs = append(pairs, pair)
 	}
 
 	if len(pairs) == 0 {
-		log.Println("No trading pairs found")
-		return false
+		return nil, errors.New("No trading pairs found")
+	}
+
+	return pairs, nil
+}
+
+func UpdateAllSpotPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchSpotPairs(ctx)
+	if err != nil {
+		return 0, err
 	}
 
 	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("Failed to begin transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Failed to begin transaction: %w", err)
 	}
 
 	placeholderStr := generateNumberedPlaceholders(len(pairs), 13)
@@ -198,8 +255,7 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 
 	stmt, err := tx.Prepare(query)
 	if err != nil {
-		log.Printf("Failed to prepare statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
@@ -212,53 +268,83 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 	_, err = stmt.Exec(args...)
 	if err != nil {
 		tx.Rollback()
-		log.Printf("Failed to execute statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Failed to execute statement: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("Failed to commit transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Failed to commit transaction: %w", err)
 	}
 
-	return true
+	return len(pairs), nil
 }
 
-func UpdateAllFuturesPairs(db *sql.DB) bool {
+// fetchFuturesPairs downloads the linear and inverse perpetuals and dated
+// futures and builds their pairs. The inverse category is optional: when its
+// requests fail the linear pairs are still returned.
+func fetchFuturesPairs(ctx context.Context) ([]models.PairFutures, error) {
 	var wg sync.WaitGroup
 	errChan := make(chan error, 2)
+	inverseErrChan := make(chan error, 2)
 
-	var futuresData TickerResponseFutures
-	var symbols SymbolsResponse
+	var linearTickers, inverseTickers TickerResponseFutures
+	var linearSymbols, inverseSymbols SymbolsResponse
 
-	wg.Add(2)
-	go fetchJSON(tickerFuturesURL, &futuresData, &wg, errChan)
-	go fetchJSON(symbolsFuturesURL, &symbols, &wg, errChan)
+	wg.Add(4)
+	go fetchJSON(ctx, baseURL+tickerFuturesPath, &linearTickers, &wg, errChan)
+	go fetchJSON(ctx, baseURL+symbolsFuturesPath, &linearSymbols, &wg, errChan)
+	go fetchJSON(ctx, baseURL+tickerInversePath, &inverseTickers, &wg, inverseErrChan)
+	go fetchJSON(ctx, baseURL+symbolsInversePath, &inverseSymbols, &wg, inverseErrChan)
 
 	wg.Wait()
 	close(errChan)
+	close(inverseErrChan)
 
 	for err := range errChan {
 		if err != nil {
-			log.Printf("Bybit Error: %v", err)
-			return false
+			return nil, err
 		}
 	}
 
+	pairs := futuresPairs(linearSymbols, linearTickers, models.ContractLinear)
+	if err := <-inverseErrChan; err != nil {
+		logger.Warn("skipping inverse futures", "error", err)
+		metrics.PartialFailures.WithLabelValues("Bybit", "inverse").Inc()
+	} else {
+		pairs = append(pairs, futuresPairs(inverseSymbols, inverseTickers, models.ContractInverse)...)
+	}
+
+	if len(pairs) == 0 {
+		return nil, errors.New("Bybit No futures pairs to update")
+	}
+
+	return pairs, nil
+}
+
+// futuresPairs builds the pairs of one category. Linear contracts are worth one
+// base coin and inverse contracts one USD, which is also the unit of their
+// volume24h; their turnover24h is in the base coin.
+func futuresPairs(symbols SymbolsResponse, futuresData TickerResponseFutures, contractType string) []models.PairFutures {
 	symbolMap := make(map[string]struct {
 		Symbol     string
 		BaseAsset  string
 		QuoteAsset string
+		Expiry     int64
 	})
 	for _, sym := range symbols.Result.List {
+		var expiry int64
+		if sym.ContractType == "LinearFutures" || sym.ContractType == "InverseFutures" {
+			expiry = int64(parseFloat(sym.DeliveryTime, "UpdateAllFuturesPairs: parsing DeliveryTime"))
+		}
 		symbolMap[sym.Symbol] = struct {
 			Symbol     string
 			BaseAsset  string
 			QuoteAsset string
+			Expiry     int64
 		}{
 			Symbol:     sym.Symbol,
 			BaseAsset:  sym.BaseAsset,
 			QuoteAsset: sym.QuoteAsset,
+			Expiry:     expiry,
 		}
 	}
 
@@ -266,47 +352,61 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
 	for _, data := range futuresData.Result.List {
 		symbolInfo, exists := symbolMap[data.Symbol]
 		if !exists {
-			// log.Printf("Bybit Warning: Symbol %s not found in symbolsFuturesURL", data.Symbol)
 			continue
 		}
-		if data.FundingRate == "" {
-			continue
+		// Dated contracts have no funding, perpetuals without it are not trading yet
+		var fundingRate float64
+		if symbolInfo.Expiry == 0 {
+			if data.FundingRate == "" {
+				continue
+			}
+			fundingRate = parseFloat(data.FundingRate, "UpdateAllFuturesPairs: parsing FundingRate")
+		}
+		baseVolume := parseFloat(data.BaseVolume24h, "UpdateAllFuturesPairs: parsing BaseVolume24h")
+		quoteVolume := parseFloat(data.QuoteVolume24h, "UpdateAllFuturesPairs: parsing QuoteVolume24h")
+		if contractType == models.ContractInverse {
+			baseVolume, quoteVolume = quoteVolume, baseVolume
 		}
 		pair := models.PairFutures{
 			PairKey:               fmt.Sprintf("%s_Bybit_futures", data.Symbol),
 			Symbol:                data.Symbol,
 			Exchange:              "Bybit",
 			Market:                "futures",
-			MarkPrice:             parseFloat(data.LastPrice, "UpdateAllFuturesPairs: parsing LastPrice as MarkPrice"),
-			IndexPrice:            parseFloat(data.LastPrice, "UpdateAllFuturesPairs: parsing LastPrice as IndexPrice"),
+			MarkPrice:             parseFloat(data.MarkPrice, "UpdateAllFuturesPairs: parsing MarkPrice"),
+			IndexPrice:            parseFloat(data.IndexPrice, "UpdateAllFuturesPairs: parsing IndexPrice"),
 			BaseAsset:             symbolInfo.BaseAsset,
 			QuoteAsset:            symbolInfo.QuoteAsset,
 			DisplayName:           fmt.Sprintf("%s/%s", symbolInfo.BaseAsset, symbolInfo.QuoteAsset),
-			FundingRatePercent:    parseFloat(data.FundingRate, "UpdateAllFuturesPairs: parsing FundingRate"),
-			NextFundingTimestamp:  int(parseFloat(data.BaseVolume24h, "UpdateAllFuturesPairs: parsing BaseVolume24h as NextFundingTimestamp")),
+			FundingRatePercent:    fundingRate,
+			NextFundingTimestamp:  int(parseFloat(data.NextFundingTime, "UpdateAllFuturesPairs: parsing NextFundingTime")),
 			PriceChangePercent24h: parseFloat(data.PriceChange24h, "UpdateAllFuturesPairs: parsing PriceChange24h") * 100,
-			BaseVolume24h:         parseFloat(data.BaseVolume24h, "UpdateAllFuturesPairs: parsing BaseVolume24h"),
-			QuoteVolume24h:        parseFloat(data.QuoteVolume24h, "UpdateAllFuturesPairs: parsing QuoteVolume24h"),
-			UpdatedAt:             time.Now(),
+			BaseVolume24h:         baseVolume,
+			QuoteVolume24h:        quoteVolume,
+			Expiry:                symbolInfo.Expiry,
+			ContractType:          contractType,
+			ContractValue:         1,
+			UpdatedAt:             time.Now().UTC(),
 			CreatedAt:             time.Now(),
 		}
 		pairs = append(pairs, pair)
 	}
+	return pairs
+}
 
-	if len(pairs) == 0 {
-		log.Printf("Bybit No futures pairs to update")
-		return false
+func UpdateAllFuturesPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchFuturesPairs(ctx)
+	if err != nil {
+		return 0, err
 	}
 
 	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("Bybit Failed to begin transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Bybit Failed to begin transaction: %w", err)
 	}
 
-	placeholderStr := generateNumberedPlaceholders(len(pairs), 16)
+	placeholderStr := generateNumberedPlaceholders(len(pairs), 19)
 	query := `
-    INSERT INTO pairsfutures (pairkey, symbol, exchange, market, markprice, indexprice, baseasset, quoteasset, displayname, fundingRatePercent, nextfundingtimestamp, pricechangepercent24h, basevolume24h, quotevolume24h, updatedat, createdat)
+    INSERT INTO pairsfutures (pairkey, symbol, exchange, market, markprice, indexprice, baseasset, quoteasset, displayname, fundingRatePercent, nextfundingtimestamp, pricechangepercent24h, basevolume24h, quotevolume24h, expiry, contracttype, contractvalue, updatedat, createdat)
     VALUES ` + placeholderStr + `
     ON CONFLICT (pairkey) DO UPDATE SET
         markprice = EXCLUDED.markprice,
@@ -316,17 +416,19 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
         pricechangepercent24h = EXCLUDED.pricechangepercent24h,
         basevolume24h = EXCLUDED.basevolume24h,
         quotevolume24h = EXCLUDED.quotevolume24h,
+        expiry = EXCLUDED.expiry,
+        contracttype = EXCLUDED.contracttype,
+        contractvalue = EXCLUDED.contractvalue,
         updatedat = EXCLUDED.updatedat
     `
 
 	stmt, err := tx.Prepare(query)
 	if err != nil {
-		log.Printf("Bybit Failed to prepare statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Bybit Failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
-	args := make([]interface{}, 0, len(pairs)*16)
+	args := make([]interface{}, 0, len(pairs)*19)
 	for _, pair := range pairs {
 		args = append(
 			args,
@@ -344,6 +446,9 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
 			pair.PriceChangePercent24h,
 			pair.BaseVolume24h,
 			pair.QuoteVolume24h,
+			pair.Expiry,
+			pair.ContractType,
+			pair.ContractValue,
 			pair.UpdatedAt,
 			pair.CreatedAt,
 		)
@@ -352,14 +457,144 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
 	_, err = stmt.Exec(args...)
 	if err != nil {
 		tx.Rollback()
-		log.Printf("Bybit Failed to execute statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Bybit Failed to execute statement: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("Bybit Failed to commit transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Bybit Failed to commit transaction: %w", err)
+	}
+
+	return len(pairs), nil
+}
+
+// serverClock corrects signed request timestamps for the local clock skew.
+var serverClock = signing.NewClock("Bybit", getServerTime)
+
+// fetchNetworks downloads the deposit and withdrawal status of every coin chain.
+func fetchNetworks(ctx context.Context, creds signing.Credentials) ([]models.Network, error) {
+	if !creds.Configured() {
+		return nil, fmt.Errorf("Bybit error: %w", signing.ErrNoCredentials)
+	}
+
+	serverTime, err := serverClock.Now(ctx)
+	if err != nil {
+		return nil, err
+	}
+	timestamp := strconv.FormatInt(serverTime.UnixMilli(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+coinInfoPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Bybit error creating request: %w", err)
+	}
+	// Bybit signs the timestamp, key and receive window followed by the query string, empty here
+	req.Header.Set("X-BAPI-API-KEY", creds.Key)
+	req.Header.Set("X-BAPI-TIMESTAMP", timestamp)
+	req.Header.Set("X-BAPI-RECV-WINDOW", recvWindow)
+	req.Header.Set("X-BAPI-SIGN", signing.HMACSHA256Hex(creds.Secret, timestamp+creds.Key+recvWindow))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Bybit error fetching coin info: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		serverClock.Invalidate()
+		return nil, fmt.Errorf("Bybit non-OK status code %d from %s", resp.StatusCode, baseURL+coinInfoPath)
+	}
+
+	var info CoinInfoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("Bybit error unmarshalling JSON: %w", err)
+	}
+	// Rejected signatures and timestamps come back with status 200 and a non-zero retCode
+	if info.RetCode != 0 {
+		serverClock.Invalidate()
+		return nil, fmt.Errorf("Bybit error %d from %s: %s", info.RetCode, baseURL+coinInfoPath, info.RetMsg)
+	}
+
+	var networks []models.Network
+	for _, coin := range info.Result.Rows {
+		for _, chain := range coin.Chains {
+			networks = append(networks, models.Network{
+				CoinKey:        fmt.Sprintf("%s_Bybit_%s", coin.Coin, chain.Chain),
+				Coin:           coin.Coin,
+				Exchange:       "Bybit",
+				Network:        chain.Chain,
+				NetworkName:    chain.ChainType,
+				DepositEnable:  chain.ChainDeposit == "1",
+				WithdrawEnable: chain.ChainWithdraw == "1",
+				UpdatedAt:      time.Now().UTC(),
+			})
+		}
+	}
+
+	return networks, nil
+}
+
+func UpdateAllNetworks(ctx context.Context, db *sql.DB, creds signing.Credentials) (int, error) {
+	networks, err := fetchNetworks(ctx, creds)
+	if err != nil {
+		return 0, err
+	}
+	if len(networks) == 0 {
+		logger.Debug("no network data to update")
+		return 0, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("Bybit Failed to begin transaction: %w", err)
+	}
+
+	placeholderStr := generateNumberedPlaceholders(len(networks), 8)
+	query := `
+	INSERT INTO nets (coinKey, coin, exchange, network, networkName, depositEnable, withdrawEnable, updatedAt)
+	VALUES ` + placeholderStr + `
+	ON CONFLICT (coinKey) DO UPDATE SET
+		networkName = EXCLUDED.networkName,
+		depositEnable = EXCLUDED.depositEnable,
+		withdrawEnable = EXCLUDED.withdrawEnable,
+		updatedAt = EXCLUDED.updatedAt
+	`
+
+	args := make([]interface{}, 0, len(networks)*8)
+	for _, n := range networks {
+		args = append(args, n.CoinKey, n.Coin, n.Exchange, n.Network, n.NetworkName, n.DepositEnable, n.WithdrawEnable, n.UpdatedAt)
+	}
+
+	_, err = tx.Exec(query, args...)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("Bybit Failed to execute statement: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("Bybit Failed to commit transaction: %w", err)
+	}
+
+	return len(networks), nil
+}
+
+func getServerTime(ctx context.Context) (time.Time, error) {
+	var wg sync.WaitGroup
+	errChan := make(chan error, 1)
+
+	var result struct {
+		Time int64 `json:"time"`
+	}
+
+	wg.Add(1)
+	go fetchJSON(ctx, baseURL+serverTimePath, &result, &wg, errChan)
+
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		if err != nil {
+			return time.Time{}, err
+		}
 	}
 
-	return true
+	return time.UnixMilli(result.Time), nil
 }
@@ -0,0 +1,223 @@
+package bybit
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"Updater/exchanges/exchangetest"
+	"Updater/exchanges/signing"
+	"Updater/models"
+)
+
+func TestFetchSpotPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		symbolsPath: "instruments_spot.json",
+		tickerPath:  "tickers_spot.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	pairs, err := fetchSpotPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// NEWUSDT has no ticker yet; price24hPcnt is a fraction
+	exchangetest.Compare(t, pairs, []models.Pair{
+		{
+			PairKey:               "BTCUSDT_Bybit_spot",
+			Symbol:                "BTCUSDT",
+			Exchange:              "Bybit",
+			Market:                "spot",
+			Price:                 67012.35,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDT",
+			DisplayName:           "BTC/USDT",
+			PriceChangePercent24h: -6.25,
+			BaseVolume24h:         15234.123456,
+			QuoteVolume24h:        1021345678.123456,
+		},
+		{
+			PairKey:               "ETHBTC_Bybit_spot",
+			Symbol:                "ETHBTC",
+			Exchange:              "Bybit",
+			Market:                "spot",
+			Price:                 0.051234,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "BTC",
+			DisplayName:           "ETH/BTC",
+			PriceChangePercent24h: 3.125,
+			BaseVolume24h:         812.5,
+			QuoteVolume24h:        41.62,
+		},
+	})
+}
+
+func TestFetchFuturesPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		symbolsFuturesPath: "instruments_linear.json",
+		tickerFuturesPath:  "tickers_linear.json",
+		symbolsInversePath: "instruments_inverse.json",
+		tickerInversePath:  "tickers_inverse.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	pairs, err := fetchFuturesPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// BTC-27DEC24 and BTCUSDZ24 are dated and have no funding rate; SOLUSDT is not in
+	// the instruments. Inverse contracts count volume24h in USD and turnover24h in BTC
+	exchangetest.Compare(t, pairs, []models.PairFutures{
+		{
+			PairKey:               "BTCUSDT_Bybit_futures",
+			Symbol:                "BTCUSDT",
+			Exchange:              "Bybit",
+			Market:                "futures",
+			MarkPrice:             67051.12,
+			IndexPrice:            67040.55,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDT",
+			DisplayName:           "BTC/USDT",
+			FundingRatePercent:    0.0001,
+			NextFundingTimestamp:  1718006400000,
+			PriceChangePercent24h: -1.5625,
+			BaseVolume24h:         98765.432,
+			QuoteVolume24h:        6621234567.89,
+			ContractType:          models.ContractLinear,
+			ContractValue:         1,
+		},
+		{
+			PairKey:               "ETHUSDT_Bybit_futures",
+			Symbol:                "ETHUSDT",
+			Exchange:              "Bybit",
+			Market:                "futures",
+			MarkPrice:             3510.25,
+			IndexPrice:            3509.8,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "USDT",
+			DisplayName:           "ETH/USDT",
+			FundingRatePercent:    -0.000025,
+			NextFundingTimestamp:  1718006400000,
+			PriceChangePercent24h: 50,
+			BaseVolume24h:         456789.1,
+			QuoteVolume24h:        1603456789.5,
+			ContractType:          models.ContractLinear,
+			ContractValue:         1,
+		},
+		{
+			PairKey:               "BTC-27DEC24_Bybit_futures",
+			Symbol:                "BTC-27DEC24",
+			Exchange:              "Bybit",
+			Market:                "futures",
+			MarkPrice:             70110,
+			IndexPrice:            67040.55,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDC",
+			DisplayName:           "BTC/USDC",
+			PriceChangePercent24h: 1,
+			BaseVolume24h:         12,
+			QuoteVolume24h:        841200,
+			Expiry:                1735286400000,
+			ContractType:          models.ContractLinear,
+			ContractValue:         1,
+		},
+		{
+			PairKey:               "BTCUSD_Bybit_futures",
+			Symbol:                "BTCUSD",
+			Exchange:              "Bybit",
+			Market:                "futures",
+			MarkPrice:             67059.9,
+			IndexPrice:            67045.3,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USD",
+			DisplayName:           "BTC/USD",
+			FundingRatePercent:    0.000075,
+			NextFundingTimestamp:  1718006400000,
+			PriceChangePercent24h: -1.25,
+			BaseVolume24h:         6150.25,
+			QuoteVolume24h:        412345678,
+			ContractType:          models.ContractInverse,
+			ContractValue:         1,
+		},
+		{
+			PairKey:               "BTCUSDZ24_Bybit_futures",
+			Symbol:                "BTCUSDZ24",
+			Exchange:              "Bybit",
+			Market:                "futures",
+			MarkPrice:             70212.5,
+			IndexPrice:            67045.3,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USD",
+			DisplayName:           "BTC/USD",
+			PriceChangePercent24h: 0.5,
+			BaseVolume24h:         49.85,
+			QuoteVolume24h:        3500000,
+			Expiry:                1735286400000,
+			ContractType:          models.ContractInverse,
+			ContractValue:         1,
+		},
+	})
+}
+
+func TestFetchFuturesPairsWithoutInverse(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		symbolsFuturesPath: "instruments_linear.json",
+		tickerFuturesPath:  "tickers_linear.json",
+		symbolsInversePath: "",
+		tickerInversePath:  "",
+	})
+	srv.SetURL(t, &baseURL)
+
+	pairs, err := fetchFuturesPairs(context.Background())
+	if err != nil {
+		t.Fatalf("Inverse category outage failed the job: %v", err)
+	}
+	var symbols []string
+	for _, p := range pairs {
+		symbols = append(symbols, p.Symbol)
+	}
+	if want := []string{"BTCUSDT", "ETHUSDT", "BTC-27DEC24"}; !slices.Equal(symbols, want) {
+		t.Errorf("symbols = %v, want the linear pairs %v", symbols, want)
+	}
+}
+
+func TestFetchNetworks(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		serverTimePath: "server_time.json",
+		coinInfoPath:   "coin_info.json",
+	})
+	srv.SetURL(t, &baseURL)
+	serverClock.Invalidate()
+
+	networks, err := fetchNetworks(context.Background(), signing.Credentials{Key: "key", Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exchangetest.Compare(t, networks, []models.Network{
+		{CoinKey: "USDT_Bybit_ETH", Coin: "USDT", Exchange: "Bybit", Network: "ETH", NetworkName: "ERC20", DepositEnable: true, WithdrawEnable: true},
+		{CoinKey: "USDT_Bybit_TRX", Coin: "USDT", Exchange: "Bybit", Network: "TRX", NetworkName: "TRC20", DepositEnable: true},
+		{CoinKey: "BTC_Bybit_BTC", Coin: "BTC", Exchange: "Bybit", Network: "BTC", NetworkName: "BTC", WithdrawEnable: true},
+	})
+
+	requests := srv.Requests()
+	signed := requests[len(requests)-1]
+	if got := signed.Header.Get("X-BAPI-API-KEY"); got != "key" {
+		t.Errorf("X-BAPI-API-KEY = %q, want %q", got, "key")
+	}
+	if got := signed.Header.Get("X-BAPI-TIMESTAMP"); got != "1718000000000" {
+		t.Errorf("X-BAPI-TIMESTAMP = %q, want the server time", got)
+	}
+	want := signing.HMACSHA256Hex("secret", "1718000000000key5000")
+	if got := signed.Header.Get("X-BAPI-SIGN"); got != want {
+		t.Errorf("X-BAPI-SIGN = %q, want %q", got, want)
+	}
+}
+
+func TestFetchNetworksWithoutKeys(t *testing.T) {
+	if _, err := fetchNetworks(context.Background(), signing.Credentials{Key: "key"}); err == nil {
+		t.Error("expected an error without an API secret")
+	}
+}
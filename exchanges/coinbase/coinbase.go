@@ -0,0 +1,339 @@
+package coinbase
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Updater/exchanges/httpclient"
+	"Updater/logging"
+	"Updater/metrics"
+	"Updater/models"
+)
+
+var (
+	logger       = logging.Exchange("Coinbase")
+	parseSampler = logging.NewSampler()
+	httpClient   = httpclient.For("Coinbase")
+)
+
+// baseURL is a variable so tests can point the connector at recorded responses
+var baseURL = "https://api.exchange.coinbase.com"
+
+const (
+	productsPath   = "/products"
+	statsPath      = "/products/stats"
+	currenciesPath = "/currencies"
+
+	MAX_DECIMAL_18_8 = 9999999999.99999999   // Максимальне значення для DECIMAL(18,8)
+	MAX_DECIMAL_10_2 = 99999999.99           // Максимальне значення для DECIMAL(10,2)
+	MAX_DECIMAL_20_2 = 999999999999999999.99 // Максимальне значення для DECIMAL(20,2)
+)
+
+// Product is a trading pair such as BTC-USD.
+type Product struct {
+	ID              string `json:"id"`
+	BaseCurrency    string `json:"base_currency"`
+	QuoteCurrency   string `json:"quote_currency"`
+	Status          string `json:"status"`
+	TradingDisabled bool   `json:"trading_disabled"`
+}
+
+// StatsResponse maps product ids to their 24h statistics.
+type StatsResponse map[string]struct {
+	Stats24h struct {
+		Open   string `json:"open"`
+		Last   string `json:"last"`
+		Volume string `json:"volume"` // Базовий обсяг
+	} `json:"stats_24hour"`
+}
+
+// Currency is an asset with the networks it can be deposited and withdrawn on.
+type Currency struct {
+	ID                string `json:"id"`
+	Status            string `json:"status"`
+	SupportedNetworks []struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Status string `json:"status"`
+	} `json:"supported_networks"`
+}
+
+func fetchJSON(ctx context.Context, url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
+	defer wg.Done()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		errChan <- fmt.Errorf("Coinbase error fetching %s: %w", url, err)
+		return
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		errChan <- fmt.Errorf("Coinbase error fetching %s: %w", url, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errChan <- fmt.Errorf("Coinbase non-OK status code %d from %s", resp.StatusCode, url)
+		return
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		errChan <- fmt.Errorf("Coinbase error reading response from %s: %w", url, err)
+		return
+	}
+
+	if err := json.Unmarshal(body, target); err != nil {
+		errChan <- fmt.Errorf("Coinbase error unmarshalling JSON from %s: %w", url, err)
+	}
+}
+
+func parseFloat(s string) float64 {
+	val, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		parseSampler.Warn(logger, "parseFloat", "failed to parse float", "value", s)
+		metrics.ParseWarnings.WithLabelValues("Coinbase").Inc()
+		return 0
+	}
+	return val
+}
+
+func generateNumberedPlaceholders(rows int, fieldCount int) string {
+	placeholders := make([]string, rows)
+	counter := 1
+	for i := 0; i < rows; i++ {
+		inner := make([]string, fieldCount)
+		for j := 0; j < fieldCount; j++ {
+			inner[j] = "$" + strconv.Itoa(counter)
+			counter++
+		}
+		placeholders[i] = "(" + strings.Join(inner, ", ") + ")"
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+func sanitizeDecimal(value float64, maxValue float64, precision int) float64 {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0
+	}
+
+	if value > maxValue {
+		value = maxValue
+	} else if value < -maxValue {
+		value = -maxValue
+	}
+
+	format := "%." + strconv.Itoa(precision) + "f"
+	strVal := fmt.Sprintf(format, value)
+	formattedVal, _ := strconv.ParseFloat(strVal, 64)
+	return formattedVal
+}
+
+// fetchSpotPairs downloads the products and their 24h statistics and builds
+// the pairs of the online products, ordered by symbol.
+func fetchSpotPairs(ctx context.Context) ([]models.Pair, error) {
+	var wg sync.WaitGroup
+	errChan := make(chan error, 2)
+
+	var products []Product
+	var stats StatsResponse
+
+	wg.Add(2)
+	go fetchJSON(ctx, baseURL+productsPath, &products, &wg, errChan)
+	go fetchJSON(ctx, baseURL+statsPath, &stats, &wg, errChan)
+
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var pairs []models.Pair
+	for _, product := range products {
+		if product.Status != "online" || product.TradingDisabled {
+			continue
+		}
+		stat, exists := stats[product.ID]
+		if !exists || stat.Stats24h.Last == "" {
+			continue
+		}
+
+		last := parseFloat(stat.Stats24h.Last)
+		price := sanitizeDecimal(last, MAX_DECIMAL_18_8, 8)
+		if price <= 0 {
+			continue
+		}
+
+		var priceChangePercent float64
+		if open := parseFloat(stat.Stats24h.Open); open > 0 {
+			priceChangePercent = sanitizeDecimal((last-open)/open*100, MAX_DECIMAL_10_2, 2)
+		}
+		volume := parseFloat(stat.Stats24h.Volume)
+
+		// BTC-USD becomes BTCUSD, like the symbols of the other exchanges
+		symbol := product.BaseCurrency + product.QuoteCurrency
+		pairs = append(pairs, models.Pair{
+			PairKey:               fmt.Sprintf("%s_Coinbase_spot", symbol),
+			Symbol:                symbol,
+			Exchange:              "Coinbase",
+			Market:                "spot",
+			Price:                 price,
+			BaseAsset:             product.BaseCurrency,
+			QuoteAsset:            product.QuoteCurrency,
+			DisplayName:           fmt.Sprintf("%s/%s", product.BaseCurrency, product.QuoteCurrency),
+			PriceChangePercent24h: priceChangePercent,
+			BaseVolume24h:         sanitizeDecimal(volume, MAX_DECIMAL_20_2, 2),
+			QuoteVolume24h:        sanitizeDecimal(volume*last, MAX_DECIMAL_20_2, 2),
+			UpdatedAt:             time.Now().UTC(),
+		})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Symbol < pairs[j].Symbol })
+
+	return pairs, nil
+}
+
+func UpdateAllSpotPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchSpotPairs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(pairs) == 0 {
+		return 0, errors.New("Coinbase No pairs to update")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("Coinbase Failed to begin transaction: %w", err)
+	}
+
+	placeholderStr := generateNumberedPlaceholders(len(pairs), 12)
+	query := `
+    INSERT INTO pairs (pairkey, symbol, exchange, market, price, baseasset, quoteasset, displayname, pricechangepercent24h, basevolume24h, quotevolume24h, updatedat)
+    VALUES ` + placeholderStr + `
+    ON CONFLICT (pairkey) DO UPDATE SET
+        price = EXCLUDED.price,
+        pricechangepercent24h = EXCLUDED.pricechangepercent24h,
+        basevolume24h = EXCLUDED.basevolume24h,
+        quotevolume24h = EXCLUDED.quotevolume24h,
+        updatedat = EXCLUDED.updatedat
+    `
+
+	args := make([]interface{}, 0, len(pairs)*12)
+	for _, pair := range pairs {
+		args = append(args, pair.PairKey, pair.Symbol, pair.Exchange, pair.Market, pair.Price, pair.BaseAsset, pair.QuoteAsset,
+			pair.DisplayName, pair.PriceChangePercent24h, pair.BaseVolume24h, pair.QuoteVolume24h, pair.UpdatedAt)
+	}
+
+	_, err = tx.Exec(query, args...)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("Coinbase Failed to execute statement: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("Coinbase Failed to commit transaction: %w", err)
+	}
+
+	return len(pairs), nil
+}
+
+// fetchNetworks downloads the currencies and builds one record per supported
+// network. Coinbase publishes one status per network, so deposits and
+// withdrawals are both open while it is online.
+func fetchNetworks(ctx context.Context) ([]models.Network, error) {
+	var wg sync.WaitGroup
+	errChan := make(chan error, 1)
+
+	var currencies []Currency
+
+	wg.Add(1)
+	go fetchJSON(ctx, baseURL+currenciesPath, &currencies, &wg, errChan)
+
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var networks []models.Network
+	for _, currency := range currencies {
+		for _, n := range currency.SupportedNetworks {
+			online := currency.Status == "online" && n.Status == "online"
+			network := strings.ToUpper(n.ID)
+			networks = append(networks, models.Network{
+				CoinKey:        fmt.Sprintf("%s_Coinbase_%s", currency.ID, network),
+				Coin:           currency.ID,
+				Exchange:       "Coinbase",
+				Network:        network,
+				NetworkName:    n.Name,
+				DepositEnable:  online,
+				WithdrawEnable: online,
+				UpdatedAt:      time.Now().UTC(),
+			})
+		}
+	}
+
+	return networks, nil
+}
+
+func UpdateAllNetworks(ctx context.Context, db *sql.DB) (int, error) {
+	networks, err := fetchNetworks(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(networks) == 0 {
+		logger.Debug("no network data to update")
+		return 0, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("Coinbase Failed to begin transaction: %w", err)
+	}
+
+	placeholderStr := generateNumberedPlaceholders(len(networks), 8)
+	query := `
+	INSERT INTO nets (coinKey, coin, exchange, network, networkName, depositEnable, withdrawEnable, updatedAt)
+	VALUES ` + placeholderStr + `
+	ON CONFLICT (coinKey) DO UPDATE SET
+		networkName = EXCLUDED.networkName,
+		depositEnable = EXCLUDED.depositEnable,
+		withdrawEnable = EXCLUDED.withdrawEnable,
+		updatedAt = EXCLUDED.updatedAt
+	`
+
+	args := make([]interface{}, 0, len(networks)*8)
+	for _, n := range networks {
+		args = append(args, n.CoinKey, n.Coin, n.Exchange, n.Network, n.NetworkName, n.DepositEnable, n.WithdrawEnable, n.UpdatedAt)
+	}
+
+	_, err = tx.Exec(query, args...)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("Coinbase Failed to execute statement: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("Coinbase Failed to commit transaction: %w", err)
+	}
+
+	return len(networks), nil
+}
@@ -0,0 +1,85 @@
+package coinbase
+
+import (
+	"context"
+	"testing"
+
+	"Updater/exchanges/exchangetest"
+	"Updater/models"
+)
+
+func TestFetchSpotPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		productsPath: "products.json",
+		statsPath:    "products_stats.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	pairs, err := fetchSpotPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// OLD-USD is delisted, HALT-USD has trading disabled and NEW-USD no
+	// stats; the change is measured from the 24h open
+	exchangetest.Compare(t, pairs, []models.Pair{
+		{
+			PairKey:               "BTCUSD_Coinbase_spot",
+			Symbol:                "BTCUSD",
+			Exchange:              "Coinbase",
+			Market:                "spot",
+			Price:                 67012.4,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USD",
+			DisplayName:           "BTC/USD",
+			PriceChangePercent24h: 1.53,
+			BaseVolume24h:         10234.5,
+			QuoteVolume24h:        685838407.8,
+		},
+		{
+			PairKey:               "ETHBTC_Coinbase_spot",
+			Symbol:                "ETHBTC",
+			Exchange:              "Coinbase",
+			Market:                "spot",
+			Price:                 0.051234,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "BTC",
+			DisplayName:           "ETH/BTC",
+			PriceChangePercent24h: -2.41,
+			BaseVolume24h:         812.5,
+			QuoteVolume24h:        41.63,
+		},
+		{
+			PairKey:               "USDTUSDC_Coinbase_spot",
+			Symbol:                "USDTUSDC",
+			Exchange:              "Coinbase",
+			Market:                "spot",
+			Price:                 1.0001,
+			BaseAsset:             "USDT",
+			QuoteAsset:            "USDC",
+			DisplayName:           "USDT/USDC",
+			PriceChangePercent24h: 0.01,
+			BaseVolume24h:         250000,
+			QuoteVolume24h:        250025,
+		},
+	})
+}
+
+func TestFetchNetworks(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		currenciesPath: "currencies.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	networks, err := fetchNetworks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The delisted Solana network of USDC is closed both ways; fiat USD has no networks
+	exchangetest.Compare(t, networks, []models.Network{
+		{CoinKey: "USDC_Coinbase_ETHEREUM", Coin: "USDC", Exchange: "Coinbase", Network: "ETHEREUM", NetworkName: "Ethereum", DepositEnable: true, WithdrawEnable: true},
+		{CoinKey: "USDC_Coinbase_SOLANA", Coin: "USDC", Exchange: "Coinbase", Network: "SOLANA", NetworkName: "Solana"},
+		{CoinKey: "BTC_Coinbase_BITCOIN", Coin: "BTC", Exchange: "Coinbase", Network: "BITCOIN", NetworkName: "Bitcoin", DepositEnable: true, WithdrawEnable: true},
+	})
+}
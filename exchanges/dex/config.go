@@ -0,0 +1,188 @@
+package dex
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Pool types; both read the price from a single call to the pool contract.
+const (
+	// UniswapV2 pools price by their reserves, from getReserves().
+	UniswapV2 = "uniswap-v2"
+	// UniswapV3 pools price by the square root price in slot0().
+	UniswapV3 = "uniswap-v3"
+)
+
+// defaultExchange is the exchange of pools when the file does not name one.
+const defaultExchange = "DEX"
+
+// defaultBatchSize keeps the batches under the limits of public RPC providers.
+const defaultBatchSize = 100
+
+var addressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+// Token is one side of a pool and the asset it stands for, such as WETH for ETH.
+type Token struct {
+	// Asset is the symbol the exchanges use, which the diffs compare on.
+	Asset    string `yaml:"asset" toml:"asset"`
+	Address  string `yaml:"address" toml:"address"`
+	Decimals int    `yaml:"decimals" toml:"decimals"`
+}
+
+// Pool is a liquidity pool collected as a spot market.
+type Pool struct {
+	Address string `yaml:"address" toml:"address"`
+	Type    string `yaml:"type" toml:"type"`
+	// Exchange overrides the exchange of the file for this pool.
+	Exchange string `yaml:"exchange" toml:"exchange"`
+	Base     Token  `yaml:"base" toml:"base"`
+	Quote    Token  `yaml:"quote" toml:"quote"`
+}
+
+// Symbol is the market symbol, such as ETHUSDC.
+func (p Pool) Symbol() string {
+	return p.Base.Asset + p.Quote.Asset
+}
+
+// baseIsToken0 reports whether the base token is token0 of the pool. Uniswap
+// and its forks order the two tokens of a pool by address.
+func (p Pool) baseIsToken0() bool {
+	return strings.ToLower(p.Base.Address) < strings.ToLower(p.Quote.Address)
+}
+
+// Config lists the pools to collect and the node to read them from.
+type Config struct {
+	// Exchange is the synthetic exchange the pools are stored under in pairs.
+	Exchange string `yaml:"exchange" toml:"exchange"`
+	// RPCURL is the EVM JSON-RPC endpoint, overridden by DEX_RPC_URL.
+	RPCURL string `yaml:"rpcUrl" toml:"rpcUrl"`
+	// BatchSize is the number of calls sent in one JSON-RPC batch.
+	BatchSize int    `yaml:"batchSize" toml:"batchSize"`
+	Pools     []Pool `yaml:"pools" toml:"pools"`
+}
+
+// Load reads the pools file at path (YAML or TOML) and validates it. A
+// non-empty rpcURL replaces the endpoint of the file.
+func Load(path, rpcURL string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading DEX pools: %w", err)
+	}
+
+	var cfg Config
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("error parsing %s: %w", path, err)
+		}
+	case ".toml":
+		dec := toml.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported DEX pools format %q, expected .yaml, .yml or .toml", filepath.Ext(path))
+	}
+
+	if rpcURL != "" {
+		cfg.RPCURL = rpcURL
+	}
+	cfg.setDefaults()
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+func (c *Config) setDefaults() {
+	if c.Exchange == "" {
+		c.Exchange = defaultExchange
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = defaultBatchSize
+	}
+	for i := range c.Pools {
+		if c.Pools[i].Exchange == "" {
+			c.Pools[i].Exchange = c.Exchange
+		}
+	}
+}
+
+// Validate returns every problem of the config, not only the first one.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.RPCURL == "" {
+		errs = append(errs, errors.New("rpcUrl (or DEX_RPC_URL) is required"))
+	}
+	if c.BatchSize < 1 {
+		errs = append(errs, errors.New("batchSize must be positive"))
+	}
+	if len(c.Pools) == 0 {
+		errs = append(errs, errors.New("no pools configured"))
+	}
+
+	markets := make(map[string]bool)
+	for i, p := range c.Pools {
+		prefix := fmt.Sprintf("pools[%d]", i)
+		if !addressPattern.MatchString(p.Address) {
+			errs = append(errs, fmt.Errorf("%s: invalid address %q", prefix, p.Address))
+		}
+		if p.Type != UniswapV2 && p.Type != UniswapV3 {
+			errs = append(errs, fmt.Errorf("%s: unknown type %q, expected %s or %s", prefix, p.Type, UniswapV2, UniswapV3))
+		}
+		errs = append(errs, p.Base.validate(prefix, "base")...)
+		errs = append(errs, p.Quote.validate(prefix, "quote")...)
+		if strings.EqualFold(p.Base.Address, p.Quote.Address) {
+			errs = append(errs, fmt.Errorf("%s: base and quote are the same token", prefix))
+		}
+
+		market := p.Exchange + " " + p.Symbol()
+		if markets[market] {
+			errs = append(errs, fmt.Errorf("%s: duplicate market %s on %s, give one of the pools its own exchange", prefix, p.Symbol(), p.Exchange))
+		}
+		markets[market] = true
+	}
+	return errors.Join(errs...)
+}
+
+func (t Token) validate(prefix, side string) []error {
+	var errs []error
+	if t.Asset == "" {
+		errs = append(errs, fmt.Errorf("%s: %s asset is required", prefix, side))
+	}
+	if !addressPattern.MatchString(t.Address) {
+		errs = append(errs, fmt.Errorf("%s: invalid %s address %q", prefix, side, t.Address))
+	}
+	if t.Decimals < 0 || t.Decimals > 36 {
+		errs = append(errs, fmt.Errorf("%s: %s decimals must be between 0 and 36", prefix, side))
+	}
+	return errs
+}
+
+// Exchanges returns the exchanges of the pools in alphabetical order.
+func (c *Config) Exchanges() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, p := range c.Pools {
+		if !seen[p.Exchange] {
+			seen[p.Exchange] = true
+			names = append(names, p.Exchange)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
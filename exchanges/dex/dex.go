@@ -0,0 +1,328 @@
+// Package dex reads the prices of on-chain liquidity pools through an EVM
+// JSON-RPC node and stores them as spot markets of synthetic exchanges, so the
+// spot diffs compare them with the centralized exchanges.
+package dex
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"Updater/exchanges/httpclient"
+	"Updater/logging"
+	"Updater/metrics"
+	"Updater/models"
+)
+
+var (
+	logger       = logging.For("component", "dex")
+	parseSampler = logging.NewSampler()
+	httpClient   = httpclient.For("DEX")
+)
+
+const (
+	MAX_DECIMAL_18_8 = 9999999999.99999999 // Максимальне значення для DECIMAL(18,8)
+)
+
+// Selectors of the pool functions that return the price.
+const (
+	getReservesSelector = "0x0902f1ac" // getReserves() returns (uint112 reserve0, uint112 reserve1, uint32 blockTimestampLast)
+	slot0Selector       = "0x3850c7bd" // slot0() returns (uint160 sqrtPriceX96, int24 tick, ...)
+)
+
+// q192 is 2^192, the scale of a squared sqrtPriceX96.
+var q192 = new(big.Int).Lsh(big.NewInt(1), 192)
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      int    `json:"id"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+}
+
+type rpcResponse struct {
+	ID     int    `json:"id"`
+	Result string `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type callParams struct {
+	To   string `json:"to"`
+	Data string `json:"data"`
+}
+
+// ethCalls sends one eth_call per request in a JSON-RPC batch and returns the
+// responses by id. Calls that failed on the node carry their error.
+func ethCalls(ctx context.Context, url string, requests []rpcRequest) (map[int]rpcResponse, error) {
+	body, err := json.Marshal(requests)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding JSON-RPC batch: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error calling %s: %w", url, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("non-OK status code %d from %s", resp.StatusCode, url)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response from %s: %w", url, err)
+	}
+
+	var responses []rpcResponse
+	if err := json.Unmarshal(data, &responses); err != nil {
+		// Nodes answer a batch they reject as a whole with a single error object
+		var single rpcResponse
+		if json.Unmarshal(data, &single) == nil && single.Error != nil {
+			return nil, fmt.Errorf("JSON-RPC error from %s: %s", url, single.Error.Message)
+		}
+		return nil, fmt.Errorf("error unmarshalling JSON-RPC response from %s: %w", url, err)
+	}
+
+	byID := make(map[int]rpcResponse, len(responses))
+	for _, r := range responses {
+		byID[r.ID] = r
+	}
+	return byID, nil
+}
+
+// words splits an ABI encoded result into its 32 byte words.
+func words(result string) ([]*big.Int, error) {
+	data, err := hex.DecodeString(strings.TrimPrefix(result, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex result: %w", err)
+	}
+	if len(data) == 0 || len(data)%32 != 0 {
+		return nil, fmt.Errorf("unexpected result length %d", len(data))
+	}
+	out := make([]*big.Int, len(data)/32)
+	for i := range out {
+		out[i] = new(big.Int).SetBytes(data[i*32 : (i+1)*32])
+	}
+	return out, nil
+}
+
+// poolPrice converts the result of the price call of pool into the price of
+// the base asset in the quote asset.
+func poolPrice(pool Pool, result string) (float64, error) {
+	values, err := words(result)
+	if err != nil {
+		return 0, err
+	}
+
+	// ratio is the price of token0 in token1, in their smallest units
+	var num, den *big.Int
+	switch pool.Type {
+	case UniswapV2:
+		if len(values) < 2 {
+			return 0, fmt.Errorf("unexpected getReserves result with %d words", len(values))
+		}
+		num, den = values[1], values[0]
+	case UniswapV3:
+		num, den = new(big.Int).Mul(values[0], values[0]), q192
+	default:
+		return 0, fmt.Errorf("unknown pool type %q", pool.Type)
+	}
+	if num.Sign() == 0 || den.Sign() == 0 {
+		return 0, errors.New("pool has no liquidity")
+	}
+	ratio := new(big.Float).SetPrec(256).SetInt(num)
+	ratio.Quo(ratio, new(big.Float).SetPrec(256).SetInt(den))
+
+	token0, token1 := pool.Base, pool.Quote
+	if !pool.baseIsToken0() {
+		token0, token1 = pool.Quote, pool.Base
+	}
+	// Whole tokens: 10^decimals0 units of token0 buy ratio * 10^decimals0 units of token1
+	scale := new(big.Float).SetPrec(256).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(token0.Decimals-token1.Decimals))), nil))
+	if token0.Decimals > token1.Decimals {
+		ratio.Mul(ratio, scale)
+	} else {
+		ratio.Quo(ratio, scale)
+	}
+
+	price, _ := ratio.Float64()
+	if !pool.baseIsToken0() {
+		price = 1 / price
+	}
+	return price, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func generateNumberedPlaceholders(rows int, fieldCount int) string {
+	placeholders := make([]string, rows)
+	counter := 1
+	for i := 0; i < rows; i++ {
+		inner := make([]string, fieldCount)
+		for j := 0; j < fieldCount; j++ {
+			inner[j] = "$" + strconv.Itoa(counter)
+			counter++
+		}
+		placeholders[i] = "(" + strings.Join(inner, ", ") + ")"
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+func sanitizeDecimal(value float64, maxValue float64, precision int) float64 {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0
+	}
+
+	if value > maxValue {
+		value = maxValue
+	} else if value < -maxValue {
+		value = -maxValue
+	}
+
+	format := "%." + strconv.Itoa(precision) + "f"
+	strVal := fmt.Sprintf(format, value)
+	formattedVal, _ := strconv.ParseFloat(strVal, 64)
+	return formattedVal
+}
+
+// fetchSpotPairs reads the current price of every pool of exchange from the
+// node, in batches of cfg.BatchSize calls, in the order of the config. Pools
+// whose call fails or that have no liquidity are skipped with a warning.
+// Pools report no 24h change or volume.
+func fetchSpotPairs(ctx context.Context, cfg *Config, exchange string) ([]models.Pair, error) {
+	var pools []Pool
+	for _, p := range cfg.Pools {
+		if p.Exchange == exchange {
+			pools = append(pools, p)
+		}
+	}
+
+	var pairs []models.Pair
+	for start := 0; start < len(pools); start += cfg.BatchSize {
+		batch := pools[start:min(start+cfg.BatchSize, len(pools))]
+
+		requests := make([]rpcRequest, len(batch))
+		for i, p := range batch {
+			selector := getReservesSelector
+			if p.Type == UniswapV3 {
+				selector = slot0Selector
+			}
+			requests[i] = rpcRequest{
+				JSONRPC: "2.0",
+				ID:      i + 1,
+				Method:  "eth_call",
+				Params:  []any{callParams{To: p.Address, Data: selector}, "latest"},
+			}
+		}
+
+		responses, err := ethCalls(ctx, cfg.RPCURL, requests)
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", exchange, err)
+		}
+
+		for i, p := range batch {
+			resp, ok := responses[i+1]
+			var price float64
+			switch {
+			case !ok:
+				err = errors.New("no response to the call")
+			case resp.Error != nil:
+				err = errors.New(resp.Error.Message)
+			default:
+				price, err = poolPrice(p, resp.Result)
+			}
+			price = sanitizeDecimal(price, MAX_DECIMAL_18_8, 8)
+			if err == nil && price <= 0 {
+				err = errors.New("price rounds to zero")
+			}
+			if err != nil {
+				parseSampler.Warn(logger, p.Address, "skipping pool", "exchange", exchange, "symbol", p.Symbol(), "pool", p.Address, "error", err)
+				metrics.ParseWarnings.WithLabelValues(exchange).Inc()
+				continue
+			}
+
+			pairs = append(pairs, models.Pair{
+				PairKey:     fmt.Sprintf("%s_%s_spot", p.Symbol(), exchange),
+				Symbol:      p.Symbol(),
+				Exchange:    exchange,
+				Market:      "spot",
+				Price:       price,
+				BaseAsset:   p.Base.Asset,
+				QuoteAsset:  p.Quote.Asset,
+				DisplayName: fmt.Sprintf("%s/%s", p.Base.Asset, p.Quote.Asset),
+				UpdatedAt:   time.Now().UTC(),
+			})
+		}
+	}
+
+	return pairs, nil
+}
+
+// UpdateAllSpotPairs stores the prices of the pools of exchange in pairs.
+func UpdateAllSpotPairs(ctx context.Context, db *sql.DB, cfg *Config, exchange string) (int, error) {
+	pairs, err := fetchSpotPairs(ctx, cfg, exchange)
+	if err != nil {
+		return 0, err
+	}
+	if len(pairs) == 0 {
+		return 0, fmt.Errorf("%s No pools to update", exchange)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("%s Failed to begin transaction: %w", exchange, err)
+	}
+
+	placeholderStr := generateNumberedPlaceholders(len(pairs), 12)
+	query := `
+    INSERT INTO pairs (pairkey, symbol, exchange, market, price, baseasset, quoteasset, displayname, pricechangepercent24h, basevolume24h, quotevolume24h, updatedat)
+    VALUES ` + placeholderStr + `
+    ON CONFLICT (pairkey) DO UPDATE SET
+        price = EXCLUDED.price,
+        pricechangepercent24h = EXCLUDED.pricechangepercent24h,
+        basevolume24h = EXCLUDED.basevolume24h,
+        quotevolume24h = EXCLUDED.quotevolume24h,
+        updatedat = EXCLUDED.updatedat
+    `
+
+	args := make([]interface{}, 0, len(pairs)*12)
+	for _, pair := range pairs {
+		args = append(args, pair.PairKey, pair.Symbol, pair.Exchange, pair.Market, pair.Price, pair.BaseAsset, pair.QuoteAsset,
+			pair.DisplayName, pair.PriceChangePercent24h, pair.BaseVolume24h, pair.QuoteVolume24h, pair.UpdatedAt)
+	}
+
+	_, err = tx.Exec(query, args...)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("%s Failed to execute statement: %w", exchange, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s Failed to commit transaction: %w", exchange, err)
+	}
+
+	return len(pairs), nil
+}
@@ -0,0 +1,59 @@
+//go:build integration
+
+package dex
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+// TestMainnetPools reads the WETH/USDC pools of Uniswap v2 and v3 from the
+// node in TEST_EVM_RPC_URL, usually a local fork of mainnet:
+//
+//	anvil --fork-url <mainnet RPC URL>
+//	TEST_EVM_RPC_URL=http://127.0.0.1:8545 go test -tags integration ./exchanges/dex/
+func TestMainnetPools(t *testing.T) {
+	rpcURL := os.Getenv("TEST_EVM_RPC_URL")
+	if rpcURL == "" {
+		t.Skip("TEST_EVM_RPC_URL is not set")
+	}
+
+	weth := Token{Asset: "ETH", Address: "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", Decimals: 18}
+	usdc := Token{Asset: "USDC", Address: "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", Decimals: 6}
+	cfg := &Config{
+		RPCURL: rpcURL,
+		Pools: []Pool{
+			{Address: "0xB4e16d0168e52d35CaCD2c6185b44281Ec28C9Dc", Type: UniswapV2, Exchange: "UniswapV2", Base: weth, Quote: usdc},
+			{Address: "0x88e6A0c2dDD26FEEb64F039a2c41296FcB3f5640", Type: UniswapV3, Exchange: "UniswapV3", Base: weth, Quote: usdc},
+		},
+	}
+	cfg.setDefaults()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var prices []float64
+	for _, exchange := range cfg.Exchanges() {
+		pairs, err := fetchSpotPairs(context.Background(), cfg, exchange)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pairs) != 1 {
+			t.Fatalf("%s: %d pairs, want 1", exchange, len(pairs))
+		}
+		t.Logf("%s %s %v", exchange, pairs[0].Symbol, pairs[0].Price)
+		prices = append(prices, pairs[0].Price)
+	}
+
+	// Whatever the fork block, ETH trades far above 100 USDC and both pools
+	// are kept within a few percent of each other by arbitrage
+	for _, price := range prices {
+		if price < 100 || price > 100000 {
+			t.Errorf("implausible ETH price %v", price)
+		}
+	}
+	if ratio := prices[0] / prices[1]; ratio < 0.95 || ratio > 1.05 {
+		t.Errorf("pool prices %v differ by more than 5%%", prices)
+	}
+}
@@ -0,0 +1,98 @@
+package dex
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"Updater/exchanges/exchangetest"
+	"Updater/models"
+)
+
+func TestFetchSpotPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		"/": "eth_call_batch.json",
+	})
+	cfg, err := Load("testdata/pools.yaml", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pairs, err := fetchSpotPairs(context.Background(), cfg, "Uniswap")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The node answers out of order; the DAI pool reverts and the NEW pool is
+	// empty. USDC is token0 of the ETH pool, so its price is inverted.
+	exchangetest.Compare(t, pairs, []models.Pair{
+		{PairKey: "ETHUSDC_Uniswap_spot", Symbol: "ETHUSDC", Exchange: "Uniswap", Market: "spot", Price: 3500, BaseAsset: "ETH", QuoteAsset: "USDC", DisplayName: "ETH/USDC"},
+		{PairKey: "ETHUSDT_Uniswap_spot", Symbol: "ETHUSDT", Exchange: "Uniswap", Market: "spot", Price: 3501, BaseAsset: "ETH", QuoteAsset: "USDT", DisplayName: "ETH/USDT"},
+		{PairKey: "BTCUSDC_Uniswap_spot", Symbol: "BTCUSDC", Exchange: "Uniswap", Market: "spot", Price: 67000, BaseAsset: "BTC", QuoteAsset: "USDC", DisplayName: "BTC/USDC"},
+	})
+
+	// One batch with the Uniswap pools only
+	requests := srv.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("%d requests, want 1", len(requests))
+	}
+	body, _ := io.ReadAll(requests[0].Body)
+	want := `[` +
+		`{"jsonrpc":"2.0","id":1,"method":"eth_call","params":[{"to":"0x88e6A0c2dDD26FEEb64F039a2c41296FcB3f5640","data":"0x3850c7bd"},"latest"]},` +
+		`{"jsonrpc":"2.0","id":2,"method":"eth_call","params":[{"to":"0x0d4a11d5EEaaC28EC3F61d100daF4d40471f1852","data":"0x0902f1ac"},"latest"]},` +
+		`{"jsonrpc":"2.0","id":3,"method":"eth_call","params":[{"to":"0x99ac8cA7087fA4A2A1FB6357269965A2014ABc35","data":"0x3850c7bd"},"latest"]},` +
+		`{"jsonrpc":"2.0","id":4,"method":"eth_call","params":[{"to":"0xA478c2975Ab1Ea89e8196811F51A7B7Ade33eB11","data":"0x0902f1ac"},"latest"]},` +
+		`{"jsonrpc":"2.0","id":5,"method":"eth_call","params":[{"to":"0x1111111111111111111111111111111111111111","data":"0x0902f1ac"},"latest"]}` +
+		`]`
+	if string(body) != want {
+		t.Errorf("batch:\n%s\nwant:\n%s", body, want)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pools.yaml")
+	err := os.WriteFile(path, []byte(`
+pools:
+  - address: "0x88e6"
+    type: balancer
+    base: {asset: ETH, address: "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", decimals: 18}
+    quote: {asset: USDC, address: "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48", decimals: 6}
+  - address: "0x88e6A0c2dDD26FEEb64F039a2c41296FcB3f5640"
+    type: uniswap-v3
+    base: {asset: ETH, address: "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", decimals: 18}
+    quote: {asset: USDC, address: "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2", decimals: 40}
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Load(path, "")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{
+		"rpcUrl (or DEX_RPC_URL) is required",
+		`pools[0]: invalid address "0x88e6"`,
+		`pools[0]: unknown type "balancer"`,
+		"pools[1]: quote decimals must be between 0 and 36",
+		"pools[1]: base and quote are the same token",
+		"pools[1]: duplicate market ETHUSDC on DEX",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLoadExample(t *testing.T) {
+	cfg, err := Load("../../dex.example.yaml", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exchanges := cfg.Exchanges(); len(cfg.Pools) != 3 || strings.Join(exchanges, ",") != "Uniswap,UniswapV2" {
+		t.Errorf("unexpected pools %+v", cfg)
+	}
+}
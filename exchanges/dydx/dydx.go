@@ -0,0 +1,252 @@
+package dydx
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Updater/exchanges/httpclient"
+	"Updater/logging"
+	"Updater/metrics"
+	"Updater/models"
+)
+
+var (
+	logger       = logging.Exchange("dYdX")
+	parseSampler = logging.NewSampler()
+	httpClient   = httpclient.For("dYdX")
+)
+
+// baseURL is a variable so tests can point the connector at recorded responses
+var baseURL = "https://indexer.dydx.trade"
+
+// now is a variable so tests can fix the next funding time
+var now = time.Now
+
+const (
+	perpetualMarketsPath = "/v4/perpetualMarkets"
+
+	MAX_DECIMAL_18_8  = 9999999999.99999999   // Максимальне значення для DECIMAL(18,8)
+	MAX_DECIMAL_10_2  = 99999999.99           // Максимальне значення для DECIMAL(10,2)
+	MAX_DECIMAL_20_2  = 999999999999999999.99 // Максимальне значення для DECIMAL(20,2)
+	MAX_DECIMAL_24_8  = 9999999999999999.99999999
+	MAX_DECIMAL_14_10 = 9999.9999999999
+)
+
+// PerpetualMarketsResponse maps tickers such as BTC-USD to their markets.
+type PerpetualMarketsResponse struct {
+	Markets map[string]struct {
+		Ticker          string `json:"ticker"`
+		Status          string `json:"status"`
+		OraclePrice     string `json:"oraclePrice"`
+		PriceChange24H  string `json:"priceChange24H"`  // Absolute change
+		Volume24H       string `json:"volume24H"`       // In USD
+		NextFundingRate string `json:"nextFundingRate"` // Hourly rate
+		OpenInterest    string `json:"openInterest"`    // In the base asset
+	} `json:"markets"`
+}
+
+func fetchJSON(ctx context.Context, url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
+	defer wg.Done()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		errChan <- fmt.Errorf("dYdX error fetching %s: %w", url, err)
+		return
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		errChan <- fmt.Errorf("dYdX error fetching %s: %w", url, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errChan <- fmt.Errorf("dYdX non-OK status code %d from %s", resp.StatusCode, url)
+		return
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		errChan <- fmt.Errorf("dYdX error reading response from %s: %w", url, err)
+		return
+	}
+
+	if err := json.Unmarshal(body, target); err != nil {
+		errChan <- fmt.Errorf("dYdX error unmarshalling JSON from %s: %w", url, err)
+	}
+}
+
+func parseFloat(s string, field string) float64 {
+	val, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		parseSampler.Warn(logger, "parseFloat", "failed to parse float", "value", s, "field", field)
+		metrics.ParseWarnings.WithLabelValues("dYdX").Inc()
+		return 0
+	}
+	return val
+}
+
+func generateNumberedPlaceholders(rows int, fieldCount int) string {
+	placeholders := make([]string, rows)
+	counter := 1
+	for i := 0; i < rows; i++ {
+		inner := make([]string, fieldCount)
+		for j := 0; j < fieldCount; j++ {
+			inner[j] = "$" + strconv.Itoa(counter)
+			counter++
+		}
+		placeholders[i] = "(" + strings.Join(inner, ", ") + ")"
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+func sanitizeDecimal(value float64, maxValue float64, precision int) float64 {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0
+	}
+
+	if value > maxValue {
+		value = maxValue
+	} else if value < -maxValue {
+		value = -maxValue
+	}
+
+	format := "%." + strconv.Itoa(precision) + "f"
+	strVal := fmt.Sprintf(format, value)
+	formattedVal, _ := strconv.ParseFloat(strVal, 64)
+	return formattedVal
+}
+
+// fetchFuturesPairs downloads the perpetual markets from the indexer and
+// builds the pairs of the active ones, ordered by symbol. Markets are quoted
+// in USD and settled in USDC, so they are stored as USDC pairs. dYdX marks
+// positions at the oracle price, which is both the mark and the index price.
+func fetchFuturesPairs(ctx context.Context) ([]models.PairFutures, error) {
+	var wg sync.WaitGroup
+	errChan := make(chan error, 1)
+
+	var markets PerpetualMarketsResponse
+
+	wg.Add(1)
+	go fetchJSON(ctx, baseURL+perpetualMarketsPath, &markets, &wg, errChan)
+
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Funding is paid at the start of every hour
+	nextFunding := now().UTC().Truncate(time.Hour).Add(time.Hour).UnixMilli()
+
+	var pairs []models.PairFutures
+	for _, market := range markets.Markets {
+		if market.Status != "ACTIVE" {
+			continue
+		}
+		base, _, ok := strings.Cut(market.Ticker, "-")
+		if !ok {
+			parseSampler.Warn(logger, "ticker", "skipping market with an unexpected ticker", "ticker", market.Ticker)
+			continue
+		}
+
+		oracle := parseFloat(market.OraclePrice, "oraclePrice")
+		price := sanitizeDecimal(oracle, MAX_DECIMAL_18_8, 8)
+		if price <= 0 {
+			continue
+		}
+
+		var priceChangePercent float64
+		change := parseFloat(market.PriceChange24H, "priceChange24H")
+		if previous := oracle - change; previous > 0 {
+			priceChangePercent = sanitizeDecimal(change/previous*100, MAX_DECIMAL_10_2, 2)
+		}
+		quoteVolume := parseFloat(market.Volume24H, "volume24H")
+
+		pairs = append(pairs, models.PairFutures{
+			PairKey:               fmt.Sprintf("%sUSDC_dYdX_futures", base),
+			Symbol:                base + "USDC",
+			Exchange:              "dYdX",
+			Market:                "futures",
+			MarkPrice:             price,
+			IndexPrice:            price,
+			BaseAsset:             base,
+			QuoteAsset:            "USDC",
+			DisplayName:           fmt.Sprintf("%s/USDC", base),
+			FundingRatePercent:    sanitizeDecimal(models.FundingRateFromHourly(parseFloat(market.NextFundingRate, "nextFundingRate")), MAX_DECIMAL_14_10, 10),
+			NextFundingTimestamp:  int(nextFunding),
+			PriceChangePercent24h: priceChangePercent,
+			BaseVolume24h:         sanitizeDecimal(quoteVolume/oracle, MAX_DECIMAL_20_2, 2),
+			QuoteVolume24h:        sanitizeDecimal(quoteVolume, MAX_DECIMAL_20_2, 2),
+			OpenInterest:          sanitizeDecimal(parseFloat(market.OpenInterest, "openInterest"), MAX_DECIMAL_24_8, 8),
+			UpdatedAt:             time.Now().UTC(),
+		})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Symbol < pairs[j].Symbol })
+
+	return pairs, nil
+}
+
+func UpdateAllFuturesPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchFuturesPairs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(pairs) == 0 {
+		return 0, errors.New("dYdX No futures pairs to update")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("dYdX Failed to begin transaction: %w", err)
+	}
+
+	placeholderStr := generateNumberedPlaceholders(len(pairs), 16)
+	query := `
+    INSERT INTO pairsfutures (pairkey, symbol, exchange, market, markprice, indexprice, baseasset, quoteasset, displayname, fundingRatePercent, nextfundingtimestamp, pricechangepercent24h, basevolume24h, quotevolume24h, openinterest, updatedat)
+    VALUES ` + placeholderStr + `
+    ON CONFLICT (pairkey) DO UPDATE SET
+        markprice = EXCLUDED.markprice,
+        indexprice = EXCLUDED.indexprice,
+        fundingRatePercent = EXCLUDED.fundingRatePercent,
+        nextfundingtimestamp = EXCLUDED.nextfundingtimestamp,
+        pricechangepercent24h = EXCLUDED.pricechangepercent24h,
+        basevolume24h = EXCLUDED.basevolume24h,
+        quotevolume24h = EXCLUDED.quotevolume24h,
+        openinterest = EXCLUDED.openinterest,
+        updatedat = EXCLUDED.updatedat
+    `
+
+	args := make([]interface{}, 0, len(pairs)*16)
+	for _, pair := range pairs {
+		args = append(args, pair.PairKey, pair.Symbol, pair.Exchange, pair.Market, pair.MarkPrice, pair.IndexPrice, pair.BaseAsset,
+			pair.QuoteAsset, pair.DisplayName, pair.FundingRatePercent, pair.NextFundingTimestamp, pair.PriceChangePercent24h,
+			pair.BaseVolume24h, pair.QuoteVolume24h, pair.OpenInterest, pair.UpdatedAt)
+	}
+
+	_, err = tx.Exec(query, args...)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("dYdX Failed to execute statement: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("dYdX Failed to commit transaction: %w", err)
+	}
+
+	return len(pairs), nil
+}
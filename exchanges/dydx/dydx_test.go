@@ -0,0 +1,63 @@
+package dydx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"Updater/exchanges/exchangetest"
+	"Updater/models"
+)
+
+func TestFetchFuturesPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		perpetualMarketsPath: "perpetual_markets.json",
+	})
+	srv.SetURL(t, &baseURL)
+	now = func() time.Time { return time.UnixMilli(1718000000000) }
+	t.Cleanup(func() { now = time.Now })
+
+	pairs, err := fetchFuturesPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// LUNA-USD is settling and NEW-USD has no oracle price; the change is
+	// absolute and the volume in USD, the hourly funding is stored per 8 hours
+	exchangetest.Compare(t, pairs, []models.PairFutures{
+		{
+			PairKey:               "BTCUSDC_dYdX_futures",
+			Symbol:                "BTCUSDC",
+			Exchange:              "dYdX",
+			Market:                "futures",
+			MarkPrice:             67012,
+			IndexPrice:            67012,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDC",
+			DisplayName:           "BTC/USDC",
+			FundingRatePercent:    0.00005,
+			NextFundingTimestamp:  1718002800000,
+			PriceChangePercent24h: 1.53,
+			BaseVolume24h:         10000,
+			QuoteVolume24h:        670120000,
+			OpenInterest:          812.3456,
+		},
+		{
+			PairKey:               "ETHUSDC_dYdX_futures",
+			Symbol:                "ETHUSDC",
+			Exchange:              "dYdX",
+			Market:                "futures",
+			MarkPrice:             3500,
+			IndexPrice:            3500,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "USDC",
+			DisplayName:           "ETH/USDC",
+			FundingRatePercent:    -0.00002,
+			NextFundingTimestamp:  1718002800000,
+			PriceChangePercent24h: -1.41,
+			BaseVolume24h:         100000,
+			QuoteVolume24h:        350000000,
+			OpenInterest:          15000.25,
+		},
+	})
+}
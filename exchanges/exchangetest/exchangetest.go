@@ -0,0 +1,145 @@
+// Package exchangetest serves recorded exchange responses to the connector
+// tests and compares the records the connectors build from them.
+package exchangetest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Server answers requests with fixture files from the testdata directory of
+// the package under test.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+// Serve starts a server for the given routes. A route is a path, optionally
+// followed by its query string, and maps to a file in testdata. Requests
+// match the route with their exact query string first and then the bare
+// path, so signed requests with timestamps still find their fixture.
+// A route mapped to an empty file name answers 404, like an endpoint that is
+// down. Requests to any other route fail the test. The server is closed when
+// the test ends.
+func Serve(t *testing.T, routes map[string]string) *Server {
+	t.Helper()
+
+	for route, file := range routes {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join("testdata", file)); err != nil {
+			t.Fatalf("fixture for %s: %v", route, err)
+		}
+	}
+
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Keep the body so tests can check what POST requests sent
+		body, _ := io.ReadAll(r.Body)
+		recorded := r.Clone(r.Context())
+		recorded.Body = io.NopCloser(bytes.NewReader(body))
+		s.mu.Lock()
+		s.requests = append(s.requests, recorded)
+		s.mu.Unlock()
+
+		file, ok := routes[r.URL.Path+"?"+r.URL.RawQuery]
+		if !ok {
+			file, ok = routes[r.URL.Path]
+		}
+		if !ok {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.RequestURI())
+			http.NotFound(w, r)
+			return
+		}
+		if file == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		data, err := os.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Errorf("reading fixture %s: %v", file, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Requests returns the requests received so far, in order, with their bodies.
+func (s *Server) Requests() []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*http.Request(nil), s.requests...)
+}
+
+// SetURL points a connector base URL variable at the server for the
+// duration of the test.
+func (s *Server) SetURL(t *testing.T, target *string) {
+	t.Helper()
+	previous := *target
+	*target = s.URL
+	t.Cleanup(func() { *target = previous })
+}
+
+// Compare fails the test unless got and want hold the same records in the
+// same order. The UpdatedAt field of every record in got must be set; it and
+// CreatedAt are then ignored, since connectors stamp them with the current time.
+func Compare[T any](t *testing.T, got, want []T) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Errorf("got %d records, want %d", len(got), len(want))
+	}
+	for i := 0; i < len(got) && i < len(want); i++ {
+		g := clearTimestamps(t, i, got[i])
+		if !reflect.DeepEqual(g, want[i]) {
+			t.Errorf("record %d:\n got  %+v\n want %+v", i, g, want[i])
+		}
+	}
+	for i := len(want); i < len(got); i++ {
+		t.Errorf("unexpected record %d: %+v", i, got[i])
+	}
+	for i := len(got); i < len(want); i++ {
+		t.Errorf("missing record %d: %+v", i, want[i])
+	}
+}
+
+func clearTimestamps[T any](t *testing.T, i int, record T) T {
+	t.Helper()
+
+	v := reflect.ValueOf(&record).Elem()
+	if v.Kind() != reflect.Struct {
+		return record
+	}
+	for _, name := range []string{"UpdatedAt", "CreatedAt"} {
+		field := v.FieldByName(name)
+		if !field.IsValid() {
+			continue
+		}
+		stamp, ok := field.Interface().(time.Time)
+		if !ok {
+			panic(fmt.Sprintf("%s of %T is not a time.Time", name, record))
+		}
+		if name == "UpdatedAt" && stamp.IsZero() {
+			t.Errorf("record %d: UpdatedAt is not set", i)
+		}
+		field.Set(reflect.ValueOf(time.Time{}))
+	}
+	return record
+}
@@ -1,12 +1,12 @@
 package gate
 
 import (
-	"crypto/tls"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"math"
 	"net/http"
 	"strconv"
@@ -14,13 +14,28 @@ import (
 	"sync"
 	"time"
 
+	"Updater/exchanges/httpclient"
+	"Updater/logging"
+	"Updater/metrics"
 	"Updater/models"
 )
 
+var (
+	logger       = logging.Exchange("Gate")
+	parseSampler = logging.NewSampler()
+	httpClient   = httpclient.For("Gate")
+)
+
+// baseURL is a variable so tests can point the connector at recorded responses
+var baseURL = "https://api.gateio.ws/api/v4"
+
 const (
-	baseURL          = "https://api.gateio.ws/api/v4"
-	currencyPairsURL = baseURL + "/spot/currency_pairs"
-	tickerPricesURL  = baseURL + "/spot/tickers"
+	currencyPairsPath = "/spot/currency_pairs"
+	tickerPricesPath  = "/spot/tickers"
+	currenciesPath    = "/spot/currencies"
+
+	futuresContractsPath = "/futures/usdt/contracts"
+	futuresTickersPath   = "/futures/usdt/tickers"
 )
 
 type CurrencyPairsResponse struct {
@@ -38,15 +53,41 @@ type TickerResponse struct {
 	QuoteVolume24h       string `json:"quote_volume"`
 }
 
-func fetchJSON(url string, target interface{}, wg *sync.WaitGroup, errChan chan error) {
+type CurrencyResponse struct {
+	Currency string `json:"currency"`
+	Delisted bool   `json:"delisted"`
+	Chains   []struct {
+		Name             string `json:"name"` // e.g. ETH, TRX, BSC
+		DepositDisabled  bool   `json:"deposit_disabled"`
+		WithdrawDisabled bool   `json:"withdraw_disabled"`
+	} `json:"chains"`
+}
+
+type FuturesContractResponse struct {
+	Name             string  `json:"name"`
+	InDelisting      bool    `json:"in_delisting"`
+	FundingNextApply float64 `json:"funding_next_apply"` // unix seconds
+}
+
+type FuturesTickerResponse struct {
+	Contract             string `json:"contract"`
+	LastPrice            string `json:"last"`
+	MarkPrice            string `json:"mark_price"`
+	IndexPrice           string `json:"index_price"`
+	FundingRate          string `json:"funding_rate"`
+	PriceChangePercent24 string `json:"change_percentage"`
+	BaseVolume24h        string `json:"volume_24h_base"`
+	QuoteVolume24h       string `json:"volume_24h_quote"`
+}
+
+func fetchJSON(ctx context.Context, url string, target interface{}, wg *sync.WaitGroup, errChan chan error) {
 	defer wg.Done()
-	// Create a custom HTTP client with TLS certificate verification disabled
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		errChan <- fmt.Errorf("Gate.io error fetching %s: %w", url, err)
+		return
 	}
-	resp, err := client.Get(url)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		errChan <- fmt.Errorf("Gate.io error fetching %s: %w", url, err)
 		return
@@ -55,7 +96,7 @@ func fetchJSON(url string, target interface{}, wg *sync.WaitGroup, errChan chan
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		log.Printf("Gate.io non-OK status code %d: %s", resp.StatusCode, string(body))
+		logger.Debug("non-OK response", "url", url, "status", resp.StatusCode, "body", string(body))
 		errChan <- fmt.Errorf("Gate.io non-OK status code %d from %s", resp.StatusCode, url)
 		return
 	}
@@ -68,7 +109,7 @@ func fetchJSON(url string, target interface{}, wg *sync.WaitGroup, errChan chan
 
 	// Log the response body if unmarshalling fails
 	if err := json.Unmarshal(body, target); err != nil {
-		log.Printf("Gate.io response body: %s", string(body))
+		logger.Debug("invalid JSON response", "url", url, "body", string(body))
 		errChan <- fmt.Errorf("Gate.io error unmarshalling JSON: %w", err)
 		return
 	}
@@ -77,7 +118,8 @@ func fetchJSON(url string, target interface{}, wg *sync.WaitGroup, errChan chan
 func parseFloat(s string) float64 {
 	val, err := strconv.ParseFloat(s, 64)
 	if err != nil {
-		log.Printf("Gate.io Warning: failed to parse float from %s", s)
+		parseSampler.Warn(logger, "parseFloat", "failed to parse float", "value", s)
+		metrics.ParseWarnings.WithLabelValues("Gate").Inc()
 		return 0
 	}
 	return val
@@ -93,11 +135,11 @@ func roundToPrecision(value float64, precision int) float64 {
 func validateFloat64(value float64, precision int, scale int) float64 {
 	maxValue := math.Pow10(precision - scale)
 	if value > maxValue {
-		log.Printf("Gate.io Warning: value %f exceeds max %f, setting to %f", value, maxValue, maxValue)
+		parseSampler.Warn(logger, "clamp", "value exceeds column range, clamping", "value", value, "max", maxValue)
 		return maxValue
 	}
 	if value < -maxValue {
-		log.Printf("Gate.io Warning: value %f below min %f, setting to %f", value, -maxValue, -maxValue)
+		parseSampler.Warn(logger, "clamp", "value exceeds column range, clamping", "value", value, "min", -maxValue)
 		return -maxValue
 	}
 	return roundToPrecision(value, scale)
@@ -117,7 +159,8 @@ func generateNumberedPlaceholders(rows int, fieldCount int) string {
 	return strings.Join(placeholders, ", ")
 }
 
-func UpdateAllSpotPairs(db *sql.DB) bool {
+// fetchSpotPairs downloads the tradable currency pairs and builds their pairs.
+func fetchSpotPairs(ctx context.Context) ([]models.Pair, error) {
 	var wg sync.WaitGroup
 	errChan := make(chan error, 2)
 
@@ -125,16 +168,15 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 	var tickers []TickerResponse
 
 	wg.Add(2)
-	go fetchJSON(currencyPairsURL, &currencyPairs, &wg, errChan)
-	go fetchJSON(tickerPricesURL, &tickers, &wg, errChan)
+	go fetchJSON(ctx, baseURL+currencyPairsPath, &currencyPairs, &wg, errChan)
+	go fetchJSON(ctx, baseURL+tickerPricesPath, &tickers, &wg, errChan)
 
 	wg.Wait()
 	close(errChan)
 
 	for err := range errChan {
 		if err != nil {
-			log.Printf("Error: %v", err)
-			return false
+			return nil, err
 		}
 	}
 
@@ -163,15 +205,23 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 			PriceChangePercent24h: validateFloat64(parseFloat(ticker.PriceChangePercent24), 10, 2), // 2 decimal places
 			BaseVolume24h:         validateFloat64(parseFloat(ticker.BaseVolume24h), 20, 2),        // 2 decimal places
 			QuoteVolume24h:        validateFloat64(parseFloat(ticker.QuoteVolume24h), 20, 2),       // 2 decimal places
-			UpdatedAt:             time.Now(),
+			UpdatedAt:             time.Now().UTC(),
 		}
 		pairs = append(pairs, pair)
 	}
 
+	return pairs, nil
+}
+
+func UpdateAllSpotPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchSpotPairs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
 	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("Gate.io Failed to begin transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Gate.io Failed to begin transaction: %w", err)
 	}
 
 	placeholderStr := generateNumberedPlaceholders(len(pairs), 12)
@@ -187,8 +237,7 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
     `
 	stmt, err := tx.Prepare(query)
 	if err != nil {
-		log.Printf("Gate.io Failed to prepare statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Gate.io Failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
@@ -201,14 +250,214 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 	_, err = stmt.Exec(args...)
 	if err != nil {
 		tx.Rollback()
-		log.Printf("Gate.io Failed to execute statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Gate.io Failed to execute statement: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("Gate.io Failed to commit transaction: %w", err)
+	}
+
+	return len(pairs), nil
+}
+
+// fetchNetworks downloads the deposit and withdrawal status of every chain of the listed currencies.
+func fetchNetworks(ctx context.Context) ([]models.Network, error) {
+	var wg sync.WaitGroup
+	errChan := make(chan error, 1)
+
+	var currencies []CurrencyResponse
+
+	wg.Add(1)
+	go fetchJSON(ctx, baseURL+currenciesPath, &currencies, &wg, errChan)
+
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var networks []models.Network
+	for _, currency := range currencies {
+		if currency.Delisted {
+			continue
+		}
+		for _, chain := range currency.Chains {
+			networks = append(networks, models.Network{
+				CoinKey:        fmt.Sprintf("%s_Gate_%s", currency.Currency, chain.Name),
+				Coin:           currency.Currency,
+				Exchange:       "Gate",
+				Network:        chain.Name,
+				NetworkName:    chain.Name,
+				DepositEnable:  !chain.DepositDisabled,
+				WithdrawEnable: !chain.WithdrawDisabled,
+				UpdatedAt:      time.Now().UTC(),
+			})
+		}
+	}
+
+	return networks, nil
+}
+
+func UpdateAllNetworks(ctx context.Context, db *sql.DB) (int, error) {
+	networks, err := fetchNetworks(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(networks) == 0 {
+		logger.Debug("no network data to update")
+		return 0, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("Gate.io Failed to begin transaction: %w", err)
+	}
+
+	placeholderStr := generateNumberedPlaceholders(len(networks), 8)
+	query := `
+    INSERT INTO nets (coinKey, coin, exchange, network, networkName, depositEnable, withdrawEnable, updatedAt)
+    VALUES ` + placeholderStr + `
+    ON CONFLICT (coinKey) DO UPDATE SET
+        networkName = EXCLUDED.networkName,
+        depositEnable = EXCLUDED.depositEnable,
+        withdrawEnable = EXCLUDED.withdrawEnable,
+        updatedAt = EXCLUDED.updatedAt
+    `
+
+	args := make([]interface{}, 0, len(networks)*8)
+	for _, n := range networks {
+		args = append(args, n.CoinKey, n.Coin, n.Exchange, n.Network, n.NetworkName, n.DepositEnable, n.WithdrawEnable, n.UpdatedAt)
+	}
+
+	_, err = tx.Exec(query, args...)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("Gate.io Failed to execute statement: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("Gate.io Failed to commit transaction: %w", err)
+	}
+
+	return len(networks), nil
+}
+
+// fetchFuturesPairs downloads the USDT perpetual contracts and builds their pairs.
+func fetchFuturesPairs(ctx context.Context) ([]models.PairFutures, error) {
+	var wg sync.WaitGroup
+	errChan := make(chan error, 2)
+
+	var contracts []FuturesContractResponse
+	var tickers []FuturesTickerResponse
+
+	wg.Add(2)
+	go fetchJSON(ctx, baseURL+futuresContractsPath, &contracts, &wg, errChan)
+	go fetchJSON(ctx, baseURL+futuresTickersPath, &tickers, &wg, errChan)
+
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	contractMap := make(map[string]FuturesContractResponse)
+	for _, c := range contracts {
+		contractMap[c.Name] = c
+	}
+
+	var pairs []models.PairFutures
+	for _, ticker := range tickers {
+		contract, exists := contractMap[ticker.Contract]
+		if !exists || contract.InDelisting || ticker.FundingRate == "" {
+			continue
+		}
+		base, quote, ok := strings.Cut(ticker.Contract, "_")
+		if !ok {
+			continue
+		}
+
+		symbol := base + quote
+		pair := models.PairFutures{
+			PairKey:               fmt.Sprintf("%s_Gate_futures", symbol),
+			Symbol:                symbol,
+			Exchange:              "Gate",
+			Market:                "futures",
+			MarkPrice:             validateFloat64(parseFloat(ticker.MarkPrice), 18, 8),
+			IndexPrice:            validateFloat64(parseFloat(ticker.IndexPrice), 18, 8),
+			BaseAsset:             base,
+			QuoteAsset:            quote,
+			DisplayName:           fmt.Sprintf("%s/%s", base, quote),
+			FundingRatePercent:    parseFloat(ticker.FundingRate),
+			NextFundingTimestamp:  int(contract.FundingNextApply) * 1000,
+			PriceChangePercent24h: validateFloat64(parseFloat(ticker.PriceChangePercent24), 10, 2),
+			BaseVolume24h:         validateFloat64(parseFloat(ticker.BaseVolume24h), 20, 2),
+			QuoteVolume24h:        validateFloat64(parseFloat(ticker.QuoteVolume24h), 20, 2),
+			UpdatedAt:             time.Now().UTC(),
+			CreatedAt:             time.Now(),
+		}
+		pairs = append(pairs, pair)
+	}
+
+	if len(pairs) == 0 {
+		return nil, errors.New("Gate.io No futures pairs to update")
+	}
+
+	return pairs, nil
+}
+
+func UpdateAllFuturesPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchFuturesPairs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("Gate.io Failed to begin transaction: %w", err)
+	}
+
+	placeholderStr := generateNumberedPlaceholders(len(pairs), 16)
+	query := `
+    INSERT INTO pairsfutures (pairkey, symbol, exchange, market, markprice, indexprice, baseasset, quoteasset, displayname, fundingRatePercent, nextfundingtimestamp, pricechangepercent24h, basevolume24h, quotevolume24h, updatedat, createdat)
+    VALUES ` + placeholderStr + `
+    ON CONFLICT (pairkey) DO UPDATE SET
+        markprice = EXCLUDED.markprice,
+        indexprice = EXCLUDED.indexprice,
+        fundingRatePercent = EXCLUDED.fundingRatePercent,
+        nextfundingtimestamp = EXCLUDED.nextfundingtimestamp,
+        pricechangepercent24h = EXCLUDED.pricechangepercent24h,
+        basevolume24h = EXCLUDED.basevolume24h,
+        quotevolume24h = EXCLUDED.quotevolume24h,
+        updatedat = EXCLUDED.updatedat
+    `
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return 0, fmt.Errorf("Gate.io Failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	args := make([]interface{}, 0, len(pairs)*16)
+	for _, pair := range pairs {
+		args = append(args, pair.PairKey, pair.Symbol, pair.Exchange, pair.Market, pair.MarkPrice, pair.IndexPrice, pair.BaseAsset,
+			pair.QuoteAsset, pair.DisplayName, pair.FundingRatePercent, pair.NextFundingTimestamp, pair.PriceChangePercent24h,
+			pair.BaseVolume24h, pair.QuoteVolume24h, pair.UpdatedAt, pair.CreatedAt)
+	}
+
+	_, err = stmt.Exec(args...)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("Gate.io Failed to execute statement: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("Gate.io Failed to commit transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Gate.io Failed to commit transaction: %w", err)
 	}
 
-	return true
+	return len(pairs), nil
 }
@@ -0,0 +1,143 @@
+package gate
+
+import (
+	"context"
+	"testing"
+
+	"Updater/exchanges/exchangetest"
+	"Updater/models"
+)
+
+func TestFetchSpotPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		currencyPairsPath: "currency_pairs.json",
+		tickerPricesPath:  "tickers.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	pairs, err := fetchSpotPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// OLD_USDT is untradable; PEPE's base volume is clamped to the column
+	// range and NEW_USDT is listed before its ticker exists
+	exchangetest.Compare(t, pairs, []models.Pair{
+		{
+			PairKey:               "BTCUSDT_Gate_spot",
+			Symbol:                "BTCUSDT",
+			Exchange:              "Gate",
+			Market:                "spot",
+			Price:                 67012.3,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDT",
+			DisplayName:           "BTC/USDT",
+			PriceChangePercent24h: -1.24,
+			BaseVolume24h:         5123.46,
+			QuoteVolume24h:        343412345.68,
+		},
+		{
+			PairKey:               "ETHBTC_Gate_spot",
+			Symbol:                "ETHBTC",
+			Exchange:              "Gate",
+			Market:                "spot",
+			Price:                 0.051234,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "BTC",
+			DisplayName:           "ETH/BTC",
+			PriceChangePercent24h: 0.87,
+			BaseVolume24h:         812.5,
+			QuoteVolume24h:        41.62,
+		},
+		{
+			PairKey:               "PEPEUSDT_Gate_spot",
+			Symbol:                "PEPEUSDT",
+			Exchange:              "Gate",
+			Market:                "spot",
+			Price:                 0.00001235,
+			BaseAsset:             "PEPE",
+			QuoteAsset:            "USDT",
+			DisplayName:           "PEPE/USDT",
+			PriceChangePercent24h: 12.5,
+			BaseVolume24h:         1e18,
+			QuoteVolume24h:        1523456.7,
+		},
+		{
+			PairKey:     "NEWUSDT_Gate_spot",
+			Symbol:      "NEWUSDT",
+			Exchange:    "Gate",
+			Market:      "spot",
+			BaseAsset:   "NEW",
+			QuoteAsset:  "USDT",
+			DisplayName: "NEW/USDT",
+		},
+	})
+}
+
+func TestFetchNetworks(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		currenciesPath: "currencies.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	networks, err := fetchNetworks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// OLD is delisted; delayed BTC withdrawals still count as enabled
+	exchangetest.Compare(t, networks, []models.Network{
+		{CoinKey: "USDT_Gate_ETH", Coin: "USDT", Exchange: "Gate", Network: "ETH", NetworkName: "ETH", DepositEnable: true},
+		{CoinKey: "USDT_Gate_TRX", Coin: "USDT", Exchange: "Gate", Network: "TRX", NetworkName: "TRX", DepositEnable: true, WithdrawEnable: true},
+		{CoinKey: "BTC_Gate_BTC", Coin: "BTC", Exchange: "Gate", Network: "BTC", NetworkName: "BTC", WithdrawEnable: true},
+	})
+}
+
+func TestFetchFuturesPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		futuresContractsPath: "futures_contracts.json",
+		futuresTickersPath:   "futures_tickers.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	pairs, err := fetchFuturesPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// OLD_USDT is being delisted and NEW_USDT is not in the contracts
+	exchangetest.Compare(t, pairs, []models.PairFutures{
+		{
+			PairKey:               "BTCUSDT_Gate_futures",
+			Symbol:                "BTCUSDT",
+			Exchange:              "Gate",
+			Market:                "futures",
+			MarkPrice:             67051.2,
+			IndexPrice:            67040.8,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDT",
+			DisplayName:           "BTC/USDT",
+			FundingRatePercent:    0.0001,
+			NextFundingTimestamp:  1718006400000,
+			PriceChangePercent24h: 1.23,
+			BaseVolume24h:         12345.68,
+			QuoteVolume24h:        827812345.68,
+		},
+		{
+			PairKey:               "ETHUSDT_Gate_futures",
+			Symbol:                "ETHUSDT",
+			Exchange:              "Gate",
+			Market:                "futures",
+			MarkPrice:             3510.15,
+			IndexPrice:            3509.92,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "USDT",
+			DisplayName:           "ETH/USDT",
+			FundingRatePercent:    -0.000031,
+			NextFundingTimestamp:  1718006400000,
+			PriceChangePercent24h: -0.57,
+			BaseVolume24h:         234567.8,
+			QuoteVolume24h:        823412345.1,
+		},
+	})
+}
@@ -0,0 +1,250 @@
+package generic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"Updater/exchanges/httpclient"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KeyPath is the symbol path of items listed as an object keyed by symbol.
+const KeyPath = "$key"
+
+// Formats of the 24h change.
+const (
+	ChangePercent  = "percent"
+	ChangeFraction = "fraction"
+)
+
+// Definition describes a spot exchange whose public endpoints list every
+// market in one response.
+type Definition struct {
+	// Name is the exchange name stored in pairs.
+	Name string `yaml:"name"`
+	// RateLimit replaces the default request budget of the exchange.
+	RateLimit *RateLimit `yaml:"rateLimit"`
+	// Tickers lists the prices, one item per market.
+	Tickers Endpoint `yaml:"tickers"`
+	// Markets optionally lists the markets, joined to the tickers by symbol.
+	// Tickers without a market are skipped.
+	Markets *Endpoint `yaml:"markets"`
+	// Symbols turns the exchange symbols into assets.
+	Symbols SymbolRules `yaml:"symbols"`
+}
+
+// RateLimit is the request budget of an exchange.
+type RateLimit struct {
+	RequestsPerSecond float64 `yaml:"requestsPerSecond"`
+	Burst             int     `yaml:"burst"`
+}
+
+// Config returns the httpclient settings of the budget.
+func (r RateLimit) Config() httpclient.Config {
+	return httpclient.Config{RequestsPerSecond: r.RequestsPerSecond, Burst: r.Burst}
+}
+
+// Endpoint is one request and where its fields are in the response. Paths
+// are dot separated object keys and array indexes, such as data.tickers or 7.
+// Fields left empty are not read from the endpoint.
+type Endpoint struct {
+	URL string `yaml:"url"`
+	// Items is the path to the list of items, empty for the whole response.
+	// The list is an array or an object keyed by symbol.
+	Items string `yaml:"items"`
+
+	// Symbol is the path of the symbol in an item, or $key for the key of the item.
+	Symbol string `yaml:"symbol"`
+	Base   string `yaml:"base"`
+	Quote  string `yaml:"quote"`
+	// Status keeps only the items whose field has one of the values.
+	Status *StatusFilter `yaml:"status"`
+
+	// Price and the fields below are only read from tickers.
+	Price       string `yaml:"price"`
+	BaseVolume  string `yaml:"baseVolume"`
+	QuoteVolume string `yaml:"quoteVolume"`
+	// Change is the 24h change, in ChangeFormat.
+	Change string `yaml:"change"`
+	// ChangeFormat is percent (the default) or fraction.
+	ChangeFormat string `yaml:"changeFormat"`
+	// Open is the price 24h ago, for exchanges that do not report the change.
+	Open string `yaml:"open"`
+}
+
+// StatusFilter keeps the items whose field is one of Values. Booleans and
+// numbers are compared in their JSON form, such as true.
+type StatusFilter struct {
+	Field  string   `yaml:"field"`
+	Values []string `yaml:"values"`
+}
+
+// SymbolRules derive the base and quote assets when no endpoint reports
+// them, and rename assets. Assets are always upper case.
+type SymbolRules struct {
+	// TrimPrefix is removed from the symbols first, such as t in tBTCUSD.
+	TrimPrefix string `yaml:"trimPrefix"`
+	// Separator splits a symbol into base and quote, such as _ in BTC_USDT.
+	Separator string `yaml:"separator"`
+	// Quotes are the quote assets of symbols without a separator; the longest
+	// one the symbol ends with is used.
+	Quotes []string `yaml:"quotes"`
+	// Assets renames the exchange's assets, such as XBT: BTC.
+	Assets map[string]string `yaml:"assets"`
+}
+
+// Load reads and validates one definition.
+func Load(path string) (*Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading connector definition: %w", err)
+	}
+
+	var def Definition
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&def); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	if err := def.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &def, nil
+}
+
+// LoadDir reads every .yaml and .yml definition in dir, ordered by file
+// name, and reports the problems of all of them.
+func LoadDir(dir string) ([]*Definition, error) {
+	var paths []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+
+	var defs []*Definition
+	var errs []error
+	names := make(map[string]string)
+	for _, path := range paths {
+		def, err := Load(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if other, ok := names[def.Name]; ok {
+			errs = append(errs, fmt.Errorf("%s: exchange %s is already defined in %s", path, def.Name, other))
+			continue
+		}
+		names[def.Name] = path
+		defs = append(defs, def)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return defs, nil
+}
+
+// Validate returns every problem of the definition, not only the first one.
+func (d *Definition) Validate() error {
+	var errs []error
+
+	if d.Name == "" {
+		errs = append(errs, errors.New("name is required"))
+	}
+	if d.RateLimit != nil && (d.RateLimit.RequestsPerSecond <= 0 || d.RateLimit.Burst < 1) {
+		errs = append(errs, errors.New("rateLimit needs a positive requestsPerSecond and burst"))
+	}
+
+	errs = append(errs, d.Tickers.validate("tickers")...)
+	if d.Tickers.Price == "" {
+		errs = append(errs, errors.New("tickers: price is required"))
+	}
+	switch d.Tickers.ChangeFormat {
+	case "", ChangePercent, ChangeFraction:
+	default:
+		errs = append(errs, fmt.Errorf("tickers: unknown changeFormat %q, expected %s or %s", d.Tickers.ChangeFormat, ChangePercent, ChangeFraction))
+	}
+	if d.Tickers.Change != "" && d.Tickers.Open != "" {
+		errs = append(errs, errors.New("tickers: set either change or open"))
+	}
+
+	if m := d.Markets; m != nil {
+		errs = append(errs, m.validate("markets")...)
+		if m.Price != "" || m.BaseVolume != "" || m.QuoteVolume != "" || m.Change != "" || m.ChangeFormat != "" || m.Open != "" {
+			errs = append(errs, errors.New("markets: prices, volumes and changes are only read from tickers"))
+		}
+	}
+
+	hasBase := d.Tickers.Base != "" || (d.Markets != nil && d.Markets.Base != "")
+	hasQuote := d.Tickers.Quote != "" || (d.Markets != nil && d.Markets.Quote != "")
+	if hasBase != hasQuote {
+		errs = append(errs, errors.New("base and quote must both be read from the endpoints or both from the symbol"))
+	}
+	if !hasBase && d.Symbols.Separator == "" && len(d.Symbols.Quotes) == 0 {
+		errs = append(errs, errors.New("symbols: separator or quotes are required when no endpoint reports base and quote"))
+	}
+	for from, to := range d.Symbols.Assets {
+		if from == "" || to == "" {
+			errs = append(errs, errors.New("symbols: assets renames need both names"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (e Endpoint) validate(name string) []error {
+	var errs []error
+
+	if u, err := url.Parse(e.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("%s: url must be an absolute http or https URL, got %q", name, e.URL))
+	}
+	if e.Symbol == "" {
+		errs = append(errs, fmt.Errorf("%s: symbol is required", name))
+	}
+	if e.Status != nil && (e.Status.Field == "" || len(e.Status.Values) == 0) {
+		errs = append(errs, fmt.Errorf("%s: status needs a field and values", name))
+	}
+
+	paths := map[string]string{
+		"items": e.Items, "base": e.Base, "quote": e.Quote, "price": e.Price,
+		"baseVolume": e.BaseVolume, "quoteVolume": e.QuoteVolume, "change": e.Change, "open": e.Open,
+	}
+	if e.Symbol != KeyPath {
+		paths["symbol"] = e.Symbol
+	}
+	if e.Status != nil {
+		paths["status.field"] = e.Status.Field
+	}
+	for _, field := range sortedKeys(paths) {
+		path := paths[field]
+		if path == "" {
+			continue
+		}
+		for _, part := range strings.Split(path, ".") {
+			if part == "" || part == KeyPath {
+				errs = append(errs, fmt.Errorf("%s: invalid %s path %q", name, field, path))
+				break
+			}
+		}
+	}
+	return errs
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,421 @@
+// Package generic collects the spot markets of exchanges described by a YAML
+// definition instead of a connector of their own: the endpoints, where the
+// fields are in their responses and how symbols map to assets.
+package generic
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Updater/exchanges/httpclient"
+	"Updater/logging"
+	"Updater/metrics"
+	"Updater/models"
+)
+
+var parseSampler = logging.NewSampler()
+
+const (
+	MAX_DECIMAL_18_8 = 9999999999.99999999   // Максимальне значення для DECIMAL(18,8)
+	MAX_DECIMAL_10_2 = 99999999.99           // Максимальне значення для DECIMAL(10,2)
+	MAX_DECIMAL_20_2 = 999999999999999999.99 // Максимальне значення для DECIMAL(20,2)
+)
+
+// item is one entry of an endpoint's list and its key when the list is an object.
+type item struct {
+	key   string
+	value any
+}
+
+func fetchJSON(ctx context.Context, exchange, url string, target *any, wg *sync.WaitGroup, errChan chan<- error) {
+	defer wg.Done()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		errChan <- fmt.Errorf("%s error fetching %s: %w", exchange, url, err)
+		return
+	}
+	resp, err := httpclient.For(exchange).Do(req)
+	if err != nil {
+		errChan <- fmt.Errorf("%s error fetching %s: %w", exchange, url, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errChan <- fmt.Errorf("%s non-OK status code %d from %s", exchange, resp.StatusCode, url)
+		return
+	}
+
+	// Numbers are kept as written so large volumes do not lose digits before parsing
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	if err := dec.Decode(target); err != nil {
+		errChan <- fmt.Errorf("%s error unmarshalling JSON from %s: %w", exchange, url, err)
+	}
+}
+
+// lookup follows a dot separated path of object keys and array indexes.
+func lookup(v any, path string) (any, bool) {
+	if path == "" {
+		return v, true
+	}
+	for _, part := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			next, ok := node[part]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// items returns the list at the endpoint's items path, ordered by key when
+// it is an object.
+func items(e Endpoint, response any) ([]item, error) {
+	list, ok := lookup(response, e.Items)
+	if !ok {
+		return nil, fmt.Errorf("no items at %q", e.Items)
+	}
+	switch list := list.(type) {
+	case []any:
+		out := make([]item, len(list))
+		for i, v := range list {
+			out[i] = item{value: v}
+		}
+		return out, nil
+	case map[string]any:
+		keys := make([]string, 0, len(list))
+		for key := range list {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		out := make([]item, len(keys))
+		for i, key := range keys {
+			out[i] = item{key: key, value: list[key]}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("items at %q are neither an array nor an object", e.Items)
+}
+
+// text returns the value at path as a string, numbers and booleans as written.
+func text(it item, path string) (string, bool) {
+	if path == KeyPath {
+		return it.key, true
+	}
+	v, ok := lookup(it.value, path)
+	if !ok {
+		return "", false
+	}
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+// number parses the value at path, logging a sampled warning when it is
+// missing or not a number.
+func number(def *Definition, it item, path, field string) float64 {
+	s, ok := text(it, path)
+	val, err := strconv.ParseFloat(s, 64)
+	if !ok || err != nil {
+		parseSampler.Warn(logging.Exchange(def.Name), field, "failed to parse float", "value", s, "field", field)
+		metrics.ParseWarnings.WithLabelValues(def.Name).Inc()
+		return 0
+	}
+	return val
+}
+
+// matches reports whether the item passes the status filter of e.
+func matches(e *Endpoint, it item) bool {
+	if e == nil || e.Status == nil {
+		return true
+	}
+	value, _ := text(it, e.Status.Field)
+	for _, allowed := range e.Status.Values {
+		if value == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// asset upper cases an asset and applies the renames of the definition.
+func (d *Definition) asset(name string) string {
+	name = strings.ToUpper(name)
+	for from, to := range d.Symbols.Assets {
+		if strings.EqualFold(from, name) {
+			return strings.ToUpper(to)
+		}
+	}
+	return name
+}
+
+// split derives base and quote from an exchange symbol.
+func (d *Definition) split(symbol string) (string, string, bool) {
+	symbol = strings.TrimPrefix(symbol, d.Symbols.TrimPrefix)
+	if d.Symbols.Separator != "" {
+		return strings.Cut(symbol, d.Symbols.Separator)
+	}
+	best := ""
+	for _, quote := range d.Symbols.Quotes {
+		if len(quote) > len(best) && len(symbol) > len(quote) && strings.HasSuffix(strings.ToUpper(symbol), strings.ToUpper(quote)) {
+			best = quote
+		}
+	}
+	if best == "" {
+		return "", "", false
+	}
+	return symbol[:len(symbol)-len(best)], symbol[len(symbol)-len(best):], true
+}
+
+// assets returns the base and quote of a ticker, read from the ticker, then
+// its market, then its symbol.
+func (d *Definition) assets(symbol string, ticker item, market *item) (string, string, bool) {
+	base, _ := text(ticker, d.Tickers.Base)
+	quote, _ := text(ticker, d.Tickers.Quote)
+	if market != nil {
+		if base == "" && d.Markets.Base != "" {
+			base, _ = text(*market, d.Markets.Base)
+		}
+		if quote == "" && d.Markets.Quote != "" {
+			quote, _ = text(*market, d.Markets.Quote)
+		}
+	}
+	if base == "" && quote == "" {
+		var ok bool
+		if base, quote, ok = d.split(symbol); !ok {
+			return "", "", false
+		}
+	}
+	if base == "" || quote == "" {
+		return "", "", false
+	}
+	return d.asset(base), d.asset(quote), true
+}
+
+func generateNumberedPlaceholders(rows int, fieldCount int) string {
+	placeholders := make([]string, rows)
+	counter := 1
+	for i := 0; i < rows; i++ {
+		inner := make([]string, fieldCount)
+		for j := 0; j < fieldCount; j++ {
+			inner[j] = "$" + strconv.Itoa(counter)
+			counter++
+		}
+		placeholders[i] = "(" + strings.Join(inner, ", ") + ")"
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+func sanitizeDecimal(value float64, maxValue float64, precision int) float64 {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0
+	}
+
+	if value > maxValue {
+		value = maxValue
+	} else if value < -maxValue {
+		value = -maxValue
+	}
+
+	format := "%." + strconv.Itoa(precision) + "f"
+	strVal := fmt.Sprintf(format, value)
+	formattedVal, _ := strconv.ParseFloat(strVal, 64)
+	return formattedVal
+}
+
+// fetchSpotPairs downloads the endpoints of def and builds the pairs of the
+// tickers that pass the status filters and have a price, ordered by symbol.
+// A missing volume is derived from the other one and the price.
+func fetchSpotPairs(ctx context.Context, def *Definition) ([]models.Pair, error) {
+	var wg sync.WaitGroup
+	errChan := make(chan error, 2)
+
+	var tickersResp, marketsResp any
+
+	wg.Add(1)
+	go fetchJSON(ctx, def.Name, def.Tickers.URL, &tickersResp, &wg, errChan)
+	if def.Markets != nil {
+		wg.Add(1)
+		go fetchJSON(ctx, def.Name, def.Markets.URL, &marketsResp, &wg, errChan)
+	}
+
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tickers, err := items(def.Tickers, tickersResp)
+	if err != nil {
+		return nil, fmt.Errorf("%s tickers: %w", def.Name, err)
+	}
+	var markets map[string]item
+	if def.Markets != nil {
+		list, err := items(*def.Markets, marketsResp)
+		if err != nil {
+			return nil, fmt.Errorf("%s markets: %w", def.Name, err)
+		}
+		markets = make(map[string]item, len(list))
+		for _, it := range list {
+			if symbol, ok := text(it, def.Markets.Symbol); ok {
+				markets[symbol] = it
+			}
+		}
+	}
+
+	logger := logging.Exchange(def.Name)
+	seen := make(map[string]bool)
+	var pairs []models.Pair
+	for _, ticker := range tickers {
+		symbol, ok := text(ticker, def.Tickers.Symbol)
+		if !ok || symbol == "" || !matches(&def.Tickers, ticker) {
+			continue
+		}
+		var market *item
+		if markets != nil {
+			m, ok := markets[symbol]
+			if !ok || !matches(def.Markets, m) {
+				continue
+			}
+			market = &m
+		}
+
+		base, quote, ok := def.assets(symbol, ticker, market)
+		if !ok {
+			parseSampler.Warn(logger, "symbol", "skipping market without base or quote", "symbol", symbol)
+			continue
+		}
+		if seen[base+quote] {
+			parseSampler.Warn(logger, "duplicate", "skipping market listed twice", "symbol", symbol)
+			continue
+		}
+
+		price := sanitizeDecimal(number(def, ticker, def.Tickers.Price, "price"), MAX_DECIMAL_18_8, 8)
+		if price <= 0 {
+			continue
+		}
+		seen[base+quote] = true
+
+		var baseVolume, quoteVolume float64
+		if def.Tickers.BaseVolume != "" {
+			baseVolume = number(def, ticker, def.Tickers.BaseVolume, "baseVolume")
+		}
+		if def.Tickers.QuoteVolume != "" {
+			quoteVolume = number(def, ticker, def.Tickers.QuoteVolume, "quoteVolume")
+		}
+		switch {
+		case def.Tickers.QuoteVolume == "":
+			quoteVolume = baseVolume * price
+		case def.Tickers.BaseVolume == "":
+			baseVolume = quoteVolume / price
+		}
+
+		var change float64
+		switch {
+		case def.Tickers.Change != "":
+			change = number(def, ticker, def.Tickers.Change, "change")
+			if def.Tickers.ChangeFormat == ChangeFraction {
+				change *= 100
+			}
+		case def.Tickers.Open != "":
+			if open := number(def, ticker, def.Tickers.Open, "open"); open > 0 {
+				change = (price - open) / open * 100
+			}
+		}
+
+		pairs = append(pairs, models.Pair{
+			PairKey:               fmt.Sprintf("%s%s_%s_spot", base, quote, def.Name),
+			Symbol:                base + quote,
+			Exchange:              def.Name,
+			Market:                "spot",
+			Price:                 price,
+			BaseAsset:             base,
+			QuoteAsset:            quote,
+			DisplayName:           fmt.Sprintf("%s/%s", base, quote),
+			PriceChangePercent24h: sanitizeDecimal(change, MAX_DECIMAL_10_2, 2),
+			BaseVolume24h:         sanitizeDecimal(baseVolume, MAX_DECIMAL_20_2, 2),
+			QuoteVolume24h:        sanitizeDecimal(quoteVolume, MAX_DECIMAL_20_2, 2),
+			UpdatedAt:             time.Now().UTC(),
+		})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Symbol < pairs[j].Symbol })
+
+	return pairs, nil
+}
+
+// UpdateAllSpotPairs stores the spot markets of def in pairs.
+func UpdateAllSpotPairs(ctx context.Context, db *sql.DB, def *Definition) (int, error) {
+	pairs, err := fetchSpotPairs(ctx, def)
+	if err != nil {
+		return 0, err
+	}
+	if len(pairs) == 0 {
+		return 0, fmt.Errorf("%s No spot pairs to update", def.Name)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("%s Failed to begin transaction: %w", def.Name, err)
+	}
+
+	placeholderStr := generateNumberedPlaceholders(len(pairs), 12)
+	query := `
+    INSERT INTO pairs (pairkey, symbol, exchange, market, price, baseasset, quoteasset, displayname, pricechangepercent24h, basevolume24h, quotevolume24h, updatedat)
+    VALUES ` + placeholderStr + `
+    ON CONFLICT (pairkey) DO UPDATE SET
+        price = EXCLUDED.price,
+        pricechangepercent24h = EXCLUDED.pricechangepercent24h,
+        basevolume24h = EXCLUDED.basevolume24h,
+        quotevolume24h = EXCLUDED.quotevolume24h,
+        updatedat = EXCLUDED.updatedat
+    `
+
+	args := make([]interface{}, 0, len(pairs)*12)
+	for _, pair := range pairs {
+		args = append(args, pair.PairKey, pair.Symbol, pair.Exchange, pair.Market, pair.Price, pair.BaseAsset, pair.QuoteAsset,
+			pair.DisplayName, pair.PriceChangePercent24h, pair.BaseVolume24h, pair.QuoteVolume24h, pair.UpdatedAt)
+	}
+
+	_, err = tx.Exec(query, args...)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("%s Failed to execute statement: %w", def.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s Failed to commit transaction: %w", def.Name, err)
+	}
+
+	return len(pairs), nil
+}
@@ -0,0 +1,177 @@
+package generic
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"Updater/exchanges/exchangetest"
+	"Updater/models"
+)
+
+// definitionsDir holds the definitions shipped with the updater. Each one is
+// tested with the fixtures in testdata/<file name> next to it: tickers.json,
+// markets.json when it has markets, and expected.json with the pairs.
+const definitionsDir = "../../connectors"
+
+// serve answers the endpoints of def with the fixtures in dir, which is
+// relative to testdata, and points def at the server.
+func serve(t *testing.T, def *Definition, dir string) {
+	t.Helper()
+
+	endpoints := map[string]*Endpoint{"tickers.json": &def.Tickers}
+	if def.Markets != nil {
+		endpoints["markets.json"] = def.Markets
+	}
+	routes := make(map[string]string)
+	for file, e := range endpoints {
+		u, err := url.Parse(e.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		routes[u.RequestURI()] = filepath.Join(dir, file)
+	}
+	srv := exchangetest.Serve(t, routes)
+	for _, e := range endpoints {
+		u, _ := url.Parse(e.URL)
+		e.URL = srv.URL + u.RequestURI()
+	}
+}
+
+func TestFetchSpotPairs(t *testing.T) {
+	def, err := Load("testdata/example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve(t, def, "example")
+
+	pairs, err := fetchSpotPairs(context.Background(), def)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// XOLDZUSD is not tradable and XBADEUR has no known quote; XBT and ZUSD
+	// are renamed, the change comes from the open price and the quote volume
+	// is the base volume at the last price
+	exchangetest.Compare(t, pairs, []models.Pair{
+		{
+			PairKey:               "BTCUSD_Example_spot",
+			Symbol:                "BTCUSD",
+			Exchange:              "Example",
+			Market:                "spot",
+			Price:                 67000.5,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USD",
+			DisplayName:           "BTC/USD",
+			PriceChangePercent24h: 1.52,
+			BaseVolume24h:         1200.5,
+			QuoteVolume24h:        80434100.25,
+		},
+		{
+			PairKey:        "DOTUSDT_Example_spot",
+			Symbol:         "DOTUSDT",
+			Exchange:       "Example",
+			Market:         "spot",
+			Price:          7.25,
+			BaseAsset:      "DOT",
+			QuoteAsset:     "USDT",
+			DisplayName:    "DOT/USDT",
+			BaseVolume24h:  5000,
+			QuoteVolume24h: 36250,
+		},
+		{
+			PairKey:               "ETHUSD_Example_spot",
+			Symbol:                "ETHUSD",
+			Exchange:              "Example",
+			Market:                "spot",
+			Price:                 3500,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "USD",
+			DisplayName:           "ETH/USD",
+			PriceChangePercent24h: -2.78,
+			BaseVolume24h:         20000,
+			QuoteVolume24h:        70000000,
+		},
+	})
+}
+
+func TestDefinitions(t *testing.T) {
+	defs, err := LoadDir(definitionsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths, _ := filepath.Glob(filepath.Join(definitionsDir, "*.yaml"))
+	if len(defs) == 0 || len(defs) != len(paths) {
+		t.Fatalf("loaded %d definitions from %d files", len(defs), len(paths))
+	}
+
+	for i, def := range defs {
+		name := strings.TrimSuffix(filepath.Base(paths[i]), filepath.Ext(paths[i]))
+		t.Run(name, func(t *testing.T) {
+			// Fixture paths are relative to this package's testdata
+			fixtures := filepath.Join("..", definitionsDir, "testdata", name)
+			serve(t, def, fixtures)
+
+			data, err := os.ReadFile(filepath.Join(definitionsDir, "testdata", name, "expected.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want []models.Pair
+			if err := json.Unmarshal(data, &want); err != nil {
+				t.Fatal(err)
+			}
+
+			pairs, err := fetchSpotPairs(context.Background(), def)
+			if err != nil {
+				t.Fatal(err)
+			}
+			exchangetest.Compare(t, pairs, want)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	def := &Definition{
+		RateLimit: &RateLimit{RequestsPerSecond: 5},
+		Tickers: Endpoint{
+			URL:          "api.example.com/tickers",
+			Items:        "data..list",
+			Symbol:       "symbol",
+			Change:       "change",
+			ChangeFormat: "ratio",
+			Open:         "open",
+		},
+		Markets: &Endpoint{
+			URL:    "https://api.example.com/markets",
+			Symbol: "$key",
+			Base:   "base",
+			Price:  "last",
+			Status: &StatusFilter{Field: "state"},
+		},
+	}
+
+	err := def.Validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{
+		"name is required",
+		"rateLimit needs a positive requestsPerSecond and burst",
+		`tickers: url must be an absolute http or https URL, got "api.example.com/tickers"`,
+		`tickers: invalid items path "data..list"`,
+		"tickers: price is required",
+		`tickers: unknown changeFormat "ratio"`,
+		"tickers: set either change or open",
+		"markets: status needs a field and values",
+		"markets: prices, volumes and changes are only read from tickers",
+		"base and quote must both be read from the endpoints or both from the symbol",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,421 @@
+// Package httpclient is the HTTP client shared by the exchange connectors. It
+// pools connections, bounds every attempt with a per-exchange timeout, retries
+// 429 and 5xx responses with exponential backoff and jitter, honours
+// Retry-After and exchange weight headers, and keeps each exchange within a
+// request budget.
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"Updater/logging"
+	"Updater/metrics"
+)
+
+var logger = logging.For("component", "httpclient")
+
+// Config controls the client of one exchange.
+type Config struct {
+	// Timeout bounds one attempt, including reading the response body.
+	Timeout time.Duration
+	// MaxRetries is how many times a 429, 5xx or network error is retried,
+	// NoRetries to send every request only once.
+	MaxRetries int
+	// BaseBackoff is the delay before the first retry, doubled for every further one.
+	BaseBackoff time.Duration
+	// MaxBackoff caps the delay between retries and how long a Retry-After is honoured.
+	MaxBackoff time.Duration
+
+	// RequestsPerSecond is the sustained request budget, a negative value disables it.
+	RequestsPerSecond float64
+	// Burst is how many requests may be sent at once.
+	Burst int
+
+	// WeightHeader is a response header with the weight used in the current
+	// window, such as Binance's X-MBX-USED-WEIGHT-1M.
+	WeightHeader string
+	// WeightLimit pauses the exchange until the window ends once the used weight reaches it.
+	WeightLimit int
+	// WeightWindow is the length of the weight window.
+	WeightWindow time.Duration
+}
+
+// NoRetries as Config.MaxRetries turns retries off, since 0 takes the default.
+const NoRetries = -1
+
+// defaultConfig applies to exchanges without an entry in exchangeConfigs.
+var defaultConfig = Config{
+	Timeout:           10 * time.Second,
+	MaxRetries:        2,
+	BaseBackoff:       250 * time.Millisecond,
+	MaxBackoff:        10 * time.Second,
+	RequestsPerSecond: 10,
+	Burst:             10,
+}
+
+// exchangeConfigs holds the limits published by the exchanges, with some headroom.
+// Binance counts the weight of spot, USDⓈ-M and COIN-M futures separately, so
+// each API has its own client.
+var exchangeConfigs = map[string]Config{
+	"Binance": {
+		RequestsPerSecond: 10,
+		Burst:             10,
+		WeightHeader:      "X-MBX-USED-WEIGHT-1M",
+		WeightLimit:       5000, // of 6000 per minute
+		WeightWindow:      time.Minute,
+	},
+	"Binance Futures": {
+		RequestsPerSecond: 10,
+		Burst:             10,
+		WeightHeader:      "X-MBX-USED-WEIGHT-1M",
+		WeightLimit:       2000, // of 2400 per minute
+		WeightWindow:      time.Minute,
+	},
+	"Binance COIN-M": {
+		RequestsPerSecond: 10,
+		Burst:             10,
+		WeightHeader:      "X-MBX-USED-WEIGHT-1M",
+		WeightLimit:       2000, // of 2400 per minute
+		WeightWindow:      time.Minute,
+	},
+	"Bybit":       {RequestsPerSecond: 10, Burst: 10},
+	"Gate":        {RequestsPerSecond: 10, Burst: 10},
+	"Huobi":       {RequestsPerSecond: 10, Burst: 10},
+	"Kraken":      {RequestsPerSecond: 1, Burst: 5, Timeout: 15 * time.Second},
+	"KuCoin":      {RequestsPerSecond: 10, Burst: 10},
+	"MEXC":        {RequestsPerSecond: 10, Burst: 10},
+	"OKX":         {RequestsPerSecond: 5, Burst: 10},
+	"WhiteBIT":    {RequestsPerSecond: 5, Burst: 10},
+	"Bitget":      {RequestsPerSecond: 10, Burst: 10},
+	"Backpack":    {RequestsPerSecond: 5, Burst: 10},
+	"Coinbase":    {RequestsPerSecond: 5, Burst: 10},
+	"Bitfinex":    {RequestsPerSecond: 1, Burst: 6}, // 90 requests per minute on the public endpoints
+	"Hyperliquid": {RequestsPerSecond: 2, Burst: 5},
+	"dYdX":        {RequestsPerSecond: 5, Burst: 10},
+}
+
+// transport is shared by all exchanges so connections are pooled per host.
+// Responses are decompressed transparently since requests leave
+// Accept-Encoding to the transport.
+var transport = metrics.InstrumentTransport(&http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          100,
+	MaxIdleConnsPerHost:   10,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   5 * time.Second,
+	ExpectContinueTimeout: time.Second,
+})
+
+// Client sends the requests of one exchange.
+type Client struct {
+	name string
+	cfg  Config
+	http *http.Client
+
+	mu         sync.Mutex
+	tokens     float64
+	lastRefill time.Time
+	// pausedUntil is kept per host, a weight limit or Retry-After of one API
+	// does not hold back the others.
+	pausedUntil map[string]time.Time
+}
+
+var (
+	clientsMu sync.Mutex
+	clients   = make(map[string]*Client)
+)
+
+// For returns the client of an exchange, creating it on first use.
+func For(exchange string) *Client {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	if c, ok := clients[exchange]; ok {
+		return c
+	}
+	c := newClient(exchange, merge(exchangeConfigs[exchange], defaultConfig))
+	clients[exchange] = c
+	return c
+}
+
+// Configure replaces the settings of an exchange; zero fields use the defaults.
+func Configure(exchange string, cfg Config) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	next := newClient(exchange, merge(cfg, defaultConfig))
+	if c, ok := clients[exchange]; ok {
+		// Connectors keep the pointer they got from For
+		c.mu.Lock()
+		c.cfg, c.http, c.tokens = next.cfg, next.http, next.tokens
+		c.mu.Unlock()
+		return
+	}
+	clients[exchange] = next
+}
+
+// WrapTransport wraps the transport shared by all exchanges, such as to send
+// their requests to the simulator. Call it before the jobs start; requests
+// already in flight keep the previous transport.
+func WrapTransport(wrap func(http.RoundTripper) http.RoundTripper) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	transport = wrap(transport)
+	for _, c := range clients {
+		c.mu.Lock()
+		c.http = &http.Client{Transport: transport}
+		c.mu.Unlock()
+	}
+}
+
+func newClient(name string, cfg Config) *Client {
+	return &Client{
+		name:        name,
+		cfg:         cfg,
+		http:        &http.Client{Transport: transport},
+		tokens:      float64(cfg.Burst),
+		lastRefill:  time.Now(),
+		pausedUntil: make(map[string]time.Time),
+	}
+}
+
+// merge returns cfg with zero fields taken from def.
+func merge(cfg, def Config) Config {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = def.Timeout
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = def.MaxRetries
+	} else if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.BaseBackoff == 0 {
+		cfg.BaseBackoff = def.BaseBackoff
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = def.MaxBackoff
+	}
+	if cfg.RequestsPerSecond == 0 {
+		cfg.RequestsPerSecond = def.RequestsPerSecond
+	}
+	if cfg.Burst == 0 {
+		cfg.Burst = def.Burst
+	}
+	if cfg.WeightHeader != "" && cfg.WeightWindow == 0 {
+		cfg.WeightWindow = time.Minute
+	}
+	return cfg
+}
+
+// Get sends a GET request to url.
+func (c *Client) Get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Do sends req, retrying 429, 5xx and network errors. Requests with a body
+// must set GetBody to be retried. The response of the last attempt is
+// returned as is, so callers still check the status code. The response body
+// must be read before the attempt timeout expires.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx, host := req.Context(), req.URL.Host
+	c.mu.Lock()
+	cfg, client := c.cfg, c.http
+	c.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		if err := c.wait(ctx, host); err != nil {
+			return nil, err
+		}
+
+		resp, err := c.attempt(req, cfg, client)
+		if ctx.Err() != nil {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return nil, ctx.Err()
+		}
+
+		retryable, delay := c.inspect(resp, err, cfg, host)
+		if !retryable || attempt >= cfg.MaxRetries || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if delay == 0 {
+			delay = backoff(cfg, attempt)
+		}
+		metrics.HTTPRetries.WithLabelValues(c.name).Inc()
+		logger.Debug("retrying request", "exchange", c.name, "url", req.URL.Redacted(), "attempt", attempt+1, "delay", delay, "reason", retryReason(resp, err))
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// attempt sends one try of req bounded by the exchange timeout. The timeout
+// keeps running while the caller reads the body.
+func (c *Client) attempt(req *http.Request, cfg Config, client *http.Client) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), cfg.Timeout)
+	try := req.Clone(ctx)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		try.Body = body
+	}
+
+	resp, err := client.Do(try)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// inspect records weight and Retry-After headers of host and reports whether the
+// outcome should be retried and after which delay (0 for the default backoff).
+func (c *Client) inspect(resp *http.Response, err error, cfg Config, host string) (bool, time.Duration) {
+	if err != nil {
+		var netErr net.Error
+		return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded), 0
+	}
+
+	if cfg.WeightHeader != "" && cfg.WeightLimit > 0 {
+		if used, err := strconv.Atoi(resp.Header.Get(cfg.WeightHeader)); err == nil && used >= cfg.WeightLimit {
+			until := time.Now().Truncate(cfg.WeightWindow).Add(cfg.WeightWindow)
+			c.pause(host, until)
+			logger.Warn("request weight limit reached, pausing", "exchange", c.name, "host", host, "used", used, "limit", cfg.WeightLimit, "until", until)
+		}
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return false, 0
+	}
+
+	delay := retryAfter(resp.Header.Get("Retry-After"))
+	if delay > 0 {
+		// The exchange asked every request to the host to back off, not just this one
+		c.pause(host, time.Now().Add(delay))
+		if delay > cfg.MaxBackoff {
+			return false, 0
+		}
+	}
+	return true, delay
+}
+
+// wait blocks until host is not paused and a request token is available.
+func (c *Client) wait(ctx context.Context, host string) error {
+	for {
+		delay := c.reserve(host, time.Now())
+		if delay <= 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// reserve takes a token for a request to host and returns 0, or returns how long
+// to wait before trying again.
+func (c *Client) reserve(host string, now time.Time) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if until := c.pausedUntil[host]; now.Before(until) {
+		return until.Sub(now)
+	}
+	if c.cfg.RequestsPerSecond <= 0 {
+		return 0
+	}
+
+	burst := float64(c.cfg.Burst)
+	c.tokens = math.Min(burst, c.tokens+now.Sub(c.lastRefill).Seconds()*c.cfg.RequestsPerSecond)
+	c.lastRefill = now
+	if c.tokens >= 1 {
+		c.tokens--
+		return 0
+	}
+	return time.Duration((1 - c.tokens) / c.cfg.RequestsPerSecond * float64(time.Second))
+}
+
+func (c *Client) pause(host string, until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if until.After(c.pausedUntil[host]) {
+		c.pausedUntil[host] = until
+	}
+}
+
+// backoff is the exponential delay before retry attempt+1, with up to 50% jitter.
+func backoff(cfg Config, attempt int) time.Duration {
+	d := cfg.BaseBackoff << attempt
+	if d <= 0 || d > cfg.MaxBackoff {
+		d = cfg.MaxBackoff
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+func retryReason(resp *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("status %d", resp.StatusCode)
+}
+
+// cancelOnClose releases the attempt timeout once the body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
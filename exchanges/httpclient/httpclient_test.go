@@ -0,0 +1,355 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastConfig retries quickly and has no request budget.
+func fastConfig() Config {
+	return Config{
+		Timeout:           time.Second,
+		MaxRetries:        2,
+		BaseBackoff:       time.Millisecond,
+		MaxBackoff:        50 * time.Millisecond,
+		RequestsPerSecond: -1,
+	}
+}
+
+func testClient(cfg Config) *Client {
+	return newClient("test", merge(cfg, defaultConfig))
+}
+
+// sequenceServer answers the requests with the given handlers in turn and
+// repeats the last one, counting the requests it got.
+func sequenceServer(t *testing.T, handlers ...http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1)) - 1
+		handlers[min(n, len(handlers)-1)](w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func status(code int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(code) }
+}
+
+func withHeader(key, value string, code int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(key, value)
+		w.WriteHeader(code)
+	}
+}
+
+// dropConnection closes the connection without answering.
+func dropConnection(w http.ResponseWriter, r *http.Request) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
+
+func host(srv *httptest.Server) string {
+	return srv.Listener.Addr().String()
+}
+
+func get(t *testing.T, c *Client, url string) int {
+	t.Helper()
+	resp, err := c.Get(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestDoRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxRetries   int
+		handlers     []http.HandlerFunc
+		wantStatus   int
+		wantRequests int32
+	}{
+		{"server errors", 2, []http.HandlerFunc{status(500), status(503), status(200)}, 200, 3},
+		{"too many requests", 2, []http.HandlerFunc{status(429), status(200)}, 200, 2},
+		{"network error", 2, []http.HandlerFunc{dropConnection, status(200)}, 200, 2},
+		{"retries exhausted", 1, []http.HandlerFunc{status(502)}, 502, 2},
+		{"client error", 2, []http.HandlerFunc{status(404), status(200)}, 404, 1},
+		{"retries disabled", NoRetries, []http.HandlerFunc{status(503), status(200)}, 503, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := sequenceServer(t, tt.handlers...)
+			cfg := fastConfig()
+			cfg.MaxRetries = tt.maxRetries
+
+			if got := get(t, testClient(cfg), srv.URL); got != tt.wantStatus {
+				t.Errorf("status = %d, want %d", got, tt.wantStatus)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("server got %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestDoNetworkErrorExhausted(t *testing.T) {
+	srv, requests := sequenceServer(t, dropConnection)
+	_, err := testClient(fastConfig()).Get(context.Background(), srv.URL)
+	if err == nil {
+		t.Fatal("want an error after the connection was dropped on every attempt")
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("server got %d requests, want 3", got)
+	}
+}
+
+func TestMergeMaxRetries(t *testing.T) {
+	tests := []struct {
+		maxRetries, want int
+	}{
+		{0, defaultConfig.MaxRetries},
+		{5, 5},
+		{NoRetries, 0},
+	}
+	for _, tt := range tests {
+		if got := merge(Config{MaxRetries: tt.maxRetries}, defaultConfig).MaxRetries; got != tt.want {
+			t.Errorf("merge(MaxRetries %d).MaxRetries = %d, want %d", tt.maxRetries, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cfg := Config{BaseBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{2, 400 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{4, time.Second},
+		{70, time.Second}, // the shift overflows
+	}
+	for _, tt := range tests {
+		// Jitter takes off up to half of the delay
+		for i := 0; i < 100; i++ {
+			if got := backoff(cfg, tt.attempt); got < tt.max/2 || got > tt.max {
+				t.Fatalf("backoff(attempt %d) = %s, want between %s and %s", tt.attempt, got, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+func TestDoBacksOffExponentially(t *testing.T) {
+	srv, _ := sequenceServer(t, status(503))
+	cfg := fastConfig()
+	cfg.MaxRetries, cfg.BaseBackoff, cfg.MaxBackoff = 3, 20*time.Millisecond, time.Second
+
+	start := time.Now()
+	get(t, testClient(cfg), srv.URL)
+	// At least half of 20, 40 and 80ms
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("3 retries took %s, want at least 70ms", elapsed)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if got := retryAfter("3"); got != 3*time.Second {
+		t.Errorf("retryAfter(3) = %s, want 3s", got)
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := retryAfter(date); got <= 58*time.Second || got > time.Minute {
+		t.Errorf("retryAfter(%q) = %s, want about a minute", date, got)
+	}
+	for _, value := range []string{"", "soon"} {
+		if got := retryAfter(value); got != 0 {
+			t.Errorf("retryAfter(%q) = %s, want 0", value, got)
+		}
+	}
+}
+
+func TestDoHonoursRetryAfter(t *testing.T) {
+	srv, requests := sequenceServer(t, withHeader("Retry-After", "1", http.StatusTooManyRequests), status(200))
+	cfg := fastConfig()
+	cfg.MaxBackoff = 2 * time.Second
+
+	start := time.Now()
+	if got := get(t, testClient(cfg), srv.URL); got != 200 {
+		t.Errorf("status = %d, want 200", got)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want the 1s Retry-After", elapsed)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("server got %d requests, want 2", got)
+	}
+}
+
+func TestDoRetryAfterBeyondMaxBackoff(t *testing.T) {
+	srv, requests := sequenceServer(t, withHeader("Retry-After", "60", http.StatusTooManyRequests), status(200))
+	c := testClient(fastConfig())
+
+	if got := get(t, c, srv.URL); got != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429 without waiting a minute", got)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("server got %d requests, want 1", got)
+	}
+	// Every request to the host stays paused for the Retry-After
+	if wait := c.reserve(host(srv), time.Now()); wait < 59*time.Second {
+		t.Errorf("next request waits %s, want about a minute", wait)
+	}
+}
+
+func TestDoPausesAtWeightLimit(t *testing.T) {
+	tests := []struct {
+		used  string
+		pause bool
+	}{
+		{"999", false},
+		{"1000", true},
+		{"1200", true},
+		{"", false},
+	}
+	for _, tt := range tests {
+		srv, _ := sequenceServer(t, withHeader("X-Used-Weight", tt.used, 200))
+		cfg := fastConfig()
+		cfg.WeightHeader, cfg.WeightLimit, cfg.WeightWindow = "X-Used-Weight", 1000, time.Hour
+		c := testClient(cfg)
+
+		get(t, c, srv.URL)
+		now := time.Now()
+		wait := c.reserve(host(srv), now)
+		if !tt.pause {
+			if wait != 0 {
+				t.Errorf("used %q: next request waits %s, want no pause", tt.used, wait)
+			}
+			continue
+		}
+		// Paused until the current window ends
+		if want := now.Truncate(time.Hour).Add(time.Hour).Sub(now); wait <= 0 || wait > want {
+			t.Errorf("used %q: next request waits %s, want until the window ends in %s", tt.used, wait, want)
+		}
+	}
+}
+
+func TestDoPausesPerHost(t *testing.T) {
+	limited, _ := sequenceServer(t, withHeader("X-Used-Weight", "1000", 200))
+	other, requests := sequenceServer(t, status(200))
+	cfg := fastConfig()
+	cfg.WeightHeader, cfg.WeightLimit, cfg.WeightWindow = "X-Used-Weight", 1000, time.Hour
+	c := testClient(cfg)
+
+	get(t, c, limited.URL)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	resp, err := c.Get(ctx, other.URL)
+	if err != nil {
+		t.Fatalf("request to another host: %v, want it sent despite the pause", err)
+	}
+	resp.Body.Close()
+	if got := requests.Load(); got != 1 {
+		t.Errorf("other host got %d requests, want 1", got)
+	}
+	if wait := c.reserve(host(limited), time.Now()); wait <= 0 {
+		t.Error("limited host is not paused")
+	}
+}
+
+func TestBinanceWeightLimits(t *testing.T) {
+	// Weight limits per minute published for each Binance API
+	published := map[string]int{
+		"Binance":         6000,
+		"Binance Futures": 2400,
+		"Binance COIN-M":  2400,
+	}
+	for name, limit := range published {
+		cfg, ok := exchangeConfigs[name]
+		if !ok {
+			t.Errorf("no config for %s", name)
+			continue
+		}
+		if cfg.WeightHeader != "X-MBX-USED-WEIGHT-1M" || cfg.WeightLimit <= 0 || cfg.WeightLimit >= limit {
+			t.Errorf("%s pauses at %s %d, want the used weight header below %d", name, cfg.WeightHeader, cfg.WeightLimit, limit)
+		}
+	}
+}
+
+func TestDoWaitsWhilePaused(t *testing.T) {
+	srv, requests := sequenceServer(t, status(200))
+	c := testClient(fastConfig())
+	c.pause(host(srv), time.Now().Add(time.Minute))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.Get(ctx, srv.URL); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want the deadline while paused", err)
+	}
+	if got := requests.Load(); got != 0 {
+		t.Errorf("server got %d requests while paused, want 0", got)
+	}
+}
+
+func TestReserveBudget(t *testing.T) {
+	cfg := fastConfig()
+	cfg.RequestsPerSecond, cfg.Burst = 2, 3
+	c := testClient(cfg)
+	start := c.lastRefill
+
+	// The burst goes out at once
+	for i := 0; i < 3; i++ {
+		if wait := c.reserve("api.example.com", start); wait != 0 {
+			t.Fatalf("request %d waits %s, want none within the burst", i+1, wait)
+		}
+	}
+	if wait := c.reserve("api.example.com", start); wait != 500*time.Millisecond {
+		t.Errorf("request after the burst waits %s, want 500ms", wait)
+	}
+	// Tokens refill at the sustained rate
+	if wait := c.reserve("api.example.com", start.Add(500*time.Millisecond)); wait != 0 {
+		t.Errorf("request after 500ms waits %s, want none", wait)
+	}
+	if wait := c.reserve("api.example.com", start.Add(600*time.Millisecond)); wait != 400*time.Millisecond {
+		t.Errorf("request after 600ms waits %s, want 400ms", wait)
+	}
+}
+
+func TestDoKeepsToBudget(t *testing.T) {
+	srv, requests := sequenceServer(t, status(200))
+	cfg := fastConfig()
+	cfg.RequestsPerSecond, cfg.Burst = 20, 1
+	c := testClient(cfg)
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		get(t, c, srv.URL)
+	}
+	// One request at once and three more 50ms apart
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("4 requests took %s, want at least 140ms at 20 per second", elapsed)
+	}
+	if got := requests.Load(); got != 4 {
+		t.Errorf("server got %d requests, want 4", got)
+	}
+}
+
+func TestReserveWithoutBudget(t *testing.T) {
+	c := testClient(fastConfig())
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		if wait := c.reserve("api.example.com", now); wait != 0 {
+			t.Fatalf("request %d waits %s, want no budget", i+1, wait)
+		}
+	}
+}
@@ -1,11 +1,12 @@
 package huobi
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"math"
 	"net/http"
 	"strconv"
@@ -13,14 +14,35 @@ import (
 	"sync"
 	"time"
 
+	"Updater/exchanges/httpclient"
+	"Updater/logging"
+	"Updater/metrics"
 	"Updater/models"
 )
 
+var (
+	logger       = logging.Exchange("Huobi")
+	parseSampler = logging.NewSampler()
+	httpClient   = httpclient.For("Huobi")
+)
+
+// Base URLs are variables so tests can point the connector at recorded responses
+var (
+	baseURL        = "https://api.huobi.pro"
+	futuresBaseURL = "https://api.hbdm.com"
+)
+
 const (
-	symbolsURL     = "https://api.huobi.pro/v1/common/symbols"
-	tickerPriceURL = "https://api.huobi.pro/market/tickers"
-	ticker24hrURL  = "https://api.huobi.pro/market/detail"
-	currenciesURL  = "https://api.huobi.pro/v2/reference/currencies"
+	symbolsPath     = "/v1/common/symbols"
+	tickerPricePath = "/market/tickers"
+	ticker24hrPath  = "/market/detail"
+	currenciesPath  = "/v2/reference/currencies"
+
+	// USDT-M linear swaps on futuresBaseURL
+	swapContractInfoPath = "/linear-swap-api/v1/swap_contract_info?business_type=swap"
+	swapTickersPath      = "/linear-swap-ex/market/detail/batch_merged?business_type=swap"
+	swapFundingRatePath  = "/linear-swap-api/v1/swap_batch_funding_rate"
+	swapIndexPath        = "/linear-swap-api/v1/swap_index"
 
 	// Обмеження для числових полів в PostgreSQL
 	MAX_DECIMAL_18_8 = 9999999999.99999999   // Максимальне значення для DECIMAL(18,8)
@@ -80,15 +102,59 @@ type CurrenciesResponse struct {
 	} `json:"data"`
 }
 
+// SwapContractInfoResponse lists the linear swap contracts
+type SwapContractInfoResponse struct {
+	Status string `json:"status"`
+	Data   []struct {
+		ContractCode   string `json:"contract_code"` // e.g. BTC-USDT
+		Symbol         string `json:"symbol"`
+		TradePartition string `json:"trade_partition"`
+		ContractStatus int    `json:"contract_status"` // 1 is listed
+		BusinessType   string `json:"business_type"`
+	} `json:"data"`
+}
+
+// SwapTickersResponse holds the 24h tickers of the linear swaps
+type SwapTickersResponse struct {
+	Status string `json:"status"`
+	Ticks  []struct {
+		ContractCode  string `json:"contract_code"`
+		Open          string `json:"open"`
+		Close         string `json:"close"`
+		Amount        string `json:"amount"` // base asset
+		TradeTurnover string `json:"trade_turnover"`
+	} `json:"ticks"`
+}
+
+// SwapFundingRateResponse holds the current funding rate of every linear swap
+type SwapFundingRateResponse struct {
+	Status string `json:"status"`
+	Data   []struct {
+		ContractCode string `json:"contract_code"`
+		FundingRate  string `json:"funding_rate"`
+		FundingTime  string `json:"funding_time"` // when the current rate is settled, in milliseconds
+	} `json:"data"`
+}
+
+// SwapIndexResponse holds the index price of every linear swap
+type SwapIndexResponse struct {
+	Status string `json:"status"`
+	Data   []struct {
+		ContractCode string  `json:"contract_code"`
+		IndexPrice   float64 `json:"index_price"`
+	} `json:"data"`
+}
+
 // fetchJSON універсальна функція для отримання JSON з API
-func fetchJSON(url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
+func fetchJSON(ctx context.Context, url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
 	defer wg.Done()
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		errChan <- fmt.Errorf("Huobi error fetching %s: %w", url, err)
+		return
 	}
-
-	resp, err := client.Get(url)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		errChan <- fmt.Errorf("Huobi error fetching %s: %w", url, err)
 		return
@@ -111,6 +177,16 @@ func fetchJSON(url string, target interface{}, wg *sync.WaitGroup, errChan chan<
 	}
 }
 
+func parseFloat(s string, field string) float64 {
+	val, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		parseSampler.Warn(logger, "parseFloat", "failed to parse float", "value", s, "field", field)
+		metrics.ParseWarnings.WithLabelValues("Huobi").Inc()
+		return 0
+	}
+	return val
+}
+
 // sanitizeDecimal перевіряє та обмежує числове значення
 func sanitizeDecimal(value float64, maxValue float64, precision int) float64 {
 	// Перевіряємо на NaN та Inf
@@ -120,10 +196,10 @@ func sanitizeDecimal(value float64, maxValue float64, precision int) float64 {
 
 	// Обмежуємо значення
 	if value > maxValue {
-		// log.Printf("Warning: Value %f exceeds maximum allowed %f, clamping", value, maxValue)
+		parseSampler.Debug(logger, "clamp", "value exceeds column range, clamping", "value", value, "max", maxValue)
 		value = maxValue
 	} else if value < -maxValue {
-		// log.Printf("Warning: Value %f exceeds minimum allowed %f, clamping", value, -maxValue)
+		parseSampler.Debug(logger, "clamp", "value exceeds column range, clamping", "value", value, "min", -maxValue)
 		value = -maxValue
 	}
 
@@ -157,8 +233,8 @@ func generateNumberedPlaceholders(rows int, fieldCount int) string {
 	return strings.Join(placeholders, ", ")
 }
 
-// UpdateAllSpotPairs оновлює інформацію про всі спотові пари з Huobi
-func UpdateAllSpotPairs(db *sql.DB) bool {
+// fetchSpotPairs завантажує спотові пари Huobi, пропускаючи неактивні символи
+func fetchSpotPairs(ctx context.Context) ([]models.Pair, error) {
 	var wg sync.WaitGroup
 	errChan := make(chan error, 2)
 
@@ -168,8 +244,8 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 
 	// Запускаємо два паралельні запити
 	wg.Add(2)
-	go fetchJSON(symbolsURL, &symbolsInfo, &wg, errChan)
-	go fetchJSON(tickerPriceURL, &tickersInfo, &wg, errChan)
+	go fetchJSON(ctx, baseURL+symbolsPath, &symbolsInfo, &wg, errChan)
+	go fetchJSON(ctx, baseURL+tickerPricePath, &tickersInfo, &wg, errChan)
 
 	// Чекаємо завершення всіх запитів
 	wg.Wait()
@@ -178,15 +254,13 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 	// Перевіряємо наявність помилок
 	for err := range errChan {
 		if err != nil {
-			log.Printf("Error: %v", err)
-			return false
+			return nil, err
 		}
 	}
 
 	// Перевіряємо статуси відповідей
 	if symbolsInfo.Status != "ok" || tickersInfo.Status != "ok" {
-		log.Printf("Huobi API returned non-OK status")
-		return false
+		return nil, errors.New("Huobi API returned non-OK status")
 	}
 
 	// Створюємо мапу для швидкого доступу до даних тікера
@@ -213,7 +287,7 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 
 	// Формуємо фінальний масив `Pair`
 	var pairs []models.Pair
-	now := time.Now()
+	now := time.Now().UTC()
 
 	for _, sym := range symbolsInfo.Data {
 		// Перевіряємо чи активна пара
@@ -238,7 +312,7 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 
 		// Перевірка на неприпустимі значення для полів price, baseVolume і quoteVolume
 		if price <= 0 || math.IsNaN(price) || math.IsInf(price, 0) {
-			// log.Printf("Skipping pair %s due to invalid price: %v", sym.Symbol, tickerData.Close)
+			parseSampler.Debug(logger, "invalidPrice", "skipping pair with invalid price", "symbol", sym.Symbol, "value", tickerData.Close)
 			continue
 		}
 
@@ -287,15 +361,23 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 
 	// Перевіряємо, чи є дані для вставки
 	if len(pairs) == 0 {
-		log.Printf("Huobi: No pairs data to insert")
-		return false
+		return nil, errors.New("Huobi: No pairs data to insert")
+	}
+
+	return pairs, nil
+}
+
+// UpdateAllSpotPairs оновлює інформацію про всі спотові пари з Huobi
+func UpdateAllSpotPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchSpotPairs(ctx)
+	if err != nil {
+		return 0, err
 	}
 
 	// Розпочинаємо транзакцію
 	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("Huobi: Failed to begin transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Huobi: Failed to begin transaction: %w", err)
 	}
 
 	// Використовуємо 12 колонок на запис
@@ -314,8 +396,7 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 	stmt, err := tx.Prepare(query)
 	if err != nil {
 		tx.Rollback()
-		log.Printf("Huobi: Failed to prepare statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Huobi: Failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
@@ -341,34 +422,63 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 	_, err = stmt.Exec(args...)
 	if err != nil {
 		tx.Rollback()
-		log.Printf("Huobi: Failed to execute statement: %v", err)
-		return false
+		return 0, fmt.Errorf("Huobi: Failed to execute statement: %w", err)
 	}
 
 	// Завершення транзакції
 	if err := tx.Commit(); err != nil {
-		log.Printf("Huobi: Failed to commit transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("Huobi: Failed to commit transaction: %w", err)
 	}
 
-	// log.Printf("Huobi: Successfully updated %d spot pairs", len(pairs))
-	return true
+	return len(pairs), nil
 }
 
-// Функція для збору та збереження мереж із Huobi
-func UpdateAllNetworks(db *sql.DB) bool {
+// fetchNetworks завантажує мережі для кожної монети Huobi
+func fetchNetworks(ctx context.Context) ([]models.Network, error) {
 	// Запит до API
-	resp, err := http.Get(currenciesURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+currenciesPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching data from Huobi: %w", err)
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		log.Printf("error fetching data from Huobi: %v", err)
-		return false
+		return nil, fmt.Errorf("error fetching data from Huobi: %w", err)
 	}
 	defer resp.Body.Close()
 
 	var result CurrenciesResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Printf("error decoding response: %v", err)
-		return false
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	var networks []models.Network
+	for _, coin := range result.Data {
+		coinSymbol := strings.ToUpper(coin.Currency)
+
+		for _, chain := range coin.Chains {
+			network := strings.ToUpper(chain.Name) // Наприклад, "BTC", "BSC", "ERC20"
+
+			networks = append(networks, models.Network{
+				CoinKey:        fmt.Sprintf("%s_Huobi_%s", coinSymbol, network),
+				Coin:           coinSymbol,
+				Exchange:       "Huobi",
+				Network:        network,
+				NetworkName:    chain.FullName, // Наприклад, "Bitcoin", "Binance Smart Chain"
+				DepositEnable:  chain.DepositStatus == "allowed",
+				WithdrawEnable: chain.WithdrawStatus == "allowed",
+				UpdatedAt:      time.Now().UTC(),
+			})
+		}
+	}
+
+	return networks, nil
+}
+
+// Функція для збору та збереження мереж із Huobi
+func UpdateAllNetworks(ctx context.Context, db *sql.DB) (int, error) {
+	networks, err := fetchNetworks(ctx)
+	if err != nil {
+		return 0, err
 	}
 
 	// Підготовка SQL-запиту на вставку/оновлення
@@ -382,25 +492,181 @@ func UpdateAllNetworks(db *sql.DB) bool {
 	`
 
 	// Обробка отриманих даних
-	for _, coin := range result.Data {
-		coinSymbol := strings.ToUpper(coin.Currency)
+	updated := 0
+	for _, n := range networks {
+		_, err := db.Exec(query, n.CoinKey, n.Coin, n.Exchange, n.Network, n.NetworkName, n.DepositEnable, n.WithdrawEnable, n.UpdatedAt)
+		if err != nil {
+			logger.Warn("error upserting network", "coin", n.CoinKey, "error", err)
+			continue
+		}
+		updated++
+	}
 
-		for _, chain := range coin.Chains {
-			network := strings.ToUpper(chain.Name) // Наприклад, "BTC", "BSC", "ERC20"
-			networkName := chain.FullName          // Наприклад, "Bitcoin", "Binance Smart Chain"
-			depositEnabled := chain.DepositStatus == "allowed"
-			withdrawEnabled := chain.WithdrawStatus == "allowed"
+	return updated, nil
+}
+
+// fetchFuturesPairs downloads the listed USDT-M linear swaps and builds their pairs.
+func fetchFuturesPairs(ctx context.Context) ([]models.PairFutures, error) {
+	var wg sync.WaitGroup
+	errChan := make(chan error, 4)
 
-			coinKey := fmt.Sprintf("%s_Huobi_%s", coinSymbol, network)
-			updatedAt := time.Now().UTC() // Поточний час у форматі UTC
+	var contracts SwapContractInfoResponse
+	var tickers SwapTickersResponse
+	var fundingRates SwapFundingRateResponse
+	var indexes SwapIndexResponse
 
-			_, err := db.Exec(query, coinKey, coinSymbol, "Huobi", network, networkName, depositEnabled, withdrawEnabled, updatedAt)
-			if err != nil {
-				log.Printf("Error inserting/updating %s: %v", coinKey, err)
-			}
+	wg.Add(4)
+	go fetchJSON(ctx, futuresBaseURL+swapContractInfoPath, &contracts, &wg, errChan)
+	go fetchJSON(ctx, futuresBaseURL+swapTickersPath, &tickers, &wg, errChan)
+	go fetchJSON(ctx, futuresBaseURL+swapFundingRatePath, &fundingRates, &wg, errChan)
+	go fetchJSON(ctx, futuresBaseURL+swapIndexPath, &indexes, &wg, errChan)
+
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	// fmt.Println("Huobi networks updated successfully.")
-	return true
+	if contracts.Status != "ok" || tickers.Status != "ok" || fundingRates.Status != "ok" || indexes.Status != "ok" {
+		return nil, errors.New("Huobi API returned non-OK status")
+	}
+
+	tickerMap := make(map[string]int, len(tickers.Ticks))
+	for i, t := range tickers.Ticks {
+		tickerMap[t.ContractCode] = i
+	}
+	fundingMap := make(map[string]int, len(fundingRates.Data))
+	for i, f := range fundingRates.Data {
+		fundingMap[f.ContractCode] = i
+	}
+	indexMap := make(map[string]float64, len(indexes.Data))
+	for _, idx := range indexes.Data {
+		indexMap[idx.ContractCode] = idx.IndexPrice
+	}
+
+	var pairs []models.PairFutures
+	for _, c := range contracts.Data {
+		if c.ContractStatus != 1 || c.BusinessType != "swap" {
+			continue
+		}
+		baseAsset, quoteAsset, ok := strings.Cut(c.ContractCode, "-")
+		if !ok {
+			continue
+		}
+		t, exists := tickerMap[c.ContractCode]
+		if !exists {
+			continue
+		}
+		f, exists := fundingMap[c.ContractCode]
+		if !exists || fundingRates.Data[f].FundingRate == "" {
+			continue
+		}
+		ticker := tickers.Ticks[t]
+		funding := fundingRates.Data[f]
+
+		// HTX only publishes mark prices as per contract klines, the last price stands in for it
+		last := parseFloat(ticker.Close, c.ContractCode+" close")
+		if last <= 0 {
+			continue
+		}
+		open := parseFloat(ticker.Open, c.ContractCode+" open")
+
+		symbol := baseAsset + quoteAsset
+		pair := models.PairFutures{
+			PairKey:               fmt.Sprintf("%s_HUOBI_FUTURES", symbol),
+			Symbol:                symbol,
+			Exchange:              "Huobi",
+			Market:                "futures",
+			MarkPrice:             sanitizeDecimal(last, MAX_DECIMAL_18_8, 8),
+			IndexPrice:            sanitizeDecimal(indexMap[c.ContractCode], MAX_DECIMAL_18_8, 8),
+			BaseAsset:             baseAsset,
+			QuoteAsset:            quoteAsset,
+			DisplayName:           fmt.Sprintf("%s/%s", baseAsset, quoteAsset),
+			FundingRatePercent:    parseFloat(funding.FundingRate, c.ContractCode+" funding_rate"),
+			NextFundingTimestamp:  int(parseFloat(funding.FundingTime, c.ContractCode+" funding_time")),
+			PriceChangePercent24h: sanitizeDecimal(calculatePercentChange(open, last), MAX_DECIMAL_10_2, 2),
+			BaseVolume24h:         sanitizeDecimal(parseFloat(ticker.Amount, c.ContractCode+" amount"), MAX_DECIMAL_20_2, 2),
+			QuoteVolume24h:        sanitizeDecimal(parseFloat(ticker.TradeTurnover, c.ContractCode+" trade_turnover"), MAX_DECIMAL_20_2, 2),
+			UpdatedAt:             time.Now().UTC(),
+			CreatedAt:             time.Now(),
+		}
+		pairs = append(pairs, pair)
+	}
+
+	if len(pairs) == 0 {
+		return nil, errors.New("Huobi: No futures pairs to update")
+	}
+
+	return pairs, nil
+}
+
+// UpdateAllFuturesPairs updates the USDT-M linear swaps of HTX
+func UpdateAllFuturesPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchFuturesPairs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("Huobi: Failed to begin transaction: %w", err)
+	}
+
+	placeholderStr := generateNumberedPlaceholders(len(pairs), 16)
+	query := `
+    INSERT INTO pairsfutures (pairkey, symbol, exchange, market, markprice, indexprice, baseasset, quoteasset, displayname, fundingRatePercent, nextfundingtimestamp, pricechangepercent24h, basevolume24h, quotevolume24h, updatedat, createdat)
+    VALUES ` + placeholderStr + `
+    ON CONFLICT (pairkey) DO UPDATE SET
+        markprice = EXCLUDED.markprice,
+        indexprice = EXCLUDED.indexprice,
+        fundingRatePercent = EXCLUDED.fundingRatePercent,
+        nextfundingtimestamp = EXCLUDED.nextfundingtimestamp,
+        pricechangepercent24h = EXCLUDED.pricechangepercent24h,
+        basevolume24h = EXCLUDED.basevolume24h,
+        quotevolume24h = EXCLUDED.quotevolume24h,
+        updatedat = EXCLUDED.updatedat
+    `
+
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("Huobi: Failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	args := make([]interface{}, 0, len(pairs)*16)
+	for _, pair := range pairs {
+		args = append(args,
+			pair.PairKey,
+			pair.Symbol,
+			pair.Exchange,
+			pair.Market,
+			pair.MarkPrice,
+			pair.IndexPrice,
+			pair.BaseAsset,
+			pair.QuoteAsset,
+			pair.DisplayName,
+			pair.FundingRatePercent,
+			pair.NextFundingTimestamp,
+			pair.PriceChangePercent24h,
+			pair.BaseVolume24h,
+			pair.QuoteVolume24h,
+			pair.UpdatedAt,
+			pair.CreatedAt)
+	}
+
+	_, err = stmt.Exec(args...)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("Huobi: Failed to execute statement: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("Huobi: Failed to commit transaction: %w", err)
+	}
+
+	return len(pairs), nil
 }
@@ -0,0 +1,123 @@
+package huobi
+
+import (
+	"context"
+	"testing"
+
+	"Updater/exchanges/exchangetest"
+	"Updater/models"
+)
+
+func TestFetchSpotPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		symbolsPath:     "symbols.json",
+		tickerPricePath: "tickers.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	pairs, err := fetchSpotPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// lendusdt is offline and newusdt pre-online, even though lendusdt still
+	// has a ticker; deadusdt has a zero close and quietusdt no ticker at all
+	exchangetest.Compare(t, pairs, []models.Pair{
+		{
+			PairKey:               "BTCUSDT_HUOBI_SPOT",
+			Symbol:                "BTCUSDT",
+			Exchange:              "Huobi",
+			Market:                "spot",
+			Price:                 67012.35,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDT",
+			DisplayName:           "BTC/USDT",
+			PriceChangePercent24h: 1.53,
+			BaseVolume24h:         4321.12,
+			QuoteVolume24h:        289456789.13,
+		},
+		{
+			PairKey:               "ETHBTC_HUOBI_SPOT",
+			Symbol:                "ETHBTC",
+			Exchange:              "Huobi",
+			Market:                "spot",
+			Price:                 0.051234,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "BTC",
+			DisplayName:           "ETH/BTC",
+			PriceChangePercent24h: -2.41,
+			BaseVolume24h:         812.5,
+			QuoteVolume24h:        41.62,
+		},
+	})
+}
+
+func TestFetchNetworks(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		currenciesPath: "currencies.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	networks, err := fetchNetworks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exchangetest.Compare(t, networks, []models.Network{
+		{CoinKey: "USDT_Huobi_TRC20", Coin: "USDT", Exchange: "Huobi", Network: "TRC20", NetworkName: "TRC20", DepositEnable: true, WithdrawEnable: true},
+		{CoinKey: "USDT_Huobi_ERC20", Coin: "USDT", Exchange: "Huobi", Network: "ERC20", NetworkName: "ERC20", DepositEnable: true},
+		{CoinKey: "BTC_Huobi_BITCOIN", Coin: "BTC", Exchange: "Huobi", Network: "BITCOIN", NetworkName: "BTC", WithdrawEnable: true},
+	})
+}
+
+func TestFetchFuturesPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		swapContractInfoPath: "swap_contract_info.json",
+		swapTickersPath:      "swap_tickers.json",
+		swapFundingRatePath:  "swap_funding_rate.json",
+		swapIndexPath:        "swap_index.json",
+	})
+	srv.SetURL(t, &futuresBaseURL)
+
+	pairs, err := fetchFuturesPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// DOGE-USDT is suspended and NEW-USDT has not traded yet; the last price
+	// stands in for the mark price
+	exchangetest.Compare(t, pairs, []models.PairFutures{
+		{
+			PairKey:               "BTCUSDT_HUOBI_FUTURES",
+			Symbol:                "BTCUSDT",
+			Exchange:              "Huobi",
+			Market:                "futures",
+			MarkPrice:             67050.1,
+			IndexPrice:            67040.55,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDT",
+			DisplayName:           "BTC/USDT",
+			FundingRatePercent:    0.0001,
+			NextFundingTimestamp:  1718006400000,
+			PriceChangePercent24h: 0.83,
+			BaseVolume24h:         12345.68,
+			QuoteVolume24h:        827812345.6,
+		},
+		{
+			PairKey:               "ETHUSDT_HUOBI_FUTURES",
+			Symbol:                "ETHUSDT",
+			Exchange:              "Huobi",
+			Market:                "futures",
+			MarkPrice:             3510.2,
+			IndexPrice:            3509.87,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "USDT",
+			DisplayName:           "ETH/USDT",
+			FundingRatePercent:    -0.000035,
+			NextFundingTimestamp:  1718006400000,
+			PriceChangePercent24h: -2.49,
+			BaseVolume24h:         234567.81,
+			QuoteVolume24h:        823412345.12,
+		},
+	})
+}
@@ -0,0 +1,263 @@
+package hyperliquid
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"Updater/exchanges/httpclient"
+	"Updater/logging"
+	"Updater/metrics"
+	"Updater/models"
+)
+
+var (
+	logger       = logging.Exchange("Hyperliquid")
+	parseSampler = logging.NewSampler()
+	httpClient   = httpclient.For("Hyperliquid")
+)
+
+// baseURL is a variable so tests can point the connector at recorded responses
+var baseURL = "https://api.hyperliquid.xyz"
+
+// now is a variable so tests can fix the next funding time
+var now = time.Now
+
+const (
+	infoPath = "/info"
+
+	// metaAndAssetCtxsRequest asks for the perpetuals and their current state
+	metaAndAssetCtxsRequest = `{"type":"metaAndAssetCtxs"}`
+
+	MAX_DECIMAL_18_8  = 9999999999.99999999   // Максимальне значення для DECIMAL(18,8)
+	MAX_DECIMAL_10_2  = 99999999.99           // Максимальне значення для DECIMAL(10,2)
+	MAX_DECIMAL_20_2  = 999999999999999999.99 // Максимальне значення для DECIMAL(20,2)
+	MAX_DECIMAL_24_8  = 9999999999999999.99999999
+	MAX_DECIMAL_14_10 = 9999.9999999999
+)
+
+// Meta lists the perpetuals in the order of their contexts.
+type Meta struct {
+	Universe []struct {
+		Name       string `json:"name"`
+		IsDelisted bool   `json:"isDelisted"`
+	} `json:"universe"`
+}
+
+// AssetCtx is the current state of one perpetual.
+type AssetCtx struct {
+	Funding      string `json:"funding"` // Hourly rate
+	OpenInterest string `json:"openInterest"`
+	PrevDayPx    string `json:"prevDayPx"`
+	DayNtlVlm    string `json:"dayNtlVlm"`
+	DayBaseVlm   string `json:"dayBaseVlm"`
+	OraclePx     string `json:"oraclePx"`
+	MarkPx       string `json:"markPx"`
+}
+
+// postJSON sends body to the info endpoint and decodes the response into target.
+func postJSON(ctx context.Context, url, body string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader([]byte(body)))
+	if err != nil {
+		return fmt.Errorf("Hyperliquid error fetching %s: %w", url, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Hyperliquid error fetching %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Hyperliquid non-OK status code %d from %s", resp.StatusCode, url)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("Hyperliquid error reading response from %s: %w", url, err)
+	}
+
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("Hyperliquid error unmarshalling JSON from %s: %w", url, err)
+	}
+	return nil
+}
+
+func parseFloat(s string, field string) float64 {
+	val, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		parseSampler.Warn(logger, "parseFloat", "failed to parse float", "value", s, "field", field)
+		metrics.ParseWarnings.WithLabelValues("Hyperliquid").Inc()
+		return 0
+	}
+	return val
+}
+
+func generateNumberedPlaceholders(rows int, fieldCount int) string {
+	placeholders := make([]string, rows)
+	counter := 1
+	for i := 0; i < rows; i++ {
+		inner := make([]string, fieldCount)
+		for j := 0; j < fieldCount; j++ {
+			inner[j] = "$" + strconv.Itoa(counter)
+			counter++
+		}
+		placeholders[i] = "(" + strings.Join(inner, ", ") + ")"
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+func sanitizeDecimal(value float64, maxValue float64, precision int) float64 {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0
+	}
+
+	if value > maxValue {
+		value = maxValue
+	} else if value < -maxValue {
+		value = -maxValue
+	}
+
+	format := "%." + strconv.Itoa(precision) + "f"
+	strVal := fmt.Sprintf(format, value)
+	formattedVal, _ := strconv.ParseFloat(strVal, 64)
+	return formattedVal
+}
+
+// baseAsset returns the usual code of a perpetual. Hyperliquid prefixes
+// contracts of 1000 units with k (kPEPE), Binance and Bybit with 1000.
+func baseAsset(name string) string {
+	if rest, ok := strings.CutPrefix(name, "k"); ok && rest != "" && strings.ToUpper(rest) == rest {
+		return "1000" + rest
+	}
+	return name
+}
+
+// fetchFuturesPairs downloads the perpetuals and their contexts and builds
+// the pairs of the listed ones, ordered by symbol. Every perpetual is
+// margined and settled in USDC.
+func fetchFuturesPairs(ctx context.Context) ([]models.PairFutures, error) {
+	var response []json.RawMessage
+	if err := postJSON(ctx, baseURL+infoPath, metaAndAssetCtxsRequest, &response); err != nil {
+		return nil, err
+	}
+	if len(response) != 2 {
+		return nil, fmt.Errorf("Hyperliquid unexpected metaAndAssetCtxs response with %d elements", len(response))
+	}
+
+	var meta Meta
+	var contexts []AssetCtx
+	if err := json.Unmarshal(response[0], &meta); err != nil {
+		return nil, fmt.Errorf("Hyperliquid error unmarshalling meta: %w", err)
+	}
+	if err := json.Unmarshal(response[1], &contexts); err != nil {
+		return nil, fmt.Errorf("Hyperliquid error unmarshalling asset contexts: %w", err)
+	}
+	if len(contexts) != len(meta.Universe) {
+		return nil, fmt.Errorf("Hyperliquid got %d asset contexts for %d perpetuals", len(contexts), len(meta.Universe))
+	}
+
+	// Funding is paid at the start of every hour
+	nextFunding := now().UTC().Truncate(time.Hour).Add(time.Hour).UnixMilli()
+
+	var pairs []models.PairFutures
+	for i, perp := range meta.Universe {
+		if perp.IsDelisted {
+			continue
+		}
+		asset := contexts[i]
+		markPrice := sanitizeDecimal(parseFloat(asset.MarkPx, "markPx"), MAX_DECIMAL_18_8, 8)
+		indexPrice := sanitizeDecimal(parseFloat(asset.OraclePx, "oraclePx"), MAX_DECIMAL_18_8, 8)
+		if markPrice <= 0 || indexPrice <= 0 {
+			continue
+		}
+
+		var priceChangePercent float64
+		if prevDay := parseFloat(asset.PrevDayPx, "prevDayPx"); prevDay > 0 {
+			priceChangePercent = sanitizeDecimal((markPrice-prevDay)/prevDay*100, MAX_DECIMAL_10_2, 2)
+		}
+
+		base := baseAsset(perp.Name)
+		pairs = append(pairs, models.PairFutures{
+			PairKey:               fmt.Sprintf("%sUSDC_Hyperliquid_futures", base),
+			Symbol:                base + "USDC",
+			Exchange:              "Hyperliquid",
+			Market:                "futures",
+			MarkPrice:             markPrice,
+			IndexPrice:            indexPrice,
+			BaseAsset:             base,
+			QuoteAsset:            "USDC",
+			DisplayName:           fmt.Sprintf("%s/USDC", base),
+			FundingRatePercent:    sanitizeDecimal(models.FundingRateFromHourly(parseFloat(asset.Funding, "funding")), MAX_DECIMAL_14_10, 10),
+			NextFundingTimestamp:  int(nextFunding),
+			PriceChangePercent24h: priceChangePercent,
+			BaseVolume24h:         sanitizeDecimal(parseFloat(asset.DayBaseVlm, "dayBaseVlm"), MAX_DECIMAL_20_2, 2),
+			QuoteVolume24h:        sanitizeDecimal(parseFloat(asset.DayNtlVlm, "dayNtlVlm"), MAX_DECIMAL_20_2, 2),
+			OpenInterest:          sanitizeDecimal(parseFloat(asset.OpenInterest, "openInterest"), MAX_DECIMAL_24_8, 8),
+			UpdatedAt:             time.Now().UTC(),
+		})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Symbol < pairs[j].Symbol })
+
+	return pairs, nil
+}
+
+func UpdateAllFuturesPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchFuturesPairs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(pairs) == 0 {
+		return 0, errors.New("Hyperliquid No futures pairs to update")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("Hyperliquid Failed to begin transaction: %w", err)
+	}
+
+	placeholderStr := generateNumberedPlaceholders(len(pairs), 16)
+	query := `
+    INSERT INTO pairsfutures (pairkey, symbol, exchange, market, markprice, indexprice, baseasset, quoteasset, displayname, fundingRatePercent, nextfundingtimestamp, pricechangepercent24h, basevolume24h, quotevolume24h, openinterest, updatedat)
+    VALUES ` + placeholderStr + `
+    ON CONFLICT (pairkey) DO UPDATE SET
+        markprice = EXCLUDED.markprice,
+        indexprice = EXCLUDED.indexprice,
+        fundingRatePercent = EXCLUDED.fundingRatePercent,
+        nextfundingtimestamp = EXCLUDED.nextfundingtimestamp,
+        pricechangepercent24h = EXCLUDED.pricechangepercent24h,
+        basevolume24h = EXCLUDED.basevolume24h,
+        quotevolume24h = EXCLUDED.quotevolume24h,
+        openinterest = EXCLUDED.openinterest,
+        updatedat = EXCLUDED.updatedat
+    `
+
+	args := make([]interface{}, 0, len(pairs)*16)
+	for _, pair := range pairs {
+		args = append(args, pair.PairKey, pair.Symbol, pair.Exchange, pair.Market, pair.MarkPrice, pair.IndexPrice, pair.BaseAsset,
+			pair.QuoteAsset, pair.DisplayName, pair.FundingRatePercent, pair.NextFundingTimestamp, pair.PriceChangePercent24h,
+			pair.BaseVolume24h, pair.QuoteVolume24h, pair.OpenInterest, pair.UpdatedAt)
+	}
+
+	_, err = tx.Exec(query, args...)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("Hyperliquid Failed to execute statement: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("Hyperliquid Failed to commit transaction: %w", err)
+	}
+
+	return len(pairs), nil
+}
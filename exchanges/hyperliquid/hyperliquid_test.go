@@ -0,0 +1,88 @@
+package hyperliquid
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"Updater/exchanges/exchangetest"
+	"Updater/models"
+)
+
+func TestFetchFuturesPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		infoPath: "meta_and_asset_ctxs.json",
+	})
+	srv.SetURL(t, &baseURL)
+	now = func() time.Time { return time.UnixMilli(1718000000000) }
+	t.Cleanup(func() { now = time.Now })
+
+	pairs, err := fetchFuturesPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// kPEPE is quoted like 1000PEPE elsewhere, MATIC is delisted and NEW has
+	// no price yet; the hourly funding is stored per 8 hours
+	exchangetest.Compare(t, pairs, []models.PairFutures{
+		{
+			PairKey:               "1000PEPEUSDC_Hyperliquid_futures",
+			Symbol:                "1000PEPEUSDC",
+			Exchange:              "Hyperliquid",
+			Market:                "futures",
+			MarkPrice:             0.011245,
+			IndexPrice:            0.011234,
+			BaseAsset:             "1000PEPE",
+			QuoteAsset:            "USDC",
+			DisplayName:           "1000PEPE/USDC",
+			FundingRatePercent:    0.0001,
+			NextFundingTimestamp:  1718002800000,
+			PriceChangePercent24h: 2.23,
+			BaseVolume24h:         4000000000,
+			QuoteVolume24h:        45000000,
+			OpenInterest:          15000000000,
+		},
+		{
+			PairKey:               "BTCUSDC_Hyperliquid_futures",
+			Symbol:                "BTCUSDC",
+			Exchange:              "Hyperliquid",
+			Market:                "futures",
+			MarkPrice:             67012,
+			IndexPrice:            67005,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDC",
+			DisplayName:           "BTC/USDC",
+			FundingRatePercent:    0.0001,
+			NextFundingTimestamp:  1718002800000,
+			PriceChangePercent24h: 1.53,
+			BaseVolume24h:         35120.5,
+			QuoteVolume24h:        2345678901.25,
+			OpenInterest:          28512.34567,
+		},
+		{
+			PairKey:               "ETHUSDC_Hyperliquid_futures",
+			Symbol:                "ETHUSDC",
+			Exchange:              "Hyperliquid",
+			Market:                "futures",
+			MarkPrice:             3500.9,
+			IndexPrice:            3501.2,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "USDC",
+			DisplayName:           "ETH/USDC",
+			FundingRatePercent:    -0.0000248,
+			NextFundingTimestamp:  1718002800000,
+			PriceChangePercent24h: -1.38,
+			BaseVolume24h:         231000.25,
+			QuoteVolume24h:        812345678.4,
+			OpenInterest:          512345.678,
+		},
+	})
+
+	requests := srv.Requests()
+	body, _ := io.ReadAll(requests[0].Body)
+	if requests[0].Method != http.MethodPost || string(body) != metaAndAssetCtxsRequest {
+		t.Errorf("request = %s %s, want POST %s", requests[0].Method, body, metaAndAssetCtxsRequest)
+	}
+}
@@ -2,23 +2,37 @@
 package kraken
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"Updater/exchanges/httpclient"
+	"Updater/logging"
+	"Updater/metrics"
 	"Updater/models"
 )
 
+var (
+	logger       = logging.Exchange("Kraken")
+	parseSampler = logging.NewSampler()
+	httpClient   = httpclient.For("Kraken")
+)
+
+// baseURL is a variable so tests can point the connector at recorded responses
+var baseURL = "https://api.kraken.com"
+
 const (
-	symbolsURL = "https://api.kraken.com/0/public/AssetPairs"
-	tickerURL  = "https://api.kraken.com/0/public/Ticker"
+	symbolsPath = "/0/public/AssetPairs"
+	tickerPath  = "/0/public/Ticker"
 )
 
 type SymbolsResponse struct {
@@ -28,6 +42,8 @@ type SymbolsResponse struct {
 	} `json:"result"`
 }
 
+// TickerResponse holds the ticker arrays: c is [price, lot volume] of the
+// last trade and v is [today, last 24 hours] volume.
 type TickerResponse struct {
 	Result map[string]struct {
 		Last []string `json:"c"`
@@ -35,8 +51,12 @@ type TickerResponse struct {
 	} `json:"result"`
 }
 
-func fetchJSON(url string, target interface{}) error {
-	resp, err := http.Get(url)
+func fetchJSON(ctx context.Context, url string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("Kraken error fetching %s: %w", url, err)
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("Kraken error fetching %s: %w", url, err)
 	}
@@ -56,13 +76,16 @@ func fetchJSON(url string, target interface{}) error {
 func parseFloat(s string) float64 {
 	val, err := strconv.ParseFloat(s, 64)
 	if err != nil {
-		log.Printf("Kraken Warning: failed to parse float from %s", s)
+		parseSampler.Warn(logger, "parseFloat", "failed to parse float", "value", s)
+		metrics.ParseWarnings.WithLabelValues("Kraken").Inc()
 		return 0
 	}
 	return val
 }
 
-func UpdateAllSpotPairs(db *sql.DB) bool {
+// fetchSpotPairs downloads the asset pairs and tickers and builds the pairs,
+// ordered by symbol.
+func fetchSpotPairs(ctx context.Context) ([]models.Pair, error) {
 	var wg sync.WaitGroup
 	var symbols SymbolsResponse
 	var tickers TickerResponse
@@ -70,11 +93,11 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 
 	wg.Add(2)
 	go func() {
-		errChan <- fetchJSON(symbolsURL, &symbols)
+		errChan <- fetchJSON(ctx, baseURL+symbolsPath, &symbols)
 		wg.Done()
 	}()
 	go func() {
-		errChan <- fetchJSON(tickerURL, &tickers)
+		errChan <- fetchJSON(ctx, baseURL+tickerPath, &tickers)
 		wg.Done()
 	}()
 	wg.Wait()
@@ -82,14 +105,17 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 
 	for err := range errChan {
 		if err != nil {
-			log.Println("Kraken Error:", err)
-			return false
+			return nil, err
 		}
 	}
 
 	var pairs []models.Pair
 	for symbol, info := range symbols.Result {
 		if ticker, exists := tickers.Result[symbol]; exists {
+			if len(ticker.Last) < 1 || len(ticker.Vol) < 2 {
+				parseSampler.Warn(logger, "tickerArrays", "skipping ticker with short c or v array", "symbol", symbol)
+				continue
+			}
 			pair := models.Pair{
 				PairKey:               fmt.Sprintf("%s_Kraken_spot", symbol),
 				Symbol:                symbol,
@@ -102,24 +128,31 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 				PriceChangePercent24h: 0,
 				BaseVolume24h:         parseFloat(ticker.Vol[1]),
 				QuoteVolume24h:        0,
-				UpdatedAt:             time.Now(),
+				UpdatedAt:             time.Now().UTC(),
 			}
 			pairs = append(pairs, pair)
 		}
 	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Symbol < pairs[j].Symbol })
+
+	return pairs, nil
+}
 
-	return savePairsToDB(db, pairs)
+func UpdateAllSpotPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchSpotPairs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return savePairsToDB(ctx, db, pairs)
 }
 
-func savePairsToDB(db *sql.DB, pairs []models.Pair) bool {
+func savePairsToDB(ctx context.Context, db *sql.DB, pairs []models.Pair) (int, error) {
 	if len(pairs) == 0 {
-		log.Println("Kraken No pairs to update")
-		return false
+		return 0, errors.New("Kraken No pairs to update")
 	}
 	tx, err := db.Begin()
 	if err != nil {
-		log.Println("Kraken Failed to begin transaction:", err)
-		return false
+		return 0, fmt.Errorf("Kraken Failed to begin transaction: %w", err)
 	}
 
 	query := "INSERT INTO pairs (pairkey, symbol, exchange, market, price, baseasset, quoteasset, displayname, pricechangepercent24h, basevolume24h, quotevolume24h, updatedat, createdat) VALUES "
@@ -135,14 +168,12 @@ func savePairsToDB(db *sql.DB, pairs []models.Pair) bool {
 	_, err = tx.Exec(query, args...)
 	if err != nil {
 		tx.Rollback()
-		log.Println("Kraken Failed to execute statement:", err)
-		return false
+		return 0, fmt.Errorf("Kraken Failed to execute statement: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Println("Kraken Failed to commit transaction:", err)
-		return false
+		return 0, fmt.Errorf("Kraken Failed to commit transaction: %w", err)
 	}
 
-	return true
+	return len(pairs), nil
 }
@@ -0,0 +1,60 @@
+package kraken
+
+import (
+	"context"
+	"testing"
+
+	"Updater/exchanges/exchangetest"
+	"Updater/models"
+)
+
+func TestFetchSpotPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		symbolsPath: "asset_pairs.json",
+		tickerPath:  "ticker.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	pairs, err := fetchSpotPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The price is c[0] and the 24h volume v[1]; BROKENUSD has short c and v
+	// arrays and NEWUSD no ticker
+	exchangetest.Compare(t, pairs, []models.Pair{
+		{
+			PairKey:       "SOLUSD_Kraken_spot",
+			Symbol:        "SOLUSD",
+			Exchange:      "Kraken",
+			Market:        "spot",
+			Price:         145.11,
+			BaseAsset:     "SOL",
+			QuoteAsset:    "ZUSD",
+			DisplayName:   "SOL/ZUSD",
+			BaseVolume24h: 25000.75,
+		},
+		{
+			PairKey:       "XETHXXBT_Kraken_spot",
+			Symbol:        "XETHXXBT",
+			Exchange:      "Kraken",
+			Market:        "spot",
+			Price:         0.051234,
+			BaseAsset:     "XETH",
+			QuoteAsset:    "XXBT",
+			DisplayName:   "XETH/XXBT",
+			BaseVolume24h: 812.5,
+		},
+		{
+			PairKey:       "XXBTZUSD_Kraken_spot",
+			Symbol:        "XXBTZUSD",
+			Exchange:      "Kraken",
+			Market:        "spot",
+			Price:         67012.3,
+			BaseAsset:     "XXBT",
+			QuoteAsset:    "ZUSD",
+			DisplayName:   "XXBT/ZUSD",
+			BaseVolume24h: 2345.67890123,
+		},
+	})
+}
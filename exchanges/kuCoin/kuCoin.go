@@ -1,26 +1,52 @@
 package kucoin
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"Updater/exchanges/httpclient"
+	"Updater/logging"
+	"Updater/metrics"
 	"Updater/models"
 )
 
+var (
+	logger       = logging.Exchange("KuCoin")
+	parseSampler = logging.NewSampler()
+	httpClient   = httpclient.For("KuCoin")
+)
+
+// Base URLs are variables so tests can point the connector at recorded responses
+var (
+	baseURL        = "https://api.kucoin.com"
+	futuresBaseURL = "https://api-futures.kucoin.com"
+)
+
 const (
-	symbolsURL    = "https://api.kucoin.com/api/v1/symbols"
-	tickerURL     = "https://api.kucoin.com/api/v1/market/allTickers"
-	currenciesURL = "https://api.kucoin.com/api/v3/currencies"
+	symbolsPath    = "/api/v1/symbols"
+	tickerPath     = "/api/v1/market/allTickers"
+	currenciesPath = "/api/v3/currencies"
+
+	activeContractsPath = "/api/v1/contracts/active"
+
+	// perpetualContractType is the type of perpetual contracts, dated ones are FFICSX
+	perpetualContractType = "FFWCSX"
 )
 
+// futuresAssets maps the asset codes of KuCoin Futures to the ones used everywhere else
+var futuresAssets = map[string]string{
+	"XBT": "BTC",
+}
+
 type SymbolResponse struct {
 	Data []struct {
 		Symbol        string `json:"symbol"`
@@ -36,15 +62,53 @@ type TickerResponse struct {
 			Symbol    string `json:"symbol"`
 			Last      string `json:"last"`
 			Change24h string `json:"changeRate"`
-			BaseVol   string `json:"volValue"`
+			BaseVol   string `json:"vol"`
+			QuoteVol  string `json:"volValue"`
 		} `json:"ticker"`
 	} `json:"data"`
 }
 
-func fetchJSON(url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
+type CurrenciesResponse struct {
+	Code string `json:"code"`
+	Data []struct {
+		Currency string `json:"currency"`
+		Chains   []struct {
+			ChainName         string `json:"chainName"` // e.g. TRC20
+			ChainID           string `json:"chainId"`   // e.g. trx
+			IsDepositEnabled  bool   `json:"isDepositEnabled"`
+			IsWithdrawEnabled bool   `json:"isWithdrawEnabled"`
+		} `json:"chains"` // null for coins that cannot be moved on chain
+	} `json:"data"`
+}
+
+type ContractsResponse struct {
+	Data []struct {
+		Symbol                  string   `json:"symbol"` // e.g. XBTUSDTM
+		Type                    string   `json:"type"`
+		BaseCurrency            string   `json:"baseCurrency"`
+		QuoteCurrency           string   `json:"quoteCurrency"`
+		IsInverse               bool     `json:"isInverse"`
+		Status                  string   `json:"status"`
+		MarkPrice               float64  `json:"markPrice"`
+		IndexPrice              float64  `json:"indexPrice"`
+		LastTradePrice          float64  `json:"lastTradePrice"`
+		FundingFeeRate          *float64 `json:"fundingFeeRate"`
+		NextFundingRateDateTime int64    `json:"nextFundingRateDateTime"` // milliseconds
+		PriceChgPct             float64  `json:"priceChgPct"`
+		VolumeOf24h             float64  `json:"volumeOf24h"`   // base asset
+		TurnoverOf24h           float64  `json:"turnoverOf24h"` // quote asset
+	} `json:"data"`
+}
+
+func fetchJSON(ctx context.Context, url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
 	defer wg.Done()
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		errChan <- fmt.Errorf("KuCoin error fetching %s: %w", url, err)
+		return
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		errChan <- fmt.Errorf("KuCoin error fetching %s: %w", url, err)
 		return
@@ -70,7 +134,8 @@ func fetchJSON(url string, target interface{}, wg *sync.WaitGroup, errChan chan<
 func parseFloat(s string, d string) float64 {
 	val, err := strconv.ParseFloat(s, 64)
 	if err != nil {
-		log.Printf("KuCoin Warning: failed to parse float from %s, field: %v", s, d)
+		parseSampler.Warn(logger, "parseFloat", "failed to parse float", "value", s, "field", d)
+		metrics.ParseWarnings.WithLabelValues("KuCoin").Inc()
 		return 0
 	}
 	return val
@@ -100,24 +165,24 @@ func generateNumberedPlaceholders(rows int, fieldCount int) string {
 	return strings.Join(placeholders, ", ")
 }
 
-func UpdateAllSpotPairs(db *sql.DB) bool {
+// fetchSpotPairs downloads the tradable symbols and tickers and builds their pairs.
+func fetchSpotPairs(ctx context.Context) ([]models.Pair, error) {
 	var wg sync.WaitGroup
-	errChan := make(chan error, 1)
+	errChan := make(chan error, 2)
 
 	var symbols SymbolResponse
 	var tickerData TickerResponse
 
 	wg.Add(2)
-	go fetchJSON(symbolsURL, &symbols, &wg, errChan)
-	go fetchJSON(tickerURL, &tickerData, &wg, errChan)
+	go fetchJSON(ctx, baseURL+symbolsPath, &symbols, &wg, errChan)
+	go fetchJSON(ctx, baseURL+tickerPath, &tickerData, &wg, errChan)
 
 	wg.Wait()
 	close(errChan)
 
 	for err := range errChan {
 		if err != nil {
-			log.Printf("KuCoin Error: %v", err)
-			return false
+			return nil, err
 		}
 	}
 
@@ -144,11 +209,13 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 		price := parseFloat(t.Last, t.Symbol)
 		priceChangePercent24h := parseFloat(t.Change24h, "Price%Change") * 100
 		baseVolume24h := parseFloat(t.BaseVol, "BaseVolume24h")
+		quoteVolume24h := parseFloat(t.QuoteVol, "QuoteVolume24h")
 
 		// Limit the values to avoid numeric field overflow
 		price = limitFloat(price, -1e10, 1e10)
 		priceChangePercent24h = limitFloat(priceChangePercent24h, -1e10, 1e10)
 		baseVolume24h = limitFloat(baseVolume24h, -1e10, 1e10)
+		quoteVolume24h = limitFloat(quoteVolume24h, -1e10, 1e10)
 
 		pair := models.Pair{
 			PairKey:               fmt.Sprintf("%s_KuCoin_spot", strings.ReplaceAll(t.Symbol, "-", "")),
@@ -161,16 +228,24 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 			DisplayName:           fmt.Sprintf("%s/%s", symbolInfo.Base, symbolInfo.Quote),
 			PriceChangePercent24h: priceChangePercent24h,
 			BaseVolume24h:         baseVolume24h,
-			QuoteVolume24h:        0,
-			UpdatedAt:             time.Now(),
+			QuoteVolume24h:        quoteVolume24h,
+			UpdatedAt:             time.Now().UTC(),
 		}
 		pairs = append(pairs, pair)
 	}
 
+	return pairs, nil
+}
+
+func UpdateAllSpotPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchSpotPairs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
 	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("KuCoin Failed to begin transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("KuCoin Failed to begin transaction: %w", err)
 	}
 
 	placeholderStr := generateNumberedPlaceholders(len(pairs), 13)
@@ -186,8 +261,7 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 	`
 	stmt, err := tx.Prepare(query)
 	if err != nil {
-		log.Printf("KuCoin Failed to prepare statement: %v", err)
-		return false
+		return 0, fmt.Errorf("KuCoin Failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
@@ -200,14 +274,216 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 	_, err = stmt.Exec(args...)
 	if err != nil {
 		tx.Rollback()
-		log.Printf("KuCoin Failed to execute statement: %v", err)
-		return false
+		return 0, fmt.Errorf("KuCoin Failed to execute statement: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("KuCoin Failed to commit transaction: %w", err)
+	}
+
+	return len(pairs), nil
+}
+
+// fetchNetworks downloads the deposit and withdrawal status of every coin chain.
+func fetchNetworks(ctx context.Context) ([]models.Network, error) {
+	var wg sync.WaitGroup
+	errChan := make(chan error, 1)
+
+	var currencies CurrenciesResponse
+
+	wg.Add(1)
+	go fetchJSON(ctx, baseURL+currenciesPath, &currencies, &wg, errChan)
+
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var networks []models.Network
+	for _, coin := range currencies.Data {
+		for _, chain := range coin.Chains {
+			// chainId matches the network codes of the other exchanges (TRX, ETH, BSC)
+			network := strings.ToUpper(chain.ChainID)
+			if network == "" {
+				network = chain.ChainName
+			}
+			networks = append(networks, models.Network{
+				CoinKey:        fmt.Sprintf("%s_KuCoin_%s", coin.Currency, network),
+				Coin:           coin.Currency,
+				Exchange:       "KuCoin",
+				Network:        network,
+				NetworkName:    chain.ChainName,
+				DepositEnable:  chain.IsDepositEnabled,
+				WithdrawEnable: chain.IsWithdrawEnabled,
+				UpdatedAt:      time.Now().UTC(),
+			})
+		}
+	}
+
+	return networks, nil
+}
+
+func UpdateAllNetworks(ctx context.Context, db *sql.DB) (int, error) {
+	networks, err := fetchNetworks(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(networks) == 0 {
+		logger.Debug("no network data to update")
+		return 0, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("KuCoin Failed to begin transaction: %w", err)
+	}
+
+	placeholderStr := generateNumberedPlaceholders(len(networks), 8)
+	query := `
+	INSERT INTO nets (coinKey, coin, exchange, network, networkName, depositEnable, withdrawEnable, updatedAt)
+	VALUES ` + placeholderStr + `
+	ON CONFLICT (coinKey) DO UPDATE SET
+		networkName = EXCLUDED.networkName,
+		depositEnable = EXCLUDED.depositEnable,
+		withdrawEnable = EXCLUDED.withdrawEnable,
+		updatedAt = EXCLUDED.updatedAt
+	`
+
+	args := make([]interface{}, 0, len(networks)*8)
+	for _, n := range networks {
+		args = append(args, n.CoinKey, n.Coin, n.Exchange, n.Network, n.NetworkName, n.DepositEnable, n.WithdrawEnable, n.UpdatedAt)
+	}
+
+	_, err = tx.Exec(query, args...)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("KuCoin Failed to execute statement: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("KuCoin Failed to commit transaction: %w", err)
+	}
+
+	return len(networks), nil
+}
+
+// futuresAsset returns the usual code of a KuCoin Futures asset.
+func futuresAsset(asset string) string {
+	if canonical, ok := futuresAssets[asset]; ok {
+		return canonical
+	}
+	return asset
+}
+
+// fetchFuturesPairs downloads the open linear perpetual contracts and builds their pairs.
+func fetchFuturesPairs(ctx context.Context) ([]models.PairFutures, error) {
+	var wg sync.WaitGroup
+	errChan := make(chan error, 1)
+
+	var contracts ContractsResponse
+
+	wg.Add(1)
+	go fetchJSON(ctx, futuresBaseURL+activeContractsPath, &contracts, &wg, errChan)
+
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var pairs []models.PairFutures
+	for _, c := range contracts.Data {
+		if c.Type != perpetualContractType || c.IsInverse || c.Status != "Open" || c.FundingFeeRate == nil {
+			continue
+		}
+
+		// XBTUSDTM becomes BTCUSDT, like the spot symbol
+		baseAsset := futuresAsset(c.BaseCurrency)
+		quoteAsset := futuresAsset(c.QuoteCurrency)
+		symbol := baseAsset + quoteAsset
+
+		pair := models.PairFutures{
+			PairKey:               fmt.Sprintf("%s_KuCoin_futures", symbol),
+			Symbol:                symbol,
+			Exchange:              "KuCoin",
+			Market:                "futures",
+			MarkPrice:             limitFloat(c.MarkPrice, -1e10, 1e10),
+			IndexPrice:            limitFloat(c.IndexPrice, -1e10, 1e10),
+			BaseAsset:             baseAsset,
+			QuoteAsset:            quoteAsset,
+			DisplayName:           fmt.Sprintf("%s/%s", baseAsset, quoteAsset),
+			FundingRatePercent:    *c.FundingFeeRate,
+			NextFundingTimestamp:  int(c.NextFundingRateDateTime),
+			PriceChangePercent24h: limitFloat(c.PriceChgPct*100, -1e10, 1e10),
+			BaseVolume24h:         limitFloat(c.VolumeOf24h, -1e10, 1e10),
+			QuoteVolume24h:        limitFloat(c.TurnoverOf24h, -1e10, 1e10),
+			UpdatedAt:             time.Now().UTC(),
+			CreatedAt:             time.Now(),
+		}
+		pairs = append(pairs, pair)
+	}
+
+	if len(pairs) == 0 {
+		return nil, errors.New("KuCoin No futures pairs to update")
+	}
+
+	return pairs, nil
+}
+
+func UpdateAllFuturesPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchFuturesPairs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("KuCoin Failed to begin transaction: %w", err)
+	}
+
+	placeholderStr := generateNumberedPlaceholders(len(pairs), 16)
+	query := `
+	INSERT INTO pairsfutures (pairkey, symbol, exchange, market, markprice, indexprice, baseasset, quoteasset, displayname, fundingRatePercent, nextfundingtimestamp, pricechangepercent24h, basevolume24h, quotevolume24h, updatedat, createdat)
+	VALUES ` + placeholderStr + `
+	ON CONFLICT (pairkey) DO UPDATE SET
+		markprice = EXCLUDED.markprice,
+		indexprice = EXCLUDED.indexprice,
+		fundingRatePercent = EXCLUDED.fundingRatePercent,
+		nextfundingtimestamp = EXCLUDED.nextfundingtimestamp,
+		pricechangepercent24h = EXCLUDED.pricechangepercent24h,
+		basevolume24h = EXCLUDED.basevolume24h,
+		quotevolume24h = EXCLUDED.quotevolume24h,
+		updatedat = EXCLUDED.updatedat
+	`
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return 0, fmt.Errorf("KuCoin Failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	args := make([]interface{}, 0, len(pairs)*16)
+	for _, pair := range pairs {
+		args = append(args, pair.PairKey, pair.Symbol, pair.Exchange, pair.Market, pair.MarkPrice, pair.IndexPrice, pair.BaseAsset,
+			pair.QuoteAsset, pair.DisplayName, pair.FundingRatePercent, pair.NextFundingTimestamp, pair.PriceChangePercent24h,
+			pair.BaseVolume24h, pair.QuoteVolume24h, pair.UpdatedAt, pair.CreatedAt)
+	}
+
+	_, err = stmt.Exec(args...)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("KuCoin Failed to execute statement: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("KuCoin Failed to commit transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("KuCoin Failed to commit transaction: %w", err)
 	}
 
-	return true
+	return len(pairs), nil
 }
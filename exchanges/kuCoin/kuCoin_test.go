@@ -0,0 +1,133 @@
+package kucoin
+
+import (
+	"context"
+	"testing"
+
+	"Updater/exchanges/exchangetest"
+	"Updater/models"
+)
+
+func TestFetchSpotPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		symbolsPath: "symbols.json",
+		tickerPath:  "all_tickers.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	pairs, err := fetchSpotPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// LUNC-USDT has trading disabled and NEW-USDT is not listed yet; SHIB's
+	// base volume is clamped to the column range
+	exchangetest.Compare(t, pairs, []models.Pair{
+		{
+			PairKey:               "BTCUSDT_KuCoin_spot",
+			Symbol:                "BTCUSDT",
+			Exchange:              "KuCoin",
+			Market:                "spot",
+			Price:                 67012.3,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDT",
+			DisplayName:           "BTC/USDT",
+			PriceChangePercent24h: -0.78125,
+			BaseVolume24h:         4321.12345678,
+			QuoteVolume24h:        289456789.12345,
+		},
+		{
+			PairKey:               "ETHBTC_KuCoin_spot",
+			Symbol:                "ETHBTC",
+			Exchange:              "KuCoin",
+			Market:                "spot",
+			Price:                 0.051234,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "BTC",
+			DisplayName:           "ETH/BTC",
+			PriceChangePercent24h: 25,
+			BaseVolume24h:         812.5,
+			QuoteVolume24h:        41.62,
+		},
+		{
+			PairKey:               "SHIBUSDT_KuCoin_spot",
+			Symbol:                "SHIBUSDT",
+			Exchange:              "KuCoin",
+			Market:                "spot",
+			Price:                 0.00001812,
+			BaseAsset:             "SHIB",
+			QuoteAsset:            "USDT",
+			DisplayName:           "SHIB/USDT",
+			PriceChangePercent24h: 50,
+			BaseVolume24h:         1e10,
+			QuoteVolume24h:        2234567.89,
+		},
+	})
+}
+
+func TestFetchNetworks(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		currenciesPath: "currencies.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	networks, err := fetchNetworks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// KCS-POINTS has no chains
+	exchangetest.Compare(t, networks, []models.Network{
+		{CoinKey: "USDT_KuCoin_TRX", Coin: "USDT", Exchange: "KuCoin", Network: "TRX", NetworkName: "TRC20", DepositEnable: true, WithdrawEnable: true},
+		{CoinKey: "USDT_KuCoin_ETH", Coin: "USDT", Exchange: "KuCoin", Network: "ETH", NetworkName: "ERC20", DepositEnable: true},
+		{CoinKey: "BTC_KuCoin_BTC", Coin: "BTC", Exchange: "KuCoin", Network: "BTC", NetworkName: "BTC", WithdrawEnable: true},
+	})
+}
+
+func TestFetchFuturesPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		activeContractsPath: "active_contracts.json",
+	})
+	srv.SetURL(t, &futuresBaseURL)
+
+	pairs, err := fetchFuturesPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// XBTUSDM is inverse, XBTMM24 is dated and OLDUSDTM is paused; XBT is BTC
+	exchangetest.Compare(t, pairs, []models.PairFutures{
+		{
+			PairKey:               "BTCUSDT_KuCoin_futures",
+			Symbol:                "BTCUSDT",
+			Exchange:              "KuCoin",
+			Market:                "futures",
+			MarkPrice:             67049.87,
+			IndexPrice:            67041.2,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDT",
+			DisplayName:           "BTC/USDT",
+			FundingRatePercent:    0.0001,
+			NextFundingTimestamp:  1718006400000,
+			PriceChangePercent24h: 1.25,
+			BaseVolume24h:         23456.789,
+			QuoteVolume24h:        1572345678.9,
+		},
+		{
+			PairKey:               "ETHUSDC_KuCoin_futures",
+			Symbol:                "ETHUSDC",
+			Exchange:              "KuCoin",
+			Market:                "futures",
+			MarkPrice:             3510.05,
+			IndexPrice:            3509.9,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "USDC",
+			DisplayName:           "ETH/USDC",
+			FundingRatePercent:    -0.000042,
+			NextFundingTimestamp:  1718006400000,
+			PriceChangePercent24h: -2.15,
+			BaseVolume24h:         1520.5,
+			QuoteVolume24h:        5337112.75,
+		},
+	})
+}
@@ -1,12 +1,17 @@
 package mexc
 
 import (
+	"Updater/exchanges/httpclient"
+	"Updater/exchanges/signing"
+	"Updater/logging"
+	"Updater/metrics"
 	"Updater/models"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"math"
 	"net/http"
 	"strconv"
@@ -15,10 +20,24 @@ import (
 	"time"
 )
 
+var (
+	logger       = logging.Exchange("MEXC")
+	parseSampler = logging.NewSampler()
+	httpClient   = httpclient.For("MEXC")
+)
+
+// Base URLs are variables so tests can point the connector at recorded responses
+var (
+	spotBaseURL    = "https://api.mexc.com"
+	futuresBaseURL = "https://contract.mexc.com"
+)
+
 const (
-	symbolsURL       = "https://api.mexc.com/api/v3/exchangeInfo"
-	tickerURL        = "https://api.mexc.com/api/v3/ticker/24hr"
-	futuresTickerURL = "https://contract.mexc.com/api/v1/contract/ticker"
+	symbolsPath       = "/api/v3/exchangeInfo"
+	tickerPath        = "/api/v3/ticker/24hr"
+	futuresTickerPath = "/api/v1/contract/ticker"
+	capitalConfigPath = "/api/v3/capital/config/getall"
+	serverTimePath    = "/api/v3/time"
 )
 
 type SymbolResponse struct {
@@ -48,10 +67,27 @@ type FuturesTickerResponse struct {
 	} `json:"data"`
 }
 
-func fetchJSON(url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
+// CapitalConfig is the deposit and withdrawal status of the networks of one coin.
+type CapitalConfig struct {
+	Coin        string `json:"coin"`
+	NetworkList []struct {
+		Name           string `json:"name"`
+		Network        string `json:"network"`
+		NetWork        string `json:"netWork"`
+		DepositEnable  bool   `json:"depositEnable"`
+		WithdrawEnable bool   `json:"withdrawEnable"`
+	} `json:"networkList"`
+}
+
+func fetchJSON(ctx context.Context, url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
 	defer wg.Done()
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		errChan <- fmt.Errorf("MEXC error fetching %s: %w", url, err)
+		return
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		errChan <- fmt.Errorf("MEXC error fetching %s: %w", url, err)
 		return
@@ -77,7 +113,8 @@ func fetchJSON(url string, target interface{}, wg *sync.WaitGroup, errChan chan<
 func parseFloat(s string) float64 {
 	val, err := strconv.ParseFloat(s, 64)
 	if err != nil {
-		log.Printf("MEXC Warning: failed to parse float from %s", s)
+		parseSampler.Warn(logger, "parseFloat", "failed to parse float", "value", s)
+		metrics.ParseWarnings.WithLabelValues("MEXC").Inc()
 		return 0
 	}
 	return val
@@ -131,7 +168,8 @@ func generateNumberedPlaceholders(rows int, fieldCount int) string {
 	return strings.Join(placeholders, ", ")
 }
 
-func UpdateAllSpotPairs(db *sql.DB) bool {
+// fetchSpotPairs downloads the spot symbols and tickers and builds their pairs.
+func fetchSpotPairs(ctx context.Context) ([]models.Pair, error) {
 	var wg sync.WaitGroup
 	errChan := make(chan error, 2)
 
@@ -139,16 +177,15 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 	var tickerData []TickerResponse
 
 	wg.Add(2)
-	go fetchJSON(symbolsURL, &symbols, &wg, errChan)
-	go fetchJSON(tickerURL, &tickerData, &wg, errChan)
+	go fetchJSON(ctx, spotBaseURL+symbolsPath, &symbols, &wg, errChan)
+	go fetchJSON(ctx, spotBaseURL+tickerPath, &tickerData, &wg, errChan)
 
 	wg.Wait()
 	close(errChan)
 
 	for err := range errChan {
 		if err != nil {
-			log.Printf("MEXC Error: %v", err)
-			return false
+			return nil, err
 		}
 	}
 
@@ -179,7 +216,6 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 
 		// Перевірка на неприпустимі значення для полів price, baseVolume і quoteVolume
 		if price <= 0 || math.IsNaN(price) || math.IsInf(price, 0) {
-			// log.Printf("Skipping pair %s due to invalid price", t.Symbol)
 			continue
 		}
 
@@ -195,20 +231,27 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 			PriceChangePercent24h: priceChangePercent24h,
 			BaseVolume24h:         baseVolume24h,
 			QuoteVolume24h:        quoteVolume24h,
-			UpdatedAt:             time.Now(),
+			UpdatedAt:             time.Now().UTC(),
 		}
 		pairs = append(pairs, pair)
 	}
 
 	if len(pairs) == 0 {
-		log.Printf("MEXC No pairs to update")
-		return false
+		return nil, errors.New("MEXC No pairs to update")
+	}
+
+	return pairs, nil
+}
+
+func UpdateAllSpotPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchSpotPairs(ctx)
+	if err != nil {
+		return 0, err
 	}
 
 	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("MEXC Failed to begin transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("MEXC Failed to begin transaction: %w", err)
 	}
 
 	placeholderStr := generateNumberedPlaceholders(len(pairs), 12)
@@ -224,8 +267,7 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
     `
 	stmt, err := tx.Prepare(query)
 	if err != nil {
-		log.Printf("MEXC Failed to prepare statement: %v", err)
-		return false
+		return 0, fmt.Errorf("MEXC Failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
@@ -238,34 +280,32 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 	_, err = stmt.Exec(args...)
 	if err != nil {
 		tx.Rollback()
-		log.Printf("MEXC Failed to execute statement: %v", err)
-		return false
+		return 0, fmt.Errorf("MEXC Failed to execute statement: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("MEXC Failed to commit transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("MEXC Failed to commit transaction: %w", err)
 	}
 
-	return true
+	return len(pairs), nil
 }
 
-func UpdateAllFuturesPairs(db *sql.DB) bool {
+// fetchFuturesPairs downloads the perpetual contract tickers and builds their pairs.
+func fetchFuturesPairs(ctx context.Context) ([]models.PairFutures, error) {
 	var wg sync.WaitGroup
 	errChan := make(chan error, 1)
 
 	var futuresData FuturesTickerResponse
 
 	wg.Add(1)
-	go fetchJSON(futuresTickerURL, &futuresData, &wg, errChan)
+	go fetchJSON(ctx, futuresBaseURL+futuresTickerPath, &futuresData, &wg, errChan)
 
 	wg.Wait()
 	close(errChan)
 
 	for err := range errChan {
 		if err != nil {
-			log.Printf("MEXC Error: %v", err)
-			return false
+			return nil, err
 		}
 	}
 
@@ -274,7 +314,7 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
 		// Split symbol to get baseAsset and quoteAsset
 		symbolParts := strings.Split(data.Symbol, "_")
 		if len(symbolParts) != 2 {
-			log.Printf("MEXC Warning: Invalid symbol format %s", data.Symbol)
+			parseSampler.Warn(logger, "symbolFormat", "invalid symbol format", "symbol", data.Symbol)
 			continue
 		}
 		baseAsset := symbolParts[0]
@@ -300,20 +340,27 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
 			PriceChangePercent24h: 0,                                // Not provided in the endpoint
 			BaseVolume24h:         formatFloat(data.Volume24, 2),
 			QuoteVolume24h:        formatFloat(quoteVolume24h, 2),
-			UpdatedAt:             time.Now(),
+			UpdatedAt:             time.Now().UTC(),
 		}
 		pairs = append(pairs, pair)
 	}
 
 	if len(pairs) == 0 {
-		log.Printf("MEXC No futures pairs to update")
-		return false
+		return nil, errors.New("MEXC No futures pairs to update")
+	}
+
+	return pairs, nil
+}
+
+func UpdateAllFuturesPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchFuturesPairs(ctx)
+	if err != nil {
+		return 0, err
 	}
 
 	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("MEXC Failed to begin transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("MEXC Failed to begin transaction: %w", err)
 	}
 
 	placeholderStr := generateNumberedPlaceholders(len(pairs), 15)
@@ -331,8 +378,7 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
     `
 	stmt, err := tx.Prepare(query)
 	if err != nil {
-		log.Printf("MEXC Failed to prepare statement: %v", err)
-		return false
+		return 0, fmt.Errorf("MEXC Failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
@@ -361,14 +407,143 @@ func UpdateAllFuturesPairs(db *sql.DB) bool {
 	_, err = stmt.Exec(args...)
 	if err != nil {
 		tx.Rollback()
-		log.Printf("MEXC Failed to execute statement: %v", err)
-		return false
+		return 0, fmt.Errorf("MEXC Failed to execute statement: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("MEXC Failed to commit transaction: %w", err)
+	}
+
+	return len(pairs), nil
+}
+
+// serverClock corrects signed request timestamps for the local clock skew.
+var serverClock = signing.NewClock("MEXC", getServerTime)
+
+// fetchNetworks downloads the deposit and withdrawal status of every coin network.
+func fetchNetworks(ctx context.Context, creds signing.Credentials) ([]models.Network, error) {
+	if !creds.Configured() {
+		return nil, fmt.Errorf("MEXC error: %w", signing.ErrNoCredentials)
+	}
+
+	serverTime, err := serverClock.Now(ctx)
+	if err != nil {
+		return nil, err
+	}
+	queryString := fmt.Sprintf("timestamp=%d", serverTime.UnixMilli())
+	signature := signing.HMACSHA256Hex(creds.Secret, queryString)
+	urlWithSignature := fmt.Sprintf("%s?%s&signature=%s", spotBaseURL+capitalConfigPath, queryString, signature)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlWithSignature, nil)
+	if err != nil {
+		return nil, fmt.Errorf("MEXC error creating request: %w", err)
+	}
+	req.Header.Set("X-MEXC-APIKEY", creds.Key)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("MEXC error fetching capital config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// A rejected timestamp is the usual cause, measure the offset again on the next run
+		serverClock.Invalidate()
+		return nil, fmt.Errorf("MEXC non-OK status code %d from %s", resp.StatusCode, spotBaseURL+capitalConfigPath)
+	}
+
+	var coins []CapitalConfig
+	if err := json.NewDecoder(resp.Body).Decode(&coins); err != nil {
+		return nil, fmt.Errorf("MEXC error unmarshalling JSON: %w", err)
+	}
+
+	var networks []models.Network
+	for _, coin := range coins {
+		for _, n := range coin.NetworkList {
+			// netWork holds the short network code, network the older display form
+			network := n.NetWork
+			if network == "" {
+				network = n.Network
+			}
+			networks = append(networks, models.Network{
+				CoinKey:        fmt.Sprintf("%s_MEXC_%s", coin.Coin, network),
+				Coin:           coin.Coin,
+				Exchange:       "MEXC",
+				Network:        network,
+				NetworkName:    n.Name,
+				DepositEnable:  n.DepositEnable,
+				WithdrawEnable: n.WithdrawEnable,
+				UpdatedAt:      time.Now().UTC(),
+			})
+		}
+	}
+
+	return networks, nil
+}
+
+func UpdateAllNetworks(ctx context.Context, db *sql.DB, creds signing.Credentials) (int, error) {
+	networks, err := fetchNetworks(ctx, creds)
+	if err != nil {
+		return 0, err
+	}
+	if len(networks) == 0 {
+		logger.Debug("no network data to update")
+		return 0, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("MEXC Failed to begin transaction: %w", err)
+	}
+
+	placeholderStr := generateNumberedPlaceholders(len(networks), 8)
+	query := `
+	INSERT INTO nets (coinKey, coin, exchange, network, networkName, depositEnable, withdrawEnable, updatedAt)
+	VALUES ` + placeholderStr + `
+	ON CONFLICT (coinKey) DO UPDATE SET
+		networkName = EXCLUDED.networkName,
+		depositEnable = EXCLUDED.depositEnable,
+		withdrawEnable = EXCLUDED.withdrawEnable,
+		updatedAt = EXCLUDED.updatedAt
+	`
+
+	args := make([]interface{}, 0, len(networks)*8)
+	for _, n := range networks {
+		args = append(args, n.CoinKey, n.Coin, n.Exchange, n.Network, n.NetworkName, n.DepositEnable, n.WithdrawEnable, n.UpdatedAt)
+	}
+
+	_, err = tx.Exec(query, args...)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("MEXC Failed to execute statement: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("MEXC Failed to commit transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("MEXC Failed to commit transaction: %w", err)
+	}
+
+	return len(networks), nil
+}
+
+func getServerTime(ctx context.Context) (time.Time, error) {
+	var wg sync.WaitGroup
+	errChan := make(chan error, 1)
+
+	var result struct {
+		ServerTime int64 `json:"serverTime"`
+	}
+
+	wg.Add(1)
+	go fetchJSON(ctx, spotBaseURL+serverTimePath, &result, &wg, errChan)
+
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		if err != nil {
+			return time.Time{}, err
+		}
 	}
 
-	return true
+	return time.UnixMilli(result.ServerTime), nil
 }
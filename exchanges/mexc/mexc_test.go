@@ -0,0 +1,128 @@
+package mexc
+
+import (
+	"context"
+	"testing"
+
+	"Updater/exchanges/exchangetest"
+	"Updater/exchanges/signing"
+	"Updater/models"
+)
+
+func TestFetchSpotPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		symbolsPath: "exchange_info.json",
+		tickerPath:  "ticker_24hr.json",
+	})
+	srv.SetURL(t, &spotBaseURL)
+
+	pairs, err := fetchSpotPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// GONEUSDT is not tradable on spot and ZEROUSDT has no price
+	exchangetest.Compare(t, pairs, []models.Pair{
+		{
+			PairKey:               "BTCUSDT_MEXC_spot",
+			Symbol:                "BTCUSDT",
+			Exchange:              "MEXC",
+			Market:                "spot",
+			Price:                 67012.34567891,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDT",
+			DisplayName:           "BTC/USDT",
+			PriceChangePercent24h: -0.01,
+			BaseVolume24h:         2345.68,
+			QuoteVolume24h:        157234567.89,
+		},
+		{
+			PairKey:               "ETHBTC_MEXC_spot",
+			Symbol:                "ETHBTC",
+			Exchange:              "MEXC",
+			Market:                "spot",
+			Price:                 0.051234,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "BTC",
+			DisplayName:           "ETH/BTC",
+			PriceChangePercent24h: 0.02,
+			BaseVolume24h:         812.5,
+			QuoteVolume24h:        41.62,
+		},
+	})
+}
+
+func TestFetchFuturesPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		futuresTickerPath: "contract_ticker.json",
+	})
+	srv.SetURL(t, &futuresBaseURL)
+
+	pairs, err := fetchFuturesPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// STOCKINDEX is not a BASE_QUOTE contract; the quote volume is volume24 times the fair price
+	exchangetest.Compare(t, pairs, []models.PairFutures{
+		{
+			PairKey:            "BTCUSDT_MEXC_futures",
+			Symbol:             "BTCUSDT",
+			Exchange:           "MEXC",
+			Market:             "futures",
+			MarkPrice:          67051.12,
+			IndexPrice:         67040.55,
+			BaseAsset:          "BTC",
+			QuoteAsset:         "USDT",
+			DisplayName:        "BTC/USDT",
+			FundingRatePercent: 0.0001,
+			BaseVolume24h:      1234.5,
+			QuoteVolume24h:     82774607.64,
+		},
+		{
+			PairKey:            "ETHUSDT_MEXC_futures",
+			Symbol:             "ETHUSDT",
+			Exchange:           "MEXC",
+			Market:             "futures",
+			MarkPrice:          3510.25,
+			IndexPrice:         3509.8,
+			BaseAsset:          "ETH",
+			QuoteAsset:         "USDT",
+			DisplayName:        "ETH/USDT",
+			FundingRatePercent: -0.000025,
+			BaseVolume24h:      20000,
+			QuoteVolume24h:     70205000,
+		},
+	})
+}
+
+func TestFetchNetworks(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		serverTimePath:    "server_time.json",
+		capitalConfigPath: "capital_config_getall.json",
+	})
+	srv.SetURL(t, &spotBaseURL)
+	serverClock.Invalidate()
+
+	networks, err := fetchNetworks(context.Background(), signing.Credentials{Key: "key", Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exchangetest.Compare(t, networks, []models.Network{
+		{CoinKey: "USDT_MEXC_ERC20", Coin: "USDT", Exchange: "MEXC", Network: "ERC20", NetworkName: "Ethereum(ERC20)", DepositEnable: true, WithdrawEnable: true},
+		{CoinKey: "USDT_MEXC_TRC20", Coin: "USDT", Exchange: "MEXC", Network: "TRC20", NetworkName: "Tron(TRC20)", WithdrawEnable: true},
+		{CoinKey: "BTC_MEXC_BTC", Coin: "BTC", Exchange: "MEXC", Network: "BTC", NetworkName: "Bitcoin", DepositEnable: true},
+	})
+
+	requests := srv.Requests()
+	signed := requests[len(requests)-1]
+	if got := signed.Header.Get("X-MEXC-APIKEY"); got != "key" {
+		t.Errorf("X-MEXC-APIKEY = %q, want %q", got, "key")
+	}
+	query := signed.URL.Query()
+	want := signing.HMACSHA256Hex("secret", "timestamp="+query.Get("timestamp"))
+	if query.Get("timestamp") != "1718000000000" || query.Get("signature") != want {
+		t.Errorf("signed query = %s, want the server timestamp signed with the secret", signed.URL.RawQuery)
+	}
+}
@@ -1,23 +1,48 @@
 package okx
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"math"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"Updater/exchanges/httpclient"
+	"Updater/exchanges/signing"
+	"Updater/logging"
+	"Updater/metrics"
 	"Updater/models"
 )
 
+var (
+	logger       = logging.Exchange("OKX")
+	parseSampler = logging.NewSampler()
+	httpClient   = httpclient.For("OKX")
+)
+
+// baseURL is a variable so tests can point the connector at recorded responses
+var baseURL = "https://www.okx.com"
+
 const (
-	instrumentsURL   = "https://www.okx.com/api/v5/market/tickers?instType=SPOT"
+	instrumentsPath      = "/api/v5/market/tickers?instType=SPOT"
+	swapInstrumentsPath  = "/api/v5/public/instruments?instType=SWAP"
+	swapTickersPath      = "/api/v5/market/tickers?instType=SWAP"
+	markPricePath        = "/api/v5/public/mark-price?instType=SWAP"
+	fundingRatePath      = "/api/v5/public/funding-rate?instId=ANY"
+	indexTickersUSDTPath = "/api/v5/market/index-tickers?quoteCcy=USDT"
+	indexTickersUSDCPath = "/api/v5/market/index-tickers?quoteCcy=USDC"
+	indexTickersUSDPath  = "/api/v5/market/index-tickers?quoteCcy=USD"
+	currenciesPath       = "/api/v5/asset/currencies"
+	serverTimePath       = "/api/v5/public/time"
+
 	MAX_DECIMAL_18_8 = 9999999999.99999999   // Максимальне значення для DECIMAL(18,8)
 	MAX_DECIMAL_10_2 = 99999999.99           // Максимальне значення для DECIMAL(10,2)
 	MAX_DECIMAL_20_2 = 999999999999999999.99 // Максимальне значення для DECIMAL(20,2)
@@ -35,10 +60,64 @@ type TickerResponse struct {
 	} `json:"data"`
 }
 
-func fetchJSON(url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
+type SwapInstrumentsResponse struct {
+	Code string `json:"code"`
+	Data []struct {
+		InstID    string `json:"instId"`
+		Uly       string `json:"uly"` // underlying, e.g. BTC-USDT
+		CtType    string `json:"ctType"`
+		CtVal     string `json:"ctVal"` // contract value, in the base coin if linear and in USD if inverse
+		SettleCcy string `json:"settleCcy"`
+		State     string `json:"state"`
+	} `json:"data"`
+}
+
+type MarkPriceResponse struct {
+	Code string `json:"code"`
+	Data []struct {
+		InstID string `json:"instId"`
+		MarkPx string `json:"markPx"`
+	} `json:"data"`
+}
+
+type FundingRateResponse struct {
+	Code string `json:"code"`
+	Data []struct {
+		InstID      string `json:"instId"`
+		FundingRate string `json:"fundingRate"`
+		FundingTime string `json:"fundingTime"` // when the current rate is settled, in milliseconds
+	} `json:"data"`
+}
+
+type IndexTickersResponse struct {
+	Code string `json:"code"`
+	Data []struct {
+		InstID string `json:"instId"`
+		IdxPx  string `json:"idxPx"`
+	} `json:"data"`
+}
+
+// CurrenciesResponse is the deposit and withdrawal status of every currency chain.
+type CurrenciesResponse struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data []struct {
+		Ccy    string `json:"ccy"`
+		Chain  string `json:"chain"`
+		CanDep bool   `json:"canDep"`
+		CanWd  bool   `json:"canWd"`
+	} `json:"data"`
+}
+
+func fetchJSON(ctx context.Context, url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
 	defer wg.Done()
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		errChan <- fmt.Errorf("OKX error fetching %s: %w", url, err)
+		return
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		errChan <- fmt.Errorf("OKX error fetching %s: %w", url, err)
 		return
@@ -67,7 +146,8 @@ func parseFloat(s string, sym string) float64 {
 	}
 	val, err := strconv.ParseFloat(s, 64)
 	if err != nil {
-		log.Printf("OKX Warning: failed to parse float from %s: %v. Symbol: %s", s, err, sym)
+		parseSampler.Warn(logger, "parseFloat", "failed to parse float", "value", s, "symbol", sym, "error", err)
+		metrics.ParseWarnings.WithLabelValues("OKX").Inc()
 		return 0
 	}
 	return val
@@ -111,22 +191,22 @@ func calculatePercentChange(open, close float64) float64 {
 	return ((close - open) / open) * 100
 }
 
-func UpdateAllSpotPairs(db *sql.DB) bool {
+// fetchSpotPairs downloads the spot tickers and builds their pairs.
+func fetchSpotPairs(ctx context.Context) ([]models.Pair, error) {
 	var wg sync.WaitGroup
 	errChan := make(chan error, 1)
 
 	var tickerData TickerResponse
 
 	wg.Add(1)
-	go fetchJSON(instrumentsURL, &tickerData, &wg, errChan)
+	go fetchJSON(ctx, baseURL+instrumentsPath, &tickerData, &wg, errChan)
 
 	wg.Wait()
 	close(errChan)
 
 	for err := range errChan {
 		if err != nil {
-			log.Printf("Error: %v", err)
-			return false
+			return nil, err
 		}
 	}
 
@@ -170,15 +250,23 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 			PriceChangePercent24h: priceChangePercent,
 			BaseVolume24h:         baseVolume,
 			QuoteVolume24h:        quoteVolume,
-			UpdatedAt:             time.Now(),
+			UpdatedAt:             time.Now().UTC(),
 		}
 		pairs = append(pairs, pair)
 	}
 
+	return pairs, nil
+}
+
+func UpdateAllSpotPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchSpotPairs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
 	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("OKX Failed to begin transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("OKX Failed to begin transaction: %w", err)
 	}
 
 	placeholderStr := generateNumberedPlaceholders(len(pairs), 12)
@@ -194,8 +282,7 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
     `
 	stmt, err := tx.Prepare(query)
 	if err != nil {
-		log.Printf("OKX Failed to prepare statement: %v", err)
-		return false
+		return 0, fmt.Errorf("OKX Failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
@@ -208,14 +295,336 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 	_, err = stmt.Exec(args...)
 	if err != nil {
 		tx.Rollback()
-		log.Printf("OKX Failed to execute statement: %v", err)
-		return false
+		return 0, fmt.Errorf("OKX Failed to execute statement: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("OKX Failed to commit transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("OKX Failed to commit transaction: %w", err)
+	}
+
+	return len(pairs), nil
+}
+
+// fetchFuturesPairs downloads the USDT and USDC margined and the coin margined
+// (inverse) perpetual swaps and builds their pairs. The inverse swaps need the
+// USD index; when it fails they are skipped and the linear swaps still returned.
+func fetchFuturesPairs(ctx context.Context) ([]models.PairFutures, error) {
+	var wg sync.WaitGroup
+	errChan := make(chan error, 6)
+	inverseErrChan := make(chan error, 1)
+
+	var instruments SwapInstrumentsResponse
+	var tickers TickerResponse
+	var markPrices MarkPriceResponse
+	var fundingRates FundingRateResponse
+	var indexUSDT, indexUSDC, indexUSD IndexTickersResponse
+
+	wg.Add(7)
+	go fetchJSON(ctx, baseURL+swapInstrumentsPath, &instruments, &wg, errChan)
+	go fetchJSON(ctx, baseURL+swapTickersPath, &tickers, &wg, errChan)
+	go fetchJSON(ctx, baseURL+markPricePath, &markPrices, &wg, errChan)
+	go fetchJSON(ctx, baseURL+fundingRatePath, &fundingRates, &wg, errChan)
+	go fetchJSON(ctx, baseURL+indexTickersUSDTPath, &indexUSDT, &wg, errChan)
+	go fetchJSON(ctx, baseURL+indexTickersUSDCPath, &indexUSDC, &wg, errChan)
+	go fetchJSON(ctx, baseURL+indexTickersUSDPath, &indexUSD, &wg, inverseErrChan)
+
+	wg.Wait()
+	close(errChan)
+	close(inverseErrChan)
+
+	for err := range errChan {
+		if err != nil {
+			return nil, err
+		}
+	}
+	inverse := true
+	if err := <-inverseErrChan; err != nil {
+		logger.Warn("skipping inverse swaps", "error", err)
+		metrics.PartialFailures.WithLabelValues("OKX", "inverse").Inc()
+		inverse = false
+	}
+
+	tickerMap := make(map[string]int, len(tickers.Data))
+	for i, t := range tickers.Data {
+		tickerMap[t.InstID] = i
+	}
+	markMap := make(map[string]string, len(markPrices.Data))
+	for _, m := range markPrices.Data {
+		markMap[m.InstID] = m.MarkPx
+	}
+	fundingMap := make(map[string]int, len(fundingRates.Data))
+	for i, f := range fundingRates.Data {
+		fundingMap[f.InstID] = i
+	}
+	// Index prices are quoted per underlying (BTC-USDT), not per swap
+	indexMap := make(map[string]string)
+	for _, idx := range slices.Concat(indexUSDT.Data, indexUSDC.Data, indexUSD.Data) {
+		indexMap[idx.InstID] = idx.IdxPx
 	}
 
-	return true
+	var pairs []models.PairFutures
+	for _, inst := range instruments.Data {
+		if inst.State != "live" {
+			continue
+		}
+		assets := strings.Split(inst.Uly, "-")
+		if len(assets) != 2 {
+			continue
+		}
+		baseAsset, quoteAsset := assets[0], assets[1]
+		// Inverse swaps are margined in the base coin and priced in USD
+		var contractType string
+		switch {
+		case inst.CtType == "linear" && (inst.SettleCcy == "USDT" || inst.SettleCcy == "USDC"):
+			contractType = models.ContractLinear
+		case inverse && inst.CtType == "inverse" && quoteAsset == "USD" && inst.SettleCcy == baseAsset:
+			contractType = models.ContractInverse
+		default:
+			continue
+		}
+
+		t, exists := tickerMap[inst.InstID]
+		if !exists {
+			continue
+		}
+		ticker := tickers.Data[t]
+		markPx, exists := markMap[inst.InstID]
+		if !exists {
+			continue
+		}
+		f, exists := fundingMap[inst.InstID]
+		if !exists || fundingRates.Data[f].FundingRate == "" {
+			continue
+		}
+		funding := fundingRates.Data[f]
+
+		markPrice := sanitizeDecimal(parseFloat(markPx, inst.InstID+"markPrice"), MAX_DECIMAL_18_8, 8)
+		if markPrice <= 0 {
+			continue
+		}
+		last := parseFloat(ticker.Last, inst.InstID+"last")
+		// volCcy24h of a swap is in the base coin, vol24h counts contracts
+		baseVolume := parseFloat(ticker.QuoteVolume, inst.InstID+"volCcy24h")
+
+		symbol := baseAsset + quoteAsset
+		pair := models.PairFutures{
+			PairKey:               fmt.Sprintf("%s_OKX_futures", symbol),
+			Symbol:                symbol,
+			Exchange:              "OKX",
+			Market:                "futures",
+			MarkPrice:             markPrice,
+			IndexPrice:            sanitizeDecimal(parseFloat(indexMap[inst.Uly], inst.InstID+"indexPrice"), MAX_DECIMAL_18_8, 8),
+			BaseAsset:             baseAsset,
+			QuoteAsset:            quoteAsset,
+			DisplayName:           fmt.Sprintf("%s/%s", baseAsset, quoteAsset),
+			FundingRatePercent:    parseFloat(funding.FundingRate, inst.InstID+"fundingRate"),
+			NextFundingTimestamp:  int(parseFloat(funding.FundingTime, inst.InstID+"fundingTime")),
+			PriceChangePercent24h: sanitizeDecimal(calculatePercentChange(parseFloat(ticker.Open24h, inst.InstID+"openPrice"), last), MAX_DECIMAL_10_2, 2),
+			BaseVolume24h:         sanitizeDecimal(baseVolume, MAX_DECIMAL_20_2, 2),
+			QuoteVolume24h:        sanitizeDecimal(baseVolume*last, MAX_DECIMAL_20_2, 2),
+			ContractType:          contractType,
+			ContractValue:         parseFloat(inst.CtVal, inst.InstID+"ctVal"),
+			UpdatedAt:             time.Now().UTC(),
+			CreatedAt:             time.Now(),
+		}
+		pairs = append(pairs, pair)
+	}
+
+	if len(pairs) == 0 {
+		return nil, errors.New("OKX No futures pairs to update")
+	}
+
+	return pairs, nil
+}
+
+func UpdateAllFuturesPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchFuturesPairs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("OKX Failed to begin transaction: %w", err)
+	}
+
+	placeholderStr := generateNumberedPlaceholders(len(pairs), 18)
+	query := `
+    INSERT INTO pairsfutures (pairkey, symbol, exchange, market, markprice, indexprice, baseasset, quoteasset, displayname, fundingRatePercent, nextfundingtimestamp, pricechangepercent24h, basevolume24h, quotevolume24h, contracttype, contractvalue, updatedat, createdat)
+    VALUES ` + placeholderStr + `
+    ON CONFLICT (pairkey) DO UPDATE SET
+        markprice = EXCLUDED.markprice,
+        indexprice = EXCLUDED.indexprice,
+        fundingRatePercent = EXCLUDED.fundingRatePercent,
+        nextfundingtimestamp = EXCLUDED.nextfundingtimestamp,
+        pricechangepercent24h = EXCLUDED.pricechangepercent24h,
+        basevolume24h = EXCLUDED.basevolume24h,
+        quotevolume24h = EXCLUDED.quotevolume24h,
+        contracttype = EXCLUDED.contracttype,
+        contractvalue = EXCLUDED.contractvalue,
+        updatedat = EXCLUDED.updatedat
+    `
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return 0, fmt.Errorf("OKX Failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	args := make([]interface{}, 0, len(pairs)*18)
+	for _, pair := range pairs {
+		args = append(args, pair.PairKey, pair.Symbol, pair.Exchange, pair.Market, pair.MarkPrice, pair.IndexPrice, pair.BaseAsset,
+			pair.QuoteAsset, pair.DisplayName, pair.FundingRatePercent, pair.NextFundingTimestamp, pair.PriceChangePercent24h,
+			pair.BaseVolume24h, pair.QuoteVolume24h, pair.ContractType, pair.ContractValue, pair.UpdatedAt, pair.CreatedAt)
+	}
+
+	_, err = stmt.Exec(args...)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("OKX Failed to execute statement: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("OKX Failed to commit transaction: %w", err)
+	}
+
+	return len(pairs), nil
+}
+
+// serverClock corrects signed request timestamps for the local clock skew.
+var serverClock = signing.NewClock("OKX", getServerTime)
+
+// fetchNetworks downloads the deposit and withdrawal status of every currency chain.
+func fetchNetworks(ctx context.Context, creds signing.Credentials) ([]models.Network, error) {
+	if !creds.Configured() || creds.Passphrase == "" {
+		return nil, fmt.Errorf("OKX error: %w", signing.ErrNoCredentials)
+	}
+
+	serverTime, err := serverClock.Now(ctx)
+	if err != nil {
+		return nil, err
+	}
+	timestamp := serverTime.UTC().Format("2006-01-02T15:04:05.000Z")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+currenciesPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("OKX error creating request: %w", err)
+	}
+	req.Header.Set("OK-ACCESS-KEY", creds.Key)
+	req.Header.Set("OK-ACCESS-SIGN", signing.HMACSHA256Base64(creds.Secret, signing.OKXPrehash(timestamp, http.MethodGet, currenciesPath, "")))
+	req.Header.Set("OK-ACCESS-TIMESTAMP", timestamp)
+	req.Header.Set("OK-ACCESS-PASSPHRASE", creds.Passphrase)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("OKX error fetching currencies: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// A rejected timestamp is the usual cause, measure the offset again on the next run
+		serverClock.Invalidate()
+		return nil, fmt.Errorf("OKX non-OK status code %d from %s", resp.StatusCode, baseURL+currenciesPath)
+	}
+
+	var currencies CurrenciesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&currencies); err != nil {
+		return nil, fmt.Errorf("OKX error unmarshalling JSON: %w", err)
+	}
+	if currencies.Code != "0" {
+		return nil, fmt.Errorf("OKX error %s from %s: %s", currencies.Code, baseURL+currenciesPath, currencies.Msg)
+	}
+
+	var networks []models.Network
+	for _, c := range currencies.Data {
+		// Chains are named after the currency, such as USDT-TRC20 or BTC-Bitcoin
+		network := strings.TrimPrefix(c.Chain, c.Ccy+"-")
+		networks = append(networks, models.Network{
+			CoinKey:        fmt.Sprintf("%s_OKX_%s", c.Ccy, network),
+			Coin:           c.Ccy,
+			Exchange:       "OKX",
+			Network:        network,
+			NetworkName:    c.Chain,
+			DepositEnable:  c.CanDep,
+			WithdrawEnable: c.CanWd,
+			UpdatedAt:      time.Now().UTC(),
+		})
+	}
+
+	return networks, nil
+}
+
+func UpdateAllNetworks(ctx context.Context, db *sql.DB, creds signing.Credentials) (int, error) {
+	networks, err := fetchNetworks(ctx, creds)
+	if err != nil {
+		return 0, err
+	}
+	if len(networks) == 0 {
+		logger.Debug("no network data to update")
+		return 0, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("OKX Failed to begin transaction: %w", err)
+	}
+
+	placeholderStr := generateNumberedPlaceholders(len(networks), 8)
+	query := `
+	INSERT INTO nets (coinKey, coin, exchange, network, networkName, depositEnable, withdrawEnable, updatedAt)
+	VALUES ` + placeholderStr + `
+	ON CONFLICT (coinKey) DO UPDATE SET
+		networkName = EXCLUDED.networkName,
+		depositEnable = EXCLUDED.depositEnable,
+		withdrawEnable = EXCLUDED.withdrawEnable,
+		updatedAt = EXCLUDED.updatedAt
+	`
+
+	args := make([]interface{}, 0, len(networks)*8)
+	for _, n := range networks {
+		args = append(args, n.CoinKey, n.Coin, n.Exchange, n.Network, n.NetworkName, n.DepositEnable, n.WithdrawEnable, n.UpdatedAt)
+	}
+
+	_, err = tx.Exec(query, args...)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("OKX Failed to execute statement: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("OKX Failed to commit transaction: %w", err)
+	}
+
+	return len(networks), nil
+}
+
+func getServerTime(ctx context.Context) (time.Time, error) {
+	var wg sync.WaitGroup
+	errChan := make(chan error, 1)
+
+	var result struct {
+		Data []struct {
+			Ts string `json:"ts"`
+		} `json:"data"`
+	}
+
+	wg.Add(1)
+	go fetchJSON(ctx, baseURL+serverTimePath, &result, &wg, errChan)
+
+	wg.Wait()
+	close(errChan)
+
+	for err := range errChan {
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	if len(result.Data) == 0 {
+		return time.Time{}, fmt.Errorf("empty server time response from %s", baseURL+serverTimePath)
+	}
+	ts, err := strconv.ParseInt(result.Data[0].Ts, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error parsing server time %q: %w", result.Data[0].Ts, err)
+	}
+	return time.UnixMilli(ts), nil
 }
@@ -0,0 +1,209 @@
+package okx
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"Updater/exchanges/exchangetest"
+	"Updater/exchanges/signing"
+	"Updater/models"
+)
+
+func TestFetchSpotPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		instrumentsPath: "tickers_spot.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	pairs, err := fetchSpotPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The 24h change is derived from open24h; NEW-USDT has not traded yet and
+	// XAUT-USDT has no open price
+	exchangetest.Compare(t, pairs, []models.Pair{
+		{
+			PairKey:               "BTCUSDT_OKX_spot",
+			Symbol:                "BTCUSDT",
+			Exchange:              "OKX",
+			Market:                "spot",
+			Price:                 67012.3,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDT",
+			DisplayName:           "BTC/USDT",
+			PriceChangePercent24h: 1.53,
+			BaseVolume24h:         4321.12,
+			QuoteVolume24h:        289456789.13,
+		},
+		{
+			PairKey:               "ETHBTC_OKX_spot",
+			Symbol:                "ETHBTC",
+			Exchange:              "OKX",
+			Market:                "spot",
+			Price:                 0.051234,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "BTC",
+			DisplayName:           "ETH/BTC",
+			PriceChangePercent24h: -2.41,
+			BaseVolume24h:         812.5,
+			QuoteVolume24h:        41.62,
+		},
+		{
+			PairKey:        "XAUTUSDT_OKX_spot",
+			Symbol:         "XAUTUSDT",
+			Exchange:       "OKX",
+			Market:         "spot",
+			Price:          2350.5,
+			BaseAsset:      "XAUT",
+			QuoteAsset:     "USDT",
+			DisplayName:    "XAUT/USDT",
+			BaseVolume24h:  5.25,
+			QuoteVolume24h: 12345.6,
+		},
+	})
+}
+
+func TestFetchFuturesPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		swapInstrumentsPath:  "instruments_swap.json",
+		swapTickersPath:      "tickers_swap.json",
+		markPricePath:        "mark_price_swap.json",
+		fundingRatePath:      "funding_rate.json",
+		indexTickersUSDTPath: "index_tickers_usdt.json",
+		indexTickersUSDCPath: "index_tickers_usdc.json",
+		indexTickersUSDPath:  "index_tickers_usd.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	pairs, err := fetchFuturesPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// BTC-USD-SWAP is inverse with contracts of 100 USD, NEW-USDT-SWAP is not live
+	// yet and DOGE-USDT-SWAP has no mark price; volCcy24h is the base volume of a swap
+	exchangetest.Compare(t, pairs, []models.PairFutures{
+		{
+			PairKey:               "BTCUSDT_OKX_futures",
+			Symbol:                "BTCUSDT",
+			Exchange:              "OKX",
+			Market:                "futures",
+			MarkPrice:             67048.2,
+			IndexPrice:            67040.1,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDT",
+			DisplayName:           "BTC/USDT",
+			FundingRatePercent:    0.0001,
+			NextFundingTimestamp:  1718006400000,
+			PriceChangePercent24h: 1.59,
+			BaseVolume24h:         12345.6,
+			QuoteVolume24h:        827778652.8,
+			ContractType:          models.ContractLinear,
+			ContractValue:         0.01,
+		},
+		{
+			PairKey:               "BTCUSD_OKX_futures",
+			Symbol:                "BTCUSD",
+			Exchange:              "OKX",
+			Market:                "futures",
+			MarkPrice:             67058.7,
+			IndexPrice:            67045.3,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USD",
+			DisplayName:           "BTC/USD",
+			FundingRatePercent:    0.00008,
+			NextFundingTimestamp:  1718006400000,
+			PriceChangePercent24h: 1.59,
+			BaseVolume24h:         1520.4,
+			QuoteVolume24h:        101958176.04,
+			ContractType:          models.ContractInverse,
+			ContractValue:         100,
+		},
+		{
+			PairKey:               "ETHUSDC_OKX_futures",
+			Symbol:                "ETHUSDC",
+			Exchange:              "OKX",
+			Market:                "futures",
+			MarkPrice:             3501.25,
+			IndexPrice:            3500.9,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "USDC",
+			DisplayName:           "ETH/USDC",
+			FundingRatePercent:    -0.00005,
+			NextFundingTimestamp:  1718006400000,
+			PriceChangePercent24h: -2.78,
+			BaseVolume24h:         2500.5,
+			QuoteVolume24h:        8751750,
+			ContractType:          models.ContractLinear,
+			ContractValue:         0.001,
+		},
+	})
+}
+
+func TestFetchFuturesPairsWithoutUSDIndex(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		swapInstrumentsPath:  "instruments_swap.json",
+		swapTickersPath:      "tickers_swap.json",
+		markPricePath:        "mark_price_swap.json",
+		fundingRatePath:      "funding_rate.json",
+		indexTickersUSDTPath: "index_tickers_usdt.json",
+		indexTickersUSDCPath: "index_tickers_usdc.json",
+		indexTickersUSDPath:  "",
+	})
+	srv.SetURL(t, &baseURL)
+
+	pairs, err := fetchFuturesPairs(context.Background())
+	if err != nil {
+		t.Fatalf("USD index outage failed the job: %v", err)
+	}
+	var symbols []string
+	for _, p := range pairs {
+		symbols = append(symbols, p.Symbol)
+	}
+	if want := []string{"BTCUSDT", "ETHUSDC"}; !slices.Equal(symbols, want) {
+		t.Errorf("symbols = %v, want the linear pairs %v", symbols, want)
+	}
+}
+
+func TestFetchNetworks(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		serverTimePath: "server_time.json",
+		currenciesPath: "currencies.json",
+	})
+	srv.SetURL(t, &baseURL)
+	serverClock.Invalidate()
+
+	creds := signing.Credentials{Key: "key", Secret: "secret", Passphrase: "passphrase"}
+	networks, err := fetchNetworks(context.Background(), creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exchangetest.Compare(t, networks, []models.Network{
+		{CoinKey: "USDT_OKX_ERC20", Coin: "USDT", Exchange: "OKX", Network: "ERC20", NetworkName: "USDT-ERC20", DepositEnable: true, WithdrawEnable: true},
+		{CoinKey: "USDT_OKX_TRC20", Coin: "USDT", Exchange: "OKX", Network: "TRC20", NetworkName: "USDT-TRC20", DepositEnable: true},
+		{CoinKey: "BTC_OKX_Bitcoin", Coin: "BTC", Exchange: "OKX", Network: "Bitcoin", NetworkName: "BTC-Bitcoin", WithdrawEnable: true},
+	})
+
+	requests := srv.Requests()
+	signed := requests[len(requests)-1]
+	timestamp := "2024-06-10T06:13:20.000Z"
+	for header, want := range map[string]string{
+		"OK-ACCESS-KEY":        "key",
+		"OK-ACCESS-TIMESTAMP":  timestamp,
+		"OK-ACCESS-PASSPHRASE": "passphrase",
+		"OK-ACCESS-SIGN":       signing.HMACSHA256Base64("secret", timestamp+"GET/api/v5/asset/currencies"),
+	} {
+		if got := signed.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestFetchNetworksWithoutPassphrase(t *testing.T) {
+	if _, err := fetchNetworks(context.Background(), signing.Credentials{Key: "key", Secret: "secret"}); err == nil {
+		t.Error("expected an error without an API passphrase")
+	}
+}
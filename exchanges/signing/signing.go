@@ -0,0 +1,139 @@
+// Package signing signs requests to the private exchange endpoints and keeps
+// their timestamps in line with the exchange clocks.
+package signing
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"Updater/logging"
+)
+
+var logger = logging.For("component", "signing")
+
+// ErrNoCredentials is returned when a signed request is made without an API key or secret.
+var ErrNoCredentials = errors.New("API key or secret is not configured")
+
+// Credentials are the API credentials of one exchange.
+type Credentials struct {
+	Key    string
+	Secret string
+	// Passphrase is only used by the exchanges that require one, such as OKX.
+	Passphrase string
+}
+
+// Configured reports whether both the key and the secret are set.
+func (c Credentials) Configured() bool {
+	return c.Key != "" && c.Secret != ""
+}
+
+// HMACSHA256Hex signs message with secret and returns the hex encoded MAC,
+// as Binance, Bybit and MEXC expect.
+func HMACSHA256Hex(secret, message string) string {
+	return hex.EncodeToString(hmacSHA256(secret, message))
+}
+
+// HMACSHA256Base64 signs message with secret and returns the base64 encoded
+// MAC, as OKX expects.
+func HMACSHA256Base64(secret, message string) string {
+	return base64.StdEncoding.EncodeToString(hmacSHA256(secret, message))
+}
+
+func hmacSHA256(secret, message string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+// OKXPrehash builds the string OKX signs: the timestamp, the upper case
+// method, the request path with its query string and the body.
+func OKXPrehash(timestamp, method, requestPath, body string) string {
+	return timestamp + method + requestPath + body
+}
+
+// ED25519 signs message with a base64 encoded ED25519 key, either the 32 byte
+// seed or the 64 byte private key, and returns the base64 encoded signature.
+func ED25519(secret, message string) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return "", fmt.Errorf("decoding ED25519 secret: %w", err)
+	}
+
+	var privateKey ed25519.PrivateKey
+	switch len(key) {
+	case ed25519.SeedSize:
+		privateKey = ed25519.NewKeyFromSeed(key)
+	case ed25519.PrivateKeySize:
+		privateKey = ed25519.PrivateKey(key)
+	default:
+		return "", fmt.Errorf("invalid ED25519 secret length %d, expected %d or %d bytes", len(key), ed25519.SeedSize, ed25519.PrivateKeySize)
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(message))), nil
+}
+
+// resyncAfter is how long a measured clock offset is trusted.
+const resyncAfter = 10 * time.Minute
+
+// skewWarning is the offset from which the local clock is reported as off.
+const skewWarning = time.Second
+
+// Clock follows the clock of one exchange. Exchanges reject signed requests
+// whose timestamp falls outside their receive window, so timestamps are taken
+// from the local clock corrected by the offset measured against the exchange.
+type Clock struct {
+	exchange string
+	fetch    func(context.Context) (time.Time, error)
+
+	mu     sync.Mutex
+	offset time.Duration
+	synced time.Time // local time of the last measurement, zero when it must be measured again
+}
+
+// NewClock returns the clock of exchange, whose server time fetch returns.
+func NewClock(exchange string, fetch func(context.Context) (time.Time, error)) *Clock {
+	return &Clock{exchange: exchange, fetch: fetch}
+}
+
+// Now returns the current exchange time. The offset is measured on the first
+// call and again once it is older than resyncAfter or was invalidated; a call
+// that measures returns the server time itself.
+func (c *Clock) Now(ctx context.Context) (time.Time, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.synced.IsZero() && time.Since(c.synced) < resyncAfter {
+		return time.Now().Add(c.offset), nil
+	}
+
+	start := time.Now()
+	server, err := c.fetch(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s error fetching server time: %w", c.exchange, err)
+	}
+	// The server read its clock about halfway through the round trip
+	roundTrip := time.Since(start)
+	c.offset = server.Sub(start.Add(roundTrip / 2))
+	c.synced = time.Now()
+
+	if c.offset > skewWarning || c.offset < -skewWarning {
+		logger.Warn("local clock differs from the exchange clock, correcting signed timestamps",
+			"exchange", c.exchange, "offset", c.offset, "roundTrip", roundTrip)
+	}
+	return server, nil
+}
+
+// Invalidate makes the next call to Now measure the offset again, such as
+// after the exchange rejected a request.
+func (c *Clock) Invalidate() {
+	c.mu.Lock()
+	c.synced = time.Time{}
+	c.mu.Unlock()
+}
@@ -0,0 +1,97 @@
+package signing
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHMACSHA256(t *testing.T) {
+	// Example request from the Binance API documentation
+	secret := "NhqPtmdSJYdKjVHjA7PZj4Mge3R5YNiP1e3UZjInClVN65XAbvqqM6A7H5fATj0j"
+	message := "symbol=LTCBTC&side=BUY&type=LIMIT&timeInForce=GTC&quantity=1&price=0.1&recvWindow=5000&timestamp=1499827319559"
+	if got, want := HMACSHA256Hex(secret, message), "c8db56825ae71d6d79447849e617115f4a920fa2acdcab2b053c4b2838bd6b71"; got != want {
+		t.Errorf("HMACSHA256Hex = %s, want %s", got, want)
+	}
+
+	// RFC 4231 test case 2
+	if got, want := HMACSHA256Base64("Jefe", "what do ya want for nothing?"), "W9zBRr9gdU5qBCQmCJV1x1oAPwidJzmDnexYuWTsOEM="; got != want {
+		t.Errorf("HMACSHA256Base64 = %s, want %s", got, want)
+	}
+}
+
+func TestOKXPrehash(t *testing.T) {
+	got := OKXPrehash("2020-12-08T09:08:57.715Z", "GET", "/api/v5/asset/currencies", "")
+	if want := "2020-12-08T09:08:57.715ZGET/api/v5/asset/currencies"; got != want {
+		t.Errorf("OKXPrehash = %q, want %q", got, want)
+	}
+}
+
+func TestED25519(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	key := ed25519.NewKeyFromSeed(seed)
+
+	for name, secret := range map[string][]byte{"seed": seed, "private key": key} {
+		signature, err := ED25519(base64.StdEncoding.EncodeToString(secret), "instruction=capitalQuery&timestamp=1")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		raw, _ := base64.StdEncoding.DecodeString(signature)
+		if !ed25519.Verify(key.Public().(ed25519.PublicKey), []byte("instruction=capitalQuery&timestamp=1"), raw) {
+			t.Errorf("%s: signature does not verify", name)
+		}
+	}
+
+	if _, err := ED25519(base64.StdEncoding.EncodeToString([]byte("short")), "message"); err == nil {
+		t.Error("expected an error for a key of the wrong length")
+	}
+	if _, err := ED25519("not base64!", "message"); err == nil {
+		t.Error("expected an error for a secret that is not base64")
+	}
+}
+
+func TestClock(t *testing.T) {
+	ahead := time.Hour
+	calls := 0
+	clock := NewClock("Test", func(context.Context) (time.Time, error) {
+		calls++
+		return time.Now().Add(ahead), nil
+	})
+
+	now, err := clock.Now(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(now) - ahead; d < -time.Second || d > time.Second {
+		t.Errorf("first call is %v off the server time", d)
+	}
+
+	now, _ = clock.Now(context.Background())
+	if d := time.Until(now) - ahead; d < -time.Second || d > time.Second {
+		t.Errorf("corrected time is %v off the server time", d)
+	}
+	if calls != 1 {
+		t.Errorf("server time fetched %d times, want 1", calls)
+	}
+
+	clock.Invalidate()
+	clock.Now(context.Background())
+	if calls != 2 {
+		t.Errorf("server time fetched %d times after Invalidate, want 2", calls)
+	}
+}
+
+func TestClockError(t *testing.T) {
+	clock := NewClock("Test", func(context.Context) (time.Time, error) {
+		return time.Time{}, errors.New("unreachable")
+	})
+	if _, err := clock.Now(context.Background()); err == nil {
+		t.Error("expected an error when the server time cannot be fetched")
+	}
+}
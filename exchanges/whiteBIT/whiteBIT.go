@@ -1,26 +1,40 @@
 package whitebit
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"math"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"Updater/exchanges/httpclient"
+	"Updater/logging"
+	"Updater/metrics"
 	"Updater/models"
 )
 
+var (
+	logger       = logging.Exchange("WhiteBIT")
+	parseSampler = logging.NewSampler()
+	httpClient   = httpclient.For("WhiteBIT")
+)
+
+// baseURL is a variable so tests can point the connector at recorded responses
+var baseURL = "https://whitebit.com"
+
 const (
-	marketsURL = "https://whitebit.com/api/v4/public/markets"
-	tickerURL  = "https://whitebit.com/api/v4/public/ticker"
-	// networksURL      = "https://whitebit.com/api/v4/public/coins"
-	assetsURL        = "https://whitebit.com/api/v4/public/assets"
+	marketsPath = "/api/v4/public/markets"
+	tickerPath  = "/api/v4/public/ticker"
+	// networksPath     = "/api/v4/public/coins"
+	assetsPath       = "/api/v4/public/assets"
 	MAX_DECIMAL_18_8 = 9999999999.99999999   // Максимальне значення для DECIMAL(18,8)
 	MAX_DECIMAL_10_2 = 99999999.99           // Максимальне значення для DECIMAL(10,2)
 	MAX_DECIMAL_20_2 = 999999999999999999.99 // Максимальне значення для DECIMAL(20,2)
@@ -65,10 +79,15 @@ type AssetInfo struct {
 	} `json:"limits"`
 }
 
-func fetchJSON(url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
+func fetchJSON(ctx context.Context, url string, target interface{}, wg *sync.WaitGroup, errChan chan<- error) {
 	defer wg.Done()
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		errChan <- fmt.Errorf("WhiteBIT error fetching %s: %w", url, err)
+		return
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		errChan <- fmt.Errorf("WhiteBIT error fetching %s: %w", url, err)
 		return
@@ -113,7 +132,8 @@ func parseTickerJSON(body []byte) (map[string]TickerInfo, error) {
 func parseFloat(s string) float64 {
 	val, err := strconv.ParseFloat(s, 64)
 	if err != nil {
-		log.Printf("WhiteBIT Warning: failed to parse float from %s", s)
+		parseSampler.Warn(logger, "parseFloat", "failed to parse float", "value", s)
+		metrics.ParseWarnings.WithLabelValues("WhiteBIT").Inc()
 		return 0
 	}
 	return val
@@ -150,7 +170,8 @@ func sanitizeDecimal(value float64, maxValue float64, precision int) float64 {
 	return formattedVal
 }
 
-func UpdateAllSpotPairs(db *sql.DB) bool {
+// fetchSpotPairs downloads the markets and tickers and builds the pairs of enabled markets.
+func fetchSpotPairs(ctx context.Context) ([]models.Pair, error) {
 	var wg sync.WaitGroup
 	errChan := make(chan error, 3)
 
@@ -158,25 +179,26 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 	var tickers map[string]TickerInfo
 
 	wg.Add(1)
-	go fetchJSON(marketsURL, &markets, &wg, errChan)
+	go fetchJSON(ctx, baseURL+marketsPath, &markets, &wg, errChan)
 
-	resp, err := http.Get(tickerURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+tickerPath, nil)
 	if err != nil {
-		log.Printf("WhiteBIT error fetching %s: %v", tickerURL, err)
-		return false
+		return nil, fmt.Errorf("WhiteBIT error fetching %s: %v", baseURL+tickerPath, err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("WhiteBIT error fetching %s: %v", baseURL+tickerPath, err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("WhiteBIT error reading response from %s: %v", tickerURL, err)
-		return false
+		return nil, fmt.Errorf("WhiteBIT error reading response from %s: %v", baseURL+tickerPath, err)
 	}
 
 	tickers, err = parseTickerJSON(body)
 	if err != nil {
-		log.Printf("WhiteBIT error parsing ticker JSON: %v", err)
-		return false
+		return nil, fmt.Errorf("WhiteBIT error parsing ticker JSON: %w", err)
 	}
 
 	wg.Wait()
@@ -184,8 +206,7 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 
 	for err := range errChan {
 		if err != nil {
-			log.Printf("Error: %v", err)
-			return false
+			return nil, err
 		}
 	}
 
@@ -221,15 +242,23 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 			PriceChangePercent24h: priceChangePercent,
 			BaseVolume24h:         baseVolume,
 			QuoteVolume24h:        quoteVolume,
-			UpdatedAt:             time.Now(),
+			UpdatedAt:             time.Now().UTC(),
 		}
 		pairs = append(pairs, pair)
 	}
 
+	return pairs, nil
+}
+
+func UpdateAllSpotPairs(ctx context.Context, db *sql.DB) (int, error) {
+	pairs, err := fetchSpotPairs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
 	tx, err := db.Begin()
 	if err != nil {
-		log.Printf("WhiteBIT Failed to begin transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("WhiteBIT Failed to begin transaction: %w", err)
 	}
 
 	placeholderStr := generateNumberedPlaceholders(len(pairs), 12)
@@ -245,8 +274,7 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
     `
 	stmt, err := tx.Prepare(query)
 	if err != nil {
-		log.Printf("WhiteBIT Failed to prepare statement: %v", err)
-		return false
+		return 0, fmt.Errorf("WhiteBIT Failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
@@ -259,64 +287,39 @@ func UpdateAllSpotPairs(db *sql.DB) bool {
 	_, err = stmt.Exec(args...)
 	if err != nil {
 		tx.Rollback()
-		log.Printf("WhiteBIT Failed to execute statement: %v", err)
-		return false
+		return 0, fmt.Errorf("WhiteBIT Failed to execute statement: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("WhiteBIT Failed to commit transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("WhiteBIT Failed to commit transaction: %w", err)
 	}
 
-	return true
+	return len(pairs), nil
 }
 
-func UpdateAllNetworks(db *sql.DB) bool {
+// fetchNetworks downloads the assets and builds one record per deposit or
+// withdrawal network, ordered by coin key.
+func fetchNetworks(ctx context.Context) ([]models.Network, error) {
 	var wg sync.WaitGroup
 	errChan := make(chan error, 1)
 	assets := make(map[string]AssetInfo)
 
 	wg.Add(1)
-	go fetchJSON(assetsURL, &assets, &wg, errChan)
+	go fetchJSON(ctx, baseURL+assetsPath, &assets, &wg, errChan)
 	wg.Wait()
 	close(errChan)
 
 	for err := range errChan {
 		if err != nil {
-			log.Printf("Error fetching WhiteBIT data: %v", err)
-			return false
+			return nil, fmt.Errorf("Error fetching WhiteBIT data: %w", err)
 		}
 	}
 
 	if len(assets) == 0 {
-		log.Println("WhiteBIT: No asset data received.")
-		return false
-	}
-
-	tx, err := db.Begin()
-	if err != nil {
-		log.Printf("WhiteBIT Failed to begin transaction: %v", err)
-		return false
-	}
-
-	_, err = tx.Exec(`DELETE FROM nets WHERE exchange = 'WhiteBIT'`)
-	if err != nil {
-		tx.Rollback()
-		log.Printf("WhiteBIT Failed to delete old network records: %v", err)
-		return false
-	}
-
-	var nets []struct {
-		CoinKey        string
-		Coin           string
-		Exchange       string
-		Network        string
-		NetworkName    string
-		DepositEnable  bool
-		WithdrawEnable bool
-		UpdatedAt      time.Time
+		return nil, errors.New("WhiteBIT: No asset data received.")
 	}
 
+	var nets []models.Network
 	for coin, asset := range assets {
 		networkMap := make(map[string]struct {
 			DepositEnable  bool
@@ -351,16 +354,7 @@ func UpdateAllNetworks(db *sql.DB) bool {
 
 		// Формування списку записів
 		for network, data := range networkMap {
-			nets = append(nets, struct {
-				CoinKey        string
-				Coin           string
-				Exchange       string
-				Network        string
-				NetworkName    string
-				DepositEnable  bool
-				WithdrawEnable bool
-				UpdatedAt      time.Time
-			}{
+			nets = append(nets, models.Network{
 				CoinKey:        fmt.Sprintf("%s_WhiteBIT_%s", coin, network),
 				Coin:           coin,
 				Exchange:       "WhiteBIT",
@@ -372,11 +366,30 @@ func UpdateAllNetworks(db *sql.DB) bool {
 			})
 		}
 	}
+	sort.Slice(nets, func(i, j int) bool { return nets[i].CoinKey < nets[j].CoinKey })
 
 	if len(nets) == 0 {
-		log.Println("WhiteBIT: No valid network entries to update.")
-		tx.Commit()
-		return false
+		return nil, errors.New("WhiteBIT: No valid network entries to update.")
+	}
+
+	return nets, nil
+}
+
+func UpdateAllNetworks(ctx context.Context, db *sql.DB) (int, error) {
+	nets, err := fetchNetworks(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("WhiteBIT Failed to begin transaction: %w", err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM nets WHERE exchange = 'WhiteBIT'`)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("WhiteBIT Failed to delete old network records: %w", err)
 	}
 
 	// Формуємо INSERT-запит з ON CONFLICT
@@ -391,9 +404,8 @@ func UpdateAllNetworks(db *sql.DB) bool {
 
 	stmt, err := tx.Prepare(query)
 	if err != nil {
-		log.Printf("WhiteBIT Failed to prepare statement: %v", err)
 		tx.Rollback()
-		return false
+		return 0, fmt.Errorf("WhiteBIT Failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
@@ -402,16 +414,13 @@ func UpdateAllNetworks(db *sql.DB) bool {
 		_, err = stmt.Exec(net.CoinKey, net.Coin, net.Exchange, net.Network, net.NetworkName, net.DepositEnable, net.WithdrawEnable, net.UpdatedAt)
 		if err != nil {
 			tx.Rollback()
-			log.Printf("WhiteBIT Failed to execute statement: %v", err)
-			return false
+			return 0, fmt.Errorf("WhiteBIT Failed to execute statement: %w", err)
 		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("WhiteBIT Failed to commit transaction: %v", err)
-		return false
+		return 0, fmt.Errorf("WhiteBIT Failed to commit transaction: %w", err)
 	}
 
-	// log.Printf("WhiteBIT: Updated %d network entries.", len(nets))
-	return true
+	return len(nets), nil
 }
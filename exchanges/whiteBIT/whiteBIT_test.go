@@ -0,0 +1,73 @@
+package whitebit
+
+import (
+	"context"
+	"testing"
+
+	"Updater/exchanges/exchangetest"
+	"Updater/models"
+)
+
+func TestFetchSpotPairs(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		marketsPath: "markets.json",
+		tickerPath:  "ticker.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	pairs, err := fetchSpotPairs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// OLD_USDT has trading disabled and NEW_USDT no price; the quote volume
+	// is the base volume at the last price
+	exchangetest.Compare(t, pairs, []models.Pair{
+		{
+			PairKey:               "BTCUSDT_WhiteBIT_spot",
+			Symbol:                "BTCUSDT",
+			Exchange:              "WhiteBIT",
+			Market:                "spot",
+			Price:                 67012.5,
+			BaseAsset:             "BTC",
+			QuoteAsset:            "USDT",
+			DisplayName:           "BTC/USDT",
+			PriceChangePercent24h: -1.24,
+			BaseVolume24h:         1228.64,
+			QuoteVolume24h:        82334070.47,
+		},
+		{
+			PairKey:               "ETHBTC_WhiteBIT_spot",
+			Symbol:                "ETHBTC",
+			Exchange:              "WhiteBIT",
+			Market:                "spot",
+			Price:                 0.051234,
+			BaseAsset:             "ETH",
+			QuoteAsset:            "BTC",
+			DisplayName:           "ETH/BTC",
+			PriceChangePercent24h: 0.87,
+			BaseVolume24h:         812.5,
+			QuoteVolume24h:        41.63,
+		},
+	})
+}
+
+func TestFetchNetworks(t *testing.T) {
+	srv := exchangetest.Serve(t, map[string]string{
+		assetsPath: "assets.json",
+	})
+	srv.SetURL(t, &baseURL)
+
+	networks, err := fetchNetworks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Deposit and withdrawal lists are merged per network; fiat USD only has providers
+	exchangetest.Compare(t, networks, []models.Network{
+		{CoinKey: "BTC_WhiteBIT_BTC", Coin: "BTC", Exchange: "WhiteBIT", Network: "BTC", NetworkName: "BTC", DepositEnable: true, WithdrawEnable: true},
+		{CoinKey: "USDT_WhiteBIT_BEP20", Coin: "USDT", Exchange: "WhiteBIT", Network: "BEP20", NetworkName: "BEP20", WithdrawEnable: true},
+		{CoinKey: "USDT_WhiteBIT_ERC20", Coin: "USDT", Exchange: "WhiteBIT", Network: "ERC20", NetworkName: "ERC20", DepositEnable: true},
+		{CoinKey: "USDT_WhiteBIT_TRC20", Coin: "USDT", Exchange: "WhiteBIT", Network: "TRC20", NetworkName: "TRC20", DepositEnable: true, WithdrawEnable: true},
+	})
+}
@@ -0,0 +1,164 @@
+package health
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Jobs tracked per exchange.
+const (
+	JobSpot     = "spot"
+	JobFutures  = "futures"
+	JobNetworks = "networks"
+)
+
+// JobStatus is the state of one exchange job.
+type JobStatus struct {
+	Exchange string `json:"exchange"`
+	Job      string `json:"job"`
+
+	LastRunAt           *time.Time `json:"lastRunAt"`
+	LastSuccessAt       *time.Time `json:"lastSuccessAt"`
+	LastFailureAt       *time.Time `json:"lastFailureAt"`
+	LastError           string     `json:"lastError,omitempty"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	LastDurationMs      int64      `json:"lastDurationMs"` // fetch and write time of the last run
+	LastRows            int        `json:"lastRows"`       // rows written by the last successful run
+
+	TotalRuns     int `json:"totalRuns"`
+	TotalFailures int `json:"totalFailures"`
+
+	// Stale is set by Snapshot when the data is older than the threshold of the job.
+	Stale bool `json:"stale"`
+
+	registeredAt time.Time
+}
+
+// Tracker records the outcome of every exchange job run.
+type Tracker struct {
+	mu   sync.Mutex
+	jobs map[string]*JobStatus
+
+	// staleAfter returns the maximum data age for a job, zero disables staleness checks.
+	staleAfter func(job string) time.Duration
+
+	now func() time.Time
+}
+
+// NewTracker creates an empty tracker.
+func NewTracker(staleAfter func(job string) time.Duration) *Tracker {
+	return &Tracker{
+		jobs:       make(map[string]*JobStatus),
+		staleAfter: staleAfter,
+		now:        time.Now,
+	}
+}
+
+func jobKey(exchange, job string) string {
+	return exchange + "|" + job
+}
+
+// Register adds a job before its first run so that a job that never succeeds
+// becomes stale too.
+func (t *Tracker) Register(exchange, job string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.job(exchange, job)
+}
+
+// Remove drops a job that is no longer scheduled, so it does not turn stale.
+func (t *Tracker) Remove(exchange, job string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.jobs, jobKey(exchange, job))
+}
+
+// job returns the status entry, creating it if needed. Callers hold t.mu.
+func (t *Tracker) job(exchange, job string) *JobStatus {
+	key := jobKey(exchange, job)
+	st, ok := t.jobs[key]
+	if !ok {
+		st = &JobStatus{Exchange: exchange, Job: job, registeredAt: t.now()}
+		t.jobs[key] = st
+	}
+	return st
+}
+
+// Run executes fn and records its duration, row count and error. A run that
+// ends after its job was removed is not recorded.
+func (t *Tracker) Run(exchange, job string, fn func() (int, error)) error {
+	start := t.now()
+	rows, err := fn()
+	end := t.now()
+	took := end.Sub(start)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	st, ok := t.jobs[jobKey(exchange, job)]
+	if !ok {
+		return err
+	}
+	st.LastRunAt = &end
+	st.LastDurationMs = took.Milliseconds()
+	st.TotalRuns++
+	if err != nil {
+		st.LastFailureAt = &end
+		st.LastError = err.Error()
+		st.ConsecutiveFailures++
+		st.TotalFailures++
+		return err
+	}
+	st.LastSuccessAt = &end
+	st.LastRows = rows
+	st.ConsecutiveFailures = 0
+	return nil
+}
+
+// Snapshot returns a copy of all job states ordered by exchange and job, with
+// Stale evaluated against now.
+func (t *Tracker) Snapshot(now time.Time) []JobStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make([]JobStatus, 0, len(t.jobs))
+	for _, st := range t.jobs {
+		cp := *st
+		cp.Stale = t.isStale(st, now)
+		result = append(result, cp)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Exchange != result[j].Exchange {
+			return result[i].Exchange < result[j].Exchange
+		}
+		return result[i].Job < result[j].Job
+	})
+	return result
+}
+
+// Stale returns the jobs whose data is older than their threshold.
+func (t *Tracker) Stale(now time.Time) []JobStatus {
+	var stale []JobStatus
+	for _, st := range t.Snapshot(now) {
+		if st.Stale {
+			stale = append(stale, st)
+		}
+	}
+	return stale
+}
+
+func (t *Tracker) isStale(st *JobStatus, now time.Time) bool {
+	if t.staleAfter == nil {
+		return false
+	}
+	maxAge := t.staleAfter(st.Job)
+	if maxAge <= 0 {
+		return false
+	}
+	since := st.registeredAt
+	if st.LastSuccessAt != nil {
+		since = *st.LastSuccessAt
+	}
+	return now.Sub(since) > maxAge
+}
@@ -0,0 +1,188 @@
+package health
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// clock is a fixed time that tests move forward by hand.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time               { return c.t }
+func (c *clock) add(d time.Duration)          { c.t = c.t.Add(d) }
+func (c *clock) at(d time.Duration) time.Time { return c.t.Add(d) }
+
+func newTestTracker() (*Tracker, *clock) {
+	c := &clock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	tracker := NewTracker(func(job string) time.Duration {
+		switch job {
+		case JobSpot:
+			return time.Minute
+		case JobFutures:
+			return 5 * time.Minute
+		}
+		return 0 // networks are never stale
+	})
+	tracker.now = c.now
+	return tracker, c
+}
+
+func ok(rows int) func() (int, error) {
+	return func() (int, error) { return rows, nil }
+}
+
+func fail(err error) func() (int, error) {
+	return func() (int, error) { return 0, err }
+}
+
+func TestRun(t *testing.T) {
+	tracker, c := newTestTracker()
+	tracker.Register("Alpha", JobSpot)
+	start := c.t
+
+	// The run takes two seconds on the clock
+	slow := func(rows int, err error) func() (int, error) {
+		return func() (int, error) {
+			c.add(2 * time.Second)
+			return rows, err
+		}
+	}
+	boom := errors.New("boom")
+	if err := tracker.Run("Alpha", JobSpot, slow(10, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.Run("Alpha", JobSpot, slow(0, boom)); err != boom {
+		t.Errorf("Run() = %v, want the error of the job", err)
+	}
+	tracker.Run("Alpha", JobSpot, fail(boom))
+
+	success, failure := start.Add(2*time.Second), start.Add(4*time.Second)
+	want := JobStatus{
+		Exchange:            "Alpha",
+		Job:                 JobSpot,
+		LastRunAt:           &failure,
+		LastSuccessAt:       &success,
+		LastFailureAt:       &failure,
+		LastError:           "boom",
+		ConsecutiveFailures: 2,
+		LastDurationMs:      0,
+		LastRows:            10,
+		TotalRuns:           3,
+		TotalFailures:       2,
+		registeredAt:        start,
+	}
+	if got := tracker.Snapshot(c.t); !reflect.DeepEqual(got, []JobStatus{want}) {
+		t.Errorf("Snapshot() =\n %+v\nwant\n %+v", got, want)
+	}
+
+	// A success resets the failure streak and keeps the last error
+	tracker.Run("Alpha", JobSpot, slow(7, nil))
+	st := tracker.Snapshot(c.t)[0]
+	if st.ConsecutiveFailures != 0 || st.LastRows != 7 || st.LastDurationMs != 2000 || st.LastError != "boom" || !st.LastSuccessAt.Equal(c.t) {
+		t.Errorf("after a success: %+v", st)
+	}
+}
+
+func TestRunAfterRemove(t *testing.T) {
+	tracker, _ := newTestTracker()
+	tracker.Register("Alpha", JobSpot)
+
+	// The job is removed by a reload while it runs
+	err := tracker.Run("Alpha", JobSpot, func() (int, error) {
+		tracker.Remove("Alpha", JobSpot)
+		return 0, errors.New("boom")
+	})
+	if err == nil {
+		t.Error("Run() = nil, want the error of the job")
+	}
+	if got := tracker.Snapshot(time.Now()); len(got) != 0 {
+		t.Errorf("Snapshot() = %+v, want the removed job gone", got)
+	}
+}
+
+func TestStale(t *testing.T) {
+	tests := []struct {
+		name      string
+		exchange  string
+		job       string
+		runs      []func() (int, error)
+		after     time.Duration
+		wantStale bool
+	}{
+		{"registered within threshold", "Alpha", JobSpot, nil, time.Minute, false},
+		{"never succeeded", "Alpha", JobSpot, nil, time.Minute + time.Second, true},
+		{"recent success", "Alpha", JobSpot, []func() (int, error){ok(1)}, time.Minute, false},
+		{"old success", "Alpha", JobSpot, []func() (int, error){ok(1)}, time.Minute + time.Second, true},
+		// Failures do not refresh the data
+		{"failing since success", "Alpha", JobSpot, []func() (int, error){ok(1), fail(errors.New("boom"))}, time.Minute + time.Second, true},
+		{"threshold per job", "Alpha", JobFutures, []func() (int, error){ok(1)}, 2 * time.Minute, false},
+		{"no threshold", "Alpha", JobNetworks, nil, 24 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker, c := newTestTracker()
+			tracker.Register(tt.exchange, tt.job)
+			for _, run := range tt.runs {
+				tracker.Run(tt.exchange, tt.job, run)
+			}
+
+			snapshot := tracker.Snapshot(c.at(tt.after))
+			if len(snapshot) != 1 || snapshot[0].Stale != tt.wantStale {
+				t.Errorf("Snapshot() = %+v, want Stale %v", snapshot, tt.wantStale)
+			}
+			if got := len(tracker.Stale(c.at(tt.after))) == 1; got != tt.wantStale {
+				t.Errorf("Stale() reports the job: %v, want %v", got, tt.wantStale)
+			}
+		})
+	}
+}
+
+func TestSnapshotPerExchange(t *testing.T) {
+	tracker, c := newTestTracker()
+	for _, exchange := range []string{"Gamma", "Alpha", "Beta"} {
+		tracker.Register(exchange, JobSpot)
+		tracker.Register(exchange, JobFutures)
+	}
+
+	// Beta spot keeps working, Alpha futures broke after its first run
+	c.add(time.Minute)
+	tracker.Run("Alpha", JobFutures, ok(5))
+	tracker.Run("Beta", JobSpot, ok(5))
+	c.add(5 * time.Minute)
+	tracker.Run("Alpha", JobFutures, fail(errors.New("boom")))
+	tracker.Run("Beta", JobSpot, ok(5))
+	c.add(time.Second)
+
+	type status struct {
+		exchange, job string
+		stale         bool
+	}
+	var got []status
+	for _, st := range tracker.Snapshot(c.t) {
+		got = append(got, status{st.Exchange, st.Job, st.Stale})
+	}
+	want := []status{
+		{"Alpha", JobFutures, true},
+		{"Alpha", JobSpot, true},
+		{"Beta", JobFutures, true},
+		{"Beta", JobSpot, false},
+		{"Gamma", JobFutures, true},
+		{"Gamma", JobSpot, true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot() =\n %v\nwant\n %v", got, want)
+	}
+	if got := len(tracker.Stale(c.t)); got != 5 {
+		t.Errorf("Stale() has %d jobs, want 5", got)
+	}
+}
+
+func TestNoThreshold(t *testing.T) {
+	tracker := NewTracker(nil)
+	tracker.Register("Alpha", JobSpot)
+	if stale := tracker.Stale(time.Now().Add(24 * time.Hour)); len(stale) != 0 {
+		t.Errorf("Stale() = %+v, want none without thresholds", stale)
+	}
+}
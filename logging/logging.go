@@ -0,0 +1,179 @@
+// Package logging configures the slog default logger and provides component
+// loggers and sampling for repetitive warnings.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Setup installs the default logger. level is debug, info, warn or error and
+// format is text or json.
+func Setup(level, format string, w io.Writer) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("invalid log format %q, expected text or json", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// Fatal logs at error level and exits, like log.Fatalf for structured logs.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// For returns a logger with the given attributes that always writes through the
+// current default logger, so package level loggers created before Setup still
+// follow the configured level and format.
+func For(args ...any) *slog.Logger {
+	return slog.New(deferredHandler{}).With(args...)
+}
+
+// Exchange returns the logger used by an exchange connector.
+func Exchange(name string) *slog.Logger {
+	return For("exchange", name)
+}
+
+// deferredHandler resolves slog.Default() on every call and replays the
+// attributes and groups added to it.
+type deferredHandler struct {
+	wrap []func(slog.Handler) slog.Handler
+}
+
+func (h deferredHandler) handler() slog.Handler {
+	next := slog.Default().Handler()
+	for _, w := range h.wrap {
+		next = w(next)
+	}
+	return next
+}
+
+func (h deferredHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return slog.Default().Handler().Enabled(ctx, level)
+}
+
+func (h deferredHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler().Handle(ctx, r)
+}
+
+func (h deferredHandler) with(w func(slog.Handler) slog.Handler) deferredHandler {
+	wrap := make([]func(slog.Handler) slog.Handler, len(h.wrap), len(h.wrap)+1)
+	copy(wrap, h.wrap)
+	return deferredHandler{wrap: append(wrap, w)}
+}
+
+func (h deferredHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h deferredHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+var (
+	sampleMu       sync.RWMutex
+	sampleInterval = time.Minute
+)
+
+// SetSampleInterval sets how often a sampled message is logged per key, 0 disables sampling.
+func SetSampleInterval(d time.Duration) {
+	sampleMu.Lock()
+	defer sampleMu.Unlock()
+	sampleInterval = d
+}
+
+func currentSampleInterval() time.Duration {
+	sampleMu.RLock()
+	defer sampleMu.RUnlock()
+	return sampleInterval
+}
+
+// Sampler limits repetitive messages, such as a parse warning repeated for
+// every symbol of a response, to one record per key and interval. The record
+// that gets through carries the number of suppressed ones.
+type Sampler struct {
+	mu   sync.Mutex
+	keys map[string]*sampleState
+}
+
+type sampleState struct {
+	lastLogged time.Time
+	suppressed int
+}
+
+// NewSampler creates an empty sampler.
+func NewSampler() *Sampler {
+	return &Sampler{keys: make(map[string]*sampleState)}
+}
+
+// allow reports whether a message for key should be logged now and how many
+// were suppressed since the last one.
+func (s *Sampler) allow(key string, now time.Time) (bool, int) {
+	interval := currentSampleInterval()
+	if interval <= 0 {
+		return true, 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.keys[key]
+	if !ok {
+		st = &sampleState{}
+		s.keys[key] = st
+	}
+	if !st.lastLogged.IsZero() && now.Sub(st.lastLogged) < interval {
+		st.suppressed++
+		return false, 0
+	}
+	suppressed := st.suppressed
+	st.lastLogged = now
+	st.suppressed = 0
+	return true, suppressed
+}
+
+// Log writes the message at level unless another message with the same key
+// was logged within the sample interval.
+func (s *Sampler) Log(logger *slog.Logger, level slog.Level, key, msg string, args ...any) {
+	if !logger.Enabled(context.Background(), level) {
+		return
+	}
+	ok, suppressed := s.allow(key, time.Now())
+	if !ok {
+		return
+	}
+	if suppressed > 0 {
+		args = append(args, "suppressed", suppressed)
+	}
+	logger.Log(context.Background(), level, msg, args...)
+}
+
+// Warn is Log at warn level.
+func (s *Sampler) Warn(logger *slog.Logger, key, msg string, args ...any) {
+	s.Log(logger, slog.LevelWarn, key, msg, args...)
+}
+
+// Debug is Log at debug level.
+func (s *Sampler) Debug(logger *slog.Logger, key, msg string, args ...any) {
+	s.Log(logger, slog.LevelDebug, key, msg, args...)
+}
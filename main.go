@@ -1,191 +1,518 @@
 package main
 
 import (
-	"log"
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
+	"Updater/alerts"
 	"Updater/api"
 	"Updater/config"
 	"Updater/db"
 	backpack "Updater/exchanges/backpack"
 	binance "Updater/exchanges/binance"
+	bitfinex "Updater/exchanges/bitfinex"
 	bitget "Updater/exchanges/bitget"
 	bybit "Updater/exchanges/bybit"
+	coinbase "Updater/exchanges/coinbase"
+	"Updater/exchanges/dex"
+	dydx "Updater/exchanges/dydx"
 	gate "Updater/exchanges/gate"
+	"Updater/exchanges/generic"
+	"Updater/exchanges/httpclient"
 	huobi "Updater/exchanges/huobi"
+	hyperliquid "Updater/exchanges/hyperliquid"
 	kraken "Updater/exchanges/kraken"
 	kuCoin "Updater/exchanges/kuCoin"
 	mexc "Updater/exchanges/mexc"
 	okx "Updater/exchanges/okx"
+	"Updater/exchanges/signing"
 	whiteBIT "Updater/exchanges/whiteBIT"
-
-	"github.com/go-co-op/gocron/v2"
+	"Updater/health"
+	"Updater/logging"
+	"Updater/metrics"
+	"Updater/scheduler"
+	"Updater/simulator"
 )
 
 func main() {
 	// Load configuration
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatalf("Error loading configuration: %v", err)
+		logging.Fatal("error loading configuration", "error", err)
+	}
+	if err := logging.Setup(cfg.LogLevel, cfg.LogFormat, os.Stderr); err != nil {
+		logging.Fatal("error configuring logging", "error", err)
 	}
+	logging.SetSampleInterval(cfg.LogSampleInterval)
+
+	// SIGINT or SIGTERM starts the shutdown; a second signal kills the process
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	// Connect to PostgreSQL database
 	dbConn, err := db.Connect(cfg.DatabaseURL)
 	if err != nil {
-		log.Fatalf("Database connection error: %v", err)
+		logging.Fatal("database connection error", "error", err)
 	}
-	defer dbConn.Close()
 
-	// Create scheduler
-	s, err := gocron.NewScheduler()
-	if err != nil {
-		log.Fatalf("Error creating scheduler: %v", err)
-	}
-
-	// List of exchanges to update every 5 seconds
-
-	exchanges := map[string]func() bool{
-		"Backpack": func() bool { return backpack.UpdateAllSpotPairs(dbConn) },
-		"Binance":  func() bool { return binance.UpdateAllSpotPairs(dbConn) },
-		"Bitget":   func() bool { return bitget.UpdateAllSpotPairs(dbConn) },
-		"Bybit":    func() bool { return bybit.UpdateAllSpotPairs(dbConn) },
-		"Gate":     func() bool { return gate.UpdateAllSpotPairs(dbConn) },
-		"Huobi":    func() bool { return huobi.UpdateAllSpotPairs(dbConn) },
-		"Kraken":   func() bool { return kraken.UpdateAllSpotPairs(dbConn) },
-		"KuCoin":   func() bool { return kuCoin.UpdateAllSpotPairs(dbConn) },
-		"MEXC":     func() bool { return mexc.UpdateAllSpotPairs(dbConn) },
-		"OKX":      func() bool { return okx.UpdateAllSpotPairs(dbConn) },
-		"WhiteBIT": func() bool { return whiteBIT.UpdateAllSpotPairs(dbConn) },
-	}
-	networks := map[string]func() bool{
-		"Binance": func() bool {
-			return binance.UpdateAllNetworks(dbConn, os.Getenv("API_KEY_BINANCE"), os.Getenv("API_SECRET_BINANCE"))
-		},
-		"Bitget":   func() bool { return bitget.UpdateAllNetworks(dbConn) },
-		"Huobi":    func() bool { return huobi.UpdateAllNetworks(dbConn) },
-		"WhiteBIT": func() bool { return whiteBIT.UpdateAllNetworks(dbConn) },
-	}
-	futures := map[string]func() bool{
-		"Backpack": func() bool { return backpack.UpdateAllFuturesPairs(dbConn) },
-		"Binance":  func() bool { return binance.UpdateAllFuturesPairs(dbConn) },
-		"Bybit":    func() bool { return bybit.UpdateAllFuturesPairs(dbConn) },
-		"MEXC":     func() bool { return mexc.UpdateAllFuturesPairs(dbConn) },
-	}
-
-	for name, updateFunc := range exchanges {
-		_, err := s.NewJob(
-			gocron.DurationJob(20*time.Second),
-			gocron.NewTask(func(exchange string, fn func() bool) {
-				if fn() {
-					// log.Printf("%s spot pairs updated.", exchange)
-				} else {
-					log.Printf("%s error updating spot pairs.", exchange)
-				}
-			}, name, updateFunc),
-		)
+	// Make sure the API key and alert tables exist (they are not part of recreateTables.sql)
+	// and that older databases have the columns added since
+	for _, file := range []string{"db/queries/createApiKeys.sql", "db/queries/createAlerts.sql", "db/queries/migratePairsStatus.sql", "db/queries/migrateOpenInterest.sql", "db/queries/migrateDatedFutures.sql", "db/queries/migrateInverseFutures.sql"} {
+		query, err := db.LoadSQLFromFile(file)
 		if err != nil {
-			log.Fatalf("Error scheduling %s job: %v", name, err)
-		}
-	}
-	for name, updateFunc := range networks {
-		_, err := s.NewJob(
-			gocron.DurationJob(150*time.Second),
-			gocron.NewTask(func(exchange string, fn func() bool) {
-				if fn() {
-					// log.Printf("%s networks updated.", exchange)
-				} else {
-					log.Printf("%s error updating networks.", exchange)
-				}
-			}, name, updateFunc),
-		)
+			logging.Fatal("error loading SQL file", "error", err)
+		}
+		if err := db.ExecuteSQL(dbConn, query); err != nil {
+			logging.Fatal("error executing SQL file", "file", file, "error", err)
+		}
+	}
+
+	// API key management: ./arbToolDBUpdater apikeys create|revoke|list
+	if len(os.Args) > 1 && os.Args[1] == "apikeys" {
+		code := runAPIKeysCommand(dbConn, os.Args[2:])
+		dbConn.Close()
+		os.Exit(code)
+	}
+
+	// Alert rules are stored in the database and evaluated after every diff job run.
+	// ALERTS_FILE only seeds rules and channels that do not exist yet.
+	if cfg.AlertsFile != "" {
+		alertsCfg, err := alerts.LoadFile(cfg.AlertsFile)
+		if err != nil {
+			logging.Fatal("error loading alerts", "error", err)
+		}
+		if err := alerts.SeedFromFile(context.Background(), dbConn, alertsCfg); err != nil {
+			logging.Fatal("error seeding alerts", "error", err)
+		}
+	}
+	alertEngine := alerts.NewEngine(dbConn)
+	if err := alertEngine.Reload(context.Background()); err != nil {
+		logging.Fatal("error loading alerts", "error", err)
+	}
+	alertRules, alertChannels := alertEngine.Counts()
+	slog.Info("alerts loaded", "rules", alertRules, "channels", alertChannels)
+
+	// Every exchange job reports its outcome here for /api/v1/exchanges/status and /api/health
+	tracker := health.NewTracker(func(job string) time.Duration {
+		if job == health.JobNetworks {
+			return cfg.NetworksStaleAfter
+		}
+		return cfg.StaleAfter
+	})
+
+	// Markets each connector can collect; which of them run and how often is
+	// set by the scheduler config (SCHEDULER_CONFIG)
+	connectors := scheduler.Connectors{
+		"Backpack": {
+			scheduler.MarketSpot:    func(ctx context.Context) (int, error) { return backpack.UpdateAllSpotPairs(ctx, dbConn) },
+			scheduler.MarketFutures: func(ctx context.Context) (int, error) { return backpack.UpdateAllFuturesPairs(ctx, dbConn) },
+		},
+		"Binance": {
+			scheduler.MarketSpot:    func(ctx context.Context) (int, error) { return binance.UpdateAllSpotPairs(ctx, dbConn) },
+			scheduler.MarketFutures: func(ctx context.Context) (int, error) { return binance.UpdateAllFuturesPairs(ctx, dbConn) },
+		},
+		"Bitfinex": {
+			scheduler.MarketSpot:     func(ctx context.Context) (int, error) { return bitfinex.UpdateAllSpotPairs(ctx, dbConn) },
+			scheduler.MarketNetworks: func(ctx context.Context) (int, error) { return bitfinex.UpdateAllNetworks(ctx, dbConn) },
+		},
+		"Bitget": {
+			scheduler.MarketSpot:     func(ctx context.Context) (int, error) { return bitget.UpdateAllSpotPairs(ctx, dbConn) },
+			scheduler.MarketFutures:  func(ctx context.Context) (int, error) { return bitget.UpdateAllFuturesPairs(ctx, dbConn) },
+			scheduler.MarketNetworks: func(ctx context.Context) (int, error) { return bitget.UpdateAllNetworks(ctx, dbConn) },
+		},
+		"Bybit": {
+			scheduler.MarketSpot:    func(ctx context.Context) (int, error) { return bybit.UpdateAllSpotPairs(ctx, dbConn) },
+			scheduler.MarketFutures: func(ctx context.Context) (int, error) { return bybit.UpdateAllFuturesPairs(ctx, dbConn) },
+		},
+		"Coinbase": {
+			scheduler.MarketSpot:     func(ctx context.Context) (int, error) { return coinbase.UpdateAllSpotPairs(ctx, dbConn) },
+			scheduler.MarketNetworks: func(ctx context.Context) (int, error) { return coinbase.UpdateAllNetworks(ctx, dbConn) },
+		},
+		"dYdX": {
+			scheduler.MarketFutures: func(ctx context.Context) (int, error) { return dydx.UpdateAllFuturesPairs(ctx, dbConn) },
+		},
+		"Gate": {
+			scheduler.MarketSpot:     func(ctx context.Context) (int, error) { return gate.UpdateAllSpotPairs(ctx, dbConn) },
+			scheduler.MarketFutures:  func(ctx context.Context) (int, error) { return gate.UpdateAllFuturesPairs(ctx, dbConn) },
+			scheduler.MarketNetworks: func(ctx context.Context) (int, error) { return gate.UpdateAllNetworks(ctx, dbConn) },
+		},
+		"Huobi": {
+			scheduler.MarketSpot:     func(ctx context.Context) (int, error) { return huobi.UpdateAllSpotPairs(ctx, dbConn) },
+			scheduler.MarketFutures:  func(ctx context.Context) (int, error) { return huobi.UpdateAllFuturesPairs(ctx, dbConn) },
+			scheduler.MarketNetworks: func(ctx context.Context) (int, error) { return huobi.UpdateAllNetworks(ctx, dbConn) },
+		},
+		"Hyperliquid": {
+			scheduler.MarketFutures: func(ctx context.Context) (int, error) { return hyperliquid.UpdateAllFuturesPairs(ctx, dbConn) },
+		},
+		"Kraken": {
+			scheduler.MarketSpot: func(ctx context.Context) (int, error) { return kraken.UpdateAllSpotPairs(ctx, dbConn) },
+		},
+		"KuCoin": {
+			scheduler.MarketSpot:     func(ctx context.Context) (int, error) { return kuCoin.UpdateAllSpotPairs(ctx, dbConn) },
+			scheduler.MarketFutures:  func(ctx context.Context) (int, error) { return kuCoin.UpdateAllFuturesPairs(ctx, dbConn) },
+			scheduler.MarketNetworks: func(ctx context.Context) (int, error) { return kuCoin.UpdateAllNetworks(ctx, dbConn) },
+		},
+		"MEXC": {
+			scheduler.MarketSpot:    func(ctx context.Context) (int, error) { return mexc.UpdateAllSpotPairs(ctx, dbConn) },
+			scheduler.MarketFutures: func(ctx context.Context) (int, error) { return mexc.UpdateAllFuturesPairs(ctx, dbConn) },
+		},
+		"OKX": {
+			scheduler.MarketSpot:    func(ctx context.Context) (int, error) { return okx.UpdateAllSpotPairs(ctx, dbConn) },
+			scheduler.MarketFutures: func(ctx context.Context) (int, error) { return okx.UpdateAllFuturesPairs(ctx, dbConn) },
+		},
+		"WhiteBIT": {
+			scheduler.MarketSpot:     func(ctx context.Context) (int, error) { return whiteBIT.UpdateAllSpotPairs(ctx, dbConn) },
+			scheduler.MarketNetworks: func(ctx context.Context) (int, error) { return whiteBIT.UpdateAllNetworks(ctx, dbConn) },
+		},
+	}
+
+	// Network data of these exchanges comes from signed endpoints, so their
+	// networks market only exists when the API credentials are configured
+	signedNetworks := map[string]func(context.Context, *sql.DB, signing.Credentials) (int, error){
+		"Backpack": backpack.UpdateAllNetworks,
+		"Binance":  binance.UpdateAllNetworks,
+		"Bybit":    bybit.UpdateAllNetworks,
+		"MEXC":     mexc.UpdateAllNetworks,
+		"OKX":      okx.UpdateAllNetworks,
+	}
+	for exchange, update := range signedNetworks {
+		creds := cfg.ExchangeCredentials[exchange]
+		if !creds.Configured() {
+			slog.Info("no API credentials, not collecting networks", "exchange", exchange)
+			continue
+		}
+		connectors[exchange][scheduler.MarketNetworks] = func(ctx context.Context) (int, error) { return update(ctx, dbConn, creds) }
+	}
+
+	// Exchanges defined in CONNECTORS_DIR are collected by the generic connector
+	if cfg.ConnectorsDir != "" {
+		defs, err := generic.LoadDir(cfg.ConnectorsDir)
 		if err != nil {
-			log.Fatalf("Error scheduling %s network job: %v", name, err)
+			logging.Fatal("error loading connector definitions", "error", err)
 		}
+		for _, def := range defs {
+			if _, ok := connectors[def.Name]; ok {
+				logging.Fatal("connector definition uses the name of an exchange connector", "exchange", def.Name)
+			}
+			if def.RateLimit != nil {
+				httpclient.Configure(def.Name, def.RateLimit.Config())
+			}
+			connectors[def.Name] = scheduler.Tasks{
+				scheduler.MarketSpot: func(ctx context.Context) (int, error) { return generic.UpdateAllSpotPairs(ctx, dbConn, def) },
+			}
+		}
+		slog.Info("connector definitions loaded", "exchanges", len(defs))
 	}
 
-	for name, updateFunc := range futures {
-		_, err := s.NewJob(
-			gocron.DurationJob(10*time.Second),
-			gocron.NewTask(func(exchange string, fn func() bool) {
-				if fn() {
-					// log.Printf("%s spot pairs updated.", exchange)
-				} else {
-					log.Printf("%s error updating futures pairs.", exchange)
-				}
-			}, name, updateFunc),
-		)
+	// On-chain pools are collected as the spot markets of synthetic exchanges
+	if cfg.DEXPoolsFile != "" {
+		dexCfg, err := dex.Load(cfg.DEXPoolsFile, cfg.DEXRPCURL)
 		if err != nil {
-			log.Fatalf("Error scheduling %s job: %v", name, err)
+			logging.Fatal("error loading DEX pools", "error", err)
+		}
+		for _, exchange := range dexCfg.Exchanges() {
+			if _, ok := connectors[exchange]; ok {
+				logging.Fatal("DEX pools use the name of an exchange connector", "exchange", exchange)
+			}
+			connectors[exchange] = scheduler.Tasks{
+				scheduler.MarketSpot: func(ctx context.Context) (int, error) { return dex.UpdateAllSpotPairs(ctx, dbConn, dexCfg, exchange) },
+			}
 		}
+		slog.Info("DEX pools loaded", "pools", len(dexCfg.Pools), "exchanges", dexCfg.Exchanges())
 	}
 
+	// Alerts are evaluated by the scheduler after a diff job, so shutdown waits for them too
+	var s *scheduler.Scheduler
+
 	// Mutex to prevent diff jobs from running simultaneously (avoids deadlocks)
 	var diffMutex sync.Mutex
 
-	updateDiffsSqlJob, err := s.NewJob(
-		gocron.DurationJob(10*time.Second),
-		gocron.NewTask(
-			func() {
-				diffMutex.Lock()
-				defer diffMutex.Unlock()
-
-				query, err := db.LoadSQLFromFile("db/queries/updateDiffs.sql")
-				if err != nil {
-					log.Println("Error loading SQL file:", err)
-					return
-				}
-
-				err = db.ExecuteSQL(dbConn, query)
-				if err != nil {
-					log.Println("Error executing SQL job (updateDiffs):", err)
-				}
-			},
-		),
-	)
+	// Markets are classified right before each diff run so stale and delisted ones are skipped
+	pairStatusCfg := db.PairStatusConfig{
+		StaleAfter:        cfg.PairsStaleAfter,
+		DelistAfter:       cfg.PairsDelistAfter,
+		DelistedRetention: cfg.PairsDelistedRetention,
+	}
+
+	diffs := map[string]scheduler.DiffFunc{
+		scheduler.MarketSpot: func(ctx context.Context) {
+			diffMutex.Lock()
+			defer diffMutex.Unlock()
+			if runDiffJob(ctx, dbConn, pairStatusCfg, "pairs", "diffs", "db/queries/updateDiffs.sql") {
+				api.InvalidateCache(api.CacheDiffs)
+				s.Go(func(ctx context.Context) { alertEngine.Evaluate(ctx, alerts.MarketSpot) })
+			}
+		},
+		scheduler.MarketFutures: func(ctx context.Context) {
+			diffMutex.Lock()
+			defer diffMutex.Unlock()
+			if runDiffJob(ctx, dbConn, pairStatusCfg, "pairsfutures", "diffsfutures", "db/queries/updateDiffsFutures.sql") {
+				api.InvalidateCache(api.CacheDiffsFutures)
+				s.Go(func(ctx context.Context) { alertEngine.Evaluate(ctx, alerts.MarketFutures) })
+			}
+		},
+		scheduler.DiffCalendar: func(ctx context.Context) {
+			diffMutex.Lock()
+			defer diffMutex.Unlock()
+			if runDiffJob(ctx, dbConn, pairStatusCfg, "pairsfutures", "diffscalendar", "db/queries/updateDiffsCalendar.sql") {
+				api.InvalidateCache(api.CacheDiffsCalendar)
+			}
+		},
+		scheduler.DiffInverse: func(ctx context.Context) {
+			diffMutex.Lock()
+			defer diffMutex.Unlock()
+			if runDiffJob(ctx, dbConn, pairStatusCfg, "pairsfutures", "diffsinverse", "db/queries/updateDiffsInverse.sql") {
+				api.InvalidateCache(api.CacheDiffsInverse)
+			}
+		},
+	}
+
+	// Exchange jobs feed the tracker and metrics and drop the cached pairs responses
+	runner := func(ctx context.Context, job scheduler.ExchangeJob, task scheduler.Task) {
+		fn := metrics.InstrumentJob(job.Exchange, job.Market, func() (int, error) { return task(ctx) })
+		if !runExchangeJob(tracker, job.Exchange, job.Market, fn) {
+			return
+		}
+		switch job.Market {
+		case scheduler.MarketSpot:
+			api.InvalidateCache(api.CachePairs)
+		case scheduler.MarketFutures:
+			api.InvalidateCache(api.CachePairsFutures)
+		}
+	}
+
+	schedCfg, err := scheduler.Load(cfg.SchedulerFile)
 	if err != nil {
-		log.Fatalf("Error scheduling SQL job: %v", err)
-	}
-	log.Println("SQL job created (updateDiffs) with ID:", updateDiffsSqlJob.ID())
-
-	updateDiffsFuturesSqlJob, err := s.NewJob(
-		gocron.DurationJob(10*time.Second),
-		gocron.NewTask(
-			func() {
-				diffMutex.Lock()
-				defer diffMutex.Unlock()
-
-				query, err := db.LoadSQLFromFile("db/queries/updateDiffsFutures.sql")
-				if err != nil {
-					log.Println("Error loading SQL file:", err)
-					return
-				}
-
-				err = db.ExecuteSQL(dbConn, query)
-				if err != nil {
-					log.Println("Error executing SQL job (updateDiffsFutures):", err)
-				}
-			},
-		),
-	)
+		logging.Fatal("error loading scheduler config", "error", err)
+	}
+	s, err = scheduler.New(connectors, diffs, runner)
 	if err != nil {
-		log.Fatalf("Error scheduling SQL job: %v", err)
+		logging.Fatal("error creating scheduler", "error", err)
+	}
+	changes, err := s.Apply(schedCfg)
+	if err != nil {
+		logging.Fatal("error scheduling jobs", "error", err)
+	}
+	trackChanges(tracker, changes)
+
+	// The scheduler config is reloaded on SIGHUP and, when watching is enabled,
+	// whenever the file changes. An invalid config keeps the current jobs.
+	if cfg.SchedulerFile != "" {
+		var reloadMu sync.Mutex
+		reload := func(reason string) {
+			reloadMu.Lock()
+			defer reloadMu.Unlock()
+
+			logger := slog.With("path", cfg.SchedulerFile, "reason", reason)
+			newCfg, err := scheduler.Load(cfg.SchedulerFile)
+			if err != nil {
+				logger.Error("scheduler config rejected, keeping the current jobs", "error", err)
+				return
+			}
+			changes, err := s.Apply(newCfg)
+			trackChanges(tracker, changes)
+			if errors.Is(err, scheduler.ErrInvalidConfig) {
+				logger.Error("scheduler config rejected, keeping the current jobs", "error", err)
+				return
+			}
+			if err != nil {
+				logger.Error("scheduler config applied only partly", "error", err,
+					"added", len(changes.Added), "removed", len(changes.Removed), "rescheduled", len(changes.Rescheduled))
+				return
+			}
+			if changes.Empty() {
+				logger.Info("scheduler config reloaded, no changes")
+				return
+			}
+			logger.Info("scheduler config reloaded",
+				"added", len(changes.Added), "removed", len(changes.Removed), "rescheduled", len(changes.Rescheduled))
+		}
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				reload("SIGHUP")
+			}
+		}()
+
+		if cfg.SchedulerWatchInterval > 0 {
+			go scheduler.WatchFile(ctx, cfg.SchedulerFile, cfg.SchedulerWatchInterval, func() {
+				reload("file changed")
+			})
+		}
+	}
+
+	// SIMULATOR_SCENARIO replaces every exchange with the local simulator
+	if cfg.SimulatorScenario != "" {
+		startSimulator(ctx, cfg.SimulatorScenario, cfg.SimulatorAddr)
 	}
-	log.Println("SQL job created (updateDiffsFutures) with ID:", updateDiffsFuturesSqlJob.ID())
 
 	// Start scheduler
 	s.Start()
 
-	// Start API server in a separate goroutine
+	router, err := api.SetupRouter(dbConn, cfg, alertEngine, tracker)
+	if err != nil {
+		logging.Fatal("error setting up the API", "error", err)
+	}
+	server := &http.Server{
+		Addr:    cfg.APIPort,
+		Handler: router,
+	}
 	go func() {
-		router := api.SetupRouter(dbConn)
-		log.Printf("Starting API server on %s", cfg.APIPort)
-		if err := router.Run(cfg.APIPort); err != nil {
-			log.Fatalf("API server error: %v", err)
+		slog.Info("starting API server", "addr", cfg.APIPort)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Fatal("API server error", "error", err)
 		}
 	}()
 
-	// Block indefinitely
-	select {}
+	<-ctx.Done()
+	stop()
+	slog.Info("shutting down", "timeout", cfg.ShutdownTimeout)
+
+	// Jobs stop first so in-flight fetches are cancelled and started database
+	// writes can finish, then the API drains its open requests
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	clean := true
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		slog.Error("error stopping scheduler", "error", err)
+		clean = false
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("error stopping API server", "error", err)
+		clean = false
+	}
+	if err := dbConn.Close(); err != nil {
+		slog.Error("error closing database", "error", err)
+	}
+
+	if !clean {
+		os.Exit(1)
+	}
+	slog.Info("shutdown complete")
+}
+
+// startSimulator serves the exchanges of the scenario at addr and routes the
+// exchange requests there until ctx is done.
+func startSimulator(ctx context.Context, path, addr string) {
+	scenario, err := simulator.Load(path)
+	if err != nil {
+		logging.Fatal("error loading simulator scenario", "error", err)
+	}
+	sim, err := simulator.New(scenario)
+	if err != nil {
+		logging.Fatal("error creating simulator", "error", err)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logging.Fatal("error starting simulator", "error", err)
+	}
+
+	server := &http.Server{Handler: sim}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Fatal("simulator error", "error", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go sim.Run(ctx)
+
+	baseURL := "http://" + listener.Addr().String()
+	httpclient.WrapTransport(func(next http.RoundTripper) http.RoundTripper {
+		return sim.Transport(baseURL, next)
+	})
+	slog.Warn("exchanges are simulated, no real exchange is queried",
+		"scenario", path, "addr", baseURL, "exchanges", scenario.Exchanges, "seed", scenario.Seed)
+}
+
+// runExchangeJob runs one exchange job through the tracker and logs its outcome.
+// It reports whether the job succeeded.
+func runExchangeJob(tracker *health.Tracker, exchange, job string, fn func() (int, error)) bool {
+	start := time.Now()
+	var rows int
+	err := tracker.Run(exchange, job, func() (int, error) {
+		var err error
+		rows, err = fn()
+		return rows, err
+	})
+	logger := slog.With("exchange", exchange, "job", job, "duration", time.Since(start))
+	if errors.Is(err, context.Canceled) {
+		logger.Info("exchange job cancelled")
+		return false
+	}
+	if err != nil {
+		logger.Error("exchange job failed", "error", err)
+		return false
+	}
+	logger.Debug("exchange job finished", "rows", rows)
+	return true
+}
+
+// trackChanges keeps the tracker in line with the scheduled exchange jobs.
+func trackChanges(tracker *health.Tracker, changes scheduler.Changes) {
+	for _, job := range changes.Added {
+		tracker.Register(job.Exchange, job.Market)
+	}
+	for _, job := range changes.Removed {
+		tracker.Remove(job.Exchange, job.Market)
+	}
+}
+
+// runDiffJob classifies the markets of pairsTable and recalculates diffsTable
+// with the given SQL file. It reports whether the diffs were updated.
+func runDiffJob(ctx context.Context, dbConn *sql.DB, statusCfg db.PairStatusConfig, pairsTable, diffsTable, sqlFile string) bool {
+	logger := slog.With("job", "diffs", "table", diffsTable)
+
+	if err := db.UpdatePairStatuses(dbConn, pairsTable, statusCfg); err != nil {
+		logger.Error("error updating pair statuses", "error", err)
+	}
+
+	query, err := db.LoadSQLFromFile(sqlFile)
+	if err != nil {
+		logger.Error("error loading SQL file", "error", err)
+		return false
+	}
+
+	// A diff run that started is allowed to finish on shutdown, only its timeout stops it
+	dbCtx := context.WithoutCancel(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		dbCtx, cancel = context.WithDeadline(dbCtx, deadline)
+		defer cancel()
+	}
+
+	start := time.Now()
+	if err := db.ExecuteSQLContext(dbCtx, dbConn, query); err != nil {
+		logger.Error("error executing diff job", "duration", time.Since(start), "error", err)
+		return false
+	}
+	recordDiffMetrics(dbConn, diffsTable, time.Since(start))
+	logger.Debug("diff job finished", "duration", time.Since(start))
+	return true
+}
+
+// recordDiffMetrics exports the duration of a diff job and the resulting table size.
+func recordDiffMetrics(dbConn *sql.DB, table string, took time.Duration) {
+	metrics.DiffJobDuration.WithLabelValues(table).Observe(took.Seconds())
+
+	var rows int
+	if err := dbConn.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&rows); err != nil {
+		slog.Error("error counting diff rows", "table", table, "error", err)
+		return
+	}
+	metrics.DiffRows.WithLabelValues(table).Set(float64(rows))
 }
@@ -0,0 +1,147 @@
+// Package metrics defines the Prometheus metrics exported on /metrics.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// JobDuration is the time an exchange job took to fetch and store its data.
+	JobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "updater_exchange_job_duration_seconds",
+		Help:    "Duration of exchange update jobs (fetch and upsert).",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 20, 30, 60},
+	}, []string{"exchange", "job", "result"})
+
+	// RowsUpserted counts rows written by exchange jobs.
+	RowsUpserted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "updater_exchange_rows_upserted_total",
+		Help: "Rows upserted by exchange update jobs.",
+	}, []string{"exchange", "job"})
+
+	// ParseWarnings counts values connectors could not parse and replaced with 0.
+	ParseWarnings = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "updater_exchange_parse_warnings_total",
+		Help: "Numeric values from exchange APIs that failed to parse and fell back to 0.",
+	}, []string{"exchange"})
+
+	// PartialFailures counts optional parts of exchange jobs, such as the inverse
+	// contracts, that failed while the rest of the job was stored.
+	PartialFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "updater_exchange_partial_failures_total",
+		Help: "Optional parts of exchange jobs that failed while the rest of the job was stored.",
+	}, []string{"exchange", "part"})
+
+	// HTTPResponses counts outgoing HTTP responses per host and status code.
+	HTTPResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "updater_exchange_http_responses_total",
+		Help: "Responses from exchange APIs by host and status code, code is \"error\" for transport failures.",
+	}, []string{"host", "code"})
+
+	// HTTPRetries counts exchange requests retried after a 429, 5xx or network error.
+	HTTPRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "updater_exchange_http_retries_total",
+		Help: "Exchange API requests retried after a 429, 5xx or network error.",
+	}, []string{"exchange"})
+
+	// DiffJobDuration is the time one diff calculation took.
+	DiffJobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "updater_diff_job_duration_seconds",
+		Help:    "Duration of diff calculation jobs.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 20, 30},
+	}, []string{"table"})
+
+	// DiffRows is the number of rows in a diff table after the last run.
+	DiffRows = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "updater_diff_rows",
+		Help: "Rows in the diff table after the last diff job.",
+	}, []string{"table"})
+
+	// DeadlockRetries counts SQL executions retried after a deadlock.
+	DeadlockRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "updater_db_deadlock_retries_total",
+		Help: "SQL executions retried because of a deadlock.",
+	})
+
+	// JobLag is how late a scheduled job started compared to its interval.
+	JobLag = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "updater_scheduler_job_lag_seconds",
+		Help:    "Delay between the expected and the actual start of scheduled jobs.",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 2, 5, 10, 30},
+	}, []string{"job"})
+
+	// APIRequestDuration is the latency of API requests by route.
+	APIRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "updater_api_request_duration_seconds",
+		Help:    "Latency of API requests by route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+)
+
+// InstrumentJob wraps an exchange job so its duration and row count are recorded.
+func InstrumentJob(exchange, job string, fn func() (int, error)) func() (int, error) {
+	return func() (int, error) {
+		start := time.Now()
+		rows, err := fn()
+		result := "success"
+		if err != nil {
+			result = "error"
+		}
+		JobDuration.WithLabelValues(exchange, job, result).Observe(time.Since(start).Seconds())
+		if err == nil {
+			RowsUpserted.WithLabelValues(exchange, job).Add(float64(rows))
+		}
+		return rows, err
+	}
+}
+
+var (
+	lagMu      sync.Mutex
+	lastStarts = make(map[string]time.Time)
+)
+
+// ObserveJobStart records the lag of a job that should run every interval.
+// The first run of a job only sets the reference point.
+func ObserveJobStart(job string, interval time.Duration) {
+	now := time.Now()
+
+	lagMu.Lock()
+	prev, ok := lastStarts[job]
+	lastStarts[job] = now
+	lagMu.Unlock()
+
+	if !ok {
+		return
+	}
+	lag := now.Sub(prev) - interval
+	if lag < 0 {
+		lag = 0
+	}
+	JobLag.WithLabelValues(job).Observe(lag.Seconds())
+}
+
+// instrumentedTransport counts responses of outgoing requests.
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+// InstrumentTransport wraps an http.RoundTripper so every response is counted in HTTPResponses.
+func InstrumentTransport(next http.RoundTripper) http.RoundTripper {
+	return &instrumentedTransport{next: next}
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		HTTPResponses.WithLabelValues(req.URL.Host, "error").Inc()
+		return resp, err
+	}
+	HTTPResponses.WithLabelValues(req.URL.Host, strconv.Itoa(resp.StatusCode)).Inc()
+	return resp, nil
+}
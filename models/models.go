@@ -18,6 +18,14 @@ type Pair struct {
 	CreatedAt             time.Time `json:"created_at"`
 }
 
+// Contract types of futures. Linear contracts are margined and settled in the
+// quote asset. Inverse (coin-margined) contracts are priced in USD but margined
+// and settled in the base asset, so each contract is worth a fixed amount of USD.
+const (
+	ContractLinear  = "linear"
+	ContractInverse = "inverse"
+)
+
 type PairFutures struct {
 	PairKey               string    `json:"key"`      // Composite key: symbol_exchange_market (e.g., "BTCUSDT_Binance_spot")
 	Symbol                string    `json:"symbol"`   // Trading symbol (e.g., "BTCUSDT")
@@ -25,18 +33,80 @@ type PairFutures struct {
 	Market                string    `json:"market"`   // Market type (e.g., "spot" or "futures")
 	MarkPrice             float64   `json:"markprice"`
 	IndexPrice            float64   `json:"indexprice"`
-	BaseAsset             string    `json:"baseAsset"`   // Base asset (e.g., "BTC")
-	QuoteAsset            string    `json:"quoteAsset"`  // Quote asset (e.g., "USDT")
-	DisplayName           string    `json:"displayName"` // Formatted display (e.g., "BTC/USDT")
-	FundingRatePercent    float64   `json:"fundingRatePercent"`
+	BaseAsset             string    `json:"baseAsset"`          // Base asset (e.g., "BTC")
+	QuoteAsset            string    `json:"quoteAsset"`         // Quote asset (e.g., "USDT")
+	DisplayName           string    `json:"displayName"`        // Formatted display (e.g., "BTC/USDT")
+	FundingRatePercent    float64   `json:"fundingRatePercent"` // Fraction per FundingPeriodHours (0.0001 is 0.01%)
 	NextFundingTimestamp  int       `json:"nextFundingTimestamp"`
 	PriceChangePercent24h float64   `json:"priceChangePercent24h"`
 	BaseVolume24h         float64   `json:"baseVolume24h"`
 	QuoteVolume24h        float64   `json:"quoteVolume24h"`
+	OpenInterest          float64   `json:"openInterest"`  // In the base asset, 0 when the exchange is not collected with it
+	Expiry                int64     `json:"expiry"`        // Delivery time in ms of a dated contract, 0 for perpetuals
+	ContractType          string    `json:"contractType"`  // ContractLinear or ContractInverse, empty is linear
+	ContractValue         float64   `json:"contractValue"` // Size of one contract: in the base asset if linear, in USD if inverse; 0 when unknown
 	UpdatedAt             time.Time `json:"updated_at"`
 	CreatedAt             time.Time `json:"created_at"`
 }
 
+// IsInverse reports whether the contract is margined and settled in the base asset.
+func (p PairFutures) IsInverse() bool {
+	return p.ContractType == ContractInverse
+}
+
+// Notional is the value of contracts at price, in the quote asset.
+func (p PairFutures) Notional(contracts, price float64) float64 {
+	if p.IsInverse() {
+		return contracts * p.ContractValue
+	}
+	return contracts * p.ContractValue * price
+}
+
+// PnL is the profit of a long position of contracts opened at entry and closed
+// at exit, in the settlement asset: the quote asset for linear contracts and
+// the base asset for inverse ones. Shorts earn the negative.
+func (p PairFutures) PnL(contracts, entry, exit float64) float64 {
+	if p.IsInverse() {
+		return contracts * p.ContractValue * (1/entry - 1/exit)
+	}
+	return contracts * p.ContractValue * (exit - entry)
+}
+
+// FundingPeriodHours is the period FundingRatePercent is quoted for. It is the
+// 8 hour period of the centralized exchanges, so funding diffs compare the same
+// period on every exchange. Despite its name the rate is the fraction the
+// exchange reports, not multiplied by 100.
+const FundingPeriodHours = 8
+
+// FundingRateFromHourly converts the hourly rate of exchanges that settle
+// funding every hour (Hyperliquid, dYdX) to the FundingPeriodHours rate.
+func FundingRateFromHourly(rate float64) float64 {
+	return rate * FundingPeriodHours
+}
+
+// Network describes deposit and withdrawal availability of a coin on one network of an exchange.
+type Network struct {
+	CoinKey        string    `json:"key"`         // Composite key: coin_exchange_network (e.g., "USDT_Binance_TRX")
+	Coin           string    `json:"coin"`        // Coin (e.g., "USDT")
+	Exchange       string    `json:"exchange"`    // Exchange (e.g., "Binance")
+	Network        string    `json:"network"`     // Network code used by the exchange (e.g., "TRX")
+	NetworkName    string    `json:"networkName"` // Human readable name (e.g., "Tron (TRC20)")
+	DepositEnable  bool      `json:"depositEnable"`
+	WithdrawEnable bool      `json:"withdrawEnable"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// APIKey describes a stored API key. Only the SHA-256 hash of the raw key is persisted.
+type APIKey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	KeyPrefix  string     `json:"keyPrefix"` // First characters of the raw key, used to identify it in listings
+	Role       string     `json:"role"`      // "read" or "admin"
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
 // Example Pair usage:
 // {
 //   key: "BTCUSDT_Binance_spot",
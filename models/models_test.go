@@ -0,0 +1,72 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPairFuturesNotional(t *testing.T) {
+	linear := PairFutures{ContractType: ContractLinear, ContractValue: 0.01}
+	inverse := PairFutures{ContractType: ContractInverse, ContractValue: 100}
+
+	tests := []struct {
+		name      string
+		pair      PairFutures
+		contracts float64
+		price     float64
+		notional  float64
+	}{
+		// 10 contracts of 0.01 BTC are 0.1 BTC, worth 5000 USDT at 50000
+		{name: "linear", pair: linear, contracts: 10, price: 50000, notional: 5000},
+		// 50 contracts of 100 USD are 5000 USD at any price
+		{name: "inverse", pair: inverse, contracts: 50, price: 60000, notional: 5000},
+		// A missing contract type is linear
+		{name: "default", pair: PairFutures{ContractValue: 1}, contracts: 2, price: 100, notional: 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pair.Notional(tt.contracts, tt.price); math.Abs(got-tt.notional) > 1e-9 {
+				t.Errorf("Notional = %v, want %v", got, tt.notional)
+			}
+		})
+	}
+}
+
+func TestPairFuturesPnL(t *testing.T) {
+	linear := PairFutures{ContractType: ContractLinear, ContractValue: 0.01}
+	inverse := PairFutures{ContractType: ContractInverse, ContractValue: 100}
+
+	tests := []struct {
+		name        string
+		pair        PairFutures
+		contracts   float64
+		entry, exit float64
+		pnl         float64
+	}{
+		// 0.1 BTC earns 1000 USDT from 50000 to 60000
+		{name: "linear", pair: linear, contracts: 10, entry: 50000, exit: 60000, pnl: 1000},
+		// 5000 USD buy 0.1 BTC at 50000 and 0.0833 BTC at 60000, so 0.01667 BTC is left over
+		{name: "inverse", pair: inverse, contracts: 50, entry: 50000, exit: 60000, pnl: 5000.0/50000 - 5000.0/60000},
+		// Inverse losses grow faster than gains: the same 10000 down loses 0.025 BTC
+		{name: "inverse loss", pair: inverse, contracts: 50, entry: 50000, exit: 40000, pnl: 5000.0/50000 - 5000.0/40000},
+		// A missing contract type is linear
+		{name: "default", pair: PairFutures{ContractValue: 1}, contracts: 2, entry: 100, exit: 90, pnl: -20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pair.PnL(tt.contracts, tt.entry, tt.exit); math.Abs(got-tt.pnl) > 1e-9 {
+				t.Errorf("PnL = %v, want %v", got, tt.pnl)
+			}
+		})
+	}
+}
+
+func TestFundingRateFromHourly(t *testing.T) {
+	// 0.00125% an hour is 0.01% over the 8 hour period of the centralized exchanges
+	if got, want := FundingRateFromHourly(0.0000125), 0.0001; math.Abs(got-want) > 1e-12 {
+		t.Errorf("FundingRateFromHourly = %v, want %v", got, want)
+	}
+	if got := FundingRateFromHourly(-0.00002); math.Abs(got+0.00016) > 1e-12 {
+		t.Errorf("FundingRateFromHourly(negative) = %v, want -0.00016", got)
+	}
+}
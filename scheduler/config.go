@@ -0,0 +1,301 @@
+package scheduler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Markets an exchange job can collect; they match the job names reported by health.
+const (
+	MarketSpot     = "spot"
+	MarketFutures  = "futures"
+	MarketNetworks = "networks"
+)
+
+// DiffCalendar is the calendar spread calculation over the dated futures and
+// DiffInverse compares the inverse perpetuals with the linear ones; the other
+// diffs are named after their market.
+const (
+	DiffCalendar = "calendar"
+	DiffInverse  = "inverse"
+)
+
+// Duration is a time.Duration written as "20s" or "2m30s" in the config file.
+type Duration time.Duration
+
+// UnmarshalText parses a duration string, used by both the YAML and TOML decoders.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText formats the duration like time.Duration.String.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Schedule controls how often a job runs.
+type Schedule struct {
+	// Interval between two runs.
+	Interval Duration
+	// Jitter randomizes every interval by up to ± this value so exchanges are not hit in lockstep.
+	Jitter Duration
+	// Timeout is the deadline passed to the job, 0 means none.
+	Timeout Duration
+}
+
+// ScheduleOverride is a schedule as written in the config file. Fields that
+// are left out are nil and keep the default, so 0 can turn off a default
+// jitter or timeout.
+type ScheduleOverride struct {
+	Interval *Duration `yaml:"interval" toml:"interval"`
+	Jitter   *Duration `yaml:"jitter" toml:"jitter"`
+	Timeout  *Duration `yaml:"timeout" toml:"timeout"`
+}
+
+// apply returns def with the fields set in o.
+func (o ScheduleOverride) apply(def Schedule) Schedule {
+	if o.Interval != nil {
+		def.Interval = *o.Interval
+	}
+	if o.Jitter != nil {
+		def.Jitter = *o.Jitter
+	}
+	if o.Timeout != nil {
+		def.Timeout = *o.Timeout
+	}
+	return def
+}
+
+func (s Schedule) validate() error {
+	switch {
+	case s.Interval <= 0:
+		return errors.New("interval must be positive")
+	case s.Jitter < 0:
+		return errors.New("jitter must not be negative")
+	case s.Jitter >= s.Interval:
+		return fmt.Errorf("jitter %v must be smaller than interval %v", time.Duration(s.Jitter), time.Duration(s.Interval))
+	case s.Timeout < 0:
+		return errors.New("timeout must not be negative")
+	}
+	return nil
+}
+
+// ExchangeConfig overrides the defaults for one exchange.
+type ExchangeConfig struct {
+	// Enabled defaults to true; false stops every job of the exchange.
+	Enabled *bool `yaml:"enabled" toml:"enabled"`
+	// Markets to collect, all markets the connector supports when omitted.
+	Markets []string `yaml:"markets" toml:"markets"`
+	// Schedules per market, fields left out fall back to the defaults.
+	Schedules map[string]ScheduleOverride `yaml:"schedules" toml:"schedules"`
+}
+
+// Config is the built-in schedules with the config file on top. Exchanges
+// that are not listed run every market they support with the default schedules.
+type Config struct {
+	// Defaults holds the schedule of each market.
+	Defaults map[string]Schedule
+	// Diffs holds the schedules of the spot, futures, calendar and inverse diff calculations.
+	Diffs     map[string]Schedule
+	Exchanges map[string]ExchangeConfig
+}
+
+// fileConfig is the content of the scheduler config file.
+type fileConfig struct {
+	Defaults  map[string]ScheduleOverride `yaml:"defaults" toml:"defaults"`
+	Diffs     map[string]ScheduleOverride `yaml:"diffs" toml:"diffs"`
+	Exchanges map[string]ExchangeConfig   `yaml:"exchanges" toml:"exchanges"`
+}
+
+// Default returns the built-in schedules, used for anything the config file leaves out.
+func Default() *Config {
+	return &Config{
+		Defaults: map[string]Schedule{
+			MarketSpot:     {Interval: Duration(20 * time.Second), Timeout: Duration(20 * time.Second)},
+			MarketFutures:  {Interval: Duration(10 * time.Second), Timeout: Duration(10 * time.Second)},
+			MarketNetworks: {Interval: Duration(150 * time.Second), Timeout: Duration(60 * time.Second)},
+		},
+		Diffs: map[string]Schedule{
+			MarketSpot:    {Interval: Duration(10 * time.Second), Timeout: Duration(30 * time.Second)},
+			MarketFutures: {Interval: Duration(10 * time.Second), Timeout: Duration(30 * time.Second)},
+			DiffCalendar:  {Interval: Duration(30 * time.Second), Timeout: Duration(30 * time.Second)},
+			DiffInverse:   {Interval: Duration(10 * time.Second), Timeout: Duration(30 * time.Second)},
+		},
+	}
+}
+
+// Load reads a YAML (.yaml, .yml) or TOML (.toml) config file on top of the
+// built-in defaults. An empty path returns the defaults.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading scheduler config: %w", err)
+	}
+
+	var file fileConfig
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("error parsing %s: %w", path, err)
+		}
+	case ".toml":
+		dec := toml.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&file); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported scheduler config format %q, expected .yaml, .yml or .toml", filepath.Ext(path))
+	}
+
+	for market, o := range file.Defaults {
+		cfg.Defaults[market] = o.apply(cfg.Defaults[market])
+	}
+	for market, o := range file.Diffs {
+		cfg.Diffs[market] = o.apply(cfg.Diffs[market])
+	}
+	cfg.Exchanges = file.Exchanges
+	return cfg, nil
+}
+
+// ExchangeJob is one resolved exchange job.
+type ExchangeJob struct {
+	Exchange string
+	Market   string
+	Schedule Schedule
+}
+
+// Name identifies the job in logs and metrics.
+func (j ExchangeJob) Name() string {
+	return j.Exchange + " " + j.Market
+}
+
+// Validate checks the config against the available connectors and returns
+// every problem found, not only the first one.
+func (c *Config) Validate(connectors Connectors) error {
+	var errs []error
+
+	for _, market := range sortedKeys(c.Defaults) {
+		s := c.Defaults[market]
+		if !knownMarket(market) {
+			errs = append(errs, fmt.Errorf("defaults: unknown market %q", market))
+			continue
+		}
+		if err := s.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("defaults.%s: %w", market, err))
+		}
+	}
+	for _, market := range sortedKeys(c.Diffs) {
+		s := c.Diffs[market]
+		if market != MarketSpot && market != MarketFutures && market != DiffCalendar && market != DiffInverse {
+			errs = append(errs, fmt.Errorf("diffs: unknown market %q, expected spot, futures, calendar or inverse", market))
+			continue
+		}
+		if err := s.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("diffs.%s: %w", market, err))
+		}
+	}
+
+	for _, name := range sortedKeys(c.Exchanges) {
+		ex := c.Exchanges[name]
+		tasks, ok := connectors[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("exchanges: unknown exchange %q, available: %s", name, strings.Join(connectors.Names(), ", ")))
+			continue
+		}
+		if ex.Markets != nil && len(ex.Markets) == 0 {
+			errs = append(errs, fmt.Errorf("exchanges.%s: markets is empty, use enabled: false to disable the exchange", name))
+		}
+		seen := make(map[string]bool)
+		for _, market := range ex.Markets {
+			switch {
+			case !knownMarket(market):
+				errs = append(errs, fmt.Errorf("exchanges.%s: unknown market %q", name, market))
+			case tasks[market] == nil:
+				errs = append(errs, fmt.Errorf("exchanges.%s: %s is not supported by the connector", name, market))
+			case seen[market]:
+				errs = append(errs, fmt.Errorf("exchanges.%s: market %s listed twice", name, market))
+			}
+			seen[market] = true
+		}
+		for _, market := range sortedKeys(ex.Schedules) {
+			o := ex.Schedules[market]
+			if !knownMarket(market) {
+				errs = append(errs, fmt.Errorf("exchanges.%s.schedules: unknown market %q", name, market))
+				continue
+			}
+			if err := o.apply(c.Defaults[market]).validate(); err != nil {
+				errs = append(errs, fmt.Errorf("exchanges.%s.schedules.%s: %w", name, market, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ExchangeJobs resolves the enabled exchange jobs, ordered by exchange and market.
+func (c *Config) ExchangeJobs(connectors Connectors) []ExchangeJob {
+	var jobs []ExchangeJob
+	for _, name := range connectors.Names() {
+		ex := c.Exchanges[name]
+		if ex.Enabled != nil && !*ex.Enabled {
+			continue
+		}
+		markets := ex.Markets
+		if markets == nil {
+			markets = connectors[name].Markets()
+		}
+		for _, market := range markets {
+			if connectors[name][market] == nil {
+				continue
+			}
+			jobs = append(jobs, ExchangeJob{
+				Exchange: name,
+				Market:   market,
+				Schedule: ex.Schedules[market].apply(c.Defaults[market]),
+			})
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].Exchange != jobs[j].Exchange {
+			return jobs[i].Exchange < jobs[j].Exchange
+		}
+		return jobs[i].Market < jobs[j].Market
+	})
+	return jobs
+}
+
+func knownMarket(market string) bool {
+	return market == MarketSpot || market == MarketFutures || market == MarketNetworks
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
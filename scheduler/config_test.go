@@ -0,0 +1,217 @@
+package scheduler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func noopTask(ctx context.Context) (int, error) { return 0, nil }
+
+// testConnectors has an exchange with every market, one with spot and futures
+// and one with spot only.
+func testConnectors() Connectors {
+	return Connectors{
+		"Alpha": {MarketSpot: noopTask, MarketFutures: noopTask, MarketNetworks: noopTask},
+		"Beta":  {MarketSpot: noopTask, MarketFutures: noopTask},
+		"Gamma": {MarketSpot: noopTask},
+	}
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func seconds(n int) Duration {
+	return Duration(time.Duration(n) * time.Second)
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"scheduler.yaml", `
+defaults:
+  spot:
+    interval: 30s
+    jitter: 5s
+diffs:
+  calendar:
+    interval: 1m
+exchanges:
+  Alpha:
+    markets: [spot, futures]
+    schedules:
+      spot:
+        jitter: 0s
+        timeout: 0s
+  Beta:
+    enabled: false
+`},
+		{"scheduler.toml", `
+[defaults.spot]
+interval = "30s"
+jitter = "5s"
+
+[diffs.calendar]
+interval = "1m"
+
+[exchanges.Alpha]
+markets = ["spot", "futures"]
+
+[exchanges.Alpha.schedules.spot]
+jitter = "0s"
+timeout = "0s"
+
+[exchanges.Beta]
+enabled = false
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(writeConfig(t, tt.name, tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cfg.Validate(testConnectors()); err != nil {
+				t.Fatal(err)
+			}
+
+			// Fields left out keep the built-in values
+			if got, want := cfg.Defaults[MarketSpot], (Schedule{seconds(30), seconds(5), seconds(20)}); got != want {
+				t.Errorf("defaults.spot = %+v, want %+v", got, want)
+			}
+			if got, want := cfg.Diffs[DiffCalendar], (Schedule{seconds(60), 0, seconds(30)}); got != want {
+				t.Errorf("diffs.calendar = %+v, want %+v", got, want)
+			}
+			if got, want := cfg.Diffs[MarketSpot], Default().Diffs[MarketSpot]; got != want {
+				t.Errorf("diffs.spot = %+v, want the default %+v", got, want)
+			}
+
+			want := []ExchangeJob{
+				// An explicit 0 turns off the default jitter and timeout
+				{"Alpha", MarketFutures, Default().Defaults[MarketFutures]},
+				{"Alpha", MarketSpot, Schedule{seconds(30), 0, 0}},
+				{"Gamma", MarketSpot, Schedule{seconds(30), seconds(5), seconds(20)}},
+			}
+			if got := cfg.ExchangeJobs(testConnectors()); !reflect.DeepEqual(got, want) {
+				t.Errorf("jobs:\n got  %+v\n want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadWithoutFile(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Errorf("config = %+v, want the defaults", cfg)
+	}
+	// Every connector runs every market it supports
+	if got := len(cfg.ExchangeJobs(testConnectors())); got != 6 {
+		t.Errorf("got %d jobs, want 6", got)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"unknown.yaml", "defaults:\n  spot:\n    every: 10s\n", "every"},
+		{"unknown.toml", "[defaults.spot]\nevery = \"10s\"\n", "missing in the target struct"},
+		{"duration.yaml", "defaults:\n  spot:\n    interval: soon\n", "soon"},
+		{"duration.toml", "[defaults.spot]\ninterval = \"soon\"\n", "soon"},
+		{"scheduler.json", "{}", "unsupported scheduler config format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, tt.name, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("want an error for a missing file")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		edit     func(c *Config)
+		wantErrs []string
+	}{
+		{"defaults", func(c *Config) {}, nil},
+		{"unknown default market", func(c *Config) { c.Defaults["options"] = Schedule{Interval: seconds(1)} },
+			[]string{`defaults: unknown market "options"`}},
+		{"zero interval", func(c *Config) { c.Defaults[MarketSpot] = Schedule{} },
+			[]string{"defaults.spot: interval must be positive"}},
+		{"jitter as long as interval", func(c *Config) { c.Diffs[MarketFutures] = Schedule{Interval: seconds(5), Jitter: seconds(5)} },
+			[]string{"diffs.futures: jitter 5s must be smaller than interval 5s"}},
+		{"negative timeout", func(c *Config) { c.Diffs[DiffInverse] = Schedule{Interval: seconds(5), Timeout: -seconds(1)} },
+			[]string{"diffs.inverse: timeout must not be negative"}},
+		{"unknown diff", func(c *Config) { c.Diffs[MarketNetworks] = Schedule{Interval: seconds(5)} },
+			[]string{`diffs: unknown market "networks"`}},
+		{"unknown exchange", func(c *Config) { c.Exchanges["Delta"] = ExchangeConfig{} },
+			[]string{`exchanges: unknown exchange "Delta", available: Alpha, Beta, Gamma`}},
+		{"empty markets", func(c *Config) { c.Exchanges["Beta"] = ExchangeConfig{Markets: []string{}} },
+			[]string{"exchanges.Beta: markets is empty"}},
+		{"unsupported market", func(c *Config) { c.Exchanges["Gamma"] = ExchangeConfig{Markets: []string{MarketSpot, MarketFutures}} },
+			[]string{"exchanges.Gamma: futures is not supported by the connector"}},
+		{"market listed twice", func(c *Config) { c.Exchanges["Beta"] = ExchangeConfig{Markets: []string{MarketSpot, MarketSpot}} },
+			[]string{"exchanges.Beta: market spot listed twice"}},
+		{"negative jitter override", func(c *Config) {
+			jitter := -seconds(1)
+			c.Exchanges["Alpha"] = ExchangeConfig{Schedules: map[string]ScheduleOverride{MarketSpot: {Jitter: &jitter}}}
+		}, []string{"exchanges.Alpha.schedules.spot: jitter must not be negative"}},
+		{"override checked against the defaults", func(c *Config) {
+			interval := seconds(1)
+			c.Defaults[MarketSpot] = Schedule{Interval: seconds(20), Jitter: seconds(5)}
+			c.Exchanges["Alpha"] = ExchangeConfig{Schedules: map[string]ScheduleOverride{MarketSpot: {Interval: &interval}}}
+		}, []string{"exchanges.Alpha.schedules.spot: jitter 5s must be smaller than interval 1s"}},
+		{"every problem is reported", func(c *Config) {
+			c.Defaults[MarketSpot] = Schedule{}
+			c.Exchanges["Delta"] = ExchangeConfig{}
+			c.Exchanges["Gamma"] = ExchangeConfig{Markets: []string{"options"}}
+		}, []string{"defaults.spot", `unknown exchange "Delta"`, `exchanges.Gamma: unknown market "options"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Exchanges = make(map[string]ExchangeConfig)
+			tt.edit(cfg)
+
+			err := cfg.Validate(testConnectors())
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() = nil, want %q", tt.wantErrs)
+			}
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() = %v, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -1 +1,316 @@
+// Package scheduler registers the exchange and diff jobs described by the
+// scheduler config with gocron.
 package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"Updater/logging"
+	"Updater/metrics"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+var (
+	logger      = logging.For("component", "scheduler")
+	skipSampler = logging.NewSampler()
+)
+
+// Task fetches one market of an exchange and stores it, returning the rows written.
+type Task func(ctx context.Context) (int, error)
+
+// Tasks maps the markets an exchange connector supports to their tasks.
+type Tasks map[string]Task
+
+// Markets returns the supported markets in a stable order.
+func (t Tasks) Markets() []string {
+	var markets []string
+	for _, market := range []string{MarketSpot, MarketFutures, MarketNetworks} {
+		if t[market] != nil {
+			markets = append(markets, market)
+		}
+	}
+	return markets
+}
+
+// Connectors maps exchange names to their tasks.
+type Connectors map[string]Tasks
+
+// Names returns the exchange names in alphabetical order.
+func (c Connectors) Names() []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Runner executes an exchange task, recording and logging its outcome.
+type Runner func(ctx context.Context, job ExchangeJob, task Task)
+
+// DiffFunc recalculates the diffs of one market.
+type DiffFunc func(ctx context.Context)
+
+// job is one registered gocron job.
+type job struct {
+	name     string
+	schedule Schedule
+	run      func(ctx context.Context)
+
+	// busy is held while a run is in progress so slow runs are never overlapped.
+	// It outlives rescheduling and removal of the job, see Scheduler.busy.
+	busy *sync.Mutex
+}
+
+// entry is a job known to gocron.
+type entry struct {
+	job      *job
+	cronJob  gocron.Job
+	exchange *ExchangeJob // nil for diff jobs
+}
+
+// Changes lists what Apply changed.
+type Changes struct {
+	Added       []ExchangeJob
+	Removed     []ExchangeJob
+	Rescheduled []string
+}
+
+// Empty reports whether Apply left every job untouched.
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Rescheduled) == 0
+}
+
+// Scheduler owns the gocron scheduler and the jobs registered from the config.
+type Scheduler struct {
+	cron       gocron.Scheduler
+	connectors Connectors
+	diffs      map[string]DiffFunc
+	runner     Runner
+
+	// ctx is the parent of every run and is cancelled by Shutdown
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	// busy keeps one lock per job name, so a job that is removed and added
+	// again does not start while its last run is still in progress
+	busy map[string]*sync.Mutex
+}
+
+// New creates a scheduler for the given connectors and diff calculations.
+func New(connectors Connectors, diffs map[string]DiffFunc, runner Runner) (*Scheduler, error) {
+	cron, err := gocron.NewScheduler()
+	if err != nil {
+		return nil, fmt.Errorf("error creating scheduler: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		ctx:        ctx,
+		cancel:     cancel,
+		cron:       cron,
+		connectors: connectors,
+		diffs:      diffs,
+		runner:     runner,
+		entries:    make(map[string]*entry),
+		busy:       make(map[string]*sync.Mutex),
+	}, nil
+}
+
+// ErrInvalidConfig is wrapped by the error Apply returns when the config fails
+// validation and every job was left as it was.
+var ErrInvalidConfig = errors.New("invalid scheduler config")
+
+// Apply validates cfg and reconciles the registered jobs with it: new jobs
+// are added, jobs no longer configured are removed and jobs whose schedule
+// changed are rescheduled. Runs in progress are never interrupted. An invalid
+// config leaves every job as it was. Any other error means gocron refused some
+// jobs and the config was applied only partly; Changes lists what did change.
+func (s *Scheduler) Apply(cfg *Config) (Changes, error) {
+	if err := cfg.Validate(s.connectors); err != nil {
+		return Changes{}, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	desired := make(map[string]*entry)
+	var order []string
+	for _, ej := range cfg.ExchangeJobs(s.connectors) {
+		ej := ej
+		task := s.connectors[ej.Exchange][ej.Market]
+		desired[ej.Name()] = &entry{
+			job: &job{
+				name:     ej.Name(),
+				schedule: ej.Schedule,
+				run:      func(ctx context.Context) { s.runner(ctx, ej, task) },
+			},
+			exchange: &ej,
+		}
+		order = append(order, ej.Name())
+	}
+	for _, market := range []string{MarketSpot, MarketFutures, DiffCalendar, DiffInverse} {
+		fn, ok := s.diffs[market]
+		if !ok {
+			continue
+		}
+		name := "diffs " + market
+		desired[name] = &entry{job: &job{
+			name:     name,
+			schedule: cfg.Diffs[market],
+			run:      func(ctx context.Context) { fn(ctx) },
+		}}
+		order = append(order, name)
+	}
+
+	var changes Changes
+	var errs []error
+
+	for _, name := range sortedKeys(s.entries) {
+		if _, ok := desired[name]; ok {
+			continue
+		}
+		old := s.entries[name]
+		if err := s.cron.RemoveJob(old.cronJob.ID()); err != nil {
+			errs = append(errs, fmt.Errorf("error removing %s: %w", name, err))
+			continue
+		}
+		delete(s.entries, name)
+		if old.exchange != nil {
+			changes.Removed = append(changes.Removed, *old.exchange)
+		}
+		logger.Info("job removed", "job", name)
+	}
+
+	for _, name := range order {
+		want := desired[name]
+		if s.busy[name] == nil {
+			s.busy[name] = &sync.Mutex{}
+		}
+		want.job.busy = s.busy[name]
+
+		old, ok := s.entries[name]
+		switch {
+		case !ok:
+			cronJob, err := s.cron.NewJob(definition(want.job.schedule), gocron.NewTask(s.execute, want.job), gocron.WithName(name))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error scheduling %s: %w", name, err))
+				continue
+			}
+			want.cronJob = cronJob
+			s.entries[name] = want
+			if want.exchange != nil {
+				changes.Added = append(changes.Added, *want.exchange)
+			}
+			logger.Info("job added", scheduleAttrs(name, want.job.schedule)...)
+		case old.job.schedule != want.job.schedule:
+			cronJob, err := s.cron.Update(old.cronJob.ID(), definition(want.job.schedule), gocron.NewTask(s.execute, want.job), gocron.WithName(name))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error rescheduling %s: %w", name, err))
+				continue
+			}
+			want.cronJob = cronJob
+			s.entries[name] = want
+			changes.Rescheduled = append(changes.Rescheduled, name)
+			logger.Info("job rescheduled", append(scheduleAttrs(name, want.job.schedule),
+				"previousInterval", time.Duration(old.job.schedule.Interval),
+				"previousJitter", time.Duration(old.job.schedule.Jitter),
+				"previousTimeout", time.Duration(old.job.schedule.Timeout))...)
+		}
+	}
+
+	return changes, errors.Join(errs...)
+}
+
+// definition converts a schedule to a gocron job definition.
+func definition(schedule Schedule) gocron.JobDefinition {
+	interval := time.Duration(schedule.Interval)
+	jitter := time.Duration(schedule.Jitter)
+	if jitter > 0 {
+		return gocron.DurationRandomJob(interval-jitter, interval+jitter)
+	}
+	return gocron.DurationJob(interval)
+}
+
+func scheduleAttrs(name string, schedule Schedule) []any {
+	return []any{
+		"job", name,
+		"interval", time.Duration(schedule.Interval),
+		"jitter", time.Duration(schedule.Jitter),
+		"timeout", time.Duration(schedule.Timeout),
+	}
+}
+
+// execute runs j once with its timeout. A run that is still in progress when
+// the next one is due makes the next one skip.
+func (s *Scheduler) execute(j *job) {
+	s.running.Add(1)
+	defer s.running.Done()
+
+	if s.ctx.Err() != nil {
+		return
+	}
+	if !j.busy.TryLock() {
+		skipSampler.Warn(logger, j.name, "previous run still in progress, skipping", "job", j.name)
+		return
+	}
+	defer j.busy.Unlock()
+
+	metrics.ObserveJobStart(j.name, time.Duration(j.schedule.Interval+j.schedule.Jitter))
+
+	ctx := s.ctx
+	if j.schedule.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(j.schedule.Timeout))
+		defer cancel()
+	}
+	j.run(ctx)
+}
+
+// Go runs fn in the background with the context of the runs, so Shutdown
+// cancels it and waits for it like for a job. Jobs use it for follow-up work
+// that should not hold their own lock; after Shutdown fn is not started.
+func (s *Scheduler) Go(fn func(ctx context.Context)) {
+	s.running.Add(1)
+	if s.ctx.Err() != nil {
+		s.running.Done()
+		return
+	}
+	go func() {
+		defer s.running.Done()
+		fn(s.ctx)
+	}()
+}
+
+// Start starts running the registered jobs.
+func (s *Scheduler) Start() {
+	s.cron.Start()
+}
+
+// Shutdown stops starting new runs, cancels the context of the runs in
+// progress and waits for them to return until ctx is done.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		err := s.cron.Shutdown()
+		s.running.Wait()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("jobs still running: %w", ctx.Err())
+	}
+}
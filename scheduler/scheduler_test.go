@@ -0,0 +1,271 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+// refusingCron makes gocron refuse new jobs while refuse is set.
+type refusingCron struct {
+	gocron.Scheduler
+	refuse bool
+}
+
+func (c *refusingCron) NewJob(definition gocron.JobDefinition, task gocron.Task, options ...gocron.JobOption) (gocron.Job, error) {
+	if c.refuse {
+		return nil, errors.New("refused")
+	}
+	return c.Scheduler.NewJob(definition, task, options...)
+}
+
+// newTestScheduler creates a scheduler that is never started, so no job runs.
+func newTestScheduler(t *testing.T) (*Scheduler, *refusingCron) {
+	t.Helper()
+	diffs := map[string]DiffFunc{MarketSpot: func(ctx context.Context) {}}
+	s, err := New(testConnectors(), diffs, func(ctx context.Context, job ExchangeJob, task Task) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cron := &refusingCron{Scheduler: s.cron}
+	s.cron = cron
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	return s, cron
+}
+
+func testConfig(edit func(c *Config)) *Config {
+	cfg := Default()
+	cfg.Exchanges = make(map[string]ExchangeConfig)
+	if edit != nil {
+		edit(cfg)
+	}
+	return cfg
+}
+
+func jobNames(jobs []ExchangeJob) []string {
+	var names []string
+	for _, j := range jobs {
+		names = append(names, j.Name())
+	}
+	return names
+}
+
+// schedules returns the registered jobs and checks that gocron knows each of them.
+func schedules(t *testing.T, s *Scheduler) map[string]Schedule {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	registered := make(map[string]Schedule)
+	for name, e := range s.entries {
+		registered[name] = e.job.schedule
+	}
+	if got := len(s.cron.Jobs()); got != len(registered) {
+		t.Errorf("gocron has %d jobs, want the %d registered ones", got, len(registered))
+	}
+	return registered
+}
+
+func TestApply(t *testing.T) {
+	s, _ := newTestScheduler(t)
+	fast := seconds(5)
+	disabled := false
+
+	steps := []struct {
+		name        string
+		cfg         *Config
+		added       []string
+		removed     []string
+		rescheduled []string
+	}{
+		{
+			name:  "start",
+			cfg:   testConfig(nil),
+			added: []string{"Alpha futures", "Alpha networks", "Alpha spot", "Beta futures", "Beta spot", "Gamma spot"},
+		},
+		{
+			name: "unchanged",
+			cfg:  testConfig(nil),
+		},
+		{
+			name: "remove and reschedule",
+			cfg: testConfig(func(c *Config) {
+				c.Exchanges["Alpha"] = ExchangeConfig{Markets: []string{MarketSpot}}
+				c.Exchanges["Beta"] = ExchangeConfig{Enabled: &disabled}
+				c.Exchanges["Gamma"] = ExchangeConfig{Schedules: map[string]ScheduleOverride{MarketSpot: {Interval: &fast}}}
+				c.Diffs[MarketSpot] = Schedule{Interval: seconds(30)}
+			}),
+			removed:     []string{"Alpha futures", "Alpha networks", "Beta futures", "Beta spot"},
+			rescheduled: []string{"Gamma spot", "diffs spot"},
+		},
+		{
+			name:        "back to the defaults",
+			cfg:         testConfig(nil),
+			added:       []string{"Alpha futures", "Alpha networks", "Beta futures", "Beta spot"},
+			rescheduled: []string{"Gamma spot", "diffs spot"},
+		},
+	}
+	for _, step := range steps {
+		changes, err := s.Apply(step.cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := jobNames(changes.Added); !reflect.DeepEqual(got, step.added) {
+			t.Errorf("%s: added %v, want %v", step.name, got, step.added)
+		}
+		if got := jobNames(changes.Removed); !reflect.DeepEqual(got, step.removed) {
+			t.Errorf("%s: removed %v, want %v", step.name, got, step.removed)
+		}
+		if !reflect.DeepEqual(changes.Rescheduled, step.rescheduled) {
+			t.Errorf("%s: rescheduled %v, want %v", step.name, changes.Rescheduled, step.rescheduled)
+		}
+		if changes.Empty() != (step.added == nil && step.removed == nil && step.rescheduled == nil) {
+			t.Errorf("%s: Empty() = %v", step.name, changes.Empty())
+		}
+
+		want := map[string]Schedule{"diffs spot": step.cfg.Diffs[MarketSpot]}
+		for _, j := range step.cfg.ExchangeJobs(s.connectors) {
+			want[j.Name()] = j.Schedule
+		}
+		if got := schedules(t, s); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: jobs\n got  %v\n want %v", step.name, got, want)
+		}
+	}
+}
+
+func TestApplyKeepsBusyLock(t *testing.T) {
+	s, _ := newTestScheduler(t)
+	if _, err := s.Apply(testConfig(nil)); err != nil {
+		t.Fatal(err)
+	}
+	busy := s.entries["Gamma spot"].job.busy
+
+	// A rescheduled or re-added job must not overlap a run of its previous version
+	fast := seconds(5)
+	disabled := false
+	s.Apply(testConfig(func(c *Config) {
+		c.Exchanges["Gamma"] = ExchangeConfig{Schedules: map[string]ScheduleOverride{MarketSpot: {Interval: &fast}}}
+	}))
+	if s.entries["Gamma spot"].job.busy != busy {
+		t.Error("rescheduled job got a new busy lock")
+	}
+	s.Apply(testConfig(func(c *Config) { c.Exchanges["Gamma"] = ExchangeConfig{Enabled: &disabled} }))
+	s.Apply(testConfig(nil))
+	if s.entries["Gamma spot"].job.busy != busy {
+		t.Error("re-added job got a new busy lock")
+	}
+}
+
+func TestApplyInvalidConfigKeepsJobs(t *testing.T) {
+	s, _ := newTestScheduler(t)
+	if _, err := s.Apply(testConfig(nil)); err != nil {
+		t.Fatal(err)
+	}
+	before := schedules(t, s)
+
+	disabled := false
+	changes, err := s.Apply(testConfig(func(c *Config) {
+		// Valid changes are not applied either when anything else is invalid
+		c.Exchanges["Beta"] = ExchangeConfig{Enabled: &disabled}
+		c.Exchanges["Delta"] = ExchangeConfig{}
+	}))
+	if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), `unknown exchange "Delta"`) {
+		t.Errorf("error = %v, want ErrInvalidConfig with the problem", err)
+	}
+	if !changes.Empty() {
+		t.Errorf("changes = %+v, want none", changes)
+	}
+	if got := schedules(t, s); !reflect.DeepEqual(got, before) {
+		t.Errorf("jobs changed by an invalid config:\n got  %v\n want %v", got, before)
+	}
+}
+
+func TestApplyPartly(t *testing.T) {
+	s, cron := newTestScheduler(t)
+	disabled := false
+	withoutAlpha := testConfig(func(c *Config) { c.Exchanges["Alpha"] = ExchangeConfig{Enabled: &disabled} })
+	if _, err := s.Apply(withoutAlpha); err != nil {
+		t.Fatal(err)
+	}
+
+	// gocron refuses the new Alpha jobs, removing Beta and rescheduling Gamma still happen
+	fast := seconds(5)
+	next := testConfig(func(c *Config) {
+		c.Exchanges["Beta"] = ExchangeConfig{Enabled: &disabled}
+		c.Exchanges["Gamma"] = ExchangeConfig{Schedules: map[string]ScheduleOverride{MarketSpot: {Interval: &fast}}}
+	})
+	cron.refuse = true
+	changes, err := s.Apply(next)
+	if err == nil || errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("error = %v, want a scheduling error", err)
+	}
+	for _, name := range []string{"Alpha futures", "Alpha networks", "Alpha spot"} {
+		if !strings.Contains(err.Error(), "error scheduling "+name) {
+			t.Errorf("error = %v, want it to name %s", err, name)
+		}
+	}
+	if len(changes.Added) != 0 {
+		t.Errorf("added %v, want none", jobNames(changes.Added))
+	}
+	if got, want := jobNames(changes.Removed), []string{"Beta futures", "Beta spot"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("removed %v, want %v", got, want)
+	}
+	if got, want := changes.Rescheduled, []string{"Gamma spot"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rescheduled %v, want %v", got, want)
+	}
+	if got := schedules(t, s); len(got) != 2 || got["Gamma spot"].Interval != fast {
+		t.Errorf("jobs = %v, want Gamma spot rescheduled and diffs spot", got)
+	}
+
+	// The next reload adds what was refused
+	cron.refuse = false
+	changes, err = s.Apply(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := jobNames(changes.Added), []string{"Alpha futures", "Alpha networks", "Alpha spot"}; !reflect.DeepEqual(got, want) || len(changes.Removed)+len(changes.Rescheduled) != 0 {
+		t.Errorf("changes = %+v, want only %v added", changes, want)
+	}
+}
+
+func TestGoStopsWithShutdown(t *testing.T) {
+	s, _ := newTestScheduler(t)
+
+	started, finished := make(chan struct{}), make(chan struct{})
+	s.Go(func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		time.Sleep(20 * time.Millisecond)
+		close(finished)
+	})
+	<-started
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-finished:
+	default:
+		t.Error("Shutdown returned before the background work")
+	}
+
+	s.Go(func(ctx context.Context) { t.Error("work started after Shutdown") })
+}
+
+func TestShutdownTimeoutWithBackgroundWork(t *testing.T) {
+	s, _ := newTestScheduler(t)
+	release := make(chan struct{})
+	defer close(release)
+	s.Go(func(ctx context.Context) { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() = %v, want the deadline while work is running", err)
+	}
+}
@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// WatchFile calls onChange whenever the modification time or size of path
+// changes, checking every interval until ctx is done. Polling is used instead
+// of file system events because config files are often bind mounts or
+// symlinks that are replaced rather than written to.
+func WatchFile(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	last, lastErr := stat(path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current, err := stat(path)
+		if err != nil {
+			if lastErr == nil {
+				logger.Warn("scheduler config is not readable, keeping the current jobs", "path", path, "error", err)
+			}
+			lastErr = err
+			continue
+		}
+		if lastErr == nil && current == last {
+			continue
+		}
+		last, lastErr = current, nil
+		onChange()
+	}
+}
+
+// fileVersion identifies a version of a file well enough to notice edits.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+func stat(path string) (fileVersion, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	return fileVersion{modTime: info.ModTime(), size: info.Size()}, nil
+}
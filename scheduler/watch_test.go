@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduler.yaml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("defaults: {}\n")
+
+	changes := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		WatchFile(ctx, path, 5*time.Millisecond, func() { changes <- struct{}{} })
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	expect := func(step string, want bool) {
+		t.Helper()
+		select {
+		case <-changes:
+			if !want {
+				t.Errorf("%s: reported a change", step)
+			}
+		case <-time.After(100 * time.Millisecond):
+			if want {
+				t.Errorf("%s: no change reported", step)
+			}
+		}
+	}
+
+	expect("unchanged", false)
+	write("defaults:\n  spot:\n    interval: 5s\n")
+	expect("edited", true)
+	expect("edited once", false)
+
+	// A file being replaced is missing for a moment, which keeps the jobs
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	expect("removed", false)
+	write("defaults:\n  spot:\n    interval: 5s\n")
+	expect("restored", true)
+}
@@ -0,0 +1,172 @@
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxFundingRate bounds the simulated funding rates, as a fraction per funding interval.
+const maxFundingRate = 0.001
+
+// generatedMarkets returns the SIMnnnnn/USDT markets added for load tests,
+// with prices spread between 0.0001 and 10,000.
+func generatedMarkets(n int, rng *rand.Rand) []Market {
+	markets := make([]Market, n)
+	for i := range markets {
+		markets[i] = Market{
+			Base:  fmt.Sprintf("SIM%05d", i+1),
+			Quote: "USDT",
+			Price: math.Pow(10, rng.Float64()*8-4),
+		}
+	}
+	return markets
+}
+
+func generatedSymbol(symbol string, n int) bool {
+	number, ok := strings.CutPrefix(symbol, "SIM")
+	if !ok {
+		return false
+	}
+	number, ok = strings.CutSuffix(number, "USDT")
+	if !ok || len(number) != 5 {
+		return false
+	}
+	i, err := strconv.Atoi(number)
+	return err == nil && i >= 1 && i <= n
+}
+
+// quote is what one exchange serves for one market.
+type quote struct {
+	Price   float64
+	Open    float64 // starting price of the market, for the 24h change
+	Mark    float64
+	Index   float64
+	Funding float64 // fraction per funding interval
+	Volume  float64 // base volume over 24h
+	Listed  bool
+}
+
+// Change24h is the price change since the start of the run as a fraction.
+func (q quote) Change24h() float64 {
+	if q.Open == 0 {
+		return 0
+	}
+	return q.Price/q.Open - 1
+}
+
+// QuoteVolume is the 24h volume in the quote asset.
+func (q quote) QuoteVolume() float64 {
+	return q.Volume * q.Price
+}
+
+// model holds the random walks behind the simulated prices. It is only used
+// by the goroutine advancing the simulation.
+type model struct {
+	scenario  *Scenario
+	markets   []Market
+	exchanges []string
+	rng       *rand.Rand
+
+	reference []float64   // price every exchange follows, per market
+	offsets   [][]float64 // relative deviation from the reference, per exchange and market
+	fundings  [][]float64
+	volumes   [][]float64
+}
+
+func newModel(sc *Scenario) *model {
+	rng := rand.New(rand.NewPCG(sc.Seed, 0x5eed))
+	markets := append(append([]Market(nil), sc.Markets...), generatedMarkets(sc.GeneratedMarkets, rng)...)
+
+	m := &model{
+		scenario:  sc,
+		markets:   markets,
+		exchanges: sc.Exchanges,
+		rng:       rng,
+		reference: make([]float64, len(markets)),
+		offsets:   make([][]float64, len(sc.Exchanges)),
+		fundings:  make([][]float64, len(sc.Exchanges)),
+		volumes:   make([][]float64, len(sc.Exchanges)),
+	}
+	for i, market := range markets {
+		m.reference[i] = market.Price
+	}
+	for e := range sc.Exchanges {
+		m.offsets[e] = make([]float64, len(markets))
+		m.fundings[e] = make([]float64, len(markets))
+		m.volumes[e] = make([]float64, len(markets))
+		for i := range markets {
+			m.offsets[e][i] = (rng.Float64()*2 - 1) * sc.Spread
+			m.fundings[e][i] = (rng.Float64()*2 - 1) * maxFundingRate / 4
+			// Between 100,000 and 100,000,000 worth of the quote asset, whatever the price
+			m.volumes[e][i] = math.Pow(10, 5+rng.Float64()*3) / markets[i].Price
+		}
+	}
+	return m
+}
+
+// step moves every price one tick. The random numbers drawn do not depend on
+// the events, so an event on one exchange leaves the others' paths unchanged.
+func (m *model) step() {
+	vol := m.scenario.Volatility
+	spread := m.scenario.Spread
+	for i := range m.reference {
+		m.reference[i] *= math.Exp(vol*m.rng.NormFloat64() - vol*vol/2)
+	}
+	for e := range m.exchanges {
+		for i := range m.markets {
+			m.offsets[e][i] = clamp(m.offsets[e][i]+spread/4*m.rng.NormFloat64(), spread)
+			m.fundings[e][i] = clamp(m.fundings[e][i]+maxFundingRate/20*m.rng.NormFloat64(), maxFundingRate)
+		}
+	}
+}
+
+// quotes builds what exchange e serves at the simulated time elapsed.
+func (m *model) quotes(e int, elapsed time.Duration) []quote {
+	exchange := m.exchanges[e]
+	quotes := make([]quote, len(m.markets))
+	for i, market := range m.markets {
+		reference := m.reference[i]
+		price := reference * (1 + m.offsets[e][i])
+		listed := true
+		for _, event := range m.scenario.Events {
+			if !event.active(exchange, elapsed) || (event.Symbol != "" && event.Symbol != market.Symbol()) {
+				continue
+			}
+			switch event.Type {
+			case EventSpike:
+				price *= 1 + event.Size
+			case EventDelist:
+				listed = false
+			}
+		}
+		quotes[i] = quote{
+			Price: price,
+			Open:  market.Price,
+			// The mark price sits between the exchange's own price and the index
+			Mark:    (price + reference) / 2,
+			Index:   reference,
+			Funding: m.fundings[e][i],
+			Volume:  m.volumes[e][i],
+			Listed:  listed,
+		}
+	}
+	return quotes
+}
+
+// frozen reports whether a freeze event holds the prices of exchange e.
+func (m *model) frozen(e int, elapsed time.Duration) bool {
+	for _, event := range m.scenario.Events {
+		if event.Type == EventFreeze && event.active(m.exchanges[e], elapsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func clamp(v, limit float64) float64 {
+	return math.Max(-limit, math.Min(limit, v))
+}
@@ -0,0 +1,219 @@
+package simulator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"Updater/scheduler"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Event types a scenario can script.
+const (
+	EventSpike   = "spike"   // moves the price on the exchange by Size, such as 0.05 for +5%
+	EventFreeze  = "freeze"  // the exchange keeps serving the prices it had when the event started
+	EventDelist  = "delist"  // the market disappears from the exchange
+	EventError   = "error"   // every request to the exchange fails with Status
+	EventLatency = "latency" // every response of the exchange is delayed by Delay
+)
+
+// Market is a trading pair listed on every simulated exchange.
+type Market struct {
+	Base  string `yaml:"base" toml:"base"`
+	Quote string `yaml:"quote" toml:"quote"`
+	// Price is the starting price.
+	Price float64 `yaml:"price" toml:"price"`
+}
+
+// Symbol is the market in the BASEQUOTE form used by events.
+func (m Market) Symbol() string {
+	return m.Base + m.Quote
+}
+
+// Event changes what an exchange serves for a while.
+type Event struct {
+	Type string `yaml:"type" toml:"type"`
+	// At is when the event starts, counted in simulated time from the start of the run.
+	At scheduler.Duration `yaml:"at" toml:"at"`
+	// For is how long the event lasts, 0 lasts until the end of the run.
+	For scheduler.Duration `yaml:"for" toml:"for"`
+	// Exchange the event applies to, every exchange when empty.
+	Exchange string `yaml:"exchange" toml:"exchange"`
+	// Symbol limits spike and delist events to one market, such as BTCUSDT.
+	Symbol string `yaml:"symbol" toml:"symbol"`
+	// Size is the relative price move of a spike.
+	Size float64 `yaml:"size" toml:"size"`
+	// Status is the HTTP status of an error event, 503 by default.
+	Status int `yaml:"status" toml:"status"`
+	// Delay is the response delay of a latency event.
+	Delay scheduler.Duration `yaml:"delay" toml:"delay"`
+}
+
+// active reports whether the event applies to exchange at the simulated time elapsed.
+func (e Event) active(exchange string, elapsed time.Duration) bool {
+	if e.Exchange != "" && e.Exchange != exchange {
+		return false
+	}
+	if elapsed < time.Duration(e.At) {
+		return false
+	}
+	return e.For == 0 || elapsed < time.Duration(e.At+e.For)
+}
+
+// Scenario describes a simulated run. The same scenario, seed included,
+// always produces the same prices and events.
+type Scenario struct {
+	// Seed of the random price paths.
+	Seed uint64 `yaml:"seed" toml:"seed"`
+	// Tick is how often prices move, 1s by default. Events are timed in ticks,
+	// so a run replays the same way however fast the updater polls.
+	Tick scheduler.Duration `yaml:"tick" toml:"tick"`
+	// Volatility is the standard deviation of one price step relative to the price, 0.001 by default.
+	Volatility float64 `yaml:"volatility" toml:"volatility"`
+	// Spread is how far, relative to the price, an exchange drifts from the others at most, 0.005 by default.
+	Spread float64 `yaml:"spread" toml:"spread"`
+	// Markets listed on every exchange, BTC/USDT and ETH/USDT by default.
+	Markets []Market `yaml:"markets" toml:"markets"`
+	// GeneratedMarkets adds that many SIMnnnnn/USDT markets with random prices.
+	GeneratedMarkets int `yaml:"generatedMarkets" toml:"generatedMarkets"`
+	// Exchanges to simulate, every exchange the simulator knows by default.
+	// Requests to the others fail.
+	Exchanges []string `yaml:"exchanges" toml:"exchanges"`
+	// Events scripted during the run.
+	Events []Event `yaml:"events" toml:"events"`
+}
+
+// Load reads a YAML (.yaml, .yml) or TOML (.toml) scenario and fills in the defaults.
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading simulator scenario: %w", err)
+	}
+
+	var sc Scenario
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&sc); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("error parsing %s: %w", path, err)
+		}
+	case ".toml":
+		dec := toml.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&sc); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported simulator scenario format %q, expected .yaml, .yml or .toml", filepath.Ext(path))
+	}
+
+	sc.setDefaults()
+	if err := sc.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &sc, nil
+}
+
+func (sc *Scenario) setDefaults() {
+	if sc.Tick == 0 {
+		sc.Tick = scheduler.Duration(time.Second)
+	}
+	if sc.Volatility == 0 {
+		sc.Volatility = 0.001
+	}
+	if sc.Spread == 0 {
+		sc.Spread = 0.005
+	}
+	if len(sc.Markets) == 0 && sc.GeneratedMarkets == 0 {
+		sc.Markets = []Market{
+			{Base: "BTC", Quote: "USDT", Price: 67000},
+			{Base: "ETH", Quote: "USDT", Price: 3500},
+		}
+	}
+	if len(sc.Exchanges) == 0 {
+		sc.Exchanges = Exchanges()
+	}
+	for i := range sc.Events {
+		if sc.Events[i].Type == EventError && sc.Events[i].Status == 0 {
+			sc.Events[i].Status = 503
+		}
+	}
+}
+
+// Validate returns every problem of the scenario, not only the first one.
+func (sc *Scenario) Validate() error {
+	var errs []error
+
+	if sc.Tick <= 0 {
+		errs = append(errs, errors.New("tick must be positive"))
+	}
+	if sc.Volatility < 0 || sc.Spread < 0 {
+		errs = append(errs, errors.New("volatility and spread must not be negative"))
+	}
+	if sc.GeneratedMarkets < 0 || sc.GeneratedMarkets > 99999 {
+		errs = append(errs, errors.New("generatedMarkets must be between 0 and 99999"))
+	}
+
+	symbols := make(map[string]bool)
+	for i, m := range sc.Markets {
+		if m.Base == "" || m.Quote == "" || m.Price <= 0 {
+			errs = append(errs, fmt.Errorf("markets[%d]: base, quote and a positive price are required", i))
+		}
+		if symbols[m.Symbol()] {
+			errs = append(errs, fmt.Errorf("markets[%d]: duplicate market %s", i, m.Symbol()))
+		}
+		symbols[m.Symbol()] = true
+	}
+
+	exchanges := make(map[string]bool)
+	for _, name := range sc.Exchanges {
+		if _, ok := venues[name]; !ok {
+			errs = append(errs, fmt.Errorf("exchanges: unknown exchange %q, available: %s", name, strings.Join(Exchanges(), ", ")))
+		}
+		exchanges[name] = true
+	}
+
+	for i, e := range sc.Events {
+		prefix := fmt.Sprintf("events[%d]", i)
+		switch e.Type {
+		case EventSpike:
+			if e.Size <= -1 || e.Size == 0 {
+				errs = append(errs, fmt.Errorf("%s: spike size must be non-zero and above -1", prefix))
+			}
+		case EventFreeze, EventDelist:
+		case EventError:
+			if e.Status < 400 || e.Status > 599 {
+				errs = append(errs, fmt.Errorf("%s: error status must be 4xx or 5xx", prefix))
+			}
+		case EventLatency:
+			if e.Delay <= 0 {
+				errs = append(errs, fmt.Errorf("%s: latency delay must be positive", prefix))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("%s: unknown type %q, expected spike, freeze, delist, error or latency", prefix, e.Type))
+		}
+		if e.At < 0 || e.For < 0 {
+			errs = append(errs, fmt.Errorf("%s: at and for must not be negative", prefix))
+		}
+		if e.Exchange != "" && !exchanges[e.Exchange] {
+			errs = append(errs, fmt.Errorf("%s: exchange %q is not simulated", prefix, e.Exchange))
+		}
+		if e.Symbol != "" && e.Type != EventSpike && e.Type != EventDelist {
+			errs = append(errs, fmt.Errorf("%s: symbol only applies to spike and delist events", prefix))
+		}
+		if e.Symbol != "" && !symbols[e.Symbol] && !generatedSymbol(e.Symbol, sc.GeneratedMarkets) {
+			errs = append(errs, fmt.Errorf("%s: unknown symbol %q", prefix, e.Symbol))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,194 @@
+// Package simulator serves fake exchange endpoints with scripted price paths,
+// so the updater and the diff jobs can run end to end, and be load tested,
+// without network access. Prices follow seeded random walks that the
+// scenario disturbs with spikes, freezes, delistings, HTTP errors and latency.
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"Updater/logging"
+)
+
+var logger = logging.For("component", "simulator")
+
+// Simulator serves the exchanges of a scenario. Requests are addressed as
+// /<exchange host>/<path>, such as /api.binance.com/api/v3/ticker/price.
+type Simulator struct {
+	scenario *Scenario
+	model    *model
+	hosts    map[string]string // exchange host to exchange name
+
+	mu      sync.RWMutex
+	steps   int
+	markets []Market
+	books   map[string][]quote // what each exchange serves, replaced on every step
+}
+
+// New prepares the simulation of sc at its starting prices.
+func New(sc *Scenario) (*Simulator, error) {
+	if err := sc.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := &Simulator{
+		scenario: sc,
+		model:    newModel(sc),
+		hosts:    make(map[string]string),
+		books:    make(map[string][]quote),
+	}
+	s.markets = s.model.markets
+	for _, name := range sc.Exchanges {
+		for _, host := range venues[name].hosts {
+			s.hosts[host] = name
+		}
+	}
+	s.publish(0)
+	return s, nil
+}
+
+// Run advances the prices every tick until ctx is done.
+func (s *Simulator) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.scenario.Tick))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.advance()
+		}
+	}
+}
+
+// advance moves the simulation one tick.
+func (s *Simulator) advance() {
+	s.model.step()
+	s.mu.RLock()
+	steps := s.steps + 1
+	s.mu.RUnlock()
+	s.publish(steps)
+}
+
+// publish makes the prices of the given step visible to the handlers.
+// Frozen exchanges keep serving their previous prices.
+func (s *Simulator) publish(steps int) {
+	elapsed := s.elapsed(steps)
+	books := make(map[string][]quote, len(s.model.exchanges))
+
+	s.mu.RLock()
+	for e, name := range s.model.exchanges {
+		if steps > 0 && s.model.frozen(e, elapsed) {
+			books[name] = s.books[name]
+			continue
+		}
+		books[name] = s.model.quotes(e, elapsed)
+	}
+	s.mu.RUnlock()
+
+	s.mu.Lock()
+	s.steps = steps
+	s.books = books
+	s.mu.Unlock()
+}
+
+func (s *Simulator) elapsed(steps int) time.Duration {
+	return time.Duration(steps) * time.Duration(s.scenario.Tick)
+}
+
+// book is what one exchange serves at one moment.
+type book struct {
+	markets []Market
+	quotes  []quote
+	now     time.Time
+}
+
+// each calls fn for every market listed on the exchange.
+func (b book) each(fn func(m Market, q quote)) {
+	for i, m := range b.markets {
+		if b.quotes[i].Listed {
+			fn(m, b.quotes[i])
+		}
+	}
+}
+
+// ServeHTTP answers a request for one of the simulated exchanges.
+func (s *Simulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	path = "/" + path
+
+	name, ok := s.hosts[host]
+	if !ok {
+		http.Error(w, fmt.Sprintf("no simulated exchange at %s", host), http.StatusNotFound)
+		return
+	}
+	v := venues[name]
+	route, ok := v.routes[path]
+	if !ok {
+		http.Error(w, fmt.Sprintf("%s does not simulate %s", name, path), http.StatusNotFound)
+		return
+	}
+
+	s.mu.RLock()
+	elapsed := s.elapsed(s.steps)
+	b := book{markets: s.markets, quotes: s.books[name], now: time.Now()}
+	s.mu.RUnlock()
+
+	for _, event := range s.scenario.Events {
+		if !event.active(name, elapsed) {
+			continue
+		}
+		switch event.Type {
+		case EventLatency:
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(time.Duration(event.Delay)):
+			}
+		case EventError:
+			http.Error(w, fmt.Sprintf("simulated %s error", name), event.Status)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(route(b, r.URL.Query())); err != nil {
+		logger.Debug("error writing response", "exchange", name, "path", path, "error", err)
+	}
+}
+
+// Transport returns a round tripper that sends requests for the simulated
+// exchanges to the simulator at baseURL through next. Requests for any other
+// host fail, so a simulated run never reaches the real exchanges.
+func (s *Simulator) Transport(baseURL string, next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if _, ok := s.hosts[req.URL.Host]; !ok {
+			return nil, fmt.Errorf("simulator: %s is not a simulated exchange host", req.URL.Host)
+		}
+		target, err := url.Parse(baseURL)
+		if err != nil {
+			return nil, err
+		}
+		target.Path = "/" + req.URL.Host + req.URL.Path
+		target.RawQuery = req.URL.RawQuery
+
+		redirected := req.Clone(req.Context())
+		redirected.URL = target
+		redirected.Host = target.Host
+		return next.RoundTrip(redirected)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,227 @@
+package simulator
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"Updater/scheduler"
+)
+
+func newScenario(events ...Event) *Scenario {
+	sc := &Scenario{
+		Seed:      7,
+		Exchanges: []string{"Binance", "Bybit"},
+		Events:    events,
+	}
+	sc.setDefaults()
+	return sc
+}
+
+func newSimulator(t *testing.T, sc *Scenario) *Simulator {
+	t.Helper()
+	s, err := New(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// get requests path from the simulated host and decodes the JSON response into target.
+func get(t *testing.T, s *Simulator, host, path string, target any) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+host+path, nil))
+	if rec.Code == http.StatusOK && target != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), target); err != nil {
+			t.Fatalf("%s%s: %v", host, path, err)
+		}
+	}
+	return rec.Code
+}
+
+type binancePrice struct {
+	Symbol string `json:"symbol"`
+	Price  string `json:"price"`
+}
+
+func binancePrices(t *testing.T, s *Simulator) map[string]string {
+	t.Helper()
+	var prices []binancePrice
+	if code := get(t, s, "api.binance.com", "/api/v3/ticker/price", &prices); code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	bySymbol := make(map[string]string)
+	for _, p := range prices {
+		bySymbol[p.Symbol] = p.Price
+	}
+	return bySymbol
+}
+
+func TestReplay(t *testing.T) {
+	run := func() []map[string][]quote {
+		s := newSimulator(t, newScenario())
+		var books []map[string][]quote
+		for i := 0; i < 20; i++ {
+			s.advance()
+			books = append(books, s.books)
+		}
+		return books
+	}
+
+	first, second := run(), run()
+	if !reflect.DeepEqual(first, second) {
+		t.Error("the same scenario produced different prices")
+	}
+	if reflect.DeepEqual(first[0]["Binance"], first[19]["Binance"]) {
+		t.Error("prices did not move")
+	}
+}
+
+func TestEventsOnOneExchangeKeepTheOthersPaths(t *testing.T) {
+	plain := newSimulator(t, newScenario())
+	disturbed := newSimulator(t, newScenario(
+		Event{Type: EventFreeze, Exchange: "Bybit", At: scheduler.Duration(time.Second)},
+	))
+	for i := 0; i < 5; i++ {
+		plain.advance()
+		disturbed.advance()
+	}
+
+	if !reflect.DeepEqual(plain.books["Binance"], disturbed.books["Binance"]) {
+		t.Error("freezing Bybit changed Binance prices")
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	s := newSimulator(t, newScenario(
+		Event{Type: EventFreeze, Exchange: "Binance", At: scheduler.Duration(2 * time.Second), For: scheduler.Duration(3 * time.Second)},
+	))
+
+	start := binancePrices(t, s)
+	s.advance()
+	frozen := binancePrices(t, s)
+	if reflect.DeepEqual(start, frozen) {
+		t.Error("prices did not move before the freeze started")
+	}
+	for i := 0; i < 3; i++ {
+		s.advance()
+		if got := binancePrices(t, s); !reflect.DeepEqual(got, frozen) {
+			t.Errorf("prices moved during the freeze: %v, want %v", got, frozen)
+		}
+	}
+	s.advance() // the freeze ends
+	if got := binancePrices(t, s); reflect.DeepEqual(got, frozen) {
+		t.Error("prices did not move after the freeze")
+	}
+}
+
+func TestSpikeAndDelist(t *testing.T) {
+	s := newSimulator(t, newScenario(
+		Event{Type: EventSpike, Exchange: "Binance", Symbol: "BTCUSDT", Size: 0.1},
+		Event{Type: EventDelist, Exchange: "Binance", Symbol: "ETHUSDT"},
+	))
+
+	binance, bybit := s.books["Binance"], s.books["Bybit"]
+	if ratio := binance[0].Price / bybit[0].Price; ratio < 1.09 || ratio > 1.11 {
+		t.Errorf("BTCUSDT on Binance is %v times the Bybit price, want about 1.1", ratio)
+	}
+	prices := binancePrices(t, s)
+	if _, ok := prices["ETHUSDT"]; ok {
+		t.Error("delisted ETHUSDT is still listed on Binance")
+	}
+	if _, ok := prices["BTCUSDT"]; !ok {
+		t.Error("BTCUSDT is missing on Binance")
+	}
+}
+
+func TestErrorEvent(t *testing.T) {
+	s := newSimulator(t, newScenario(
+		Event{Type: EventError, Exchange: "Bybit", Status: http.StatusTooManyRequests, At: scheduler.Duration(time.Second), For: scheduler.Duration(time.Second)},
+	))
+
+	path := "/v5/market/tickers?category=spot"
+	if code := get(t, s, "api.bybit.com", path, nil); code != http.StatusOK {
+		t.Errorf("before the error: status %d", code)
+	}
+	s.advance()
+	if code := get(t, s, "api.bybit.com", path, nil); code != http.StatusTooManyRequests {
+		t.Errorf("during the error: status %d, want 429", code)
+	}
+	if code := get(t, s, "api.binance.com", "/api/v3/ticker/price", nil); code != http.StatusOK {
+		t.Errorf("Binance during the Bybit error: status %d", code)
+	}
+	s.advance()
+	if code := get(t, s, "api.bybit.com", path, nil); code != http.StatusOK {
+		t.Errorf("after the error: status %d", code)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	s := newSimulator(t, newScenario())
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	client := &http.Client{Transport: s.Transport(srv.URL, http.DefaultTransport)}
+
+	resp, err := client.Get("https://fapi.binance.com/fapi/v1/premiumIndex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"markPrice"`) {
+		t.Errorf("status %d, body %s", resp.StatusCode, body)
+	}
+
+	// OKX is not part of the scenario and the request must not leave the machine
+	if _, err := client.Get("https://www.okx.com/api/v5/market/tickers?instType=SPOT"); err == nil {
+		t.Error("expected an error for an exchange that is not simulated")
+	}
+}
+
+func TestLoadExample(t *testing.T) {
+	sc, err := Load("../simulator.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sc.Exchanges) != len(venues) || len(sc.Events) != 5 || sc.Events[3].Status != 429 {
+		t.Errorf("unexpected scenario %+v", sc)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	sc := newScenario(
+		Event{Type: "outage"},
+		Event{Type: EventSpike, Exchange: "OKX", Symbol: "DOGEUSDT", Size: 0.1},
+		Event{Type: EventLatency},
+	)
+	err := sc.Validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{`unknown type "outage"`, `exchange "OKX" is not simulated`, `unknown symbol "DOGEUSDT"`, "delay must be positive"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func BenchmarkBinanceTickers(b *testing.B) {
+	sc := newScenario()
+	sc.GeneratedMarkets = 5000
+	s, err := New(sc)
+	if err != nil {
+		b.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api.binance.com/api/v3/ticker/24hr", nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
@@ -0,0 +1,900 @@
+package simulator
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// route renders the response of one endpoint in the exchange's own format.
+type route func(b book, query url.Values) any
+
+// venue is a simulated exchange: the hosts its connector calls and the
+// endpoints it uses there. Paths are matched without the query string.
+type venue struct {
+	hosts  []string
+	routes map[string]route
+}
+
+// Exchanges returns the names of the exchanges the simulator can serve.
+func Exchanges() []string {
+	names := make([]string, 0, len(venues))
+	for name := range venues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// object is a JSON object in a response.
+type object = map[string]any
+
+// num formats a number the way most exchanges do, as a string.
+func num(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+// nextFunding is the next of the usual 00:00, 08:00 and 16:00 UTC funding times.
+func nextFunding(now time.Time) time.Time {
+	return now.UTC().Truncate(8 * time.Hour).Add(8 * time.Hour)
+}
+
+// network is a deposit and withdrawal network of an asset.
+type network struct {
+	Code string
+	Name string
+}
+
+// networks returns the simulated networks of an asset, all of them open.
+func networks(asset string) []network {
+	switch asset {
+	case "USDT":
+		return []network{{Code: "ERC20", Name: "Ethereum (ERC20)"}, {Code: "TRC20", Name: "Tron (TRC20)"}}
+	case "USDC":
+		return []network{{Code: "ERC20", Name: "Ethereum (ERC20)"}, {Code: "SOL", Name: "Solana"}}
+	}
+	return []network{{Code: asset, Name: asset}}
+}
+
+// assets returns the base and quote assets of the listed markets, sorted.
+func (b book) assets() []string {
+	seen := make(map[string]bool)
+	b.each(func(m Market, _ quote) {
+		seen[m.Base] = true
+		seen[m.Quote] = true
+	})
+	assets := make([]string, 0, len(seen))
+	for asset := range seen {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
+	return assets
+}
+
+// list renders one object per listed market.
+func (b book) list(fn func(m Market, q quote) object) []object {
+	items := []object{}
+	b.each(func(m Market, q quote) {
+		items = append(items, fn(m, q))
+	})
+	return items
+}
+
+var venues = map[string]venue{
+	"Backpack": {
+		hosts: []string{"api.backpack.exchange"},
+		routes: map[string]route{
+			"/api/v1/markets": func(b book, _ url.Values) any {
+				var markets []object
+				b.each(func(m Market, _ quote) {
+					for _, kind := range []string{"SPOT", "PERP"} {
+						symbol := m.Base + "_" + m.Quote
+						if kind == "PERP" {
+							symbol += "_PERP"
+						}
+						markets = append(markets, object{
+							"symbol": symbol, "baseSymbol": m.Base, "quoteSymbol": m.Quote,
+							"marketType": kind, "orderBookState": "Open",
+						})
+					}
+				})
+				return markets
+			},
+			"/api/v1/tickers": func(b book, _ url.Values) any {
+				var tickers []object
+				b.each(func(m Market, q quote) {
+					for _, suffix := range []string{"", "_PERP"} {
+						tickers = append(tickers, object{
+							"symbol": m.Base + "_" + m.Quote + suffix, "lastPrice": num(q.Price),
+							"priceChangePercent": num(q.Change24h() * 100),
+							"volume":             num(q.Volume), "quoteVolume": num(q.QuoteVolume()),
+						})
+					}
+				})
+				return tickers
+			},
+			"/api/v1/markPrices": func(b book, _ url.Values) any {
+				return b.list(func(m Market, q quote) object {
+					return object{
+						"symbol": m.Base + "_" + m.Quote + "_PERP", "markPrice": num(q.Mark), "indexPrice": num(q.Index),
+						"fundingRate": num(q.Funding), "nextFundingTimestamp": millis(nextFunding(b.now)),
+					}
+				})
+			},
+			"/api/v1/time": func(b book, _ url.Values) any {
+				return object{"serverTime": millis(b.now)}
+			},
+			"/api/v1/capital": func(b book, _ url.Values) any {
+				var assets []object
+				for _, asset := range b.assets() {
+					var nets []object
+					for _, n := range networks(asset) {
+						nets = append(nets, object{"network": n.Code, "name": n.Name, "depositEnabled": true, "withdrawalEnabled": true})
+					}
+					assets = append(assets, object{"asset": asset, "networks": nets})
+				}
+				return assets
+			},
+		},
+	},
+
+	"Binance": {
+		hosts: []string{"api.binance.com", "fapi.binance.com", "dapi.binance.com"},
+		routes: map[string]route{
+			"/api/v3/exchangeInfo": func(b book, _ url.Values) any {
+				return object{"symbols": b.list(func(m Market, _ quote) object {
+					return object{
+						"symbol": m.Symbol(), "status": "TRADING", "baseAsset": m.Base, "quoteAsset": m.Quote,
+						"isSpotTradingAllowed": true,
+					}
+				})}
+			},
+			"/api/v3/ticker/price": func(b book, _ url.Values) any {
+				return b.list(func(m Market, q quote) object {
+					return object{"symbol": m.Symbol(), "price": num(q.Price)}
+				})
+			},
+			"/api/v3/ticker/24hr":  binanceTicker24hr,
+			"/fapi/v1/ticker/24hr": binanceTicker24hr,
+			"/api/v3/time": func(b book, _ url.Values) any {
+				return object{"serverTime": millis(b.now)}
+			},
+			"/sapi/v1/capital/config/getall": func(b book, _ url.Values) any {
+				var coins []object
+				for _, asset := range b.assets() {
+					var nets []object
+					for _, n := range networks(asset) {
+						nets = append(nets, object{"network": n.Code, "name": n.Name, "depositEnable": true, "withdrawEnable": true})
+					}
+					coins = append(coins, object{"coin": asset, "name": asset, "networkList": nets})
+				}
+				return coins
+			},
+			"/fapi/v1/exchangeInfo": func(b book, _ url.Values) any {
+				return object{"symbols": b.list(func(m Market, _ quote) object {
+					return object{
+						"symbol": m.Symbol(), "baseAsset": m.Base, "quoteAsset": m.Quote,
+						"contractType": "PERPETUAL", "status": "TRADING",
+					}
+				})}
+			},
+			"/fapi/v1/premiumIndex": func(b book, _ url.Values) any {
+				return b.list(func(m Market, q quote) object {
+					return object{
+						"symbol": m.Symbol(), "markPrice": num(q.Mark), "indexPrice": num(q.Index),
+						"lastFundingRate": num(q.Funding), "nextFundingTime": millis(nextFunding(b.now)),
+					}
+				})
+			},
+			// COIN-M perpetuals of the USDT markets, in contracts of 10 USD
+			"/dapi/v1/exchangeInfo": func(b book, _ url.Values) any {
+				return object{"symbols": usdtMarkets(b, func(m Market, _ quote) object {
+					return object{
+						"symbol": m.Base + "USD_PERP", "pair": m.Base + "USD", "baseAsset": m.Base, "quoteAsset": "USD", "marginAsset": m.Base,
+						"contractType": "PERPETUAL", "contractStatus": "TRADING", "contractSize": 10,
+					}
+				})}
+			},
+			"/dapi/v1/premiumIndex": func(b book, _ url.Values) any {
+				return usdtMarkets(b, func(m Market, q quote) object {
+					return object{
+						"symbol": m.Base + "USD_PERP", "markPrice": num(q.Mark), "indexPrice": num(q.Index),
+						"lastFundingRate": num(q.Funding), "nextFundingTime": millis(nextFunding(b.now)),
+					}
+				})
+			},
+			"/dapi/v1/ticker/24hr": func(b book, _ url.Values) any {
+				return usdtMarkets(b, func(m Market, q quote) object {
+					return object{
+						"symbol": m.Base + "USD_PERP", "lastPrice": num(q.Price), "priceChangePercent": num(q.Change24h() * 100),
+						"volume": num(q.QuoteVolume() / 10), "baseVolume": num(q.Volume),
+					}
+				})
+			},
+		},
+	},
+
+	"Bitfinex": {
+		hosts: []string{"api-pub.bitfinex.com"},
+		routes: map[string]route{
+			"/v2/conf/pub:list:pair:exchange": func(b book, _ url.Values) any {
+				pairs := []string{}
+				b.each(func(m Market, _ quote) {
+					pairs = append(pairs, bitfinexPair(m))
+				})
+				return [][]string{pairs}
+			},
+			"/v2/conf/pub:map:currency:sym": func(b book, _ url.Values) any {
+				return [][][2]string{{{"UDC", "USDC"}, {"UST", "USDT"}}}
+			},
+			"/v2/tickers": func(b book, _ url.Values) any {
+				tickers := [][]any{}
+				b.each(func(m Market, q quote) {
+					tickers = append(tickers, []any{
+						"t" + bitfinexPair(m), q.Price, 1, q.Price, 1, q.Price - q.Open, q.Change24h(), q.Price, q.Volume, q.Price, q.Price,
+					})
+				})
+				return tickers
+			},
+			"/v2/conf/pub:map:tx:method": func(b book, _ url.Values) any {
+				// Transfer methods are named after the network and carry every asset on it
+				carried := make(map[string][]string)
+				for _, asset := range b.assets() {
+					for _, n := range networks(asset) {
+						carried[n.Code] = append(carried[n.Code], bitfinexCurrency(asset))
+					}
+				}
+				names := make([]string, 0, len(carried))
+				for method := range carried {
+					names = append(names, method)
+				}
+				sort.Strings(names)
+				methods := [][]any{}
+				for _, method := range names {
+					methods = append(methods, []any{method, carried[method]})
+				}
+				return [][][]any{methods}
+			},
+			"/v2/conf/pub:info:tx:status": func(b book, _ url.Values) any {
+				seen := make(map[string]bool)
+				statuses := [][]any{}
+				for _, asset := range b.assets() {
+					for _, n := range networks(asset) {
+						if !seen[n.Code] {
+							seen[n.Code] = true
+							statuses = append(statuses, []any{n.Code, 1, 1, nil, nil, nil, nil, 0, 0, nil, nil, 1})
+						}
+					}
+				}
+				return [][][]any{statuses}
+			},
+		},
+	},
+
+	"Bitget": {
+		hosts: []string{"api.bitget.com"},
+		routes: map[string]route{
+			"/api/v2/spot/public/symbols": func(b book, _ url.Values) any {
+				return bitgetResponse(b, b.list(func(m Market, _ quote) object {
+					return object{"symbol": m.Symbol(), "baseCoin": m.Base, "quoteCoin": m.Quote, "status": "online"}
+				}))
+			},
+			"/api/v2/spot/market/tickers": func(b book, _ url.Values) any {
+				return bitgetResponse(b, b.list(func(m Market, q quote) object {
+					return object{
+						"symbol": m.Symbol(), "lastPr": num(q.Price), "change24h": num(q.Change24h()),
+						"baseVolume": num(q.Volume), "quoteVolume": num(q.QuoteVolume()),
+					}
+				}))
+			},
+			"/api/v2/mix/market/contracts": func(b book, query url.Values) any {
+				return bitgetResponse(b, bitgetFutures(b, query, func(m Market, _ quote) object {
+					return object{
+						"symbol": m.Symbol(), "baseCoin": m.Base, "quoteCoin": m.Quote,
+						"symbolType": "perpetual", "symbolStatus": "normal", "fundInterval": "8",
+					}
+				}))
+			},
+			"/api/v2/mix/market/tickers": func(b book, query url.Values) any {
+				return bitgetResponse(b, bitgetFutures(b, query, func(m Market, q quote) object {
+					return object{
+						"symbol": m.Symbol(), "lastPr": num(q.Price), "markPrice": num(q.Mark), "indexPrice": num(q.Index),
+						"fundingRate": num(q.Funding), "change24h": num(q.Change24h()), "baseVolume": num(q.Volume),
+						"quoteVolume": num(q.QuoteVolume()), "ts": strconv.FormatInt(millis(b.now), 10),
+					}
+				}))
+			},
+			"/api/v2/spot/public/coins": func(b book, _ url.Values) any {
+				var coins []object
+				for _, asset := range b.assets() {
+					var chains []object
+					for _, n := range networks(asset) {
+						chains = append(chains, object{"chain": n.Code, "withdrawable": "true", "rechargeable": "true"})
+					}
+					coins = append(coins, object{"coin": asset, "transfer": "true", "chains": chains})
+				}
+				return bitgetResponse(b, coins)
+			},
+		},
+	},
+
+	"Bybit": {
+		hosts: []string{"api.bybit.com"},
+		routes: map[string]route{
+			"/v5/market/time": func(b book, _ url.Values) any {
+				seconds := b.now.Unix()
+				return object{
+					"retCode": 0, "retMsg": "OK", "time": millis(b.now),
+					"result": object{"timeSecond": strconv.FormatInt(seconds, 10), "timeNano": strconv.FormatInt(b.now.UnixNano(), 10)},
+				}
+			},
+			"/v5/asset/coin/query-info": func(b book, _ url.Values) any {
+				var rows []object
+				for _, asset := range b.assets() {
+					var chains []object
+					for _, n := range networks(asset) {
+						chains = append(chains, object{"chain": n.Code, "chainType": n.Name, "chainDeposit": "1", "chainWithdraw": "1"})
+					}
+					rows = append(rows, object{"coin": asset, "name": asset, "chains": chains})
+				}
+				return object{"retCode": 0, "retMsg": "success", "time": millis(b.now), "result": object{"rows": rows}}
+			},
+			"/v5/market/instruments-info": func(b book, query url.Values) any {
+				category := query.Get("category")
+				if category == "inverse" {
+					return bybitResponse(b, category, usdtMarkets(b, func(m Market, _ quote) object {
+						return object{
+							"symbol": m.Base + "USD", "baseCoin": m.Base, "quoteCoin": "USD", "settleCoin": m.Base,
+							"contractType": "InversePerpetual", "status": "Trading", "deliveryTime": "0",
+						}
+					}))
+				}
+				return bybitResponse(b, category, b.list(func(m Market, _ quote) object {
+					instrument := object{"symbol": m.Symbol(), "baseCoin": m.Base, "quoteCoin": m.Quote, "status": "Trading"}
+					if category == "linear" {
+						instrument["contractType"] = "LinearPerpetual"
+					}
+					return instrument
+				}))
+			},
+			"/v5/market/tickers": func(b book, query url.Values) any {
+				category := query.Get("category")
+				if category == "inverse" {
+					// Inverse contracts count volume24h in USD and turnover24h in the base coin
+					return bybitResponse(b, category, usdtMarkets(b, func(m Market, q quote) object {
+						return object{
+							"symbol": m.Base + "USD", "lastPrice": num(q.Price), "price24hPcnt": num(q.Change24h()),
+							"volume24h": num(q.QuoteVolume()), "turnover24h": num(q.Volume),
+							"markPrice": num(q.Mark), "indexPrice": num(q.Index), "fundingRate": num(q.Funding),
+							"nextFundingTime": strconv.FormatInt(millis(nextFunding(b.now)), 10),
+						}
+					}))
+				}
+				return bybitResponse(b, category, b.list(func(m Market, q quote) object {
+					ticker := object{
+						"symbol": m.Symbol(), "lastPrice": num(q.Price), "price24hPcnt": num(q.Change24h()),
+						"volume24h": num(q.Volume), "turnover24h": num(q.QuoteVolume()),
+					}
+					if category == "linear" {
+						ticker["markPrice"] = num(q.Mark)
+						ticker["indexPrice"] = num(q.Index)
+						ticker["fundingRate"] = num(q.Funding)
+						ticker["nextFundingTime"] = strconv.FormatInt(millis(nextFunding(b.now)), 10)
+					}
+					return ticker
+				}))
+			},
+		},
+	},
+
+	"Coinbase": {
+		hosts: []string{"api.exchange.coinbase.com"},
+		routes: map[string]route{
+			"/products": func(b book, _ url.Values) any {
+				return b.list(func(m Market, _ quote) object {
+					id := m.Base + "-" + m.Quote
+					return object{"id": id, "display_name": id, "base_currency": m.Base, "quote_currency": m.Quote, "status": "online", "trading_disabled": false}
+				})
+			},
+			"/products/stats": func(b book, _ url.Values) any {
+				stats := object{}
+				b.each(func(m Market, q quote) {
+					stats[m.Base+"-"+m.Quote] = object{"stats_24hour": object{"open": num(q.Open), "last": num(q.Price), "volume": num(q.Volume)}}
+				})
+				return stats
+			},
+			"/currencies": func(b book, _ url.Values) any {
+				var currencies []object
+				for _, asset := range b.assets() {
+					var nets []object
+					for _, n := range networks(asset) {
+						nets = append(nets, object{"id": strings.ToLower(n.Code), "name": n.Name, "status": "online"})
+					}
+					currencies = append(currencies, object{"id": asset, "name": asset, "status": "online", "supported_networks": nets})
+				}
+				return currencies
+			},
+		},
+	},
+
+	"dYdX": {
+		hosts: []string{"indexer.dydx.trade"},
+		routes: map[string]route{
+			"/v4/perpetualMarkets": func(b book, _ url.Values) any {
+				markets := object{}
+				b.each(func(m Market, q quote) {
+					if m.Quote != "USDT" {
+						return
+					}
+					ticker := m.Base + "-USD"
+					markets[ticker] = object{
+						"ticker": ticker, "status": "ACTIVE", "oraclePrice": num(q.Mark),
+						"priceChange24H": num(q.Mark - q.Open), "volume24H": num(q.QuoteVolume()),
+						"nextFundingRate": num(q.Funding / 8), "openInterest": num(q.Volume / 2),
+					}
+				})
+				return object{"markets": markets}
+			},
+		},
+	},
+
+	"Gate": {
+		hosts: []string{"api.gateio.ws"},
+		routes: map[string]route{
+			"/api/v4/spot/currency_pairs": func(b book, _ url.Values) any {
+				return b.list(func(m Market, _ quote) object {
+					return object{"id": m.Base + "_" + m.Quote, "base": m.Base, "quote": m.Quote, "trade_status": "tradable", "sell_start": 0, "buy_start": 0}
+				})
+			},
+			"/api/v4/spot/tickers": func(b book, _ url.Values) any {
+				return b.list(func(m Market, q quote) object {
+					return object{
+						"currency_pair": m.Base + "_" + m.Quote, "last": num(q.Price), "change_percentage": num(q.Change24h() * 100),
+						"base_volume": num(q.Volume), "quote_volume": num(q.QuoteVolume()),
+					}
+				})
+			},
+			"/api/v4/spot/currencies": func(b book, _ url.Values) any {
+				var currencies []object
+				for _, asset := range b.assets() {
+					var chains []object
+					for _, n := range networks(asset) {
+						chains = append(chains, object{"name": n.Code, "withdraw_disabled": false, "withdraw_delayed": false, "deposit_disabled": false})
+					}
+					currencies = append(currencies, object{"currency": asset, "name": asset, "delisted": false, "chains": chains})
+				}
+				return currencies
+			},
+			"/api/v4/futures/usdt/contracts": func(b book, _ url.Values) any {
+				next := nextFunding(b.now).Unix()
+				return usdtMarkets(b, func(m Market, q quote) object {
+					return object{
+						"name": m.Base + "_" + m.Quote, "type": "direct", "mark_price": num(q.Mark), "index_price": num(q.Index),
+						"funding_rate": num(q.Funding), "funding_interval": 28800, "funding_next_apply": next, "in_delisting": false,
+					}
+				})
+			},
+			"/api/v4/futures/usdt/tickers": func(b book, _ url.Values) any {
+				return usdtMarkets(b, func(m Market, q quote) object {
+					return object{
+						"contract": m.Base + "_" + m.Quote, "last": num(q.Price), "change_percentage": num(q.Change24h() * 100),
+						"mark_price": num(q.Mark), "index_price": num(q.Index), "funding_rate": num(q.Funding),
+						"volume_24h_base": num(q.Volume), "volume_24h_quote": num(q.QuoteVolume()),
+					}
+				})
+			},
+		},
+	},
+
+	"Huobi": {
+		hosts: []string{"api.huobi.pro", "api.hbdm.com"},
+		routes: map[string]route{
+			"/v1/common/symbols": func(b book, _ url.Values) any {
+				return object{"status": "ok", "data": b.list(func(m Market, _ quote) object {
+					return object{
+						"base-currency": strings.ToLower(m.Base), "quote-currency": strings.ToLower(m.Quote),
+						"symbol": strings.ToLower(m.Symbol()), "state": "online",
+					}
+				})}
+			},
+			"/market/tickers": func(b book, _ url.Values) any {
+				return object{"status": "ok", "ts": millis(b.now), "data": b.list(func(m Market, q quote) object {
+					return object{"symbol": strings.ToLower(m.Symbol()), "open": q.Open, "close": q.Price, "amount": q.Volume, "vol": q.QuoteVolume()}
+				})}
+			},
+			"/v2/reference/currencies": func(b book, _ url.Values) any {
+				var currencies []object
+				for _, asset := range b.assets() {
+					var chains []object
+					for _, n := range networks(asset) {
+						chains = append(chains, object{
+							"chain": strings.ToLower(n.Code), "displayName": n.Code, "fullName": n.Name,
+							"depositStatus": "allowed", "withdrawStatus": "allowed",
+						})
+					}
+					currencies = append(currencies, object{"currency": strings.ToLower(asset), "instStatus": "normal", "chains": chains})
+				}
+				return object{"code": 200, "data": currencies}
+			},
+			"/linear-swap-api/v1/swap_contract_info": func(b book, _ url.Values) any {
+				return object{"status": "ok", "ts": millis(b.now), "data": usdtMarkets(b, func(m Market, _ quote) object {
+					return object{
+						"symbol": m.Base, "contract_code": m.Base + "-" + m.Quote, "contract_status": 1,
+						"business_type": "swap", "contract_type": "swap", "trade_partition": m.Quote,
+					}
+				})}
+			},
+			"/linear-swap-ex/market/detail/batch_merged": func(b book, _ url.Values) any {
+				return object{"status": "ok", "ts": millis(b.now), "ticks": usdtMarkets(b, func(m Market, q quote) object {
+					return object{
+						"contract_code": m.Base + "-" + m.Quote, "business_type": "swap", "open": num(q.Open), "close": num(q.Price),
+						"amount": num(q.Volume), "trade_turnover": num(q.QuoteVolume()),
+					}
+				})}
+			},
+			"/linear-swap-api/v1/swap_batch_funding_rate": func(b book, _ url.Values) any {
+				next := strconv.FormatInt(millis(nextFunding(b.now)), 10)
+				return object{"status": "ok", "ts": millis(b.now), "data": usdtMarkets(b, func(m Market, q quote) object {
+					return object{
+						"contract_code": m.Base + "-" + m.Quote, "symbol": m.Base, "fee_asset": m.Quote,
+						"funding_rate": num(q.Funding), "funding_time": next,
+					}
+				})}
+			},
+			"/linear-swap-api/v1/swap_index": func(b book, _ url.Values) any {
+				return object{"status": "ok", "ts": millis(b.now), "data": usdtMarkets(b, func(m Market, q quote) object {
+					return object{"contract_code": m.Base + "-" + m.Quote, "index_price": q.Index, "index_ts": millis(b.now)}
+				})}
+			},
+		},
+	},
+
+	"Hyperliquid": {
+		hosts: []string{"api.hyperliquid.xyz"},
+		routes: map[string]route{
+			// The connector only posts {"type":"metaAndAssetCtxs"}
+			"/info": func(b book, _ url.Values) any {
+				universe, ctxs := []object{}, []object{}
+				b.each(func(m Market, q quote) {
+					if m.Quote != "USDT" {
+						return
+					}
+					universe = append(universe, object{"name": m.Base, "szDecimals": 2, "maxLeverage": 20})
+					ctxs = append(ctxs, object{
+						"funding": num(q.Funding / 8), "openInterest": num(q.Volume / 2), "prevDayPx": num(q.Open),
+						"dayNtlVlm": num(q.QuoteVolume()), "dayBaseVlm": num(q.Volume),
+						"oraclePx": num(q.Index), "markPx": num(q.Mark),
+					})
+				})
+				return []any{object{"universe": universe}, ctxs}
+			},
+		},
+	},
+
+	"Kraken": {
+		hosts: []string{"api.kraken.com"},
+		routes: map[string]route{
+			"/0/public/AssetPairs": func(b book, _ url.Values) any {
+				pairs := object{}
+				b.each(func(m Market, _ quote) {
+					pairs[m.Symbol()] = object{"altname": m.Symbol(), "wsname": m.Base + "/" + m.Quote, "base": m.Base, "quote": m.Quote, "status": "online"}
+				})
+				return object{"error": []string{}, "result": pairs}
+			},
+			"/0/public/Ticker": func(b book, _ url.Values) any {
+				tickers := object{}
+				b.each(func(m Market, q quote) {
+					tickers[m.Symbol()] = object{"c": []string{num(q.Price), "1"}, "v": []string{num(q.Volume / 2), num(q.Volume)}}
+				})
+				return object{"error": []string{}, "result": tickers}
+			},
+		},
+	},
+
+	"KuCoin": {
+		hosts: []string{"api.kucoin.com", "api-futures.kucoin.com"},
+		routes: map[string]route{
+			"/api/v1/symbols": func(b book, _ url.Values) any {
+				return object{"code": "200000", "data": b.list(func(m Market, _ quote) object {
+					symbol := m.Base + "-" + m.Quote
+					return object{"symbol": symbol, "name": symbol, "baseCurrency": m.Base, "quoteCurrency": m.Quote, "enableTrading": true}
+				})}
+			},
+			"/api/v1/market/allTickers": func(b book, _ url.Values) any {
+				return object{"code": "200000", "data": object{"time": millis(b.now), "ticker": b.list(func(m Market, q quote) object {
+					symbol := m.Base + "-" + m.Quote
+					return object{
+						"symbol": symbol, "symbolName": symbol, "last": num(q.Price), "changeRate": num(q.Change24h()),
+						"vol": num(q.Volume), "volValue": num(q.QuoteVolume()),
+					}
+				})}}
+			},
+			"/api/v3/currencies": func(b book, _ url.Values) any {
+				var currencies []object
+				for _, asset := range b.assets() {
+					var chains []object
+					for _, n := range networks(asset) {
+						chains = append(chains, object{
+							"chainName": n.Code, "chainId": strings.ToLower(n.Code), "isDepositEnabled": true, "isWithdrawEnabled": true,
+						})
+					}
+					currencies = append(currencies, object{"currency": asset, "name": asset, "fullName": asset, "chains": chains})
+				}
+				return object{"code": "200000", "data": currencies}
+			},
+			"/api/v1/contracts/active": func(b book, _ url.Values) any {
+				next := millis(nextFunding(b.now))
+				return object{"code": "200000", "data": b.list(func(m Market, q quote) object {
+					// KuCoin Futures calls bitcoin XBT
+					base := m.Base
+					if base == "BTC" {
+						base = "XBT"
+					}
+					return object{
+						"symbol": base + m.Quote + "M", "type": "FFWCSX", "baseCurrency": base, "quoteCurrency": m.Quote,
+						"settleCurrency": m.Quote, "isInverse": false, "status": "Open", "markPrice": q.Mark, "indexPrice": q.Index,
+						"lastTradePrice": q.Price, "fundingFeeRate": q.Funding, "nextFundingRateDateTime": next,
+						"priceChgPct": q.Change24h(), "volumeOf24h": q.Volume, "turnoverOf24h": q.QuoteVolume(),
+					}
+				})}
+			},
+		},
+	},
+
+	"MEXC": {
+		hosts: []string{"api.mexc.com", "contract.mexc.com"},
+		routes: map[string]route{
+			"/api/v3/exchangeInfo": func(b book, _ url.Values) any {
+				return object{"symbols": b.list(func(m Market, _ quote) object {
+					return object{"symbol": m.Symbol(), "status": "1", "baseAsset": m.Base, "quoteAsset": m.Quote, "isSpotTradingAllowed": true}
+				})}
+			},
+			"/api/v3/ticker/24hr": func(b book, _ url.Values) any {
+				return b.list(func(m Market, q quote) object {
+					return object{
+						"symbol": m.Symbol(), "lastPrice": num(q.Price), "priceChangePercent": num(q.Change24h()),
+						"volume": num(q.Volume), "quoteVolume": num(q.QuoteVolume()),
+					}
+				})
+			},
+			"/api/v3/time": func(b book, _ url.Values) any {
+				return object{"serverTime": millis(b.now)}
+			},
+			"/api/v3/capital/config/getall": func(b book, _ url.Values) any {
+				var coins []object
+				for _, asset := range b.assets() {
+					var nets []object
+					for _, n := range networks(asset) {
+						nets = append(nets, object{"network": n.Code, "netWork": n.Code, "name": n.Name, "depositEnable": true, "withdrawEnable": true})
+					}
+					coins = append(coins, object{"coin": asset, "Name": asset, "networkList": nets})
+				}
+				return coins
+			},
+			"/api/v1/contract/ticker": func(b book, _ url.Values) any {
+				return object{"success": true, "code": 0, "data": b.list(func(m Market, q quote) object {
+					return object{
+						"symbol": m.Base + "_" + m.Quote, "lastPrice": q.Price, "volume24": q.Volume, "amount24": q.QuoteVolume(),
+						"riseFallRate": q.Change24h(), "indexPrice": q.Index, "fairPrice": q.Mark, "fundingRate": q.Funding,
+						"timestamp": millis(b.now),
+					}
+				})}
+			},
+		},
+	},
+
+	"OKX": {
+		hosts: []string{"www.okx.com"},
+		routes: map[string]route{
+			"/api/v5/market/tickers": func(b book, query url.Values) any {
+				swap := query.Get("instType") == "SWAP"
+				tickers := b.list(func(m Market, q quote) object {
+					ticker := object{
+						"instType": query.Get("instType"), "instId": m.Base + "-" + m.Quote, "last": num(q.Price), "open24h": num(q.Open),
+						"vol24h": num(q.Volume), "volCcy24h": num(q.QuoteVolume()), "ts": strconv.FormatInt(millis(b.now), 10),
+					}
+					if swap {
+						// Swaps count their volume in contracts of 0.01 and volCcy24h in the base coin
+						ticker["instId"] = okxSwap(m)
+						ticker["vol24h"], ticker["volCcy24h"] = num(q.Volume*100), num(q.Volume)
+					}
+					return ticker
+				})
+				if swap {
+					// Inverse swaps count their volume in contracts of 100 USD
+					tickers = append(tickers, usdtMarkets(b, func(m Market, q quote) object {
+						return object{
+							"instType": "SWAP", "instId": okxInverseSwap(m), "last": num(q.Price), "open24h": num(q.Open),
+							"vol24h": num(q.QuoteVolume() / 100), "volCcy24h": num(q.Volume), "ts": strconv.FormatInt(millis(b.now), 10),
+						}
+					})...)
+				}
+				return okxResponse(tickers)
+			},
+			"/api/v5/public/instruments": func(b book, _ url.Values) any {
+				instruments := b.list(func(m Market, _ quote) object {
+					return object{
+						"instType": "SWAP", "instId": okxSwap(m), "uly": m.Base + "-" + m.Quote, "instFamily": m.Base + "-" + m.Quote,
+						"ctType": "linear", "ctVal": "0.01", "ctValCcy": m.Base, "settleCcy": m.Quote, "state": "live",
+					}
+				})
+				instruments = append(instruments, usdtMarkets(b, func(m Market, _ quote) object {
+					return object{
+						"instType": "SWAP", "instId": okxInverseSwap(m), "uly": m.Base + "-USD", "instFamily": m.Base + "-USD",
+						"ctType": "inverse", "ctVal": "100", "ctValCcy": "USD", "settleCcy": m.Base, "state": "live",
+					}
+				})...)
+				return okxResponse(instruments)
+			},
+			"/api/v5/public/mark-price": func(b book, _ url.Values) any {
+				markPrice := func(instID func(Market) string) func(m Market, q quote) object {
+					return func(m Market, q quote) object {
+						return object{"instType": "SWAP", "instId": instID(m), "markPx": num(q.Mark), "ts": strconv.FormatInt(millis(b.now), 10)}
+					}
+				}
+				return okxResponse(append(b.list(markPrice(okxSwap)), usdtMarkets(b, markPrice(okxInverseSwap))...))
+			},
+			"/api/v5/public/funding-rate": func(b book, _ url.Values) any {
+				next := nextFunding(b.now)
+				fundingRate := func(instID func(Market) string) func(m Market, q quote) object {
+					return func(m Market, q quote) object {
+						return object{
+							"instType": "SWAP", "instId": instID(m), "fundingRate": num(q.Funding),
+							"fundingTime":     strconv.FormatInt(millis(next), 10),
+							"nextFundingTime": strconv.FormatInt(millis(next.Add(8*time.Hour)), 10),
+						}
+					}
+				}
+				return okxResponse(append(b.list(fundingRate(okxSwap)), usdtMarkets(b, fundingRate(okxInverseSwap))...))
+			},
+			"/api/v5/public/time": func(b book, _ url.Values) any {
+				return okxResponse([]object{{"ts": strconv.FormatInt(millis(b.now), 10)}})
+			},
+			"/api/v5/asset/currencies": func(b book, _ url.Values) any {
+				var currencies []object
+				for _, asset := range b.assets() {
+					for _, n := range networks(asset) {
+						currencies = append(currencies, object{"ccy": asset, "name": asset, "chain": asset + "-" + n.Code, "canDep": true, "canWd": true})
+					}
+				}
+				return okxResponse(currencies)
+			},
+			"/api/v5/market/index-tickers": func(b book, query url.Values) any {
+				var tickers []object
+				b.each(func(m Market, q quote) {
+					switch {
+					case m.Quote == query.Get("quoteCcy"):
+						tickers = append(tickers, object{"instId": m.Base + "-" + m.Quote, "idxPx": num(q.Index), "ts": strconv.FormatInt(millis(b.now), 10)})
+					case m.Quote == "USDT" && query.Get("quoteCcy") == "USD":
+						// The USD index of the inverse swaps follows the USDT market
+						tickers = append(tickers, object{"instId": m.Base + "-USD", "idxPx": num(q.Index), "ts": strconv.FormatInt(millis(b.now), 10)})
+					}
+				})
+				return okxResponse(tickers)
+			},
+		},
+	},
+
+	"WhiteBIT": {
+		hosts: []string{"whitebit.com"},
+		routes: map[string]route{
+			"/api/v4/public/markets": func(b book, _ url.Values) any {
+				return b.list(func(m Market, _ quote) object {
+					return object{"name": m.Base + "_" + m.Quote, "stock": m.Base, "money": m.Quote, "tradesEnabled": true, "type": "spot"}
+				})
+			},
+			"/api/v4/public/ticker": func(b book, _ url.Values) any {
+				tickers := object{}
+				b.each(func(m Market, q quote) {
+					tickers[m.Base+"_"+m.Quote] = object{
+						"last_price": num(q.Price), "base_volume": num(q.Volume), "quote_volume": num(q.QuoteVolume()),
+						"isFrozen": false, "change": num(q.Change24h() * 100),
+					}
+				})
+				return tickers
+			},
+			"/api/v4/public/assets": func(b book, _ url.Values) any {
+				assets := object{}
+				for _, asset := range b.assets() {
+					var codes []string
+					for _, n := range networks(asset) {
+						codes = append(codes, n.Code)
+					}
+					assets[asset] = object{
+						"name": asset, "can_deposit": true, "can_withdraw": true,
+						"networks": object{"deposits": codes, "withdraws": codes, "default": codes[0]},
+					}
+				}
+				return assets
+			},
+		},
+	},
+}
+
+func binanceTicker24hr(b book, _ url.Values) any {
+	return b.list(func(m Market, q quote) object {
+		return object{
+			"symbol": m.Symbol(), "lastPrice": num(q.Price), "priceChangePercent": num(q.Change24h() * 100),
+			"volume": num(q.Volume), "quoteVolume": num(q.QuoteVolume()),
+		}
+	})
+}
+
+func bitgetResponse(b book, data []object) object {
+	return object{"code": "00000", "msg": "success", "requestTime": millis(b.now), "data": data}
+}
+
+// usdtMarkets renders the listed markets quoted in USDT, for exchanges that
+// only simulate their USDT margined contracts.
+func usdtMarkets(b book, fn func(m Market, q quote) object) []object {
+	items := []object{}
+	b.each(func(m Market, q quote) {
+		if m.Quote == "USDT" {
+			items = append(items, fn(m, q))
+		}
+	})
+	return items
+}
+
+// bitgetFutures renders the listed markets of the product type asked for,
+// USDT-FUTURES or USDC-FUTURES.
+func bitgetFutures(b book, query url.Values, fn func(m Market, q quote) object) []object {
+	quoteCoin, _ := strings.CutSuffix(query.Get("productType"), "-FUTURES")
+	items := []object{}
+	b.each(func(m Market, q quote) {
+		if m.Quote == quoteCoin {
+			items = append(items, fn(m, q))
+		}
+	})
+	return items
+}
+
+func bybitResponse(b book, category string, list []object) object {
+	return object{
+		"retCode": 0, "retMsg": "OK", "time": millis(b.now),
+		"result": object{"category": category, "list": list, "nextPageCursor": ""},
+	}
+}
+
+func okxResponse(data []object) object {
+	if data == nil {
+		data = []object{}
+	}
+	return object{"code": "0", "msg": "", "data": data}
+}
+
+// bitfinexCurrency is the Bitfinex code of an asset, such as UST for USDT.
+func bitfinexCurrency(asset string) string {
+	switch asset {
+	case "USDT":
+		return "UST"
+	case "USDC":
+		return "UDC"
+	}
+	return asset
+}
+
+// bitfinexPair is the Bitfinex pair of a market, BTCUST or SIM00001:UST.
+func bitfinexPair(m Market) string {
+	base, quote := bitfinexCurrency(m.Base), bitfinexCurrency(m.Quote)
+	if len(base) == 3 && len(quote) == 3 {
+		return base + quote
+	}
+	return base + ":" + quote
+}
+
+// okxSwap is the perpetual swap of a market, such as BTC-USDT-SWAP.
+func okxSwap(m Market) string {
+	return m.Base + "-" + m.Quote + "-SWAP"
+}
+
+// okxInverseSwap is the coin margined swap of a market, such as BTC-USD-SWAP.
+func okxInverseSwap(m Market) string {
+	return m.Base + "-USD-SWAP"
+}